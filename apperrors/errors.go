@@ -0,0 +1,134 @@
+// apperrors package contains the domain errors of the service. Every error belongs to one of the
+// kinds below, callers branch on the kind with errors.Is instead of comparing messages.
+package apperrors
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound means the thing looked up does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict means the request clashes with the current state, e.g. a duplicate or a stale version
+	ErrConflict = errors.New("conflict")
+	// ErrInvalidCredentials means a password, code or token presented to log in is wrong
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrValidation means the request itself is malformed, Error.Fields tells which fields
+	ErrValidation = errors.New("validation failed")
+	// ErrUnauthorized means the caller is not authenticated
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden means the caller may not do this
+	ErrForbidden = errors.New("forbidden")
+	// ErrPreconditionFailed means a conditional request header did not hold
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrTooManyRequests means the caller is throttled, Error.RetryAfter tells for how long
+	ErrTooManyRequests = errors.New("too many requests")
+	// ErrUnavailable means the service is overloaded, Error.RetryAfter tells when to come back
+	ErrUnavailable = errors.New("unavailable")
+	// ErrUnsupportedMediaType means the request body is of a content type that is not accepted
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+)
+
+// FieldError names a request field that failed validation and why
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// Error is a domain error of a kind. Message is safe to show to clients, Err is the cause and is not.
+type Error struct {
+	Kind       error
+	Message    string
+	Fields     []FieldError
+	RetryAfter time.Duration
+	Err        error
+}
+
+// Error ...
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Is reports whether target is the kind of e
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap ...
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New returns an error of kind with message
+func New(kind error, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+// Wrap returns an error of kind with message caused by err
+func Wrap(kind error, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+// NotFound ...
+func NotFound(message string) *Error {
+	return New(ErrNotFound, message)
+}
+
+// Conflict ...
+func Conflict(message string) *Error {
+	return New(ErrConflict, message)
+}
+
+// InvalidCredentials ...
+func InvalidCredentials(message string) *Error {
+	return New(ErrInvalidCredentials, message)
+}
+
+// Validation returns an ErrValidation error, fields name the invalid fields when known
+func Validation(message string, fields ...FieldError) *Error {
+	return &Error{Kind: ErrValidation, Message: message, Fields: fields}
+}
+
+// Unauthorized ...
+func Unauthorized(message string) *Error {
+	return New(ErrUnauthorized, message)
+}
+
+// Forbidden ...
+func Forbidden(message string) *Error {
+	return New(ErrForbidden, message)
+}
+
+// PreconditionFailed ...
+func PreconditionFailed(message string) *Error {
+	return New(ErrPreconditionFailed, message)
+}
+
+// TooManyRequests returns an ErrTooManyRequests error that clears after retryAfter
+func TooManyRequests(message string, retryAfter time.Duration) *Error {
+	return &Error{Kind: ErrTooManyRequests, Message: message, RetryAfter: retryAfter}
+}
+
+// Unavailable returns an ErrUnavailable error, retryAfter may be 0 when unknown
+func Unavailable(message string, retryAfter time.Duration) *Error {
+	return &Error{Kind: ErrUnavailable, Message: message, RetryAfter: retryAfter}
+}
+
+// UnsupportedMediaType ...
+func UnsupportedMediaType(message string) *Error {
+	return New(ErrUnsupportedMediaType, message)
+}
+
+// MessageOf returns the client-safe message of err: the Message of the Error it wraps, or
+// else the text of err itself, which for a bare kind is the kind name.
+func MessageOf(err error) string {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Message
+	}
+	return err.Error()
+}
@@ -0,0 +1,48 @@
+package apperrors_test
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	t.Run("Matches Its Kind Only", func(t *testing.T) {
+		err := apperrors.NotFound("user not found")
+		assert.True(t, errors.Is(err, apperrors.ErrNotFound))
+		assert.False(t, errors.Is(err, apperrors.ErrConflict))
+		assert.Equal(t, "user not found", err.Error())
+	})
+
+	t.Run("Matches Through Wrapping", func(t *testing.T) {
+		err := fmt.Errorf("loading user: %w", apperrors.Conflict("email already exists"))
+		assert.True(t, errors.Is(err, apperrors.ErrConflict))
+		assert.Equal(t, "email already exists", apperrors.MessageOf(err))
+	})
+
+	t.Run("Keeps The Cause", func(t *testing.T) {
+		err := apperrors.Wrap(apperrors.ErrNotFound, "no data found", sql.ErrNoRows)
+		assert.True(t, errors.Is(err, apperrors.ErrNotFound))
+		assert.True(t, errors.Is(err, sql.ErrNoRows))
+		assert.Equal(t, "no data found: "+sql.ErrNoRows.Error(), err.Error())
+		assert.Equal(t, "no data found", apperrors.MessageOf(err))
+	})
+
+	t.Run("Carries Details", func(t *testing.T) {
+		err := apperrors.Validation("invalid data", apperrors.FieldError{Field: "code", Message: "is required"})
+		assert.Equal(t, []apperrors.FieldError{{Field: "code", Message: "is required"}}, err.Fields)
+
+		throttled := apperrors.TooManyRequests("slow down", 3*time.Second)
+		assert.True(t, errors.Is(throttled, apperrors.ErrTooManyRequests))
+		assert.Equal(t, 3*time.Second, throttled.RetryAfter)
+	})
+
+	t.Run("Bare Kind", func(t *testing.T) {
+		assert.Equal(t, "not found", apperrors.MessageOf(apperrors.ErrNotFound))
+	})
+}
@@ -1,60 +1,449 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"expvar"
+	"flag"
+	"fmt"
 	"github.com/SawitProRecruitment/UserService/commons"
 	"github.com/SawitProRecruitment/UserService/generated"
 	"github.com/SawitProRecruitment/UserService/handler"
+	"github.com/SawitProRecruitment/UserService/mailer"
 	"github.com/SawitProRecruitment/UserService/middleware"
+	"github.com/SawitProRecruitment/UserService/notifier"
+	"github.com/SawitProRecruitment/UserService/phone"
 	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/throttle"
 	"github.com/labstack/echo/v4"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		switch os.Args[1] {
+		case "migrate":
+			repo := repository.NewRepository(repository.NewRepositoryOptions{Dsn: getEnv("DATABASE_URL", "")})
+			if err := migrate(repo, os.Args[2:]); err != nil {
+				log.Fatalf("Failed to migrate: %v", err)
+			}
+			return
+		case "normalize-phones":
+			if err := normalizePhones(os.Args[2:]); err != nil {
+				log.Fatalf("Failed to normalize phone numbers: %v", err)
+			}
+			return
+		default:
+			log.Fatalf("Unknown command %q, the commands are migrate and normalize-phones", os.Args[1])
+		}
+	}
+
+	storage := flag.String("storage", getEnv("STORAGE", "database"), "where data is kept: database, the Postgres or SQLite database of DATABASE_URL, or memory for local development")
+	flag.Parse()
+
 	e := echo.New()
 
-	server, err := initializeServer()
+	server, err := initializeServer(*storage)
+	if err != nil {
+		log.Fatalf("Failed to initialize server: %v", err)
+	}
+
+	var trustedProxies []string
+	if proxies := getEnv("TRUSTED_PROXIES", ""); proxies != "" {
+		trustedProxies = strings.Split(proxies, ",")
+	}
+	// before any route, throttling and rate limits are keyed by the client IP
+	e.IPExtractor, err = middleware.NewIPExtractor(trustedProxies)
 	if err != nil {
 		log.Fatalf("Failed to initialize server: %v", err)
 	}
 
 	registerRoutes(e, server)
 
+	if metricsAddr := getEnv("METRICS_ADDR", "127.0.0.1:9090"); metricsAddr != "" {
+		go serveMetrics(metricsAddr)
+	}
+
 	log.Fatal(e.Start(":8080"))
 }
 
-func initializeServer() (*handler.Server, error) {
-	dbDsn := getEnv("DATABASE_URL", "")
+func initializeServer(storage string) (*handler.Server, error) {
+	repo, db, err := newRepository(storage)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := loadKeys(getEnv("JWT_KEYS_DIR", ""), getEnv("JWT_LEGACY_KEY_ID", "default"), "private_key.pem")
+	if err != nil {
+		return nil, err
+	}
+	go reloadKeysOnSignal(keys)
+
+	accessTokenTTL, err := getDurationEnv("ACCESS_TOKEN_TTL", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshTokenTTL, err := getDurationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	revocationCacheTTL, err := getDurationEnv("REVOCATION_CACHE_TTL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	jwtLeeway, err := getDurationEnv("JWT_LEEWAY", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	acceptLegacyTokens, err := getBoolEnv("JWT_ACCEPT_LEGACY_TOKENS", true)
+	if err != nil {
+		return nil, err
+	}
+
+	jwtMiddleware := &middleware.Jwt{
+		Keys:         keys,
+		Issuer:       getEnv("JWT_ISSUER", "user-service"),
+		Audience:     getEnv("JWT_AUDIENCE", "user-service"),
+		Leeway:       jwtLeeway,
+		AcceptLegacy: acceptLegacyTokens,
+	}
+	revocationStore := middleware.NewRevocationStore(repo, revocationCacheTTL)
+	go purgeRevokedTokens(revocationStore, time.Hour)
+
+	spec, err := generated.GetSwagger()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load api spec: %w", err)
+	}
+	middlewareInstance := middleware.NewMiddleware(jwtMiddleware, repo, revocationStore, spec)
+
+	passwordResetTTL, err := getDurationEnv("PASSWORD_RESET_TTL", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordResetMaxAttempts, err := getIntEnv("PASSWORD_RESET_MAX_ATTEMPTS", 5)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordHistorySize, err := getIntEnv("PASSWORD_HISTORY_SIZE", 5)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordManager, err := newPasswordManager()
+	if err != nil {
+		return nil, err
+	}
+
+	notifierInstance, err := newNotifier(getEnv("NOTIFIER", "console"), getEnv("NOTIFIER_FILE", "notifications.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+
+	phoneThrottle, ipThrottle, err := newLoginThrottles(getEnv("LOGIN_THROTTLE_STORE", "memory"), db)
+	if err != nil {
+		return nil, err
+	}
 
-	//dbDsn := "host=localhost port=5432 user=yogidekanata dbname=users password=newpassword sslmode=disable"
+	phones, err := newPhoneNormalizer()
+	if err != nil {
+		return nil, err
+	}
 
-	repo := repository.NewRepository(repository.NewRepositoryOptions{Dsn: dbDsn})
+	mailerInstance, err := newMailer(getEnv("MAILER", "file"))
+	if err != nil {
+		return nil, err
+	}
+	emailTokens, err := newEmailTokenSigner()
+	if err != nil {
+		return nil, err
+	}
+	emailVerificationTTL, err := getDurationEnv("EMAIL_VERIFICATION_TTL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
 
-	privateKey, publicKey, err := readKeys("private_key.pem", "public_key.pem")
+	rateLimiter, err := newRateLimiter(getEnv("RATE_LIMIT_STORE", "memory"), getEnv("RATE_LIMIT_RULES_FILE", "rate_limits.json"), db)
+	if err != nil {
+		return nil, err
+	}
+	// one phone number or email typed two ways shares its buckets
+	rateLimiter.FieldNormalizers = map[string]func(string) string{
+		"phoneNumber": func(value string) string {
+			if normalized, err := phones.Normalize(value); err == nil {
+				return normalized
+			}
+			return value
+		},
+		"email": func(value string) string {
+			return strings.ToLower(strings.TrimSpace(value))
+		},
+	}
+
+	hashWorkers, err := getIntEnv("HASH_WORKERS", runtime.NumCPU())
+	if err != nil {
+		return nil, err
+	}
+	hashQueueDepth, err := getIntEnv("HASH_QUEUE_DEPTH", commons.DefaultHashQueueDepth)
+	if err != nil {
+		return nil, err
+	}
+	hashPool := commons.NewHashPool(commons.HashPoolOptions{Workers: hashWorkers, QueueDepth: hashQueueDepth})
+	expvar.Publish("password_hashing", expvar.Func(func() interface{} { return hashPool.Stats() }))
+
+	secretBox, err := newSecretBox()
+	if err != nil {
+		return nil, err
+	}
+	mfaChallengeTTL, err := getDurationEnv("MFA_CHALLENGE_TTL", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	mfaMaxAttempts, err := getIntEnv("MFA_MAX_ATTEMPTS", 5)
+	if err != nil {
+		return nil, err
+	}
+	mfaRecoveryCodes, err := getIntEnv("MFA_RECOVERY_CODES", 10)
 	if err != nil {
 		return nil, err
 	}
 
-	jwtMiddleware := &middleware.Jwt{PrivateKey: privateKey, PublicKey: publicKey}
-	middlewareInstance := middleware.NewMiddleware(jwtMiddleware, repo)
+	otpTTL, err := getDurationEnv("OTP_TTL", 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	otpMaxAttempts, err := getIntEnv("OTP_MAX_ATTEMPTS", 5)
+	if err != nil {
+		return nil, err
+	}
+	otpResendCooldown, err := getDurationEnv("OTP_RESEND_COOLDOWN", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	otpLoginEnabled, err := getBoolEnv("OTP_LOGIN_ENABLED", false)
+	if err != nil {
+		return nil, err
+	}
 
 	return handler.NewServer(handler.NewServerOptions{
-		Middleware: middlewareInstance,
-		Repository: repo,
-		Pwd:        &commons.PasswordManager{},
-		Jwt:        jwtMiddleware,
+		Middleware:               middlewareInstance,
+		Repository:               repo,
+		Pwd:                      passwordManager,
+		Jwt:                      jwtMiddleware,
+		Revocation:               revocationStore,
+		AccessTokenTTL:           accessTokenTTL,
+		RefreshTokenTTL:          refreshTokenTTL,
+		Notifier:                 notifierInstance,
+		PasswordResetTTL:         passwordResetTTL,
+		PasswordResetMaxAttempts: passwordResetMaxAttempts,
+		PasswordHistorySize:      passwordHistorySize,
+		PhoneThrottle:            phoneThrottle,
+		IPThrottle:               ipThrottle,
+		RateLimiter:              rateLimiter,
+		HashPool:                 hashPool,
+		Secrets:                  secretBox,
+		MfaIssuer:                getEnv("MFA_ISSUER", "UserService"),
+		MfaChallengeTTL:          mfaChallengeTTL,
+		MfaMaxAttempts:           mfaMaxAttempts,
+		MfaRecoveryCodes:         mfaRecoveryCodes,
+		OtpTTL:                   otpTTL,
+		OtpMaxAttempts:           otpMaxAttempts,
+		OtpResendCooldown:        otpResendCooldown,
+		OtpLoginEnabled:          otpLoginEnabled,
+		Phones:                   phones,
+		Mailer:                   mailerInstance,
+		EmailTokens:              emailTokens,
+		EmailVerificationTTL:     emailVerificationTTL,
+		EmailVerificationURL:     getEnv("EMAIL_VERIFICATION_URL", "http://localhost:8080/email/verify"),
 	}), nil
 }
 
+// newRepository opens the storage of the server. The database is migrated first unless MIGRATE_ON_START
+// is off, memory keeps everything in process and loses it on exit. The database returned is the one
+// the Postgres stores of throttling and rate limiting share, it is nil unless the storage is Postgres.
+func newRepository(storage string) (repository.RepositoryInterface, *sql.DB, error) {
+	switch storage {
+	// postgres is the name of database from before SQLite
+	case "database", "postgres":
+		//dbDsn := "host=localhost port=5432 user=yogidekanata dbname=users password=newpassword sslmode=disable"
+		repo := repository.NewRepository(repository.NewRepositoryOptions{Dsn: getEnv("DATABASE_URL", "")})
+
+		migrateOnStartEnabled, err := getBoolEnv("MIGRATE_ON_START", true)
+		if err != nil {
+			return nil, nil, err
+		}
+		if migrateOnStartEnabled {
+			if err := migrateOnStart(repo); err != nil {
+				return nil, nil, fmt.Errorf("failed to migrate: %w", err)
+			}
+		}
+		if repo.Dialect != repository.DialectPostgres {
+			return repo, nil, nil
+		}
+		return repo, repo.Db, nil
+	case "memory":
+		log.Printf("Using in-memory storage, data is lost when the server stops")
+		return repository.NewMemoryRepository(), nil, nil
+	default:
+		return nil, nil, fmt.Errorf("invalid storage: %q", storage)
+	}
+}
+
 func registerRoutes(e *echo.Echo, server *handler.Server) {
-	e.GET("/health", server.GetHealth) // Assume HealthCheckHandler is the method you use to handle health checks
-	authGroup := e.Group("/auth")
-	authGroup.Use(server.Middleware.Auth)
+	// Auth reads the security requirements of api.yml, only public operations skip it
+	e.Use(server.Middleware.Auth)
+	// after Auth so that rules keyed by user know the caller
+	e.Use(server.RateLimiter.Limit)
 	generated.RegisterHandlers(e, server)
 
 }
 
+// serveMetrics publishes expvar metrics at /debug/vars on a listener of its own, keep it off the public network.
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("Metrics server stopped: %v", err)
+	}
+}
+
+func purgeRevokedTokens(store *middleware.RevocationStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		store.Purge(context.Background())
+	}
+}
+
+// newLoginThrottles builds the failed login throttles per phone number and per client IP. Both share the
+// store and the backoff, a client IP gets its own lockout threshold since many users can sit behind one NAT.
+func newLoginThrottles(kind string, db *sql.DB) (*throttle.Throttler, *throttle.Throttler, error) {
+	var store throttle.AttemptStoreInterface
+	switch kind {
+	case "memory":
+		store = throttle.NewMemoryAttemptStore()
+	case "postgres":
+		if db == nil {
+			return nil, nil, fmt.Errorf("LOGIN_THROTTLE_STORE=postgres needs a Postgres DATABASE_URL")
+		}
+		store = throttle.NewPostgresAttemptStore(db)
+	default:
+		return nil, nil, fmt.Errorf("invalid LOGIN_THROTTLE_STORE: %q", kind)
+	}
+
+	freeAttempts, err := getIntEnv("LOGIN_FREE_ATTEMPTS", 3)
+	if err != nil {
+		return nil, nil, err
+	}
+	baseDelay, err := getDurationEnv("LOGIN_BASE_DELAY", time.Second)
+	if err != nil {
+		return nil, nil, err
+	}
+	maxDelay, err := getDurationEnv("LOGIN_MAX_DELAY", 5*time.Minute)
+	if err != nil {
+		return nil, nil, err
+	}
+	lockoutThreshold, err := getIntEnv("LOGIN_LOCKOUT_THRESHOLD", 10)
+	if err != nil {
+		return nil, nil, err
+	}
+	ipLockoutThreshold, err := getIntEnv("LOGIN_IP_LOCKOUT_THRESHOLD", 100)
+	if err != nil {
+		return nil, nil, err
+	}
+	lockoutDuration, err := getDurationEnv("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+	if err != nil {
+		return nil, nil, err
+	}
+	window, err := getDurationEnv("LOGIN_ATTEMPT_WINDOW", 24*time.Hour)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	phonePolicy := throttle.Policy{
+		FreeAttempts:     freeAttempts,
+		BaseDelay:        baseDelay,
+		MaxDelay:         maxDelay,
+		LockoutThreshold: lockoutThreshold,
+		LockoutDuration:  lockoutDuration,
+		Window:           window,
+	}
+	ipPolicy := phonePolicy
+	ipPolicy.LockoutThreshold = ipLockoutThreshold
+
+	go purgeLoginAttempts(store, window)
+
+	return throttle.NewThrottler(store, phonePolicy), throttle.NewThrottler(store, ipPolicy), nil
+}
+
+// newRateLimiter loads the per route rate limits, buckets are kept in process or shared through Postgres.
+func newRateLimiter(kind, rulesPath string, db *sql.DB) (*middleware.RateLimiter, error) {
+	var store middleware.RateLimitStoreInterface
+	switch kind {
+	case "memory":
+		store = middleware.NewMemoryRateLimitStore()
+	case "postgres":
+		if db == nil {
+			return nil, fmt.Errorf("RATE_LIMIT_STORE=postgres needs a Postgres DATABASE_URL")
+		}
+		store = middleware.NewPostgresRateLimitStore(db)
+	default:
+		return nil, fmt.Errorf("invalid RATE_LIMIT_STORE: %q", kind)
+	}
+
+	var rules []middleware.RateLimitRule
+	if rulesPath != "" {
+		raw, err := os.ReadFile(rulesPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rate limit rules: %w", err)
+		}
+		if rules, err = middleware.ParseRateLimitRules(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	limiter, err := middleware.NewRateLimiter(store, rules)
+	if err != nil {
+		return nil, err
+	}
+	go purgeRateLimitBuckets(store, limiter.LongestRefill())
+	return limiter, nil
+}
+
+// purgeRateLimitBuckets drops buckets that have filled up again, they are the same as a new bucket
+func purgeRateLimitBuckets(store middleware.RateLimitStoreInterface, refill time.Duration) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := store.Purge(context.Background(), time.Now().Add(-refill)); err != nil {
+			log.Printf("Failed to purge rate limit buckets: %v", err)
+		}
+	}
+}
+
+func purgeLoginAttempts(store throttle.AttemptStoreInterface, window time.Duration) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := store.Purge(context.Background(), time.Now().Add(-window)); err != nil {
+			log.Printf("Failed to purge login attempts: %v", err)
+		}
+	}
+}
+
 func getEnv(key, fallback string) string {
 	value, exists := os.LookupEnv(key)
 	if !exists {
@@ -63,16 +452,201 @@ func getEnv(key, fallback string) string {
 	return value
 }
 
-func readKeys(privateKeyPath, publicKeyPath string) ([]byte, []byte, error) {
-	privateKey, err := os.ReadFile(privateKeyPath)
+func getDurationEnv(key string, fallback time.Duration) (time.Duration, error) {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback, nil
+	}
+	duration, err := time.ParseDuration(value)
 	if err != nil {
-		return nil, nil, err
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
 	}
+	return duration, nil
+}
 
-	publicKey, err := os.ReadFile(publicKeyPath)
+func getBoolEnv(key string, fallback bool) (bool, error) {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseBool(value)
 	if err != nil {
-		return nil, nil, err
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return parsed, nil
+}
+
+func getIntEnv(key string, fallback int) (int, error) {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return parsed, nil
+}
+
+// newPasswordManager configures how new password hashes are made, existing hashes are
+// upgraded to these settings when their user logs in.
+func newPasswordManager() (*commons.PasswordManager, error) {
+	memory, err := getIntEnv("ARGON2_MEMORY", commons.DefaultArgon2Memory)
+	if err != nil {
+		return nil, err
+	}
+	iterations, err := getIntEnv("ARGON2_ITERATIONS", commons.DefaultArgon2Iterations)
+	if err != nil {
+		return nil, err
+	}
+	parallelism, err := getIntEnv("ARGON2_PARALLELISM", commons.DefaultArgon2Parallelism)
+	if err != nil {
+		return nil, err
+	}
+	bcryptCost, err := getIntEnv("BCRYPT_COST", commons.DefaultBcryptCost)
+	if err != nil {
+		return nil, err
+	}
+	peppers, err := loadVersionedSecrets("pepper", "PASSWORD_PEPPERS_FILE", "PASSWORD_PEPPERS")
+	if err != nil {
+		return nil, err
+	}
+	pepperVersion, err := getIntEnv("PASSWORD_PEPPER_VERSION", commons.LatestPepperVersion(peppers))
+	if err != nil {
+		return nil, err
+	}
+	if memory <= 0 || iterations <= 0 || parallelism <= 0 || parallelism > 255 {
+		return nil, fmt.Errorf("invalid argon2 parameters m=%d t=%d p=%d", memory, iterations, parallelism)
+	}
+
+	return commons.NewPasswordManager(commons.PasswordManagerOptions{
+		Algorithm: getEnv("PASSWORD_HASH_ALGORITHM", commons.AlgorithmArgon2id),
+		Argon2: commons.Argon2Params{
+			Memory:      uint32(memory),
+			Iterations:  uint32(iterations),
+			Parallelism: uint8(parallelism),
+		},
+		BcryptCost:    bcryptCost,
+		Peppers:       peppers,
+		PepperVersion: pepperVersion,
+	})
+}
+
+// loadVersionedSecrets reads "<version>=<secret>" lines from the secret file named by fileEnv, or
+// from valueEnv when no file is given.
+func loadVersionedSecrets(kind, fileEnv, valueEnv string) (map[int]string, error) {
+	content := getEnv(valueEnv, "")
+	if path := getEnv(fileEnv, ""); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s secrets: %w", kind, err)
+		}
+		content = string(raw)
+	}
+
+	secrets, err := commons.ParseVersionedSecrets(content, kind)
+	if err != nil {
+		return nil, err
+	}
+	if len(secrets) == 0 {
+		return nil, fmt.Errorf("no %s configured, set %s or %s", kind, fileEnv, valueEnv)
+	}
+	return secrets, nil
+}
+
+// newSecretBox loads the keys TOTP secrets are encrypted with.
+func newSecretBox() (*commons.SecretBox, error) {
+	keys, err := loadVersionedSecrets("mfa encryption key", "MFA_ENCRYPTION_KEYS_FILE", "MFA_ENCRYPTION_KEYS")
+	if err != nil {
+		return nil, err
 	}
+	version, err := getIntEnv("MFA_ENCRYPTION_KEY_VERSION", commons.LatestPepperVersion(keys))
+	if err != nil {
+		return nil, err
+	}
+	return commons.NewSecretBox(keys, version)
+}
+
+// newPhoneNormalizer reads the countries whose phone numbers are accepted, numbers typed without a
+// calling code belong to the default country
+func newPhoneNormalizer() (*phone.Normalizer, error) {
+	var allowedRegions []string
+	for _, region := range strings.Split(getEnv("PHONE_ALLOWED_REGIONS", "ID"), ",") {
+		if region = strings.TrimSpace(region); region != "" {
+			allowedRegions = append(allowedRegions, region)
+		}
+	}
+	return phone.NewNormalizer(phone.NormalizerOptions{
+		AllowedRegions: allowedRegions,
+		DefaultRegion:  getEnv("PHONE_DEFAULT_REGION", "ID"),
+	})
+}
+
+// newEmailTokenSigner signs email verification links, keys rotate like MFA_ENCRYPTION_KEYS
+func newEmailTokenSigner() (*commons.Signer, error) {
+	keys, err := loadVersionedSecrets("email token key", "EMAIL_TOKEN_KEYS_FILE", "EMAIL_TOKEN_KEYS")
+	if err != nil {
+		return nil, err
+	}
+	version, err := getIntEnv("EMAIL_TOKEN_KEY_VERSION", commons.LatestPepperVersion(keys))
+	if err != nil {
+		return nil, err
+	}
+	return commons.NewSigner(keys, version)
+}
 
-	return privateKey, publicKey, nil
+func newMailer(kind string) (mailer.MailerInterface, error) {
+	from := getEnv("MAIL_FROM", "no-reply@localhost")
+	switch kind {
+	case "file":
+		return mailer.NewFileMailer(getEnv("MAILER_DIR", "mail"), from), nil
+	case "smtp":
+		addr := getEnv("SMTP_ADDR", "")
+		if addr == "" {
+			return nil, fmt.Errorf("SMTP_ADDR is required with MAILER=smtp")
+		}
+		return mailer.NewSMTPMailer(addr, from, getEnv("SMTP_USERNAME", ""), getEnv("SMTP_PASSWORD", "")), nil
+	default:
+		return nil, fmt.Errorf("invalid MAILER: %q", kind)
+	}
+}
+
+// newNotifier picks how codes are delivered to users.
+func newNotifier(kind, filePath string) (notifier.NotifierInterface, error) {
+	switch kind {
+	case "console":
+		return notifier.NewConsoleNotifier(), nil
+	case "file":
+		return notifier.NewFileNotifier(filePath), nil
+	default:
+		return nil, fmt.Errorf("invalid NOTIFIER: %q", kind)
+	}
+}
+
+// loadKeys reads every signing key from keysDir, or the single legacy key pair when no directory is configured.
+// legacyKid names the key in keysDir that signed the tokens issued without a kid.
+func loadKeys(keysDir, legacyKid, privateKeyPath string) (*middleware.KeyManager, error) {
+	if keysDir != "" {
+		return middleware.NewKeyManager(keysDir, legacyKid)
+	}
+
+	privateKey, err := os.ReadFile(privateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return middleware.NewStaticKeyManager("default", privateKey)
+}
+
+// reloadKeysOnSignal lets operators rotate signing keys with `kill -HUP`.
+func reloadKeysOnSignal(keys *middleware.KeyManager) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		if err := keys.Reload(); err != nil {
+			log.Printf("Failed to reload signing keys: %v", err)
+			continue
+		}
+		log.Printf("Signing keys reloaded, active key: %s", keys.ActiveKey().ID)
+	}
 }
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/SawitProRecruitment/UserService/migrations"
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+// migrate runs `migrate up|down|status|baseline`, up being the default
+func migrate(repo *repository.Repository, args []string) error {
+	action := "up"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+
+	flags := flag.NewFlagSet("migrate "+action, flag.ContinueOnError)
+	steps := flags.Int("steps", 1, "migrations to revert with down")
+	version := flags.Int("version", 0, "latest migration the schema already has, for baseline")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	migrator, err := migrations.NewMigrator(migrations.MigratorOptions{Db: repo.Db, Dialect: repo.Dialect})
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch action {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("applied %s\n", migration)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		return err
+	case "down":
+		if *steps <= 0 {
+			return fmt.Errorf("-steps has to be positive")
+		}
+		reverted, err := migrator.Down(ctx, *steps)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %s\n", migration)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		for _, status := range statuses {
+			if status.AppliedAt.IsZero() {
+				fmt.Printf("pending %s\n", status.Migration)
+			} else {
+				fmt.Printf("applied %s at %s\n", status.Migration, status.AppliedAt.Format("2006-01-02 15:04:05 MST"))
+			}
+		}
+		return err
+	case "baseline":
+		recorded, err := migrator.Baseline(ctx, *version)
+		for _, migration := range recorded {
+			fmt.Printf("recorded %s\n", migration)
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown migrate action %q, expected up, down, status or baseline", action)
+	}
+}
+
+// migrateOnStart applies pending migrations before the server starts, instances started together
+// wait for the first one to finish
+func migrateOnStart(repo *repository.Repository) error {
+	migrator, err := migrations.NewMigrator(migrations.MigratorOptions{Db: repo.Db, Dialect: repo.Dialect})
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up(context.Background())
+	for _, migration := range applied {
+		log.Printf("Applied migration %s", migration)
+	}
+	return err
+}
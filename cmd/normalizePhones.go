@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/SawitProRecruitment/UserService/phone"
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+// normalizePhones rewrites the phone numbers stored before normalization into E.164. Numbers that do not
+// normalize, and numbers that would end up shared by several users, are reported and left for an operator.
+func normalizePhones(args []string) error {
+	flags := flag.NewFlagSet("normalize-phones", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would change without writing")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	phones, err := newPhoneNormalizer()
+	if err != nil {
+		return err
+	}
+	repo := repository.NewRepository(repository.NewRepositoryOptions{Dsn: getEnv("DATABASE_URL", "")})
+
+	ctx := context.Background()
+	stored, err := repo.ListPhoneNumbers(ctx)
+	if err != nil {
+		return err
+	}
+	plan := phone.PlanNormalization(phones, stored)
+
+	for _, change := range plan.Changes {
+		fmt.Printf("change userId:%d %q -> %q\n", change.UserID, change.From, change.To)
+	}
+	for _, collision := range plan.Collisions {
+		fmt.Printf("collision %s userIds:%v stored:%q\n", collision.PhoneNumber, collision.UserIDs, collision.Stored)
+	}
+	for _, invalid := range plan.Invalid {
+		fmt.Printf("invalid userId:%d %q: %s\n", invalid.UserID, invalid.PhoneNumber, invalid.Reason)
+	}
+	fmt.Printf("%d to change, %d unchanged, %d collisions, %d invalid\n",
+		len(plan.Changes), plan.Unchanged, len(plan.Collisions), len(plan.Invalid))
+
+	if *dryRun {
+		return nil
+	}
+	for _, change := range plan.Changes {
+		if err := repo.UpdatePhoneNumber(ctx, change.UserID, change.To); err != nil {
+			return fmt.Errorf("userId %d: %w", change.UserID, err)
+		}
+	}
+	fmt.Printf("%d phone numbers changed\n", len(plan.Changes))
+
+	if len(plan.Collisions) > 0 || len(plan.Invalid) > 0 {
+		fmt.Fprintln(os.Stderr, "collisions and invalid phone numbers were left as stored")
+	}
+	return nil
+}
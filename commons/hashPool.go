@@ -0,0 +1,169 @@
+package commons
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+)
+
+// DefaultHashQueueDepth ...
+const DefaultHashQueueDepth = 64
+
+const (
+	hashJobPending int32 = iota
+	hashJobRunning
+	hashJobCanceled
+)
+
+// HashPoolOptions ...
+type HashPoolOptions struct {
+	// Workers is how many hashes run at once, defaults to the number of CPUs
+	Workers int
+	// QueueDepth is how many hashes may wait for a worker before new ones are refused
+	QueueDepth int
+}
+
+// HashPoolStats is published as metrics
+type HashPoolStats struct {
+	Workers    int   `json:"workers"`
+	QueueDepth int   `json:"queue_depth"`
+	Queued     int   `json:"queued"`
+	Running    int64 `json:"running"`
+	Completed  int64 `json:"completed"`
+	Rejected   int64 `json:"rejected"`
+	Canceled   int64 `json:"canceled"`
+	// WaitSecondsTotal is the time completed hashes spent queued, divide by Completed for the average
+	WaitSecondsTotal float64 `json:"wait_seconds_total"`
+	// WaitSecondsMax is the longest time a hash spent queued
+	WaitSecondsMax float64 `json:"wait_seconds_max"`
+}
+
+type hashJob struct {
+	fn       func()
+	queuedAt time.Time
+	state    int32
+	done     chan struct{}
+}
+
+// HashPool runs password hashing on a fixed number of workers so that a burst of logins
+// cannot take every CPU from the rest of the service
+type HashPool struct {
+	workers int
+	// slots admits up to workers + queue depth jobs, jobs never blocks a sender
+	slots chan struct{}
+	jobs  chan *hashJob
+	stop  sync.Once
+
+	running   int64
+	completed int64
+	rejected  int64
+	canceled  int64
+	waitNanos int64
+	maxWait   int64
+}
+
+// NewHashPool starts the workers
+func NewHashPool(opts HashPoolOptions) *HashPool {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+	if opts.QueueDepth < 0 {
+		opts.QueueDepth = 0
+	}
+
+	p := &HashPool{
+		workers: opts.Workers,
+		slots:   make(chan struct{}, opts.Workers+opts.QueueDepth),
+		jobs:    make(chan *hashJob, opts.Workers+opts.QueueDepth),
+	}
+	for i := 0; i < opts.Workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Do runs fn on a worker and waits for it. It returns an apperrors.ErrUnavailable right away when the
+// queue is full, and ctx.Err() when ctx is done before a worker picked fn up; once fn has
+// started it is waited for since hashing cannot be interrupted.
+func (p *HashPool) Do(ctx context.Context, fn func()) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		atomic.AddInt64(&p.rejected, 1)
+		return apperrors.Unavailable(ErrorServerBusy, 0)
+	}
+	job := &hashJob{fn: fn, queuedAt: time.Now(), done: make(chan struct{})}
+	p.jobs <- job
+
+	select {
+	case <-job.done:
+		return nil
+	case <-ctx.Done():
+		if atomic.CompareAndSwapInt32(&job.state, hashJobPending, hashJobCanceled) {
+			atomic.AddInt64(&p.canceled, 1)
+			return ctx.Err()
+		}
+		<-job.done
+		return nil
+	}
+}
+
+// Close stops the workers once the queue is drained, Do must not be called afterwards
+func (p *HashPool) Close() {
+	p.stop.Do(func() { close(p.jobs) })
+}
+
+// Stats ...
+func (p *HashPool) Stats() HashPoolStats {
+	return HashPoolStats{
+		Workers:          p.workers,
+		QueueDepth:       cap(p.slots) - p.workers,
+		Queued:           len(p.jobs),
+		Running:          atomic.LoadInt64(&p.running),
+		Completed:        atomic.LoadInt64(&p.completed),
+		Rejected:         atomic.LoadInt64(&p.rejected),
+		Canceled:         atomic.LoadInt64(&p.canceled),
+		WaitSecondsTotal: time.Duration(atomic.LoadInt64(&p.waitNanos)).Seconds(),
+		WaitSecondsMax:   time.Duration(atomic.LoadInt64(&p.maxWait)).Seconds(),
+	}
+}
+
+func (p *HashPool) work() {
+	for job := range p.jobs {
+		// the caller gave up while the job was queued
+		if !atomic.CompareAndSwapInt32(&job.state, hashJobPending, hashJobRunning) {
+			<-p.slots
+			continue
+		}
+
+		wait := int64(time.Since(job.queuedAt))
+		atomic.AddInt64(&p.waitNanos, wait)
+		for {
+			current := atomic.LoadInt64(&p.maxWait)
+			if wait <= current || atomic.CompareAndSwapInt64(&p.maxWait, current, wait) {
+				break
+			}
+		}
+
+		p.run(job)
+	}
+}
+
+func (p *HashPool) run(job *hashJob) {
+	atomic.AddInt64(&p.running, 1)
+	defer func() {
+		atomic.AddInt64(&p.running, -1)
+		atomic.AddInt64(&p.completed, 1)
+		<-p.slots
+		close(job.done)
+	}()
+	job.fn()
+}
@@ -0,0 +1,108 @@
+package commons_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// occupy blocks every worker of the pool until the returned func is called
+func occupy(t *testing.T, pool *commons.HashPool, workers int) func() {
+	release := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		go pool.Do(context.Background(), func() { <-release })
+	}
+	require.Eventually(t, func() bool { return pool.Stats().Running == int64(workers) }, time.Second, time.Millisecond)
+	return func() { close(release) }
+}
+
+func TestHashPool(t *testing.T) {
+	t.Run("Concurrency Is Bounded", func(t *testing.T) {
+		pool := commons.NewHashPool(commons.HashPoolOptions{Workers: 2, QueueDepth: 10})
+		defer pool.Close()
+
+		var running, peak int64
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := pool.Do(context.Background(), func() {
+					current := atomic.AddInt64(&running, 1)
+					for {
+						seen := atomic.LoadInt64(&peak)
+						if current <= seen || atomic.CompareAndSwapInt64(&peak, seen, current) {
+							break
+						}
+					}
+					time.Sleep(5 * time.Millisecond)
+					atomic.AddInt64(&running, -1)
+				})
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		assert.LessOrEqual(t, peak, int64(2))
+		assert.Equal(t, int64(10), pool.Stats().Completed)
+	})
+
+	t.Run("Full Queue Is Refused", func(t *testing.T) {
+		pool := commons.NewHashPool(commons.HashPoolOptions{Workers: 1, QueueDepth: 1})
+		defer pool.Close()
+		release := occupy(t, pool, 1)
+
+		queued := make(chan error)
+		go func() { queued <- pool.Do(context.Background(), func() {}) }()
+		require.Eventually(t, func() bool { return pool.Stats().Queued == 1 }, time.Second, time.Millisecond)
+
+		err := pool.Do(context.Background(), func() { t.Error("refused work must not run") })
+		if assert.ErrorIs(t, err, apperrors.ErrUnavailable) {
+			assert.Equal(t, commons.ErrorServerBusy, err.Error())
+		}
+
+		release()
+		assert.NoError(t, <-queued)
+		stats := pool.Stats()
+		assert.Equal(t, int64(1), stats.Rejected)
+		assert.Greater(t, stats.WaitSecondsTotal, 0.0)
+		assert.GreaterOrEqual(t, stats.WaitSecondsMax, stats.WaitSecondsTotal/float64(stats.Completed))
+	})
+
+	t.Run("Canceled While Queued Is Skipped", func(t *testing.T) {
+		pool := commons.NewHashPool(commons.HashPoolOptions{Workers: 1, QueueDepth: 1})
+		defer pool.Close()
+		release := occupy(t, pool, 1)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var ran int32
+		done := make(chan error)
+		go func() { done <- pool.Do(ctx, func() { atomic.StoreInt32(&ran, 1) }) }()
+		require.Eventually(t, func() bool { return pool.Stats().Queued == 1 }, time.Second, time.Millisecond)
+
+		cancel()
+		assert.ErrorIs(t, <-done, context.Canceled)
+
+		release()
+		require.Eventually(t, func() bool { return pool.Stats().Queued == 0 && pool.Stats().Running == 0 }, time.Second, time.Millisecond)
+		assert.Equal(t, int32(0), atomic.LoadInt32(&ran))
+		assert.Equal(t, int64(1), pool.Stats().Canceled)
+	})
+
+	t.Run("Canceled Context Is Not Queued", func(t *testing.T) {
+		pool := commons.NewHashPool(commons.HashPoolOptions{Workers: 1})
+		defer pool.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		assert.ErrorIs(t, pool.Do(ctx, func() { t.Error("canceled work must not run") }), context.Canceled)
+	})
+}
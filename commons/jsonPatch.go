@@ -0,0 +1,282 @@
+package commons
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+)
+
+// PatchOperation is one operation of a JSON Patch document (RFC 6902)
+type PatchOperation struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	From string `json:"from,omitempty"`
+	// Value is kept raw so that a null value can be told apart from a missing one
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to a decoded JSON document: objects are
+// merged recursively, null removes a member and any other value replaces the target.
+func MergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	result := make(map[string]interface{}, len(targetObject))
+	for name, value := range targetObject {
+		result[name] = value
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(result, name)
+			continue
+		}
+		result[name] = MergePatch(result[name], value)
+	}
+	return result
+}
+
+// ApplyJSONPatch applies the operations in order to a decoded JSON document and returns the
+// result, the document itself is left untouched. A failed test operation returns an
+// apperrors.ErrConflict, every other failure an apperrors.ErrValidation describing the operation.
+func ApplyJSONPatch(doc interface{}, operations []PatchOperation) (interface{}, error) {
+	doc = copyJSON(doc)
+	for i, operation := range operations {
+		var err error
+		doc, err = applyPatchOperation(doc, operation)
+		if err != nil {
+			if errors.Is(err, apperrors.ErrConflict) {
+				return nil, err
+			}
+			return nil, apperrors.Validation(fmt.Sprintf("invalid patch: operation %d: %v", i, err))
+		}
+	}
+	return doc, nil
+}
+
+func applyPatchOperation(doc interface{}, operation PatchOperation) (interface{}, error) {
+	path, err := parseJSONPointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch operation.Op {
+	case "add", "replace", "test":
+		if len(operation.Value) == 0 {
+			return nil, fmt.Errorf("%s needs a value", operation.Op)
+		}
+		var value interface{}
+		if err := json.Unmarshal(operation.Value, &value); err != nil {
+			return nil, err
+		}
+		switch operation.Op {
+		case "add":
+			return addAtPointer(doc, path, value)
+		case "replace":
+			if doc, _, err = removeAtPointer(doc, path); err != nil {
+				return nil, err
+			}
+			return addAtPointer(doc, path, value)
+		default:
+			current, err := valueAtPointer(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !reflect.DeepEqual(current, value) {
+				return nil, apperrors.Conflict(ErrorPatchTestFailed)
+			}
+			return doc, nil
+		}
+	case "remove":
+		doc, _, err = removeAtPointer(doc, path)
+		return doc, err
+	case "move", "copy":
+		from, err := parseJSONPointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+		if operation.Op == "move" {
+			if isPointerPrefix(from, path) && len(from) < len(path) {
+				return nil, errors.New("cannot move a value into itself")
+			}
+			var value interface{}
+			if doc, value, err = removeAtPointer(doc, from); err != nil {
+				return nil, err
+			}
+			return addAtPointer(doc, path, value)
+		}
+		value, err := valueAtPointer(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return addAtPointer(doc, path, copyJSON(value))
+	}
+	return nil, fmt.Errorf("unknown op %q", operation.Op)
+}
+
+// parseJSONPointer splits a JSON Pointer (RFC 6901) into its unescaped reference tokens
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("path %q must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+func isPointerPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func valueAtPointer(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, found := node[token]
+			if !found {
+				return nil, fmt.Errorf("member %q does not exist", token)
+			}
+			doc = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[index]
+		default:
+			return nil, fmt.Errorf("cannot look up %q in a scalar", token)
+		}
+	}
+	return doc, nil
+}
+
+// addAtPointer sets a member of an object, or inserts into an array, and returns the document.
+// An empty path replaces the whole document.
+func addAtPointer(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := valueAtPointer(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last] = value
+		return doc, nil
+	case []interface{}:
+		index := len(node)
+		if last != "-" {
+			if index, err = arrayIndex(last, len(node)); err != nil {
+				return nil, err
+			}
+		}
+		node = append(node, nil)
+		copy(node[index+1:], node[index:])
+		node[index] = value
+		return setAtPointer(doc, path[:len(path)-1], node), nil
+	}
+	return nil, fmt.Errorf("cannot add %q to a scalar", last)
+}
+
+// removeAtPointer removes a member of an object, or an element of an array, and returns the
+// document and the removed value
+func removeAtPointer(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	parent, err := valueAtPointer(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		value, found := node[last]
+		if !found {
+			return nil, nil, fmt.Errorf("member %q does not exist", last)
+		}
+		delete(node, last)
+		return doc, value, nil
+	case []interface{}:
+		index, err := arrayIndex(last, len(node)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := node[index]
+		node = append(node[:index:index], node[index+1:]...)
+		return setAtPointer(doc, path[:len(path)-1], node), value, nil
+	}
+	return nil, nil, fmt.Errorf("cannot remove %q from a scalar", last)
+}
+
+// setAtPointer stores an array whose length changed back into its parent, the path is known to exist
+func setAtPointer(doc interface{}, path []string, value interface{}) interface{} {
+	if len(path) == 0 {
+		return value
+	}
+	parent, _ := valueAtPointer(doc, path[:len(path)-1])
+	last := path[len(path)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last] = value
+	case []interface{}:
+		index, _ := strconv.Atoi(last)
+		node[index] = value
+	}
+	return doc
+}
+
+// arrayIndex parses an array index token that must not exceed max
+func arrayIndex(token string, max int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > max || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("index %q is out of range", token)
+	}
+	return index, nil
+}
+
+// copyJSON deep copies a decoded JSON document so that patching it leaves the original alone
+func copyJSON(value interface{}) interface{} {
+	switch node := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(node))
+		for name, member := range node {
+			result[name] = copyJSON(member)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(node))
+		for i, element := range node {
+			result[i] = copyJSON(element)
+		}
+		return result
+	}
+	return value
+}
@@ -0,0 +1,82 @@
+package commons_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeJSON(t *testing.T, raw string) interface{} {
+	var value interface{}
+	require.NoError(t, json.Unmarshal([]byte(raw), &value))
+	return value
+}
+
+func TestMergePatch(t *testing.T) {
+	cases := []struct{ target, patch, result string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":{"b":"c","d":"e"}}`, `{"a":{"b":null,"f":"g"}}`, `{"a":{"d":"e","f":"g"}}`},
+		{`{"a":["b"]}`, `{"a":["c","d"]}`, `{"a":["c","d"]}`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+	}
+	for _, c := range cases {
+		target := decodeJSON(t, c.target)
+		assert.Equal(t, decodeJSON(t, c.result), commons.MergePatch(target, decodeJSON(t, c.patch)), c.patch)
+		assert.Equal(t, decodeJSON(t, c.target), target, "the target is left untouched")
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	cases := []struct{ doc, patch, result string }{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"foo":"bar","baz":"qux"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":"qux"}]`, `{"foo":["bar","qux"]}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"foo":"bar"}`, `[{"op":"replace","path":"/foo","value":null}]`, `{"foo":null}`},
+		{`{"foo":{"bar":"baz"},"qux":{}}`, `[{"op":"move","from":"/foo/bar","path":"/qux/thud"}]`, `{"foo":{},"qux":{"thud":"baz"}}`},
+		{`{"foo":"bar"}`, `[{"op":"copy","from":"/foo","path":"/baz"}]`, `{"foo":"bar","baz":"bar"}`},
+		{`{"a/b":1,"m~n":2}`, `[{"op":"remove","path":"/a~1b"},{"op":"test","path":"/m~0n","value":2}]`, `{"m~n":2}`},
+		{`{"foo":"bar"}`, `[{"op":"replace","path":"","value":["baz"]}]`, `["baz"]`},
+	}
+	for _, c := range cases {
+		var operations []commons.PatchOperation
+		require.NoError(t, json.Unmarshal([]byte(c.patch), &operations))
+		doc := decodeJSON(t, c.doc)
+
+		result, err := commons.ApplyJSONPatch(doc, operations)
+		if assert.NoError(t, err, c.patch) {
+			assert.Equal(t, decodeJSON(t, c.result), result, c.patch)
+		}
+		assert.Equal(t, decodeJSON(t, c.doc), doc, "the document is left untouched")
+	}
+
+	failing := []string{
+		`[{"op":"replace","path":"/missing","value":1}]`,
+		`[{"op":"remove","path":"/foo/0"}]`,
+		`[{"op":"add","path":"/foo"}]`,
+		`[{"op":"add","path":"foo","value":1}]`,
+		`[{"op":"add","path":"/a/b","value":1}]`,
+		`[{"op":"move","from":"/foo","path":"/foo/bar"}]`,
+		`[{"op":"merge","path":"/foo","value":1}]`,
+	}
+	for _, patch := range failing {
+		var operations []commons.PatchOperation
+		require.NoError(t, json.Unmarshal([]byte(patch), &operations))
+		_, err := commons.ApplyJSONPatch(decodeJSON(t, `{"foo":"bar"}`), operations)
+		assert.ErrorIs(t, err, apperrors.ErrValidation, patch)
+	}
+
+	var operations []commons.PatchOperation
+	require.NoError(t, json.Unmarshal([]byte(`[{"op":"add","path":"/baz","value":1},{"op":"test","path":"/foo","value":"qux"}]`), &operations))
+	_, err := commons.ApplyJSONPatch(decodeJSON(t, `{"foo":"bar"}`), operations)
+	if assert.ErrorIs(t, err, apperrors.ErrConflict) {
+		assert.Equal(t, commons.ErrorPatchTestFailed, err.Error())
+	}
+}
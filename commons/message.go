@@ -12,12 +12,88 @@ const (
 	ErrorInvalidPassword = "invalid password"
 	// ErrorNoRow ...
 	ErrorNoRow = "no rows in result set"
+	// ErrorUserNotFound ...
+	ErrorUserNotFound = "User not found"
 	// ErrSystemError ...
 	ErrSystemError = "system error"
 	// ErrUserExists ...
 	ErrUserExists = "phone number already exists"
-	// IDClaimKey ...
-	IDClaimKey = "id"
-	// ExpClaimKey ...
-	ExpClaimKey = "exp"
+	// ErrorInvalidRefreshToken ...
+	ErrorInvalidRefreshToken = "refresh token is invalid"
+	// ErrorRefreshTokenReused ...
+	ErrorRefreshTokenReused = "refresh token reuse detected"
+	// ErrorTokenRevoked ...
+	ErrorTokenRevoked = "token has been revoked"
+	// ErrorInvalidResetCode ...
+	ErrorInvalidResetCode = "reset code is invalid or expired"
+	// ErrorPasswordReused ...
+	ErrorPasswordReused = "password was used recently"
+	// MessagePasswordResetRequested ...
+	MessagePasswordResetRequested = "if the phone number or email is registered a reset code has been sent"
+	// ErrorInvalidCredentials is returned alike for an unknown phone number or email and a wrong password
+	ErrorInvalidCredentials = "invalid phone number, email or password"
+	// ErrorTooManyAttempts ...
+	ErrorTooManyAttempts = "too many failed login attempts, try again later"
+	// ErrorRateLimited ...
+	ErrorRateLimited = "too many requests, try again later"
+	// ErrorServerBusy is returned when password hashing is saturated
+	ErrorServerBusy = "server is busy, try again later"
+	// ErrorMfaAlreadyEnabled ...
+	ErrorMfaAlreadyEnabled = "mfa is already enabled"
+	// ErrorMfaNotEnrolled ...
+	ErrorMfaNotEnrolled = "no mfa enrollment was started"
+	// ErrorInvalidMfaCode ...
+	ErrorInvalidMfaCode = "invalid mfa code"
+	// ErrorMfaCodeChoice ...
+	ErrorMfaCodeChoice = "give either code or recoveryCode"
+	// ErrorInvalidMfaChallenge covers unknown, expired, used and exhausted mfa tokens
+	ErrorInvalidMfaChallenge = "invalid or expired mfa token"
+	// ErrorInvalidOtpCode covers unknown, expired, used and exhausted one-time codes
+	ErrorInvalidOtpCode = "code is invalid or expired"
+	// ErrorOtpCooldown ...
+	ErrorOtpCooldown = "a code was sent moments ago, try again later"
+	// ErrorPhoneAlreadyVerified ...
+	ErrorPhoneAlreadyVerified = "phone number is already verified"
+	// MessageLoginCodeRequested ...
+	MessageLoginCodeRequested = "if the phone number is registered and verified a login code has been sent"
+	// ErrorOtpLoginDisabled ...
+	ErrorOtpLoginDisabled = "passwordless login is disabled"
+	// ErrorInvalidPhoneNumber ...
+	ErrorInvalidPhoneNumber = "invalid phone number"
+	// ErrorPhoneRegionNotAllowed ...
+	ErrorPhoneRegionNotAllowed = "phone numbers of this country are not accepted"
+	// ErrEmailExists ...
+	ErrEmailExists = "email already exists"
+	// ErrorPhoneNumberOrEmail is returned when a request identifies the user by neither or both
+	ErrorPhoneNumberOrEmail = "give either phoneNumber or email"
+	// ErrorInvalidEmailToken covers tampered and expired links and links to an email the user no longer has
+	ErrorInvalidEmailToken = "verification link is invalid or expired"
+	// ErrorEmailAlreadyVerified ...
+	ErrorEmailAlreadyVerified = "email is already verified"
+	// ErrorNoEmail ...
+	ErrorNoEmail = "user has no email"
+	// MessageEmailVerificationSent ...
+	MessageEmailVerificationSent = "a verification link has been sent"
+	// MessageEmailVerified ...
+	MessageEmailVerified = "email verified"
+	// ErrorVersionConflict is returned when the user changed since it was read
+	ErrorVersionConflict = "user was changed by another request, fetch it and try again"
+	// ErrorPreconditionFailed is returned when If-Match does not name the current version
+	ErrorPreconditionFailed = "user was changed since the given ETag, fetch it and try again"
+	// ErrorPatchTestFailed is returned when a test operation of a JSON Patch does not match
+	ErrorPatchTestFailed = "patch test operation failed"
+	// ErrorInvalidFilter is returned for a filter, sort or cursor of a user query that is not allowed
+	ErrorInvalidFilter = "invalid filter"
+	// ErrorInvalidCursor is returned for a cursor that was tampered with or made for another order
+	ErrorInvalidCursor = "invalid cursor, pass the nextCursor of the previous page with the same sort and order"
+	// ErrorInvalidListLimit ...
+	ErrorInvalidListLimit = "limit has to be between 1 and %d"
+	// ErrorUnsupportedMediaType ...
+	ErrorUnsupportedMediaType = "unsupported content type"
+	// RoleUser ...
+	RoleUser = "user"
+	// RoleAdmin ...
+	RoleAdmin = "admin"
+	// KidHeaderKey ...
+	KidHeaderKey = "kid"
 )
@@ -24,12 +24,13 @@ func (_m *PasswordManagerInterface) CreateSalt() string {
 }
 
 // GenerateHash provides a mock function with given fields: password, salt
-func (_m *PasswordManagerInterface) GenerateHash(password string, salt string) (string, error) {
+func (_m *PasswordManagerInterface) GenerateHash(password string, salt string) (string, int, error) {
 	ret := _m.Called(password, salt)
 
 	var r0 string
-	var r1 error
-	if rf, ok := ret.Get(0).(func(string, string) (string, error)); ok {
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(string, string) (string, int, error)); ok {
 		return rf(password, salt)
 	}
 	if rf, ok := ret.Get(0).(func(string, string) string); ok {
@@ -38,22 +39,42 @@ func (_m *PasswordManagerInterface) GenerateHash(password string, salt string) (
 		r0 = ret.Get(0).(string)
 	}
 
-	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+	if rf, ok := ret.Get(1).(func(string, string) int); ok {
 		r1 = rf(password, salt)
 	} else {
-		r1 = ret.Error(1)
+		r1 = ret.Get(1).(int)
 	}
 
-	return r0, r1
+	if rf, ok := ret.Get(2).(func(string, string) error); ok {
+		r2 = rf(password, salt)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// NeedsRehash provides a mock function with given fields: hash, pepperVersion
+func (_m *PasswordManagerInterface) NeedsRehash(hash string, pepperVersion int) bool {
+	ret := _m.Called(hash, pepperVersion)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(string, int) bool); ok {
+		r0 = rf(hash, pepperVersion)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
 }
 
-// VerifyPassword provides a mock function with given fields: password, hash, salt
-func (_m *PasswordManagerInterface) VerifyPassword(password string, hash string, salt string) bool {
-	ret := _m.Called(password, hash, salt)
+// VerifyPassword provides a mock function with given fields: password, hash, salt, pepperVersion
+func (_m *PasswordManagerInterface) VerifyPassword(password string, hash string, salt string, pepperVersion int) bool {
+	ret := _m.Called(password, hash, salt, pepperVersion)
 
 	var r0 bool
-	if rf, ok := ret.Get(0).(func(string, string, string) bool); ok {
-		r0 = rf(password, hash, salt)
+	if rf, ok := ret.Get(0).(func(string, string, string, int) bool); ok {
+		r0 = rf(password, hash, salt, pepperVersion)
 	} else {
 		r0 = ret.Get(0).(bool)
 	}
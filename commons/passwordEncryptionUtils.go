@@ -1,58 +1,206 @@
 package commons
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/google/uuid"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
 const (
-	// EncryptionCost ...
-	EncryptionCost = 16
-	// SecretKey ...
-	SecretKey = "&&hD23"
-	// MaxPasswordLength ...
-	MaxPasswordLength = 71
+	// LegacyMaxPasswordLength is where bcrypt hashes created before the PHC format
+	// cut the salted password, it is only used to verify those hashes
+	LegacyMaxPasswordLength = 71
+
+	// AlgorithmArgon2id ...
+	AlgorithmArgon2id = "argon2id"
+	// AlgorithmBcrypt ...
+	AlgorithmBcrypt = "bcrypt"
+
+	// DefaultArgon2Memory is in KiB
+	DefaultArgon2Memory = 64 * 1024
+	// DefaultArgon2Iterations ...
+	DefaultArgon2Iterations = 3
+	// DefaultArgon2Parallelism ...
+	DefaultArgon2Parallelism = 2
+	// DefaultBcryptCost ...
+	DefaultBcryptCost = 12
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+	// bcryptSha256Prefix marks bcrypt hashes of the SHA-256 of the password, which
+	// unlike plain bcrypt do not ignore anything past 72 bytes
+	bcryptSha256Prefix = "$bcrypt-sha256$v=1$"
 )
 
 // PasswordManagerInterface this is contract
 type PasswordManagerInterface interface {
-	GenerateHash(password string, salt string) (string, error)
-	VerifyPassword(password string, hash string, salt string) bool
+	GenerateHash(password string, salt string) (hash string, pepperVersion int, err error)
+	VerifyPassword(password string, hash string, salt string, pepperVersion int) bool
+	NeedsRehash(hash string, pepperVersion int) bool
 	CreateSalt() string
 }
 
-// PasswordManager ...
+// Argon2Params ...
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// PasswordManagerOptions ...
+type PasswordManagerOptions struct {
+	// Algorithm used for new hashes, AlgorithmArgon2id or AlgorithmBcrypt
+	Algorithm  string
+	Argon2     Argon2Params
+	BcryptCost int
+	// Peppers are the secrets mixed into hashes by version, every version a stored hash refers to must be present
+	Peppers map[int]string
+	// PepperVersion is the pepper new hashes are made with
+	PepperVersion int
+}
+
+// PasswordManager hashes passwords into PHC strings. Hashes of every supported
+// algorithm verify, new hashes use the configured algorithm, parameters and pepper.
 type PasswordManager struct {
+	Algorithm     string
+	Argon2        Argon2Params
+	BcryptCost    int
+	Peppers       map[int]string
+	PepperVersion int
 }
 
-// GenerateHash ...
-func (pm *PasswordManager) GenerateHash(rawPassword string, userSalt string) (string, error) {
-	enhancedPassword := addSaltAndKey(rawPassword, userSalt)
-	if len(enhancedPassword) > MaxPasswordLength {
-		enhancedPassword = enhancedPassword[:MaxPasswordLength]
+// NewPasswordManager fills unset options with the defaults
+func NewPasswordManager(opts PasswordManagerOptions) (*PasswordManager, error) {
+	pm := &PasswordManager{
+		Algorithm:     opts.Algorithm,
+		Argon2:        opts.Argon2,
+		BcryptCost:    opts.BcryptCost,
+		Peppers:       opts.Peppers,
+		PepperVersion: opts.PepperVersion,
+	}
+	if _, found := pm.Peppers[pm.PepperVersion]; !found {
+		return nil, fmt.Errorf("pepper version %d is not configured", pm.PepperVersion)
+	}
+	if pm.Algorithm == "" {
+		pm.Algorithm = AlgorithmArgon2id
+	}
+	if pm.Argon2.Memory == 0 {
+		pm.Argon2.Memory = DefaultArgon2Memory
+	}
+	if pm.Argon2.Iterations == 0 {
+		pm.Argon2.Iterations = DefaultArgon2Iterations
 	}
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(enhancedPassword), EncryptionCost)
-	if err != nil {
-		return "", err
+	if pm.Argon2.Parallelism == 0 {
+		pm.Argon2.Parallelism = DefaultArgon2Parallelism
 	}
-	return string(hashedPassword), nil
+	if pm.BcryptCost == 0 {
+		pm.BcryptCost = DefaultBcryptCost
+	}
+
+	switch pm.Algorithm {
+	case AlgorithmArgon2id:
+	case AlgorithmBcrypt:
+		if pm.BcryptCost < bcrypt.MinCost || pm.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("invalid bcrypt cost %d", pm.BcryptCost)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", pm.Algorithm)
+	}
+	return pm, nil
 }
 
-// VerifyPassword ...
-func (pm *PasswordManager) VerifyPassword(rawPassword string, storedHash string, userSalt string) bool {
-	userSalt = strings.TrimSpace(userSalt)
+// GenerateHash hashes with the current pepper and returns its version, to be stored next to the hash
+func (pm *PasswordManager) GenerateHash(rawPassword string, userSalt string) (string, int, error) {
+	enhancedPassword := addSaltAndKey(pm.Peppers[pm.PepperVersion], rawPassword, userSalt)
+
+	if pm.Algorithm == AlgorithmBcrypt {
+		hashedPassword, err := bcrypt.GenerateFromPassword(sha256Base64(enhancedPassword), pm.BcryptCost)
+		if err != nil {
+			return "", 0, err
+		}
+		return bcryptSha256Prefix + string(hashedPassword), pm.PepperVersion, nil
+	}
 
-	enhancedPassword := addSaltAndKey(rawPassword, userSalt)
-	if len(enhancedPassword) > MaxPasswordLength {
-		enhancedPassword = enhancedPassword[:MaxPasswordLength]
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", 0, err
 	}
+	key := argon2.IDKey([]byte(enhancedPassword), salt, pm.Argon2.Iterations, pm.Argon2.Memory, pm.Argon2.Parallelism, argon2KeyLength)
 
-	err := bcrypt.CompareHashAndPassword([]byte(storedHash), []byte(enhancedPassword))
-	if err != nil {
+	hash := fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		AlgorithmArgon2id,
+		argon2.Version,
+		pm.Argon2.Memory,
+		pm.Argon2.Iterations,
+		pm.Argon2.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+	return hash, pm.PepperVersion, nil
+}
+
+// VerifyPassword checks the password with the pepper version the hash was made with
+func (pm *PasswordManager) VerifyPassword(rawPassword string, storedHash string, userSalt string, pepperVersion int) bool {
+	pepper, found := pm.Peppers[pepperVersion]
+	if !found {
 		return false
 	}
+
+	userSalt = strings.TrimSpace(userSalt)
+	storedHash = strings.TrimSpace(storedHash)
+	enhancedPassword := addSaltAndKey(pepper, rawPassword, userSalt)
+
+	switch {
+	case strings.HasPrefix(storedHash, "$"+AlgorithmArgon2id+"$"):
+		hash, err := parseArgon2idHash(storedHash)
+		if err != nil {
+			return false
+		}
+		key := argon2.IDKey([]byte(enhancedPassword), hash.salt, hash.params.Iterations, hash.params.Memory, hash.params.Parallelism, uint32(len(hash.key)))
+		return subtle.ConstantTimeCompare(key, hash.key) == 1
+	case strings.HasPrefix(storedHash, bcryptSha256Prefix):
+		err := bcrypt.CompareHashAndPassword([]byte(strings.TrimPrefix(storedHash, bcryptSha256Prefix)), sha256Base64(enhancedPassword))
+		return err == nil
+	case isLegacyBcrypt(storedHash):
+		if len(enhancedPassword) > LegacyMaxPasswordLength {
+			enhancedPassword = enhancedPassword[:LegacyMaxPasswordLength]
+		}
+		err := bcrypt.CompareHashAndPassword([]byte(storedHash), []byte(enhancedPassword))
+		return err == nil
+	}
+	return false
+}
+
+// NeedsRehash reports whether a hash was made with another algorithm, other
+// parameters or another pepper than the ones new hashes are made with
+func (pm *PasswordManager) NeedsRehash(storedHash string, pepperVersion int) bool {
+	if pepperVersion != pm.PepperVersion {
+		return true
+	}
+	storedHash = strings.TrimSpace(storedHash)
+
+	switch pm.Algorithm {
+	case AlgorithmArgon2id:
+		hash, err := parseArgon2idHash(storedHash)
+		if err != nil {
+			return true
+		}
+		return hash.params != pm.Argon2 || len(hash.key) != argon2KeyLength
+	case AlgorithmBcrypt:
+		if !strings.HasPrefix(storedHash, bcryptSha256Prefix) {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(strings.TrimPrefix(storedHash, bcryptSha256Prefix)))
+		return err != nil || cost != pm.BcryptCost
+	}
 	return true
 }
 
@@ -61,9 +209,52 @@ func (pm *PasswordManager) CreateSalt() string {
 	return strings.ReplaceAll(generateUUID(), "-", "")
 }
 
+type argon2idHash struct {
+	params Argon2Params
+	salt   []byte
+	key    []byte
+}
+
+// parseArgon2idHash reads "$argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>"
+func parseArgon2idHash(storedHash string) (*argon2idHash, error) {
+	parts := strings.Split(storedHash, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, errors.New("unsupported argon2id version")
+	}
+
+	hash := &argon2idHash{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &hash.params.Memory, &hash.params.Iterations, &hash.params.Parallelism); err != nil {
+		return nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+
+	var err error
+	if hash.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	if hash.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, fmt.Errorf("invalid argon2id key: %w", err)
+	}
+	return hash, nil
+}
+
+func isLegacyBcrypt(storedHash string) bool {
+	return strings.HasPrefix(storedHash, "$2a$") || strings.HasPrefix(storedHash, "$2b$") || strings.HasPrefix(storedHash, "$2y$")
+}
+
+// sha256Base64 shrinks any password below the 72 byte bcrypt limit
+func sha256Base64(value string) []byte {
+	sum := sha256.Sum256([]byte(value))
+	return []byte(base64.StdEncoding.EncodeToString(sum[:]))
+}
+
 // addSaltAndKey ...
-func addSaltAndKey(password string, salt string) string {
-	return SecretKey + password + salt
+func addSaltAndKey(pepper string, password string, salt string) string {
+	return pepper + password + salt
 }
 
 // generateUUID ...
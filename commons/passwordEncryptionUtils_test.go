@@ -0,0 +1,115 @@
+package commons_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fast parameters, the defaults are deliberately slow
+var testArgon2 = commons.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}
+
+var testPeppers = map[int]string{0: "legacy-pepper", 1: "current-pepper"}
+
+func newTestPasswordManager(t *testing.T, opts commons.PasswordManagerOptions) *commons.PasswordManager {
+	if opts.Argon2 == (commons.Argon2Params{}) {
+		opts.Argon2 = testArgon2
+	}
+	if opts.Peppers == nil {
+		opts.Peppers = testPeppers
+		opts.PepperVersion = 1
+	}
+	pm, err := commons.NewPasswordManager(opts)
+	require.NoError(t, err)
+	return pm
+}
+
+func TestPasswordManagerArgon2id(t *testing.T) {
+	pm := newTestPasswordManager(t, commons.PasswordManagerOptions{})
+
+	hash, version, err := pm.GenerateHash("@Python12345@", "salt")
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	assert.True(t, pm.VerifyPassword("@Python12345@", hash, "salt", 1))
+	assert.False(t, pm.VerifyPassword("@Python12345!", hash, "salt", 1))
+	assert.False(t, pm.VerifyPassword("@Python12345@", hash, "other-salt", 1))
+	assert.False(t, pm.NeedsRehash(hash, 1))
+
+	stronger := newTestPasswordManager(t, commons.PasswordManagerOptions{
+		Argon2: commons.Argon2Params{Memory: 2048, Iterations: 1, Parallelism: 1},
+	})
+	assert.True(t, stronger.NeedsRehash(hash, 1))
+	assert.True(t, stronger.VerifyPassword("@Python12345@", hash, "salt", 1))
+}
+
+func TestPasswordManagerLongPasswords(t *testing.T) {
+	pm := newTestPasswordManager(t, commons.PasswordManagerOptions{})
+
+	long := strings.Repeat("@Python12345@", 5)
+	hash, _, err := pm.GenerateHash(long+"A", "salt")
+	require.NoError(t, err)
+	assert.False(t, pm.VerifyPassword(long+"B", hash, "salt", 1))
+
+	bcryptPm := newTestPasswordManager(t, commons.PasswordManagerOptions{Algorithm: commons.AlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+
+	hash, _, err = bcryptPm.GenerateHash(long+"A", "salt")
+	require.NoError(t, err)
+	assert.True(t, bcryptPm.VerifyPassword(long+"A", hash, "salt", 1))
+	assert.False(t, bcryptPm.VerifyPassword(long+"B", hash, "salt", 1))
+	assert.False(t, bcryptPm.NeedsRehash(hash, 1))
+	assert.True(t, pm.NeedsRehash(hash, 1))
+}
+
+func TestPasswordManagerLegacyBcrypt(t *testing.T) {
+	// hashes created before the PHC format: bcrypt of the peppered and salted password cut at 71 bytes
+	enhanced := "legacy-pepper" + "@Python12345@" + "legacy-salt"
+	legacy, err := bcrypt.GenerateFromPassword([]byte(enhanced), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	pm := newTestPasswordManager(t, commons.PasswordManagerOptions{})
+
+	assert.True(t, pm.VerifyPassword("@Python12345@", string(legacy), "legacy-salt", 0))
+	assert.False(t, pm.VerifyPassword("@Python12345!", string(legacy), "legacy-salt", 0))
+	assert.True(t, pm.NeedsRehash(string(legacy), 0))
+}
+
+func TestPasswordManagerPepperRotation(t *testing.T) {
+	old := newTestPasswordManager(t, commons.PasswordManagerOptions{Peppers: map[int]string{1: "first"}, PepperVersion: 1})
+	hash, version, err := old.GenerateHash("@Python12345@", "salt")
+	require.NoError(t, err)
+
+	rotated := newTestPasswordManager(t, commons.PasswordManagerOptions{Peppers: map[int]string{1: "first", 2: "second"}, PepperVersion: 2})
+	assert.True(t, rotated.VerifyPassword("@Python12345@", hash, "salt", version))
+	assert.False(t, rotated.VerifyPassword("@Python12345@", hash, "salt", 2))
+	assert.True(t, rotated.NeedsRehash(hash, version))
+
+	retired := newTestPasswordManager(t, commons.PasswordManagerOptions{Peppers: map[int]string{2: "second"}, PepperVersion: 2})
+	assert.False(t, retired.VerifyPassword("@Python12345@", hash, "salt", version))
+}
+
+func TestNewPasswordManagerRejectsInvalidOptions(t *testing.T) {
+	_, err := commons.NewPasswordManager(commons.PasswordManagerOptions{Algorithm: "md5", Peppers: testPeppers, PepperVersion: 1})
+	assert.Error(t, err)
+
+	_, err = commons.NewPasswordManager(commons.PasswordManagerOptions{Peppers: testPeppers, PepperVersion: 3})
+	assert.Error(t, err)
+}
+
+func TestParsePeppers(t *testing.T) {
+	peppers, err := commons.ParsePeppers("# rotated 2024-01\n0=old=secret\n\n2=new\n")
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{0: "old=secret", 2: "new"}, peppers)
+	assert.Equal(t, 2, commons.LatestPepperVersion(peppers))
+
+	_, err = commons.ParsePeppers("1=a\n1=b")
+	assert.Error(t, err)
+
+	_, err = commons.ParsePeppers("secret")
+	assert.Error(t, err)
+}
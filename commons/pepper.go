@@ -0,0 +1,55 @@
+package commons
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParsePeppers reads "<version>=<secret>" lines, blank lines and lines starting with # are skipped
+func ParsePeppers(content string) (map[int]string, error) {
+	return ParseVersionedSecrets(content, "pepper")
+}
+
+// ParseVersionedSecrets reads "<version>=<secret>" lines, kind names the secrets in errors
+func ParseVersionedSecrets(content string, kind string) (map[int]string, error) {
+	secrets := map[int]string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		version, secret, found := strings.Cut(text, "=")
+		if !found || secret == "" {
+			return nil, fmt.Errorf("%s line %d: expected <version>=<secret>", kind, line)
+		}
+		parsed, err := strconv.Atoi(strings.TrimSpace(version))
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("%s line %d: invalid version %q", kind, line, version)
+		}
+		if _, exists := secrets[parsed]; exists {
+			return nil, fmt.Errorf("%s line %d: duplicate version %d", kind, line, parsed)
+		}
+		secrets[parsed] = secret
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return secrets, nil
+}
+
+// LatestPepperVersion returns the highest configured version, it works for any versioned secrets
+func LatestPepperVersion(peppers map[int]string) int {
+	latest := -1
+	for version := range peppers {
+		if version > latest {
+			latest = version
+		}
+	}
+	return latest
+}
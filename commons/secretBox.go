@@ -0,0 +1,93 @@
+package commons
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// encryptionKeyLength selects AES-256
+const encryptionKeyLength = 32
+
+// SecretBox encrypts secrets kept in the database, such as TOTP secrets, with AES-256-GCM.
+// Every ciphertext records the version of the key it was made with so that keys can be rotated.
+type SecretBox struct {
+	Keys map[int][]byte
+	// Version is the key new ciphertexts are made with
+	Version int
+}
+
+// NewSecretBox takes base64 encoded 32 byte keys by version, as read by ParseVersionedSecrets
+func NewSecretBox(keys map[int]string, version int) (*SecretBox, error) {
+	box := &SecretBox{Keys: map[int][]byte{}, Version: version}
+	for keyVersion, encoded := range keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != encryptionKeyLength {
+			return nil, fmt.Errorf("encryption key %d must be %d base64 encoded bytes", keyVersion, encryptionKeyLength)
+		}
+		box.Keys[keyVersion] = key
+	}
+	if _, found := box.Keys[version]; !found {
+		return nil, fmt.Errorf("encryption key version %d is not configured", version)
+	}
+	return box, nil
+}
+
+// Encrypt returns "<version>:<base64 nonce and ciphertext>". associatedData, e.g. the owner of the
+// secret, is authenticated but not stored, the same value must be given to Decrypt.
+func (b *SecretBox) Encrypt(plaintext string, associatedData string) (string, error) {
+	aead, err := b.aead(b.Version)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(associatedData))
+	return strconv.Itoa(b.Version) + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt ...
+func (b *SecretBox) Decrypt(ciphertext string, associatedData string) (string, error) {
+	rawVersion, encoded, found := strings.Cut(ciphertext, ":")
+	if !found {
+		return "", errors.New("invalid ciphertext")
+	}
+	version, err := strconv.Atoi(rawVersion)
+	if err != nil {
+		return "", errors.New("invalid ciphertext version")
+	}
+	aead, err := b.aead(version)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("invalid ciphertext")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(associatedData))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func (b *SecretBox) aead(version int) (cipher.AEAD, error) {
+	key, found := b.Keys[version]
+	if !found {
+		return nil, fmt.Errorf("encryption key version %d is not configured", version)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
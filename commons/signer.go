@@ -0,0 +1,88 @@
+package commons
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// signingKeyMinLength keeps HMAC keys at least as long as the SHA-256 output
+const signingKeyMinLength = 32
+
+// Signer makes tokens that carry a payload and an expiry and need no storage, such as the
+// token of an email verification link. Like SecretBox, every token records its key version.
+type Signer struct {
+	Keys map[int][]byte
+	// Version is the key new tokens are signed with
+	Version int
+}
+
+// NewSigner takes base64 encoded keys of at least 32 bytes by version, as read by ParseVersionedSecrets
+func NewSigner(keys map[int]string, version int) (*Signer, error) {
+	signer := &Signer{Keys: map[int][]byte{}, Version: version}
+	for keyVersion, encoded := range keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) < signingKeyMinLength {
+			return nil, fmt.Errorf("signing key %d must be at least %d base64 encoded bytes", keyVersion, signingKeyMinLength)
+		}
+		signer.Keys[keyVersion] = key
+	}
+	if _, found := signer.Keys[version]; !found {
+		return nil, fmt.Errorf("signing key version %d is not configured", version)
+	}
+	return signer, nil
+}
+
+// Sign returns "<version>.<payload>.<expiry>.<signature>", URL safe. purpose is signed but not
+// stored, a token signed for one purpose does not verify for another.
+func (s *Signer) Sign(purpose string, payload string, expiresAt time.Time) string {
+	unsigned := strconv.Itoa(s.Version) + "." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	return unsigned + "." + s.signature(s.Keys[s.Version], purpose, unsigned)
+}
+
+// Verify returns the payload of a token made by Sign for the same purpose that has not expired at now
+func (s *Signer) Verify(purpose string, token string, now time.Time) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 4 {
+		return "", errors.New("invalid signed token")
+	}
+
+	version, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return "", errors.New("invalid signed token version")
+	}
+	key, found := s.Keys[version]
+	if !found {
+		return "", fmt.Errorf("signing key version %d is not configured", version)
+	}
+
+	unsigned := strings.Join(parts[:3], ".")
+	if !hmac.Equal([]byte(parts[3]), []byte(s.signature(key, purpose, unsigned))) {
+		return "", errors.New("invalid token signature")
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", errors.New("invalid signed token expiry")
+	}
+	if !now.Before(time.Unix(expiresAt, 0)) {
+		return "", errors.New("signed token expired")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", errors.New("invalid signed token payload")
+	}
+	return string(payload), nil
+}
+
+func (s *Signer) signature(key []byte, purpose string, unsigned string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose + "\n" + unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,49 @@
+package commons_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner(t *testing.T) {
+	keys := map[int]string{
+		1: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=",
+		2: "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=",
+	}
+	old, err := commons.NewSigner(keys, 1)
+	require.NoError(t, err)
+	signer, err := commons.NewSigner(keys, 2)
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	token := old.Sign("email_verification", "7:a@example.com", now.Add(time.Hour))
+	assert.True(t, strings.HasPrefix(token, "1."))
+
+	payload, err := signer.Verify("email_verification", token, now)
+	require.NoError(t, err)
+	assert.Equal(t, "7:a@example.com", payload, "older key versions keep verifying")
+
+	_, err = signer.Verify("password_reset", token, now)
+	assert.Error(t, err, "a token signed for another purpose")
+
+	_, err = signer.Verify("email_verification", token, now.Add(time.Hour))
+	assert.Error(t, err, "expired")
+
+	parts := strings.Split(token, ".")
+	parts[1] = strings.TrimSuffix(parts[1], "A") + "B"
+	_, err = signer.Verify("email_verification", strings.Join(parts, "."), now)
+	assert.Error(t, err, "tampered payload")
+
+	_, err = signer.Verify("email_verification", "not a token", now)
+	assert.Error(t, err)
+
+	_, err = commons.NewSigner(map[int]string{1: "c2hvcnQ="}, 1)
+	assert.Error(t, err)
+	_, err = commons.NewSigner(keys, 3)
+	assert.Error(t, err)
+}
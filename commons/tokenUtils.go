@@ -0,0 +1,46 @@
+package commons
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	// OpaqueTokenLength is the number of random bytes behind an opaque token
+	OpaqueTokenLength = 32
+)
+
+// GenerateOpaqueToken returns a random, URL safe token that carries no data by itself
+func GenerateOpaqueToken() (string, error) {
+	buf := make([]byte, OpaqueTokenLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashToken returns the hex encoded SHA-256 of a token, this is what we keep in the database
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateTokenFamily returns a new identifier used to group rotated tokens
+func GenerateTokenFamily() string {
+	return strings.ReplaceAll(generateUUID(), "-", "")
+}
+
+// GenerateNumericCode returns a random code of the given number of digits, short enough to type from an SMS
+func GenerateNumericCode(digits int) (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", digits, n), nil
+}
@@ -0,0 +1,110 @@
+package commons
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// TOTPDigits ...
+	TOTPDigits = 6
+	// TOTPPeriod is the time step of RFC 6238
+	TOTPPeriod = 30 * time.Second
+	// TOTPSkew is how many steps before and after the current one are accepted, for clock drift
+	TOTPSkew = 1
+
+	// totpSecretLength is the RFC 4226 recommended 160 bits
+	totpSecretLength = 20
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a random base32 secret as authenticator apps expect it
+func GenerateTOTPSecret() (string, error) {
+	buf := make([]byte, totpSecretLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(buf), nil
+}
+
+// TOTPStep returns the time step t falls in
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod/time.Second)
+}
+
+// TOTPCode returns the code of a time step (RFC 6238 with HMAC-SHA1, the algorithm every authenticator app supports)
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("invalid totp secret: %w", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation of RFC 4226
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", TOTPDigits, value%1000000), nil
+}
+
+// ValidateTOTP returns the time step code belongs to when it is valid at now. Callers must
+// refuse steps that were already used so that a code cannot be replayed.
+func ValidateTOTP(secret string, code string, now time.Time) (int64, bool) {
+	current := TOTPStep(now)
+	for step := current - TOTPSkew; step <= current+TOTPSkew; step++ {
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPURI returns the otpauth:// URI authenticator apps read from a QR code
+func TOTPURI(issuer string, account string, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TOTPDigits))
+	query.Set("period", fmt.Sprint(int(TOTPPeriod.Seconds())))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// GenerateRecoveryCode returns a random code such as "k3v9q-x7m2d", 50 bits of entropy typed by hand
+func GenerateRecoveryCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	encoded := strings.ToLower(totpEncoding.EncodeToString(buf))[:10]
+	return encoded[:5] + "-" + encoded[5:], nil
+}
+
+// NormalizeRecoveryCode accepts recovery codes typed with other case, spaces or without the dash
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.Join(strings.Fields(code), ""))
+	code = strings.ReplaceAll(code, "-", "")
+	if len(code) != 10 {
+		return code
+	}
+	return code[:5] + "-" + code[5:]
+}
@@ -0,0 +1,111 @@
+package commons_test
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6238Secret is the SHA1 test key of RFC 6238 appendix B
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode(t *testing.T) {
+	// the RFC lists 8 digit codes, 6 digit codes are their last 6 digits
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, expected := range vectors {
+		code, err := commons.TOTPCode(rfc6238Secret, commons.TOTPStep(time.Unix(unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, expected, code, "time %d", unix)
+	}
+
+	_, err := commons.TOTPCode("not base32!", 1)
+	assert.Error(t, err)
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	step := commons.TOTPStep(now)
+
+	got, ok := commons.ValidateTOTP(rfc6238Secret, "081804", now)
+	assert.True(t, ok)
+	assert.Equal(t, step, got)
+
+	previous, _ := commons.TOTPCode(rfc6238Secret, step-1)
+	got, ok = commons.ValidateTOTP(rfc6238Secret, previous, now)
+	assert.True(t, ok, "one step of clock drift is tolerated")
+	assert.Equal(t, step-1, got)
+
+	stale, _ := commons.TOTPCode(rfc6238Secret, step-2)
+	_, ok = commons.ValidateTOTP(rfc6238Secret, stale, now)
+	assert.False(t, ok)
+
+	_, ok = commons.ValidateTOTP(rfc6238Secret, "000000", now)
+	assert.False(t, ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	secret, err := commons.GenerateTOTPSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	uri, err := url.Parse(commons.TOTPURI("User Service", "+628123456789", secret))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/User Service:+628123456789", uri.Path)
+	assert.Equal(t, secret, uri.Query().Get("secret"))
+	assert.Equal(t, "User Service", uri.Query().Get("issuer"))
+	assert.Equal(t, "6", uri.Query().Get("digits"))
+}
+
+func TestRecoveryCode(t *testing.T) {
+	code, err := commons.GenerateRecoveryCode()
+	require.NoError(t, err)
+	assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, code)
+
+	assert.Equal(t, code, commons.NormalizeRecoveryCode(strings.ToUpper(strings.ReplaceAll(code, "-", " "))))
+}
+
+func TestSecretBox(t *testing.T) {
+	keys := map[int]string{
+		1: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=",
+		2: "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=",
+	}
+	old, err := commons.NewSecretBox(keys, 1)
+	require.NoError(t, err)
+	box, err := commons.NewSecretBox(keys, 2)
+	require.NoError(t, err)
+
+	encrypted, err := old.Encrypt("JBSWY3DPEHPK3PXP", "user_mfa:1")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, "1:"))
+	assert.NotContains(t, encrypted, "JBSWY3DPEHPK3PXP")
+
+	decrypted, err := box.Decrypt(encrypted, "user_mfa:1")
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", decrypted, "older key versions keep decrypting")
+
+	_, err = box.Decrypt(encrypted, "user_mfa:2")
+	assert.Error(t, err, "a ciphertext moved to another user does not decrypt")
+
+	reencrypted, err := box.Encrypt("JBSWY3DPEHPK3PXP", "user_mfa:1")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(reencrypted, "2:"))
+
+	_, err = commons.NewSecretBox(map[int]string{1: "c2hvcnQ="}, 1)
+	assert.Error(t, err)
+	_, err = commons.NewSecretBox(keys, 3)
+	assert.Error(t, err)
+}
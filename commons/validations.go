@@ -2,6 +2,7 @@ package commons
 
 import (
 	"github.com/go-playground/validator/v10"
+	"reflect"
 	"strings"
 	"unicode"
 )
@@ -11,6 +12,14 @@ var Validate *validator.Validate
 func init() {
 	Validate = validator.New()
 	Validate.RegisterValidation("password", PasswordValidation)
+	// fields are reported by the name clients send them under
+	Validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "" || name == "-" {
+			return field.Name
+		}
+		return name
+	})
 }
 
 // PasswordValidation ...
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/SawitProRecruitment/UserService/mailer"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/gommon/log"
+)
+
+// EmailVerificationPurpose is what email verification tokens are signed for
+const EmailVerificationPurpose = "email_verification"
+
+// SendEmailVerification mails a link to email, opening it makes email the verified email of the user.
+// The link is a signed token of the user and the email, nothing is stored until it is opened.
+func (s *Server) SendEmailVerification(ctx context.Context, userId int, email string) error {
+	link, err := url.Parse(s.EmailVerificationURL)
+	if err != nil {
+		log.Errorf("SendEmailVerification, invalid verification url err:%s", err.Error())
+		return err
+	}
+	token := s.EmailTokens.Sign(EmailVerificationPurpose, strconv.Itoa(userId)+":"+email, time.Now().Add(s.EmailVerificationTTL))
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	err = s.Mailer.Send(ctx, mailer.Mail{
+		To:      email,
+		Subject: "Verify your email",
+		Body:    fmt.Sprintf("Open this link to verify your email:\n\n%s\n\nIt expires in %s.", link, s.EmailVerificationTTL),
+	})
+	if err != nil {
+		log.Errorf("SendEmailVerification, error when sending link err:%s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// ResendEmailVerification mails a new link to the email of the user when it is not verified yet
+func (s *Server) ResendEmailVerification(ctx context.Context, userId int) error {
+	user, err := s.FetchUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if user.Email == nil {
+		return apperrors.Conflict(commons.ErrorNoEmail)
+	}
+	if user.EmailVerifiedAt != nil {
+		return apperrors.Conflict(commons.ErrorEmailAlreadyVerified)
+	}
+	return s.SendEmailVerification(ctx, userId, *user.Email)
+}
+
+// ConfirmEmailVerification marks the email a link was made for as verified. All link failures,
+// a link to an email the user has since replaced included, return ErrorInvalidEmailToken.
+func (s *Server) ConfirmEmailVerification(ctx context.Context, token string) error {
+	payload, err := s.EmailTokens.Verify(EmailVerificationPurpose, token, time.Now())
+	if err != nil {
+		return apperrors.Validation(commons.ErrorInvalidEmailToken)
+	}
+	rawUserID, email, found := strings.Cut(payload, ":")
+	userID, err := strconv.Atoi(rawUserID)
+	if !found || err != nil {
+		return apperrors.Validation(commons.ErrorInvalidEmailToken)
+	}
+
+	if err := s.Repository.VerifyEmail(ctx, userID, email); err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return apperrors.Validation(commons.ErrorInvalidEmailToken)
+		}
+		log.Errorf("ConfirmEmailVerification, error when verifying email err:%s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// FetchUserByEmail returns nil when no user has the email, verified or not
+func (s *Server) FetchUserByEmail(ctx context.Context, email string) (*repository.UserModel, error) {
+	user, err := s.Repository.GetUser(ctx, repository.GetUserInput{
+		Email: &email,
+	})
+
+	if err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+		log.Errorf("error fetching user: %v", err)
+		return nil, err
+	}
+	return user, nil
+}
+
+// FetchUserByPhoneNumberOrEmail looks the user up by whichever of the two is given. Unverified
+// emails find nobody, they must not stand in for a phone number in login or password reset.
+func (s *Server) FetchUserByPhoneNumberOrEmail(ctx context.Context, phoneNumber, email *string) (*repository.UserModel, error) {
+	if email == nil {
+		return s.FetchUserByPhoneNumber(ctx, *phoneNumber)
+	}
+
+	user, err := s.FetchUserByEmail(ctx, *email)
+	if err != nil || user == nil || user.EmailVerifiedAt == nil {
+		return nil, err
+	}
+	return user, nil
+}
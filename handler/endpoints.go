@@ -1,11 +1,17 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/SawitProRecruitment/UserService/apperrors"
 	"github.com/SawitProRecruitment/UserService/commons"
-	"github.com/go-playground/validator/v10"
+	"github.com/SawitProRecruitment/UserService/middleware"
+	"github.com/SawitProRecruitment/UserService/phone"
+	"github.com/SawitProRecruitment/UserService/repository"
 	"github.com/labstack/gommon/log"
 	"net/http"
+	"strings"
 
 	"github.com/SawitProRecruitment/UserService/generated"
 	"github.com/labstack/echo/v4"
@@ -15,128 +21,522 @@ func (s *Server) GetHealth(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, generated.SuccessResponse{Message: "HI"})
 }
 
+func (s *Server) GetWellKnownJwksJson(ctx echo.Context) error {
+	keySet := s.Jwt.JWKS()
+
+	response := generated.JwksResponse{Keys: make([]generated.Jwk, 0, len(keySet.Keys))}
+	for _, key := range keySet.Keys {
+		response.Keys = append(response.Keys, generated.Jwk{
+			Kty: key.Kty,
+			Use: key.Use,
+			Alg: key.Alg,
+			Kid: key.Kid,
+			N:   key.N,
+			E:   key.E,
+		})
+	}
+
+	// verifiers may cache the set, rotation keeps the previous key around long enough
+	ctx.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return ctx.JSON(http.StatusOK, response)
+}
+
 func (s *Server) PostRegister(ctx echo.Context) error {
 	userRegisterRequest := &generated.UserRegisterRequest{}
 	err := bindAndValidate(ctx, userRegisterRequest)
+	if err == nil {
+		err = s.normalizePhoneNumber(&userRegisterRequest.PhoneNumber)
+	}
+	if userRegisterRequest.Email != nil {
+		normalizeEmail(userRegisterRequest.Email)
+	}
 
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		return errorResponse(ctx, err)
 	}
 
 	user, err := s.FetchUserByPhoneNumber(ctx.Request().Context(), userRegisterRequest.PhoneNumber)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, commons.ErrSystemError)
+		return errorResponse(ctx, err)
 	}
 
 	if user != nil {
-		return echo.NewHTTPError(http.StatusConflict, commons.ErrUserExists)
+		return errorResponse(ctx, repository.ErrUserExists)
+	}
+
+	if userRegisterRequest.Email != nil {
+		owner, err := s.FetchUserByEmail(ctx.Request().Context(), *userRegisterRequest.Email)
+		if err != nil {
+			return errorResponse(ctx, err)
+		}
+		if owner != nil {
+			return errorResponse(ctx, repository.ErrEmailExists)
+		}
 	}
 
 	if err := s.RegisterNewUser(ctx.Request().Context(), userRegisterRequest); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, commons.ErrSystemError)
+		return errorResponse(ctx, err)
 	}
 
-	return ctx.JSON(http.StatusNoContent, generated.SuccessResponse{Message: "User successfully created"})
+	return ctx.NoContent(http.StatusNoContent)
 }
 
 func (s *Server) PostLogin(ctx echo.Context) error {
 	loginRequest := &generated.LoginRequest{}
 	err := bindAndValidate(ctx, loginRequest)
+	if err == nil {
+		err = s.normalizePhoneNumberOrEmail(loginRequest.PhoneNumber, loginRequest.Email)
+	}
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	clientIP := ctx.RealIP()
+	accountKey := accountThrottleKey(loginRequest.PhoneNumber, loginRequest.Email)
+	blocked, err := s.checkLoginThrottle(ctx.Request().Context(), accountKey, clientIP)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		return errorResponse(ctx, err)
+	}
+	if blocked > 0 {
+		return errorResponse(ctx, apperrors.TooManyRequests(commons.ErrorTooManyAttempts, blocked))
 	}
 
-	user, token, err := s.PerformLogin(ctx.Request().Context(), loginRequest)
+	loginResult, err := s.PerformLogin(ctx.Request().Context(), loginRequest)
 	if err != nil {
-		if err.Error() == commons.ErrorInvalidPassword {
-			return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
-		} else if err.Error() == "user not found" {
-			return echo.NewHTTPError(http.StatusNotFound, "User not found")
+		if errors.Is(err, apperrors.ErrInvalidCredentials) {
+			s.recordLoginFailure(ctx.Request().Context(), accountKey, clientIP)
 		}
-		return echo.NewHTTPError(http.StatusInternalServerError, commons.ErrSystemError)
+		return errorResponse(ctx, err)
 	}
 
-	return ctx.JSON(http.StatusOK, generated.LoginResponse{
-		Jwt:    token,
-		UserId: user.ID,
-	})
+	// with a second factor pending the failures stay, /login/mfa clears them once it issues the tokens
+	if loginResult.Challenge != nil {
+		return ctx.JSON(http.StatusAccepted, loginResult.Challenge)
+	}
+
+	if err := s.UnlockLogin(ctx.Request().Context(), accountKey); err != nil {
+		log.Warnf("PostLogin, failed attempts were not cleared err:%s", err.Error())
+	}
+	return ctx.JSON(http.StatusOK, loginResult.Tokens)
 }
 
-func (s *Server) GetUserId(ctx echo.Context, id int, params generated.GetUserIdParams) error {
+func (s *Server) PostLoginMfa(ctx echo.Context) error {
+	mfaLoginRequest := &generated.MfaLoginRequest{}
+	if err := bindAndValidate(ctx, mfaLoginRequest); err != nil {
+		return errorResponse(ctx, err)
+	}
+	if (mfaLoginRequest.Code == nil) == (mfaLoginRequest.RecoveryCode == nil) {
+		return errorResponse(ctx, apperrors.Validation(commons.ErrorMfaCodeChoice))
+	}
 
-	data, err := s.Jwt.ParseToken(params.Authorization)
+	loginResponse, err := s.CompleteMfaLogin(ctx.Request().Context(), mfaLoginRequest, ctx.RealIP())
 	if err != nil {
-		log.Errorf("ParseToken, error when creating token err:%s", err.Error())
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
-			Message: commons.ErrSystemError,
-		})
+		return errorResponse(ctx, err)
 	}
 
-	if data.ID != id {
-		return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
-			Message: "Forbidden",
-		})
+	return ctx.JSON(http.StatusOK, loginResponse)
+}
+
+func (s *Server) PostLoginOtpStart(ctx echo.Context) error {
+	if !s.OtpLoginEnabled {
+		return errorResponse(ctx, apperrors.NotFound(commons.ErrorOtpLoginDisabled))
+	}
+
+	startRequest := &generated.OtpLoginStartRequest{}
+	if err := bindAndValidate(ctx, startRequest); err != nil {
+		return errorResponse(ctx, err)
+	}
+	if err := s.normalizePhoneNumber(&startRequest.PhoneNumber); err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// the lookup and delivery happen after responding so timing does not reveal whether the user exists
+	s.runInBackground(func(c context.Context) {
+		if err := s.StartOtpLogin(c, startRequest.PhoneNumber); err != nil {
+			log.Errorf("PostLoginOtpStart, error when sending code err:%s", err.Error())
+		}
+	})
+
+	return ctx.JSON(http.StatusAccepted, generated.SuccessResponse{Message: commons.MessageLoginCodeRequested})
+}
+
+func (s *Server) PostLoginOtpVerify(ctx echo.Context) error {
+	if !s.OtpLoginEnabled {
+		return errorResponse(ctx, apperrors.NotFound(commons.ErrorOtpLoginDisabled))
+	}
+
+	verifyRequest := &generated.OtpLoginVerifyRequest{}
+	if err := bindAndValidate(ctx, verifyRequest); err != nil {
+		return errorResponse(ctx, err)
+	}
+	if err := s.normalizePhoneNumber(&verifyRequest.PhoneNumber); err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	clientIP := ctx.RealIP()
+	accountKey := phoneThrottleKey(verifyRequest.PhoneNumber)
+	blocked, err := s.checkLoginThrottle(ctx.Request().Context(), accountKey, clientIP)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	if blocked > 0 {
+		return errorResponse(ctx, apperrors.TooManyRequests(commons.ErrorTooManyAttempts, blocked))
 	}
 
-	user, err := s.FetchUserById(ctx.Request().Context(), data.ID)
+	loginResult, err := s.PerformOtpLogin(ctx.Request().Context(), verifyRequest)
 	if err != nil {
-		if err.Error() == commons.ErrorNoRow {
-			log.Warn(fmt.Sprintf("GetUser, not found userId:%d err:%s", data.ID, err.Error()))
-			return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
-				Message: "Forbidden",
-			})
+		if errors.Is(err, apperrors.ErrInvalidCredentials) {
+			s.recordLoginFailure(ctx.Request().Context(), accountKey, clientIP)
 		}
-		log.Errorf("GetUser, found error get user from db err:%s", err.Error())
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
-			Message: commons.ErrSystemError,
-		})
+		return errorResponse(ctx, err)
+	}
+
+	// with a second factor pending the failures stay, /login/mfa clears them once it issues the tokens
+	if loginResult.Challenge != nil {
+		return ctx.JSON(http.StatusAccepted, loginResult.Challenge)
 	}
 
-	return ctx.JSON(http.StatusOK, generated.UserResponse{
-		UserId:      &user.ID,
-		FullName:    &user.FullName,
-		PhoneNumber: &user.PhoneNumber,
+	if err := s.UnlockLogin(ctx.Request().Context(), accountKey); err != nil {
+		log.Warnf("PostLoginOtpVerify, failed attempts were not cleared err:%s", err.Error())
+	}
+	return ctx.JSON(http.StatusOK, loginResult.Tokens)
+}
+
+func (s *Server) PostTokenRefresh(ctx echo.Context) error {
+	refreshRequest := &generated.RefreshTokenRequest{}
+	err := bindAndValidate(ctx, refreshRequest)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	loginResponse, err := s.RefreshTokens(ctx.Request().Context(), refreshRequest.RefreshToken)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, loginResponse)
+}
+
+func (s *Server) PostPasswordForgot(ctx echo.Context) error {
+	forgotRequest := &generated.ForgotPasswordRequest{}
+	if err := bindAndValidate(ctx, forgotRequest); err != nil {
+		return errorResponse(ctx, err)
+	}
+	if err := s.normalizePhoneNumberOrEmail(forgotRequest.PhoneNumber, forgotRequest.Email); err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// the lookup and delivery happen after responding so timing does not reveal whether the user exists
+	s.runInBackground(func(c context.Context) {
+		if err := s.RequestPasswordReset(c, forgotRequest); err != nil {
+			log.Errorf("PostPasswordForgot, error when requesting reset err:%s", err.Error())
+		}
 	})
+
+	return ctx.JSON(http.StatusAccepted, generated.SuccessResponse{Message: commons.MessagePasswordResetRequested})
+}
+
+func (s *Server) PostPasswordReset(ctx echo.Context) error {
+	resetRequest := &generated.ResetPasswordRequest{}
+	if err := bindAndValidate(ctx, resetRequest); err != nil {
+		return errorResponse(ctx, err)
+	}
+	if err := s.normalizePhoneNumberOrEmail(resetRequest.PhoneNumber, resetRequest.Email); err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	if err := s.ResetPassword(ctx.Request().Context(), resetRequest); err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (s *Server) PostLogout(ctx echo.Context) error {
+	principal, ok := middleware.GetPrincipal(ctx)
+	if !ok {
+		return errorResponse(ctx, apperrors.Unauthorized("Unauthorized"))
+	}
+
+	logoutRequest := &generated.LogoutRequest{}
+	if err := bindAndValidate(ctx, logoutRequest); err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	if err := s.Logout(ctx.Request().Context(), principal.Token, logoutRequest.RefreshToken); err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (s *Server) PostLogoutAll(ctx echo.Context) error {
+	principal, ok := middleware.GetPrincipal(ctx)
+	if !ok {
+		return errorResponse(ctx, apperrors.Unauthorized("Unauthorized"))
+	}
+
+	if err := s.LogoutAll(ctx.Request().Context(), principal.UserID); err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (s *Server) GetUserId(ctx echo.Context, id int, params generated.GetUserIdParams) error {
+	principal, err := ownPrincipal(ctx, id)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	user, err := s.FetchUserById(ctx.Request().Context(), principal.UserID)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	etag := userETag(user)
+	ctx.Response().Header().Set(HeaderETag, etag)
+	if params.IfNoneMatch != nil && etagMatches(*params.IfNoneMatch, etag, true) {
+		return ctx.NoContent(http.StatusNotModified)
+	}
+	return ctx.JSON(http.StatusOK, userResponse(user))
 }
 
 func (s *Server) PatchUserIdEdit(ctx echo.Context, id int, params generated.PatchUserIdEditParams) error {
-	data, err := s.Jwt.ParseToken(params.Authorization)
+	principal, err := ownPrincipal(ctx, id)
 	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{Message: commons.ErrSystemError})
+		return errorResponse(ctx, err)
 	}
 
-	if data.ID != id {
-		return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{Message: "Forbidden"})
+	patch, err := parseUserPatch(ctx)
+	if err != nil {
+		return errorResponse(ctx, err)
 	}
 
-	userEditRequest := &generated.UserEditRequest{}
-	err = bindAndValidate(ctx, userEditRequest)
+	current, err := s.FetchUserById(ctx.Request().Context(), principal.UserID)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	if params.IfMatch != nil && !etagMatches(*params.IfMatch, userETag(current), false) {
+		ctx.Response().Header().Set(HeaderETag, userETag(current))
+		return errorResponse(ctx, apperrors.PreconditionFailed(commons.ErrorPreconditionFailed))
+	}
 
+	edit, err := userEdit(current, patch)
+	if err == nil && edit.PhoneNumber != nil {
+		err = s.normalizePhoneNumber(edit.PhoneNumber)
+	}
 	if err != nil {
-		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{Message: err.Error()})
+		return errorResponse(ctx, err)
+	}
+	if edit.Email != nil {
+		normalizeEmail(edit.Email)
 	}
 
-	err = s.EditUser(ctx.Request().Context(), data.ID, userEditRequest)
+	user, verification, err := s.EditUser(ctx.Request().Context(), current, edit)
 	if err != nil {
-		if err.Error() == commons.ErrUserExists {
-			return ctx.JSON(http.StatusConflict, generated.ErrorResponse{Message: err.Error()})
+		if errors.Is(err, repository.ErrVersionConflict) && params.IfMatch != nil {
+			// another request changed the user between the read above and the write
+			err = apperrors.PreconditionFailed(commons.ErrorPreconditionFailed)
 		}
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{Message: commons.ErrSystemError})
+		return errorResponse(ctx, err)
+	}
+
+	ctx.Response().Header().Set(HeaderETag, userETag(user))
+	if verification != nil {
+		return phoneVerificationSent(ctx, verification)
+	}
+	return ctx.JSON(http.StatusOK, userResponse(user))
+}
+
+func (s *Server) PostUserIdPhoneVerification(ctx echo.Context, id int) error {
+	principal, err := ownPrincipal(ctx, id)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	verification, err := s.ResendPhoneVerification(ctx.Request().Context(), principal.UserID)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	return phoneVerificationSent(ctx, verification)
+}
+
+func (s *Server) PostUserIdPhoneVerificationConfirm(ctx echo.Context, id int) error {
+	principal, err := ownPrincipal(ctx, id)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	confirmRequest := &generated.PhoneVerificationConfirmRequest{}
+	if err := bindAndValidate(ctx, confirmRequest); err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	if err := s.ConfirmPhoneVerification(ctx.Request().Context(), principal.UserID, confirmRequest.Code); err != nil {
+		return errorResponse(ctx, err)
 	}
 
-	return ctx.JSON(http.StatusNoContent, generated.SuccessResponse{Message: "success update user"})
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (s *Server) PostUserIdEmailVerification(ctx echo.Context, id int) error {
+	principal, err := ownPrincipal(ctx, id)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	if err := s.ResendEmailVerification(ctx.Request().Context(), principal.UserID); err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusAccepted, generated.SuccessResponse{Message: commons.MessageEmailVerificationSent})
+}
+
+func (s *Server) GetEmailVerify(ctx echo.Context, params generated.GetEmailVerifyParams) error {
+	if err := s.ConfirmEmailVerification(ctx.Request().Context(), params.Token); err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, generated.SuccessResponse{Message: commons.MessageEmailVerified})
+}
+
+func (s *Server) PatchUserIdPassword(ctx echo.Context, id int) error {
+	principal, err := ownPrincipal(ctx, id)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	changePasswordRequest := &generated.ChangePasswordRequest{}
+	if err := bindAndValidate(ctx, changePasswordRequest); err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	loginResponse, err := s.ChangePassword(ctx.Request().Context(), principal.UserID, changePasswordRequest)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, loginResponse)
+}
+
+func (s *Server) GetAdminUsers(ctx echo.Context, params generated.GetAdminUsersParams) error {
+	users, err := s.ListUsers(ctx.Request().Context(), params)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, users)
+}
+
+func (s *Server) PostAdminUsersIdUnlock(ctx echo.Context, id int) error {
+	user, err := s.FetchUserById(ctx.Request().Context(), id)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	if err := s.UnlockLogin(ctx.Request().Context(), userThrottleKeys(user)...); err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (s *Server) PostUserIdMfaTotp(ctx echo.Context, id int) error {
+	principal, err := ownPrincipal(ctx, id)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	enrollment, err := s.EnrollTotp(ctx.Request().Context(), principal.UserID)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// the secret must not end up in a shared cache
+	ctx.Response().Header().Set("Cache-Control", "no-store")
+	return ctx.JSON(http.StatusOK, enrollment)
+}
+
+func (s *Server) PostUserIdMfaTotpConfirm(ctx echo.Context, id int) error {
+	principal, err := ownPrincipal(ctx, id)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	confirmRequest := &generated.TotpConfirmRequest{}
+	if err := bindAndValidate(ctx, confirmRequest); err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	recoveryCodes, err := s.ConfirmTotp(ctx.Request().Context(), principal.UserID, confirmRequest.Code)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	ctx.Response().Header().Set("Cache-Control", "no-store")
+	return ctx.JSON(http.StatusOK, generated.RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
+
+func (s *Server) DeleteAdminUsersIdMfa(ctx echo.Context, id int) error {
+	if err := s.ResetMfa(ctx.Request().Context(), id); err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// phoneVerificationSent answers 202 with where the code went, or 429 while the previous code is too recent
+func phoneVerificationSent(ctx echo.Context, verification *PhoneVerificationResult) error {
+	if verification.RetryAfter > 0 {
+		return errorResponse(ctx, apperrors.TooManyRequests(commons.ErrorOtpCooldown, verification.RetryAfter))
+	}
+	return ctx.JSON(http.StatusAccepted, verification.Sent)
 }
 
 func bindAndValidate(ctx echo.Context, req interface{}) error {
 	// Bind the request
 	if err := ctx.Bind(req); err != nil {
-		return fmt.Errorf("invalid data: %v", err)
+		return apperrors.Validation(fmt.Sprintf(commons.InValidData, err))
 	}
 
 	// Validate the request
 	if err := commons.Validate.Struct(req); err != nil {
-		return fmt.Errorf("invalid data %v", err.(validator.ValidationErrors))
+		return validationError(err)
 	}
 
 	return nil
 }
+
+// normalizePhoneNumber replaces a phone number from a request with its E.164 form
+func (s *Server) normalizePhoneNumber(phoneNumber *string) error {
+	phones := s.Phones
+	if phones == nil {
+		phones = phone.Default
+	}
+
+	normalized, err := phones.Normalize(*phoneNumber)
+	if err != nil {
+		return err
+	}
+	*phoneNumber = normalized
+	return nil
+}
+
+// normalizeEmail lower cases an email from a request, emails are unique regardless of case
+func normalizeEmail(email *string) {
+	*email = strings.ToLower(strings.TrimSpace(*email))
+}
+
+// normalizePhoneNumberOrEmail checks that a request identifies the user by exactly one of the two and normalizes it
+func (s *Server) normalizePhoneNumberOrEmail(phoneNumber, email *string) error {
+	if (phoneNumber == nil) == (email == nil) {
+		return apperrors.Validation(commons.ErrorPhoneNumberOrEmail)
+	}
+	if email != nil {
+		normalizeEmail(email)
+		return nil
+	}
+	return s.normalizePhoneNumber(phoneNumber)
+}
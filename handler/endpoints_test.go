@@ -2,21 +2,39 @@ package handler_test
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"fmt"
+	"github.com/SawitProRecruitment/UserService/apperrors"
 	"github.com/SawitProRecruitment/UserService/commons"
 	pwdMocks "github.com/SawitProRecruitment/UserService/commons/mocks"
 	"github.com/SawitProRecruitment/UserService/generated"
 	"github.com/SawitProRecruitment/UserService/handler"
+	"github.com/SawitProRecruitment/UserService/mailer"
+	mailerMocks "github.com/SawitProRecruitment/UserService/mailer/mocks"
 	"github.com/SawitProRecruitment/UserService/middleware"
 	authMocks "github.com/SawitProRecruitment/UserService/middleware/mocks"
+	"github.com/SawitProRecruitment/UserService/notifier"
+	notifierMocks "github.com/SawitProRecruitment/UserService/notifier/mocks"
+	"github.com/SawitProRecruitment/UserService/phone"
 	"github.com/SawitProRecruitment/UserService/repository"
 	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/SawitProRecruitment/UserService/throttle"
+	throttleMocks "github.com/SawitProRecruitment/UserService/throttle/mocks"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 )
@@ -38,6 +56,31 @@ func TestGetHealth(t *testing.T) {
 	}
 }
 
+func TestGetWellKnownJwksJson(t *testing.T) {
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mockJwt := new(authMocks.JwtInterface)
+	mockJwt.On("JWKS").Return(middleware.JSONWebKeySet{Keys: []middleware.JSONWebKey{
+		{Kty: "RSA", Use: "sig", Alg: "RS256", Kid: "2024-01", N: "n", E: "AQAB"},
+	}})
+
+	s := &handler.Server{Jwt: mockJwt}
+
+	if assert.NoError(t, s.GetWellKnownJwksJson(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		resp := generated.JwksResponse{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		if assert.Len(t, resp.Keys, 1) {
+			assert.Equal(t, "2024-01", resp.Keys[0].Kid)
+		}
+	}
+}
+
 func TestPostRegister(t *testing.T) {
 	e := echo.New()
 
@@ -49,14 +92,45 @@ func TestPostRegister(t *testing.T) {
 		}
 		reqBodyBytes, _ := json.Marshal(reqBody)
 		req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
 
 		s := &handler.Server{Repository: mockRepo}
 
 		err := s.PostRegister(c)
-		assert.NotNil(t, err)
-		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		resp := generated.ErrorResponse{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		if assert.NotNil(t, resp.Fields) {
+			assert.Contains(t, *resp.Fields, generated.FieldError{Field: "phoneNumber", Message: "failed on the required rule"})
+		}
+	})
+
+	t.Run("Registered Concurrently", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockPwd := new(pwdMocks.PasswordManagerInterface)
+		reqBody := map[string]interface{}{"PhoneNumber": "+628222667727", "fullName": "LOLTOS", "password": "@Python12345@"}
+		reqBodyBytes, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		// another request took the phone number between the lookup and the insert
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+		mockPwd.On("CreateSalt").Return("salt")
+		mockPwd.On("GenerateHash", mock.Anything, mock.Anything).Return("hash", 1, nil)
+		mockRepo.On("CreateUser", mock.Anything, mock.Anything).Return(0, repository.ErrUserExists).Once()
+
+		s := &handler.Server{Repository: mockRepo, Pwd: mockPwd}
+		if assert.NoError(t, s.PostRegister(c)) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+			assert.Contains(t, rec.Body.String(), commons.ErrUserExists)
+		}
+		mockRepo.AssertExpectations(t)
 	})
 
 	t.Run("User Already Exists", func(t *testing.T) {
@@ -70,13 +144,146 @@ func TestPostRegister(t *testing.T) {
 		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111}, nil).Once()
 		s := &handler.Server{Repository: mockRepo}
 		err := s.PostRegister(c)
-		if assert.Error(t, err) {
-			httpErr, ok := err.(*echo.HTTPError)
-			if assert.True(t, ok) {
-				assert.Equal(t, http.StatusConflict, httpErr.Code)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Phone Number Normalized", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		reqBody := map[string]interface{}{"PhoneNumber": "0822 2667-727", "fullName": "LOLTOS", "password": "@Python12345@"}
+		reqBodyBytes, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		mockRepo.On("GetUser", mock.Anything, mock.MatchedBy(func(input repository.GetUserInput) bool {
+			return input.PhoneNumber != nil && *input.PhoneNumber == "+628222667727"
+		})).Return(&repository.UserModel{ID: 111}, nil).Once()
+		s := &handler.Server{Repository: mockRepo}
+		err := s.PostRegister(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusConflict, rec.Code, "the stored +628222667727 is the same number")
+		}
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Invalid Phone Number", func(t *testing.T) {
+		for phoneNumber, message := range map[string]string{
+			"+62 21 555 0100": commons.ErrorInvalidPhoneNumber,
+			"+65 9123 4567":   commons.ErrorPhoneRegionNotAllowed,
+		} {
+			reqBody := map[string]interface{}{"PhoneNumber": phoneNumber, "fullName": "LOLTOS", "password": "@Python12345@"}
+			reqBodyBytes, _ := json.Marshal(reqBody)
+			req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewBuffer(reqBodyBytes))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			s := &handler.Server{Repository: new(mocks.RepositoryInterface)}
+			err := s.PostRegister(c)
+			if assert.NoError(t, err, phoneNumber) {
+				assert.Equal(t, http.StatusBadRequest, rec.Code, phoneNumber)
+				assert.Contains(t, rec.Body.String(), message, phoneNumber)
 			}
 		}
+	})
+
+	t.Run("Phone Number Of Allowed Country", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		phones, err := phone.NewNormalizer(phone.NormalizerOptions{AllowedRegions: []string{"ID", "SG"}, DefaultRegion: "ID"})
+		require.NoError(t, err)
+		reqBody := map[string]interface{}{"PhoneNumber": "+65 9123 4567", "fullName": "LOLTOS", "password": "@Python12345@"}
+		reqBodyBytes, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		mockRepo.On("GetUser", mock.Anything, mock.MatchedBy(func(input repository.GetUserInput) bool {
+			return input.PhoneNumber != nil && *input.PhoneNumber == "+6591234567"
+		})).Return(&repository.UserModel{ID: 111}, nil).Once()
+		s := &handler.Server{Repository: mockRepo, Phones: phones}
+		err = s.PostRegister(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Email Already Exists", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		reqBody := map[string]interface{}{"PhoneNumber": "+628222667727", "fullName": "LOLTOS", "password": "@Python12345@", "email": "Taken@Example.com"}
+		reqBodyBytes, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		mockRepo.On("GetUser", mock.Anything, mock.MatchedBy(func(input repository.GetUserInput) bool {
+			return input.PhoneNumber != nil
+		})).Return(nil, apperrors.NotFound(commons.ErrorNoData)).Once()
+		mockRepo.On("GetUser", mock.Anything, mock.MatchedBy(func(input repository.GetUserInput) bool {
+			return input.Email != nil && *input.Email == "taken@example.com"
+		})).Return(&repository.UserModel{ID: 5}, nil).Once()
+		s := &handler.Server{Repository: mockRepo}
+		err := s.PostRegister(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+			assert.Contains(t, rec.Body.String(), commons.ErrEmailExists)
+		}
+		mockRepo.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Register With Email Mails Verification Link", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockPwd := new(pwdMocks.PasswordManagerInterface)
+		mockNotifier := new(notifierMocks.NotifierInterface)
+		mockMailer := new(mailerMocks.MailerInterface)
+		reqBody := map[string]interface{}{"PhoneNumber": "+628222667727", "fullName": "LOLTOS", "password": "@Python12345@", "email": "New@Example.com"}
+		reqBodyBytes, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+		mockPwd.On("CreateSalt").Return("salt")
+		mockPwd.On("GenerateHash", mock.Anything, mock.Anything).Return("hash", 1, nil)
+		mockRepo.On("CreateUser", mock.Anything, mock.MatchedBy(func(input repository.UserInput) bool {
+			return input.Email != nil && *input.Email == "new@example.com"
+		})).Return(11, nil).Once()
+		mockRepo.On("GetActiveOtpCode", mock.Anything, 11, handler.OtpPurposePhoneVerification).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+		mockRepo.On("CreateOtpCode", mock.Anything, mock.Anything).Return(1, nil)
+		mockNotifier.On("Send", mock.Anything, mock.Anything).Return(nil)
+		var sent mailer.Mail
+		mockMailer.On("Send", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			sent = args.Get(1).(mailer.Mail)
+		}).Return(nil).Once()
+
+		signer := testSigner(t)
+		s := &handler.Server{
+			Repository:           mockRepo,
+			Pwd:                  mockPwd,
+			Notifier:             mockNotifier,
+			Mailer:               mockMailer,
+			EmailTokens:          signer,
+			EmailVerificationTTL: time.Hour,
+			EmailVerificationURL: "https://example.com/email/verify",
+		}
+		err := s.PostRegister(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+		}
 		mockRepo.AssertExpectations(t)
+		mockMailer.AssertExpectations(t)
+
+		assert.Equal(t, "new@example.com", sent.To)
+		token := regexp.MustCompile(`https://example\.com/email/verify\?token=(\S+)`).FindStringSubmatch(sent.Body)
+		if assert.Len(t, token, 2) {
+			payload, err := signer.Verify(handler.EmailVerificationPurpose, token[1], time.Now())
+			assert.NoError(t, err)
+			assert.Equal(t, "11:new@example.com", payload)
+		}
 	})
 
 	t.Run("GetUser Err", func(t *testing.T) {
@@ -90,11 +297,8 @@ func TestPostRegister(t *testing.T) {
 		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(nil, errors.New("simulate err"))
 		s := &handler.Server{Repository: mockRepo}
 		err := s.PostRegister(c)
-		if assert.Error(t, err) {
-			httpErr, ok := err.(*echo.HTTPError)
-			if assert.True(t, ok) {
-				assert.Equal(t, http.StatusInternalServerError, httpErr.Code)
-			}
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusInternalServerError, rec.Code)
 		}
 		mockRepo.AssertExpectations(t)
 	})
@@ -111,17 +315,56 @@ func TestPostRegister(t *testing.T) {
 
 		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(nil, nil)
 		mockPwd.On("CreateSalt").Return("okCreate")
-		mockPwd.On("GenerateHash", mock.Anything, mock.Anything).Return("ok", nil)
-		mockRepo.On("CreateUser", mock.Anything, mock.Anything).Return(11, nil)
+		mockPwd.On("GenerateHash", mock.Anything, mock.Anything).Return("ok", 1, nil)
+		mockRepo.On("CreateUser", mock.Anything, mock.MatchedBy(func(input repository.UserInput) bool {
+			return input.Password == "ok" && input.SaltKey == "okCreate" && input.PepperVersion == 1
+		})).Return(11, nil)
+		mockRepo.On("GetActiveOtpCode", mock.Anything, 11, handler.OtpPurposePhoneVerification).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+		mockRepo.On("CreateOtpCode", mock.Anything, mock.MatchedBy(func(input repository.OtpCodeInput) bool {
+			return input.UserID == 11 && input.PhoneNumber == "+628222667727" && input.Purpose == handler.OtpPurposePhoneVerification
+		})).Return(1, nil).Once()
+		mockNotifier := new(notifierMocks.NotifierInterface)
+		mockNotifier.On("Send", mock.Anything, mock.MatchedBy(func(message notifier.Message) bool {
+			return message.Recipient == "+628222667727"
+		})).Return(nil).Once()
 
 		s := &handler.Server{
 			Repository: mockRepo,
 			Pwd:        mockPwd,
+			Notifier:   mockNotifier,
 		}
 		err := s.PostRegister(c)
 		if assert.NoError(t, err) {
 			assert.Equal(t, http.StatusNoContent, rec.Code)
 		}
+		mockRepo.AssertExpectations(t)
+		mockNotifier.AssertExpectations(t)
+	})
+
+	t.Run("Verification Code Not Sent", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockPwd := new(pwdMocks.PasswordManagerInterface)
+		mockNotifier := new(notifierMocks.NotifierInterface)
+		reqBody := map[string]interface{}{"PhoneNumber": "+628222667727", "fullName": "LOLTOS", "password": "@Python12345@"}
+		reqBodyBytes, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(nil, nil)
+		mockPwd.On("CreateSalt").Return("okCreate")
+		mockPwd.On("GenerateHash", mock.Anything, mock.Anything).Return("ok", 1, nil)
+		mockRepo.On("CreateUser", mock.Anything, mock.Anything).Return(11, nil)
+		mockRepo.On("GetActiveOtpCode", mock.Anything, 11, mock.Anything).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+		mockRepo.On("CreateOtpCode", mock.Anything, mock.Anything).Return(1, nil)
+		mockNotifier.On("Send", mock.Anything, mock.Anything).Return(errors.New("simulate err"))
+
+		s := &handler.Server{Repository: mockRepo, Pwd: mockPwd, Notifier: mockNotifier}
+		err := s.PostRegister(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusNoContent, rec.Code, "the code can be resent later")
+		}
 	})
 
 }
@@ -145,273 +388,667 @@ func TestPostLogin(t *testing.T) {
 		s := &handler.Server{Repository: mockRepo}
 
 		err := s.PostLogin(c)
-		assert.NotNil(t, err)
-		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
 	})
 
-	t.Run("User Not Found", func(t *testing.T) {
-		mockRepo := new(mocks.RepositoryInterface)
-
-		reqBody := map[string]interface{}{"PhoneNumber": "+628222667727", "fullName": "LOLTOS", "password": "@Python12345@"}
-		reqBodyBytes, _ := json.Marshal(reqBody)
+	newRequest := func(password string) (echo.Context, *httptest.ResponseRecorder) {
+		reqBodyBytes, _ := json.Marshal(map[string]interface{}{"PhoneNumber": "+628222667727", "password": password})
 		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(reqBodyBytes))
 		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "10.0.0.1:5000"
 		rec := httptest.NewRecorder()
-		c := e.NewContext(req, rec)
+		return e.NewContext(req, rec), rec
+	}
+
+	t.Run("User Not Found", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockPwd := new(pwdMocks.PasswordManagerInterface)
+		c, rec := newRequest("@Python12345@")
 
-		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(nil, errors.New("user not found"))
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+		mockPwd.On("CreateSalt").Return("dummy")
+		mockPwd.On("GenerateHash", "dummy", "dummy").Return("$argon2id$dummy", 1, nil).Once()
+		mockPwd.On("VerifyPassword", "@Python12345@", "$argon2id$dummy", "", 1).Return(false).Once()
 
-		s := &handler.Server{Repository: mockRepo}
+		s := &handler.Server{Repository: mockRepo, Pwd: mockPwd}
 
 		err := s.PostLogin(c)
-		if assert.Error(t, err) {
-			httpErr, ok := err.(*echo.HTTPError)
-			if assert.True(t, ok) {
-				assert.Equal(t, http.StatusNotFound, httpErr.Code)
-			}
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+			assert.Contains(t, rec.Body.String(), commons.ErrorInvalidCredentials)
 		}
+		mockPwd.AssertExpectations(t)
 	})
 
-	t.Run("Success Login", func(t *testing.T) {
+	t.Run("Wrong Password Counts Failure", func(t *testing.T) {
 		mockRepo := new(mocks.RepositoryInterface)
 		mockPwd := new(pwdMocks.PasswordManagerInterface)
-		mockJwt := new(authMocks.JwtInterface)
+		mockPhoneThrottle := new(throttleMocks.ThrottlerInterface)
+		mockIPThrottle := new(throttleMocks.ThrottlerInterface)
+		c, rec := newRequest("@Wrong12345@")
 
-		reqBody := map[string]interface{}{"PhoneNumber": "+628222667727", "fullName": "LOLTOS", "password": "@Python12345@"}
-		reqBodyBytes, _ := json.Marshal(reqBody)
-		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(reqBodyBytes))
-		req.Header.Set("Content-Type", "application/json")
-		rec := httptest.NewRecorder()
-		c := e.NewContext(req, rec)
+		mockPhoneThrottle.On("Check", mock.Anything, "phone:+628222667727").Return(time.Duration(0), nil)
+		mockIPThrottle.On("Check", mock.Anything, "ip:10.0.0.1").Return(time.Duration(0), nil)
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111, Password: "hash", SaltKey: "salt"}, nil)
+		mockPwd.On("VerifyPassword", "@Wrong12345@", "hash", "salt", 0).Return(false)
+		mockPhoneThrottle.On("Fail", mock.Anything, "phone:+628222667727").Return(time.Second, nil).Once()
+		mockIPThrottle.On("Fail", mock.Anything, "ip:10.0.0.1").Return(time.Duration(0), nil).Once()
 
-		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{
-			ID:          111,
-			PhoneNumber: "111",
-			FullName:    "111",
-			Password:    "11",
-			SaltKey:     "111",
-			CreatedAt:   time.Time{},
-			UpdatedAt:   time.Time{},
-		}, nil)
-		mockPwd.On("VerifyPassword", mock.Anything, mock.Anything, mock.Anything).Return(true)
-		mockJwt.On("CreateToken", mock.Anything, mock.Anything).Return("ok", nil)
-		s := &handler.Server{Repository: mockRepo, Pwd: mockPwd, Jwt: mockJwt}
+		s := &handler.Server{Repository: mockRepo, Pwd: mockPwd, PhoneThrottle: mockPhoneThrottle, IPThrottle: mockIPThrottle}
 
 		err := s.PostLogin(c)
 		if assert.NoError(t, err) {
-			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+			assert.Contains(t, rec.Body.String(), commons.ErrorInvalidCredentials)
 		}
+		mockPhoneThrottle.AssertExpectations(t)
+		mockIPThrottle.AssertExpectations(t)
 	})
-}
-
-func TestGetUserId(t *testing.T) {
-	e := echo.New()
 
-	t.Run("Error on ParseToken", func(t *testing.T) {
-		mockJwt := &authMocks.JwtInterface{}
+	t.Run("Forged X-Forwarded-For Does Not Reset IP Throttle", func(t *testing.T) {
+		e := echo.New()
+		ipExtractor, err := middleware.NewIPExtractor(nil)
+		require.NoError(t, err)
+		e.IPExtractor = ipExtractor
 
-		req := httptest.NewRequest(http.MethodGet, "/user/1", nil)
-		rec := httptest.NewRecorder()
-		c := e.NewContext(req, rec)
-		c.SetPath("/user/:id")
-		c.SetParamNames("id")
-		c.SetParamValues("1")
+		mockRepo := new(mocks.RepositoryInterface)
+		mockPwd := new(pwdMocks.PasswordManagerInterface)
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111, Password: "hash", SaltKey: "salt"}, nil)
+		mockPwd.On("VerifyPassword", "@Wrong12345@", "hash", "salt", 0).Return(false)
+
+		ipThrottle := throttle.NewThrottler(throttle.NewMemoryAttemptStore(), throttle.Policy{LockoutThreshold: 2, LockoutDuration: time.Hour, Window: time.Hour})
+		s := &handler.Server{Repository: mockRepo, Pwd: mockPwd, IPThrottle: ipThrottle}
+
+		for i, forged := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"} {
+			reqBodyBytes, _ := json.Marshal(map[string]interface{}{"PhoneNumber": "+628222667727", "password": "@Wrong12345@"})
+			req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(reqBodyBytes))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(echo.HeaderXForwardedFor, forged)
+			req.Header.Set(echo.HeaderXRealIP, forged)
+			req.RemoteAddr = "10.0.0.1:5000"
+			rec := httptest.NewRecorder()
+
+			require.NoError(t, s.PostLogin(e.NewContext(req, rec)))
+			if i < 2 {
+				assert.Equal(t, http.StatusUnauthorized, rec.Code)
+			} else {
+				assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+			}
+		}
+	})
 
-		mockJwt.On("ParseToken", mock.Anything).Return(nil, errors.New("some error"))
+	t.Run("Too Many Attempts", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockPhoneThrottle := new(throttleMocks.ThrottlerInterface)
+		mockIPThrottle := new(throttleMocks.ThrottlerInterface)
+		c, rec := newRequest("@Python12345@")
 
-		s := &handler.Server{Jwt: mockJwt}
+		mockPhoneThrottle.On("Check", mock.Anything, "phone:+628222667727").Return(1500*time.Millisecond, nil)
+		mockIPThrottle.On("Check", mock.Anything, "ip:10.0.0.1").Return(time.Duration(0), nil)
 
-		err := s.GetUserId(c, 1, generated.GetUserIdParams{
-			Authorization: "some-token",
-		})
+		s := &handler.Server{Repository: mockRepo, PhoneThrottle: mockPhoneThrottle, IPThrottle: mockIPThrottle}
 
+		err := s.PostLogin(c)
 		if assert.NoError(t, err) {
-			assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+			assert.Equal(t, "2", rec.Header().Get("Retry-After"))
 		}
+		mockRepo.AssertNotCalled(t, "GetUser", mock.Anything, mock.Anything)
+		mockPhoneThrottle.AssertNotCalled(t, "Fail", mock.Anything, mock.Anything)
 	})
 
-	t.Run("Forbidden", func(t *testing.T) {
-		mockJwt := &authMocks.JwtInterface{}
-
-		req := httptest.NewRequest(http.MethodGet, "/user/1", nil)
+	t.Run("Throttled By Normalized Phone Number", func(t *testing.T) {
+		mockPhoneThrottle := new(throttleMocks.ThrottlerInterface)
+		mockIPThrottle := new(throttleMocks.ThrottlerInterface)
+		reqBodyBytes, _ := json.Marshal(map[string]interface{}{"PhoneNumber": "+62 0822-2667-727", "password": "@Python12345@"})
+		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "10.0.0.1:5000"
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
-		c.SetPath("/user/:id")
-		c.SetParamNames("id")
-		c.SetParamValues("1")
-
-		mockJwt.On("ParseToken", mock.Anything).Return(&middleware.JwtParsedPayload{
-			ID:     111,
-			Expire: 111,
-		}, nil)
 
-		s := &handler.Server{Jwt: mockJwt}
+		mockPhoneThrottle.On("Check", mock.Anything, "phone:+628222667727").Return(time.Second, nil).Once()
+		mockIPThrottle.On("Check", mock.Anything, "ip:10.0.0.1").Return(time.Duration(0), nil)
 
-		err := s.GetUserId(c, 1, generated.GetUserIdParams{
-			Authorization: "some-token",
-		})
+		s := &handler.Server{Repository: new(mocks.RepositoryInterface), PhoneThrottle: mockPhoneThrottle, IPThrottle: mockIPThrottle}
 
+		err := s.PostLogin(c)
 		if assert.NoError(t, err) {
-			assert.Equal(t, http.StatusForbidden, rec.Code)
+			assert.Equal(t, http.StatusTooManyRequests, rec.Code)
 		}
+		mockPhoneThrottle.AssertExpectations(t)
 	})
 
-	t.Run("FetchUserById err", func(t *testing.T) {
+	t.Run("Hashing Saturated", func(t *testing.T) {
 		mockRepo := new(mocks.RepositoryInterface)
-		mockJwt := &authMocks.JwtInterface{}
-
-		req := httptest.NewRequest(http.MethodGet, "/user/1", nil)
-		rec := httptest.NewRecorder()
-		c := e.NewContext(req, rec)
-		c.SetPath("/user/:id")
-		c.SetParamNames("id")
-		c.SetParamValues("1")
-
-		mockJwt.On("ParseToken", mock.Anything).Return(&middleware.JwtParsedPayload{
-			ID:     1,
-			Expire: 111,
-		}, nil)
+		mockPwd := new(pwdMocks.PasswordManagerInterface)
+		c, rec := newRequest("@Python12345@")
 
-		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(nil, errors.New(commons.ErrorNoRow))
+		pool := commons.NewHashPool(commons.HashPoolOptions{Workers: 1})
+		defer pool.Close()
+		release := make(chan struct{})
+		defer close(release)
+		go pool.Do(context.Background(), func() { <-release })
+		assert.Eventually(t, func() bool { return pool.Stats().Running == 1 }, time.Second, time.Millisecond)
 
-		s := &handler.Server{Jwt: mockJwt, Repository: mockRepo}
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111, Password: "hash", SaltKey: "salt"}, nil)
 
-		err := s.GetUserId(c, 1, generated.GetUserIdParams{
-			Authorization: "some-token",
-		})
+		s := &handler.Server{Repository: mockRepo, Pwd: mockPwd, HashPool: pool}
 
+		err := s.PostLogin(c)
 		if assert.NoError(t, err) {
-			assert.Equal(t, http.StatusForbidden, rec.Code)
+			assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+			assert.Equal(t, "1", rec.Header().Get("Retry-After"))
 		}
+		mockPwd.AssertNotCalled(t, "VerifyPassword", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 	})
 
-	t.Run("Success GetUserId ", func(t *testing.T) {
+	t.Run("Success Login", func(t *testing.T) {
 		mockRepo := new(mocks.RepositoryInterface)
-		mockJwt := &authMocks.JwtInterface{}
+		mockPwd := new(pwdMocks.PasswordManagerInterface)
+		mockJwt := new(authMocks.JwtInterface)
 
-		req := httptest.NewRequest(http.MethodGet, "/user/1", nil)
+		reqBody := map[string]interface{}{"PhoneNumber": "+628222667727", "fullName": "LOLTOS", "password": "@Python12345@"}
+		reqBodyBytes, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
-		c.SetPath("/user/:id")
-		c.SetParamNames("id")
-		c.SetParamValues("1")
 
-		mockJwt.On("ParseToken", mock.Anything).Return(&middleware.JwtParsedPayload{
-			ID:     1,
-			Expire: 111,
-		}, nil)
 		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{
 			ID:          111,
 			PhoneNumber: "111",
 			FullName:    "111",
-			Password:    "111",
+			Password:    "11",
 			SaltKey:     "111",
 			CreatedAt:   time.Time{},
 			UpdatedAt:   time.Time{},
 		}, nil)
+		mockPwd.On("VerifyPassword", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(true)
+		mockPwd.On("NeedsRehash", "11", 0).Return(false)
+		mockRepo.On("GetMfa", mock.Anything, 111).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+		mockJwt.On("CreateToken", mock.Anything, mock.Anything).Return("ok", nil)
+		mockRepo.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(func(input repository.RefreshTokenInput) bool {
+			return input.UserID == 111 && input.TokenHash != "" && input.FamilyID != ""
+		})).Return(1, nil)
+		mockPhoneThrottle := new(throttleMocks.ThrottlerInterface)
+		mockPhoneThrottle.On("Check", mock.Anything, "phone:+628222667727").Return(time.Duration(0), nil)
+		mockPhoneThrottle.On("Reset", mock.Anything, "phone:+628222667727").Return(nil).Once()
+		s := &handler.Server{Repository: mockRepo, Pwd: mockPwd, Jwt: mockJwt, AccessTokenTTL: 15 * time.Minute, PhoneThrottle: mockPhoneThrottle}
 
-		s := &handler.Server{Jwt: mockJwt, Repository: mockRepo}
-
-		err := s.GetUserId(c, 1, generated.GetUserIdParams{
-			Authorization: "some-token",
-		})
-
+		err := s.PostLogin(c)
 		if assert.NoError(t, err) {
 			assert.Equal(t, http.StatusOK, rec.Code)
+
+			resp := generated.LoginResponse{}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "ok", resp.Jwt)
+			assert.NotEmpty(t, resp.RefreshToken)
+			assert.Equal(t, 900, resp.ExpiresIn)
 		}
+		mockRepo.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything)
 	})
 
-}
+	newEmailRequest := func(body map[string]interface{}) (echo.Context, *httptest.ResponseRecorder) {
+		reqBodyBytes, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "10.0.0.1:5000"
+		rec := httptest.NewRecorder()
+		return e.NewContext(req, rec), rec
+	}
 
-func TestPatchUserIdEdit(t *testing.T) {
+	t.Run("Bad Request - Phone Number And Email", func(t *testing.T) {
+		c, rec := newEmailRequest(map[string]interface{}{"phoneNumber": "+628222667727", "email": "a@example.com", "password": "@Python12345@"})
+		s := &handler.Server{}
 
-	e := echo.New()
+		err := s.PostLogin(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), commons.ErrorPhoneNumberOrEmail)
+		}
+	})
 
-	t.Run("Error on ParseToken", func(t *testing.T) {
-		mockJwt := new(authMocks.JwtInterface)
+	t.Run("Unverified Email Is Unknown", func(t *testing.T) {
 		mockRepo := new(mocks.RepositoryInterface)
-		req := httptest.NewRequest(echo.PUT, "/", nil)
-		req.Header.Set("Authorization", "someValidToken")
-		rec := httptest.NewRecorder()
-		c := e.NewContext(req, rec)
-		c.SetPath("/:id")
-		c.SetParamNames("id")
-		c.SetParamValues("1")
+		mockPwd := new(pwdMocks.PasswordManagerInterface)
+		mockPhoneThrottle := new(throttleMocks.ThrottlerInterface)
+		c, rec := newEmailRequest(map[string]interface{}{"email": "A@Example.com", "password": "@Python12345@"})
 
-		mockJwt.On("ParseToken", mock.Anything).Return(&middleware.JwtParsedPayload{
-			ID:     1,
-			Expire: 111,
-		}, errors.New("simulate err"))
-		mockRepo.On("GetUser", mock.Anything, 1, mock.Anything).Return(nil)
+		email := "a@example.com"
+		mockPhoneThrottle.On("Check", mock.Anything, "email:a@example.com").Return(time.Duration(0), nil)
+		mockRepo.On("GetUser", mock.Anything, repository.GetUserInput{Email: &email}).Return(&repository.UserModel{ID: 111, Email: &email, Password: "hash", SaltKey: "salt"}, nil)
+		mockPwd.On("CreateSalt").Return("salt")
+		mockPwd.On("GenerateHash", mock.Anything, mock.Anything).Return("dummy", 1, nil)
+		mockPwd.On("VerifyPassword", "@Python12345@", "dummy", "", 1).Return(false).Once()
+		mockPhoneThrottle.On("Fail", mock.Anything, "email:a@example.com").Return(time.Duration(0), nil).Once()
 
-		s := &handler.Server{Jwt: mockJwt, Repository: mockRepo}
-		err := s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{
-			Authorization: "some-token",
-		})
+		s := &handler.Server{Repository: mockRepo, Pwd: mockPwd, PhoneThrottle: mockPhoneThrottle}
 
+		err := s.PostLogin(c)
 		if assert.NoError(t, err) {
-			assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+			assert.Contains(t, rec.Body.String(), commons.ErrorInvalidCredentials)
 		}
+		mockPwd.AssertExpectations(t)
+		mockPwd.AssertNotCalled(t, "VerifyPassword", "@Python12345@", "hash", "salt", 0)
+		mockPhoneThrottle.AssertExpectations(t)
 	})
 
-	t.Run("Status Forbidden", func(t *testing.T) {
-		mockJwt := new(authMocks.JwtInterface)
+	t.Run("Success Login By Verified Email", func(t *testing.T) {
 		mockRepo := new(mocks.RepositoryInterface)
-		req := httptest.NewRequest(echo.PUT, "/", nil)
-		req.Header.Set("Authorization", "someValidToken")
-		rec := httptest.NewRecorder()
-		c := e.NewContext(req, rec)
-		c.SetPath("/:id")
-		c.SetParamNames("id")
-		c.SetParamValues("1")
+		mockPwd := new(pwdMocks.PasswordManagerInterface)
+		mockJwt := new(authMocks.JwtInterface)
+		mockPhoneThrottle := new(throttleMocks.ThrottlerInterface)
+		c, rec := newEmailRequest(map[string]interface{}{"email": "a@example.com", "password": "@Python12345@"})
 
-		mockJwt.On("ParseToken", mock.Anything).Return(&middleware.JwtParsedPayload{
-			ID:     111,
-			Expire: 111,
+		email, verifiedAt := "a@example.com", time.Now()
+		mockPhoneThrottle.On("Check", mock.Anything, "email:a@example.com").Return(time.Duration(0), nil)
+		mockRepo.On("GetUser", mock.Anything, repository.GetUserInput{Email: &email}).Return(&repository.UserModel{
+			ID: 111, Email: &email, EmailVerifiedAt: &verifiedAt, Password: "hash", SaltKey: "salt",
 		}, nil)
-		mockRepo.On("GetUser", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		mockPwd.On("VerifyPassword", "@Python12345@", "hash", "salt", 0).Return(true)
+		mockPwd.On("NeedsRehash", "hash", 0).Return(false)
+		mockRepo.On("GetMfa", mock.Anything, 111).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+		mockJwt.On("CreateToken", mock.Anything, mock.Anything).Return("ok", nil)
+		mockRepo.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(1, nil)
+		mockPhoneThrottle.On("Reset", mock.Anything, "email:a@example.com").Return(nil).Once()
 
-		s := &handler.Server{Jwt: mockJwt, Repository: mockRepo}
-		err := s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{
-			Authorization: "some-token",
-		})
+		s := &handler.Server{Repository: mockRepo, Pwd: mockPwd, Jwt: mockJwt, PhoneThrottle: mockPhoneThrottle}
 
-		if assert.NoError(t, err) {
-			assert.Equal(t, http.StatusForbidden, rec.Code)
+		if assert.NoError(t, s.PostLogin(c)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
 		}
+		mockPhoneThrottle.AssertExpectations(t)
 	})
 
-	t.Run("Err Body request", func(t *testing.T) {
-		mockJwt := new(authMocks.JwtInterface)
+	t.Run("MFA Enabled Returns Challenge", func(t *testing.T) {
 		mockRepo := new(mocks.RepositoryInterface)
+		mockPwd := new(pwdMocks.PasswordManagerInterface)
+		mockJwt := new(authMocks.JwtInterface)
+		c, rec := newRequest("@Python12345@")
 
-		req := httptest.NewRequest(echo.PATCH, "/", nil)
-		req.Header.Set("Authorization", "someValidToken")
+		confirmedAt := time.Now()
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111, Password: "hash", SaltKey: "salt"}, nil)
+		mockPwd.On("VerifyPassword", "@Python12345@", "hash", "salt", 0).Return(true)
+		mockPwd.On("NeedsRehash", "hash", 0).Return(false)
+		mockRepo.On("GetMfa", mock.Anything, 111).Return(&repository.MfaModel{UserID: 111, ConfirmedAt: &confirmedAt}, nil)
+		mockRepo.On("CreateMfaChallenge", mock.Anything, mock.MatchedBy(func(input repository.MfaChallengeInput) bool {
+			return input.UserID == 111 && len(input.TokenHash) == 64 && input.AccountKey == "phone:+628222667727" && input.ExpiresAt.After(time.Now())
+		})).Return(1, nil).Once()
+		mockPhoneThrottle := new(throttleMocks.ThrottlerInterface)
+		mockPhoneThrottle.On("Check", mock.Anything, "phone:+628222667727").Return(time.Duration(0), nil)
 
-		rec := httptest.NewRecorder()
-		c := e.NewContext(req, rec)
-		c.SetPath("/:id")
-		c.SetParamNames("id")
-		c.SetParamValues("1")
+		s := &handler.Server{Repository: mockRepo, Pwd: mockPwd, Jwt: mockJwt, MfaChallengeTTL: 5 * time.Minute, PhoneThrottle: mockPhoneThrottle}
 
-		mockJwt.On("ParseToken", mock.Anything).Return(&middleware.JwtParsedPayload{
-			ID:     1,
-			Expire: 111,
-		}, nil)
-		mockRepo.On("GetUser", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		err := s.PostLogin(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
 
-		s := &handler.Server{Jwt: mockJwt, Repository: mockRepo}
-		err := s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{
-			Authorization: "some-token",
-		})
+			resp := generated.MfaChallengeResponse{}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.NotEmpty(t, resp.MfaToken)
+			assert.Equal(t, 300, resp.ExpiresIn)
+		}
+		mockRepo.AssertExpectations(t)
+		mockJwt.AssertNotCalled(t, "CreateToken", mock.Anything, mock.Anything)
+		mockPhoneThrottle.AssertNotCalled(t, "Reset", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Outdated Hash Is Upgraded", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockPwd := new(pwdMocks.PasswordManagerInterface)
+		mockJwt := new(authMocks.JwtInterface)
+
+		reqBody := map[string]interface{}{"PhoneNumber": "+628222667727", "password": "@Python12345@"}
+		reqBodyBytes, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{
+			ID:       111,
+			Password: "$2a$16$legacy",
+			SaltKey:  "old-salt",
+		}, nil)
+		mockPwd.On("VerifyPassword", "@Python12345@", "$2a$16$legacy", "old-salt", 0).Return(true)
+		mockPwd.On("NeedsRehash", "$2a$16$legacy", 0).Return(true)
+		mockPwd.On("CreateSalt").Return("new-salt")
+		mockPwd.On("GenerateHash", "@Python12345@", "new-salt").Return("$argon2id$new", 1, nil)
+		mockRepo.On("UpdatePassword", mock.Anything, repository.UserInput{ID: 111, Password: "$argon2id$new", SaltKey: "new-salt", PepperVersion: 1}).Return(nil).Once()
+		mockRepo.On("GetMfa", mock.Anything, 111).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+		mockJwt.On("CreateToken", mock.Anything, mock.Anything).Return("ok", nil)
+		mockRepo.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(1, nil)
+		s := &handler.Server{Repository: mockRepo, Pwd: mockPwd, Jwt: mockJwt, AccessTokenTTL: 15 * time.Minute}
+
+		err := s.PostLogin(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestPostTokenRefresh(t *testing.T) {
+	e := echo.New()
+
+	newRequest := func(refreshToken string) (echo.Context, *httptest.ResponseRecorder) {
+		reqBodyBytes, _ := json.Marshal(map[string]interface{}{"refreshToken": refreshToken})
+		req := httptest.NewRequest(http.MethodPost, "/token/refresh", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		return e.NewContext(req, rec), rec
+	}
+
+	t.Run("Bad Request - Missing Token", func(t *testing.T) {
+		c, rec := newRequest("")
+		s := &handler.Server{Repository: new(mocks.RepositoryInterface)}
 
+		err := s.PostTokenRefresh(c)
 		if assert.NoError(t, err) {
 			assert.Equal(t, http.StatusBadRequest, rec.Code)
 		}
 	})
 
-	t.Run("Get user phone number duplicate", func(t *testing.T) {
+	t.Run("Unknown Token", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest("unknown")
+
+		mockRepo.On("GetRefreshToken", mock.Anything, commons.HashToken("unknown")).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+
+		s := &handler.Server{Repository: mockRepo}
+		err := s.PostTokenRefresh(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		}
+	})
+
+	t.Run("Expired Token", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest("expired")
+
+		mockRepo.On("GetRefreshToken", mock.Anything, commons.HashToken("expired")).Return(&repository.RefreshTokenModel{
+			ID:        1,
+			UserID:    111,
+			FamilyID:  "family",
+			ExpiresAt: time.Now().Add(-time.Minute),
+		}, nil)
+
+		s := &handler.Server{Repository: mockRepo}
+		err := s.PostTokenRefresh(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		}
+		mockRepo.AssertNotCalled(t, "RevokeRefreshToken", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Reused Token Revokes Family", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest("reused")
+
+		revokedAt := time.Now().Add(-time.Minute)
+		mockRepo.On("GetRefreshToken", mock.Anything, commons.HashToken("reused")).Return(&repository.RefreshTokenModel{
+			ID:        1,
+			UserID:    111,
+			FamilyID:  "family",
+			ExpiresAt: time.Now().Add(time.Hour),
+			RevokedAt: &revokedAt,
+		}, nil)
+		mockRepo.On("RevokeRefreshTokenFamily", mock.Anything, "family").Return(nil).Once()
+
+		s := &handler.Server{Repository: mockRepo}
+		err := s.PostTokenRefresh(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Concurrent Rotation Revokes Family", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest("raced")
+
+		mockRepo.On("GetRefreshToken", mock.Anything, commons.HashToken("raced")).Return(&repository.RefreshTokenModel{
+			ID:        1,
+			UserID:    111,
+			FamilyID:  "family",
+			ExpiresAt: time.Now().Add(time.Hour),
+		}, nil)
+		mockRepo.On("RevokeRefreshToken", mock.Anything, 1).Return(apperrors.NotFound(commons.ErrorNoData))
+		mockRepo.On("RevokeRefreshTokenFamily", mock.Anything, "family").Return(nil).Once()
+
+		s := &handler.Server{Repository: mockRepo}
+		err := s.PostTokenRefresh(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Success Rotation", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
 		mockJwt := new(authMocks.JwtInterface)
+		c, rec := newRequest("valid")
+
+		mockRepo.On("GetRefreshToken", mock.Anything, commons.HashToken("valid")).Return(&repository.RefreshTokenModel{
+			ID:        1,
+			UserID:    111,
+			FamilyID:  "family",
+			ExpiresAt: time.Now().Add(time.Hour),
+		}, nil)
+		mockRepo.On("RevokeRefreshToken", mock.Anything, 1).Return(nil).Once()
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111, TokenVersion: 2}, nil)
+		mockRepo.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(func(input repository.RefreshTokenInput) bool {
+			return input.UserID == 111 && input.FamilyID == "family" && input.TokenHash != commons.HashToken("valid")
+		})).Return(2, nil).Once()
+		mockJwt.On("CreateToken", middleware.UserJwtPayload{ID: 111, TokenVersion: 2}, 15*time.Minute).Return("new-jwt", nil)
+
+		s := &handler.Server{Repository: mockRepo, Jwt: mockJwt, AccessTokenTTL: 15 * time.Minute, RefreshTokenTTL: time.Hour}
+		err := s.PostTokenRefresh(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			resp := generated.LoginResponse{}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, 111, resp.UserId)
+			assert.Equal(t, "new-jwt", resp.Jwt)
+			assert.NotEqual(t, "valid", resp.RefreshToken)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestGetUserId(t *testing.T) {
+	e := echo.New()
+
+	newRequest := func() (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodGet, "/user/1", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/user/:id")
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		return c, rec
+	}
+
+	t.Run("Missing Principal", func(t *testing.T) {
+		c, rec := newRequest()
+
+		s := &handler.Server{}
+
+		err := s.GetUserId(c, 1, generated.GetUserIdParams{})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		}
+	})
+
+	t.Run("Forbidden", func(t *testing.T) {
+		c, rec := newRequest()
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 111})
+
+		s := &handler.Server{}
+
+		err := s.GetUserId(c, 1, generated.GetUserIdParams{})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		}
+	})
+
+	t.Run("User Not Found", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest()
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 1})
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+
+		s := &handler.Server{Repository: mockRepo}
+
+		err := s.GetUserId(c, 1, generated.GetUserIdParams{})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+			assert.Contains(t, rec.Body.String(), commons.ErrorUserNotFound)
+		}
+	})
+
+	t.Run("FetchUserById err", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest()
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 1})
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(nil, errors.New("connection refused"))
+
+		s := &handler.Server{Repository: mockRepo}
+
+		err := s.GetUserId(c, 1, generated.GetUserIdParams{})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			assert.Contains(t, rec.Body.String(), commons.ErrSystemError)
+			assert.NotContains(t, rec.Body.String(), "connection refused")
+		}
+	})
+
+	t.Run("Success GetUserId ", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest()
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 1})
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{
+			ID:          111,
+			PhoneNumber: "111",
+			FullName:    "111",
+			Password:    "111",
+			SaltKey:     "111",
+			CreatedAt:   time.Time{},
+			UpdatedAt:   time.Time{},
+		}, nil)
+
+		s := &handler.Server{Repository: mockRepo}
+
+		err := s.GetUserId(c, 1, generated.GetUserIdParams{})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+	})
+
+	t.Run("Not Modified", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 1, Version: 4}, nil)
+		s := &handler.Server{Repository: mockRepo}
+
+		c, rec := newRequest()
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 1})
+		if assert.NoError(t, s.GetUserId(c, 1, generated.GetUserIdParams{})) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, `"4"`, rec.Header().Get(handler.HeaderETag))
+		}
+
+		ifNoneMatch := `"3", W/"4"`
+		c, rec = newRequest()
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 1})
+		if assert.NoError(t, s.GetUserId(c, 1, generated.GetUserIdParams{IfNoneMatch: &ifNoneMatch})) {
+			assert.Equal(t, http.StatusNotModified, rec.Code)
+			assert.Empty(t, rec.Body.String())
+			assert.Equal(t, `"4"`, rec.Header().Get(handler.HeaderETag))
+		}
+
+		stale := `"3"`
+		c, rec = newRequest()
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 1})
+		if assert.NoError(t, s.GetUserId(c, 1, generated.GetUserIdParams{IfNoneMatch: &stale})) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+	})
+}
+
+func TestPatchUserIdEdit(t *testing.T) {
+
+	e := echo.New()
+
+	t.Run("Missing Principal", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		req := httptest.NewRequest(echo.PATCH, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/:id")
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		s := &handler.Server{Repository: mockRepo}
+		err := s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		}
+		mockRepo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Status Forbidden", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		req := httptest.NewRequest(echo.PATCH, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/:id")
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 111})
+
+		s := &handler.Server{Repository: mockRepo}
+		err := s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		}
+	})
+
+	t.Run("Err Body request", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+
+		req := httptest.NewRequest(echo.PATCH, "/", nil)
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/:id")
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 1})
+
+		s := &handler.Server{Repository: mockRepo}
+		err := s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Get user phone number duplicate", func(t *testing.T) {
 		mockRepo := new(mocks.RepositoryInterface)
 
 		reqBody := map[string]interface{}{"PhoneNumber": "+628222667727", "fullName": "LOLTOS", "password": "@Python12345@"}
@@ -419,7 +1056,6 @@ func TestPatchUserIdEdit(t *testing.T) {
 		bodyBytes, _ := json.Marshal(reqBody)
 
 		req := httptest.NewRequest(echo.PATCH, "/", bytes.NewBuffer(bodyBytes))
-		req.Header.Set("Authorization", "someValidToken")
 		req.Header.Set("Content-Type", "application/json")
 
 		rec := httptest.NewRecorder()
@@ -427,12 +1063,10 @@ func TestPatchUserIdEdit(t *testing.T) {
 		c.SetPath("/:id")
 		c.SetParamNames("id")
 		c.SetParamValues("1")
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 1})
 
-		mockJwt.On("ParseToken", mock.Anything).Return(&middleware.JwtParsedPayload{
-			ID:     1,
-			Expire: 111,
-		}, nil)
-
+		mockRepo.On("GetUser", mock.Anything, mock.MatchedBy(func(input repository.GetUserInput) bool { return input.ID != nil })).
+			Return(&repository.UserModel{ID: 1, PhoneNumber: "+628111111111", FullName: "111"}, nil)
 		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{
 			ID:          111,
 			PhoneNumber: "111",
@@ -442,18 +1076,16 @@ func TestPatchUserIdEdit(t *testing.T) {
 			CreatedAt:   time.Time{},
 			UpdatedAt:   time.Time{},
 		}, nil)
-		s := &handler.Server{Jwt: mockJwt, Repository: mockRepo}
-		err := s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{
-			Authorization: "some-token",
-		})
+
+		s := &handler.Server{Repository: mockRepo}
+		err := s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{})
 
 		if assert.NoError(t, err) {
-			assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			assert.Equal(t, http.StatusConflict, rec.Code)
 		}
 	})
 
 	t.Run("Success Update User", func(t *testing.T) {
-		mockJwt := new(authMocks.JwtInterface)
 		mockRepo := new(mocks.RepositoryInterface)
 
 		reqBody := map[string]interface{}{"PhoneNumber": "+628222667727", "fullName": "LOLTOS", "password": "@Python12345@"}
@@ -461,7 +1093,6 @@ func TestPatchUserIdEdit(t *testing.T) {
 		bodyBytes, _ := json.Marshal(reqBody)
 
 		req := httptest.NewRequest(echo.PATCH, "/", bytes.NewBuffer(bodyBytes))
-		req.Header.Set("Authorization", "someValidToken")
 		req.Header.Set("Content-Type", "application/json")
 
 		rec := httptest.NewRecorder()
@@ -469,30 +1100,2030 @@ func TestPatchUserIdEdit(t *testing.T) {
 		c.SetPath("/:id")
 		c.SetParamNames("id")
 		c.SetParamValues("1")
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 1})
 
-		mockJwt.On("ParseToken", mock.Anything).Return(&middleware.JwtParsedPayload{
-			ID:     1,
-			Expire: 111,
-		}, nil)
-
-		mockRepo.On("UpdateUser", mock.Anything, mock.Anything).Return(nil)
+		mockRepo.On("UpdateUser", mock.Anything, mock.Anything).Return(2, nil)
 		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{
 			ID:          1,
-			PhoneNumber: "111",
+			PhoneNumber: "+628222667727",
 			FullName:    "111",
 			Password:    "111",
 			SaltKey:     "111",
 			CreatedAt:   time.Time{},
 			UpdatedAt:   time.Time{},
 		}, nil)
-		s := &handler.Server{Jwt: mockJwt, Repository: mockRepo}
-		err := s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{
-			Authorization: "some-token",
-		})
+
+		s := &handler.Server{Repository: mockRepo}
+		err := s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{})
 
 		if assert.NoError(t, err) {
-			assert.Equal(t, http.StatusNoContent, rec.Code)
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			resp := generated.UserResponse{}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "LOLTOS", *resp.FullName)
+			assert.Equal(t, "+628222667727", *resp.PhoneNumber)
+		}
+	})
+
+	newEmailChange := func(email string) (echo.Context, *httptest.ResponseRecorder) {
+		bodyBytes, _ := json.Marshal(map[string]interface{}{"phoneNumber": "+628222667727", "fullName": "LOLTOS", "email": email})
+		req := httptest.NewRequest(echo.PATCH, "/", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 1})
+		return c, rec
+	}
+
+	t.Run("Email Taken", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newEmailChange("taken@example.com")
+
+		email := "taken@example.com"
+		mockRepo.On("GetUser", mock.Anything, mock.MatchedBy(func(input repository.GetUserInput) bool { return input.ID != nil })).
+			Return(&repository.UserModel{ID: 1, PhoneNumber: "+628222667727"}, nil)
+		mockRepo.On("GetUser", mock.Anything, repository.GetUserInput{Email: &email}).Return(&repository.UserModel{ID: 2}, nil)
+
+		s := &handler.Server{Repository: mockRepo}
+		if assert.NoError(t, s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{})) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+			assert.Contains(t, rec.Body.String(), commons.ErrEmailExists)
+		}
+		mockRepo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything)
+	})
+
+	t.Run("New Email Saved Unverified", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockMailer := new(mailerMocks.MailerInterface)
+		c, rec := newEmailChange("New@Example.com")
+
+		current, verifiedAt := "old@example.com", time.Now()
+		email, fullName := "new@example.com", "LOLTOS"
+		mockRepo.On("GetUser", mock.Anything, mock.MatchedBy(func(input repository.GetUserInput) bool { return input.ID != nil })).
+			Return(&repository.UserModel{ID: 1, PhoneNumber: "+628222667727", Email: &current, EmailVerifiedAt: &verifiedAt}, nil)
+		mockRepo.On("GetUser", mock.Anything, repository.GetUserInput{Email: &email}).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+		mockRepo.On("UpdateUser", mock.Anything, repository.UpdateUserInput{ID: 1, FullName: &fullName, Email: &email}).Return(2, nil).Once()
+		mockMailer.On("Send", mock.Anything, mock.MatchedBy(func(mail mailer.Mail) bool {
+			return mail.To == "new@example.com"
+		})).Return(nil).Once()
+
+		s := &handler.Server{Repository: mockRepo, Mailer: mockMailer, EmailTokens: testSigner(t), EmailVerificationURL: "https://example.com/email/verify"}
+		if assert.NoError(t, s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{})) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			resp := generated.UserResponse{}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "new@example.com", *resp.Email)
+			assert.False(t, *resp.EmailVerified)
 		}
+		mockRepo.AssertExpectations(t)
+		mockMailer.AssertExpectations(t)
 	})
 
+	newPhoneChange := func() (echo.Context, *httptest.ResponseRecorder) {
+		bodyBytes, _ := json.Marshal(map[string]interface{}{"phoneNumber": "+628999999999", "fullName": "LOLTOS"})
+		req := httptest.NewRequest(echo.PATCH, "/", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 1})
+		return c, rec
+	}
+	currentUser := &repository.UserModel{ID: 1, PhoneNumber: "+628222667727"}
+	isNewPhone := func(input repository.GetUserInput) bool {
+		return input.PhoneNumber != nil && *input.PhoneNumber == "+628999999999"
+	}
+
+	t.Run("Phone Change Waits For Verification", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockNotifier := new(notifierMocks.NotifierInterface)
+		c, rec := newPhoneChange()
+
+		mockRepo.On("GetUser", mock.Anything, mock.MatchedBy(isNewPhone)).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(currentUser, nil)
+		mockRepo.On("GetActiveOtpCode", mock.Anything, 1, handler.OtpPurposePhoneVerification).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+		mockRepo.On("CreateOtpCode", mock.Anything, mock.MatchedBy(func(input repository.OtpCodeInput) bool {
+			return input.PhoneNumber == "+628999999999"
+		})).Return(1, nil).Once()
+		mockNotifier.On("Send", mock.Anything, mock.MatchedBy(func(message notifier.Message) bool {
+			return message.Recipient == "+628999999999"
+		})).Return(nil).Once()
+		fullName := "LOLTOS"
+		mockRepo.On("UpdateUser", mock.Anything, repository.UpdateUserInput{ID: 1, FullName: &fullName}).Return(2, nil).Once()
+
+		s := &handler.Server{Repository: mockRepo, Notifier: mockNotifier, OtpTTL: 10 * time.Minute}
+		err := s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+
+			resp := generated.PhoneVerificationResponse{}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "+628999999999", resp.PhoneNumber)
+			assert.Equal(t, 600, resp.ExpiresIn)
+		}
+		mockRepo.AssertExpectations(t)
+		mockNotifier.AssertExpectations(t)
+	})
+
+	t.Run("Phone Change Cooldown", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newPhoneChange()
+
+		mockRepo.On("GetUser", mock.Anything, mock.MatchedBy(isNewPhone)).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(currentUser, nil)
+		mockRepo.On("GetActiveOtpCode", mock.Anything, 1, handler.OtpPurposePhoneVerification).Return(&repository.OtpCodeModel{
+			ID: 5, UserID: 1, CreatedAt: time.Now().Add(-20 * time.Second),
+		}, nil)
+
+		s := &handler.Server{Repository: mockRepo, OtpResendCooldown: time.Minute}
+		err := s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+			assert.Equal(t, "40", rec.Header().Get("Retry-After"))
+		}
+		mockRepo.AssertNotCalled(t, "CreateOtpCode", mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything)
+	})
+
+	newPatch := func(contentType, body string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(echo.PATCH, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 1})
+		return c, rec
+	}
+	email := "a@example.com"
+	patchedUser := func() *repository.UserModel {
+		// a full name stored before the length rule, only validated when it is changed
+		return &repository.UserModel{ID: 1, PhoneNumber: "+628222667727", FullName: "Al", Email: &email}
+	}
+
+	t.Run("Unsupported Media Type", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newPatch("text/plain", "fullName=LOLTOS")
+
+		s := &handler.Server{Repository: mockRepo}
+
+		if assert.NoError(t, s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{})) {
+			assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+		}
+		mockRepo.AssertNotCalled(t, "GetUser", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Only Supplied Fields Are Written", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newPatch(echo.MIMEApplicationJSON, `{"email":"a@example.com"}`)
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(patchedUser(), nil).Once()
+
+		s := &handler.Server{Repository: mockRepo}
+
+		if assert.NoError(t, s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{})) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+		mockRepo.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Supplied Fields Are Validated", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newPatch(handler.MIMEMergePatch, `{"fullName":"Bo"}`)
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(patchedUser(), nil)
+
+		s := &handler.Server{Repository: mockRepo}
+
+		if assert.NoError(t, s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{})) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+		mockRepo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Merge Patch Removes Email", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newPatch(handler.MIMEMergePatch, `{"email":null,"fullName":"Alice"}`)
+
+		fullName := "Alice"
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(patchedUser(), nil)
+		mockRepo.On("UpdateUser", mock.Anything, repository.UpdateUserInput{ID: 1, FullName: &fullName, RemoveEmail: true}).Return(2, nil).Once()
+
+		s := &handler.Server{Repository: mockRepo}
+
+		if assert.NoError(t, s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{})) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			resp := generated.UserResponse{}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "Alice", *resp.FullName)
+			assert.Nil(t, resp.Email)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("JSON Patch", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newPatch(handler.MIMEJSONPatch, `[
+			{"op":"test","path":"/fullName","value":"Al"},
+			{"op":"replace","path":"/fullName","value":"Alice"}
+		]`)
+
+		fullName := "Alice"
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(patchedUser(), nil)
+		mockRepo.On("UpdateUser", mock.Anything, repository.UpdateUserInput{ID: 1, FullName: &fullName}).Return(2, nil).Once()
+
+		s := &handler.Server{Repository: mockRepo}
+
+		if assert.NoError(t, s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{})) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("JSON Patch Test Failed", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newPatch(handler.MIMEJSONPatch, `[
+			{"op":"test","path":"/fullName","value":"Bob"},
+			{"op":"replace","path":"/fullName","value":"Alice"}
+		]`)
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(patchedUser(), nil)
+
+		s := &handler.Server{Repository: mockRepo}
+
+		if assert.NoError(t, s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{})) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+			assert.Contains(t, rec.Body.String(), commons.ErrorPatchTestFailed)
+		}
+		mockRepo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Full Name Cannot Be Removed", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newPatch(handler.MIMEJSONPatch, `[{"op":"remove","path":"/fullName"}]`)
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(patchedUser(), nil)
+
+		s := &handler.Server{Repository: mockRepo}
+
+		if assert.NoError(t, s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{})) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+		mockRepo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything)
+	})
+
+	t.Run("If-Match Stale", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newPatch(handler.MIMEMergePatch, `{"fullName":"Alice"}`)
+
+		user := patchedUser()
+		user.Version = 5
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(user, nil)
+
+		s := &handler.Server{Repository: mockRepo}
+
+		ifMatch := `"4"`
+		if assert.NoError(t, s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{IfMatch: &ifMatch})) {
+			assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+			assert.Equal(t, `"5"`, rec.Header().Get(handler.HeaderETag))
+		}
+		mockRepo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything)
+	})
+
+	t.Run("If-Match Current", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newPatch(handler.MIMEMergePatch, `{"fullName":"Alice"}`)
+
+		fullName := "Alice"
+		user := patchedUser()
+		user.Version = 5
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(user, nil)
+		mockRepo.On("UpdateUser", mock.Anything, repository.UpdateUserInput{ID: 1, Version: 5, FullName: &fullName}).Return(6, nil).Once()
+
+		s := &handler.Server{Repository: mockRepo}
+
+		ifMatch := `"5"`
+		if assert.NoError(t, s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{IfMatch: &ifMatch})) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, `"6"`, rec.Header().Get(handler.HeaderETag))
+		}
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Changed Between Read And Write", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(patchedUser(), nil)
+		mockRepo.On("UpdateUser", mock.Anything, mock.Anything).Return(0, repository.ErrVersionConflict)
+
+		s := &handler.Server{Repository: mockRepo}
+
+		ifMatch := `"0"`
+		c, rec := newPatch(handler.MIMEMergePatch, `{"fullName":"Alice"}`)
+		if assert.NoError(t, s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{IfMatch: &ifMatch})) {
+			assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+		}
+
+		c, rec = newPatch(handler.MIMEMergePatch, `{"fullName":"Alice"}`)
+		if assert.NoError(t, s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{})) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+			assert.Contains(t, rec.Body.String(), commons.ErrorVersionConflict)
+		}
+	})
+
+	t.Run("Phone Changed Between Read And Write", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockNotifier := new(notifierMocks.NotifierInterface)
+		c, rec := newPatch(handler.MIMEMergePatch, `{"phoneNumber":"+628999999999"}`)
+
+		user := patchedUser()
+		user.Version = 5
+		mockRepo.On("GetUser", mock.Anything, mock.MatchedBy(isNewPhone)).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(user, nil)
+		mockRepo.On("GetActiveOtpCode", mock.Anything, 1, handler.OtpPurposePhoneVerification).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+		// another request moved the user to version 6 after the read
+		mockRepo.On("UpdateUser", mock.Anything, repository.UpdateUserInput{ID: 1, Version: 5}).Return(0, repository.ErrVersionConflict).Once()
+
+		s := &handler.Server{Repository: mockRepo, Notifier: mockNotifier, OtpTTL: 10 * time.Minute}
+
+		ifMatch := `"5"`
+		if assert.NoError(t, s.PatchUserIdEdit(c, 1, generated.PatchUserIdEditParams{IfMatch: &ifMatch})) {
+			assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+		}
+		mockRepo.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "CreateOtpCode", mock.Anything, mock.Anything)
+		mockNotifier.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	})
+}
+
+func TestPostLogout(t *testing.T) {
+	e := echo.New()
+
+	payload := &middleware.JwtParsedPayload{ID: 1, Expire: time.Now().Add(time.Hour).Unix(), TokenID: "jti-1"}
+
+	newRequest := func(body interface{}, authenticated bool) (echo.Context, *httptest.ResponseRecorder) {
+		var reader *bytes.Buffer
+		if body == nil {
+			reader = bytes.NewBuffer(nil)
+		} else {
+			reqBodyBytes, _ := json.Marshal(body)
+			reader = bytes.NewBuffer(reqBodyBytes)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/logout", reader)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		if authenticated {
+			middleware.SetPrincipal(c, &middleware.Principal{UserID: payload.ID, Token: payload})
+		}
+		return c, rec
+	}
+
+	t.Run("Missing Principal", func(t *testing.T) {
+		mockRevocation := new(authMocks.RevocationStoreInterface)
+		c, rec := newRequest(nil, false)
+
+		s := &handler.Server{Revocation: mockRevocation}
+		err := s.PostLogout(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		}
+		mockRevocation.AssertNotCalled(t, "Revoke", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Revoke Access Token Only", func(t *testing.T) {
+		mockRevocation := new(authMocks.RevocationStoreInterface)
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest(nil, true)
+
+		mockRevocation.On("Revoke", mock.Anything, payload).Return(nil).Once()
+
+		s := &handler.Server{Revocation: mockRevocation, Repository: mockRepo}
+		err := s.PostLogout(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+		}
+		mockRevocation.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "RevokeRefreshTokenFamily", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Revoke Refresh Token Family", func(t *testing.T) {
+		mockRevocation := new(authMocks.RevocationStoreInterface)
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest(map[string]interface{}{"refreshToken": "refresh"}, true)
+
+		mockRevocation.On("Revoke", mock.Anything, payload).Return(nil).Once()
+		mockRepo.On("GetRefreshToken", mock.Anything, commons.HashToken("refresh")).Return(&repository.RefreshTokenModel{
+			ID:       5,
+			UserID:   1,
+			FamilyID: "family",
+		}, nil)
+		mockRepo.On("RevokeRefreshTokenFamily", mock.Anything, "family").Return(nil).Once()
+
+		s := &handler.Server{Revocation: mockRevocation, Repository: mockRepo}
+		err := s.PostLogout(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Ignore Refresh Token Of Another User", func(t *testing.T) {
+		mockRevocation := new(authMocks.RevocationStoreInterface)
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest(map[string]interface{}{"refreshToken": "refresh"}, true)
+
+		mockRevocation.On("Revoke", mock.Anything, payload).Return(nil).Once()
+		mockRepo.On("GetRefreshToken", mock.Anything, commons.HashToken("refresh")).Return(&repository.RefreshTokenModel{
+			ID:       5,
+			UserID:   2,
+			FamilyID: "family",
+		}, nil)
+
+		s := &handler.Server{Revocation: mockRevocation, Repository: mockRepo}
+		err := s.PostLogout(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+		}
+		mockRepo.AssertNotCalled(t, "RevokeRefreshTokenFamily", mock.Anything, mock.Anything)
+	})
+}
+
+func TestPostLogoutAll(t *testing.T) {
+	e := echo.New()
+
+	t.Run("Success", func(t *testing.T) {
+		mockRevocation := new(authMocks.RevocationStoreInterface)
+		mockRepo := new(mocks.RepositoryInterface)
+
+		req := httptest.NewRequest(http.MethodPost, "/logout-all", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 1})
+
+		mockRevocation.On("RevokeAll", mock.Anything, 1).Return(nil).Once()
+		mockRepo.On("RevokeUserRefreshTokens", mock.Anything, 1).Return(nil).Once()
+
+		s := &handler.Server{Revocation: mockRevocation, Repository: mockRepo}
+		err := s.PostLogoutAll(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+		}
+		mockRevocation.AssertExpectations(t)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestPostPasswordForgot(t *testing.T) {
+	e := echo.New()
+
+	newRequest := func(phoneNumber string) (echo.Context, *httptest.ResponseRecorder) {
+		reqBodyBytes, _ := json.Marshal(map[string]interface{}{"phoneNumber": phoneNumber})
+		req := httptest.NewRequest(http.MethodPost, "/password/forgot", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		return e.NewContext(req, rec), rec
+	}
+
+	t.Run("Bad Request - Invalid Phone Number", func(t *testing.T) {
+		c, rec := newRequest("123")
+		s := &handler.Server{}
+
+		err := s.PostPasswordForgot(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Unknown Phone Number", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockNotifier := new(notifierMocks.NotifierInterface)
+		c, rec := newRequest("+628123456789")
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+
+		s := &handler.Server{Repository: mockRepo, Notifier: mockNotifier}
+		err := s.PostPasswordForgot(c)
+		s.WaitBackground()
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+			assert.Contains(t, rec.Body.String(), commons.MessagePasswordResetRequested)
+		}
+		mockRepo.AssertNotCalled(t, "CreatePasswordResetCode", mock.Anything, mock.Anything)
+		mockNotifier.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Code Sent", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockNotifier := new(notifierMocks.NotifierInterface)
+		c, rec := newRequest("+628123456789")
+
+		var stored repository.PasswordResetCodeInput
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111, PhoneNumber: "+628123456789"}, nil)
+		mockRepo.On("CreatePasswordResetCode", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			stored = args.Get(1).(repository.PasswordResetCodeInput)
+		}).Return(1, nil)
+
+		var sent notifier.Message
+		mockNotifier.On("Send", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			sent = args.Get(1).(notifier.Message)
+		}).Return(nil)
+
+		s := &handler.Server{Repository: mockRepo, Notifier: mockNotifier, PasswordResetTTL: 15 * time.Minute}
+		err := s.PostPasswordForgot(c)
+		s.WaitBackground()
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+			assert.Contains(t, rec.Body.String(), commons.MessagePasswordResetRequested)
+		}
+		assert.Equal(t, "+628123456789", sent.Recipient)
+		assert.Equal(t, 111, stored.UserID)
+		assert.WithinDuration(t, time.Now().Add(15*time.Minute), stored.ExpiresAt, time.Minute)
+
+		code := regexp.MustCompile(`\d{6}`).FindString(sent.Body)
+		assert.Equal(t, commons.HashToken("111:"+code), stored.CodeHash)
+		assert.NotContains(t, stored.CodeHash, code)
+	})
+
+	newEmailRequest := func(email string) (echo.Context, *httptest.ResponseRecorder) {
+		reqBodyBytes, _ := json.Marshal(map[string]interface{}{"email": email})
+		req := httptest.NewRequest(http.MethodPost, "/password/forgot", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		return e.NewContext(req, rec), rec
+	}
+
+	t.Run("Unverified Email Is Ignored", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockMailer := new(mailerMocks.MailerInterface)
+		c, rec := newEmailRequest("a@example.com")
+
+		email := "a@example.com"
+		mockRepo.On("GetUser", mock.Anything, repository.GetUserInput{Email: &email}).Return(&repository.UserModel{ID: 111, Email: &email}, nil)
+
+		s := &handler.Server{Repository: mockRepo, Mailer: mockMailer}
+		err := s.PostPasswordForgot(c)
+		s.WaitBackground()
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+		}
+		mockRepo.AssertNotCalled(t, "CreatePasswordResetCode", mock.Anything, mock.Anything)
+		mockMailer.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Code Mailed To Verified Email", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockNotifier := new(notifierMocks.NotifierInterface)
+		mockMailer := new(mailerMocks.MailerInterface)
+		c, _ := newEmailRequest("A@Example.com")
+
+		email, verifiedAt := "a@example.com", time.Now()
+		mockRepo.On("GetUser", mock.Anything, repository.GetUserInput{Email: &email}).Return(&repository.UserModel{ID: 111, Email: &email, EmailVerifiedAt: &verifiedAt}, nil)
+		mockRepo.On("CreatePasswordResetCode", mock.Anything, mock.Anything).Return(1, nil).Once()
+		mockMailer.On("Send", mock.Anything, mock.MatchedBy(func(mail mailer.Mail) bool {
+			return mail.To == "a@example.com" && regexp.MustCompile(`\d{6}`).MatchString(mail.Body)
+		})).Return(nil).Once()
+
+		s := &handler.Server{Repository: mockRepo, Notifier: mockNotifier, Mailer: mockMailer, PasswordResetTTL: 15 * time.Minute}
+		assert.NoError(t, s.PostPasswordForgot(c))
+		s.WaitBackground()
+
+		mockRepo.AssertExpectations(t)
+		mockMailer.AssertExpectations(t)
+		mockNotifier.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	})
+}
+
+func TestPostPasswordReset(t *testing.T) {
+	e := echo.New()
+
+	user := &repository.UserModel{ID: 111, PhoneNumber: "+628123456789"}
+	codeHash := commons.HashToken("111:123456")
+
+	newRequest := func(code, password string) (echo.Context, *httptest.ResponseRecorder) {
+		reqBodyBytes, _ := json.Marshal(map[string]interface{}{"phoneNumber": "+628123456789", "code": code, "password": password})
+		req := httptest.NewRequest(http.MethodPost, "/password/reset", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		return e.NewContext(req, rec), rec
+	}
+
+	activeCode := func(attempts int, expiresAt time.Time) *repository.PasswordResetCodeModel {
+		return &repository.PasswordResetCodeModel{ID: 5, UserID: 111, CodeHash: codeHash, Attempts: attempts, ExpiresAt: expiresAt}
+	}
+
+	assertInvalidCode := func(t *testing.T, rec *httptest.ResponseRecorder, err error) {
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), commons.ErrorInvalidResetCode)
+		}
+	}
+
+	t.Run("Bad Request - Weak Password", func(t *testing.T) {
+		c, rec := newRequest("123456", "password")
+		s := &handler.Server{}
+
+		err := s.PostPasswordReset(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Unknown Phone Number", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest("123456", "@Python12345@")
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+
+		s := &handler.Server{Repository: mockRepo}
+		assertInvalidCode(t, rec, s.PostPasswordReset(c))
+	})
+
+	t.Run("No Active Code", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest("123456", "@Python12345@")
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(user, nil)
+		mockRepo.On("GetActivePasswordResetCode", mock.Anything, 111).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+
+		s := &handler.Server{Repository: mockRepo}
+		assertInvalidCode(t, rec, s.PostPasswordReset(c))
+	})
+
+	t.Run("Wrong Code Counts Attempt", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest("654321", "@Python12345@")
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(user, nil)
+		mockRepo.On("GetActivePasswordResetCode", mock.Anything, 111).Return(activeCode(0, time.Now().Add(time.Minute)), nil)
+		mockRepo.On("IncrementPasswordResetAttempts", mock.Anything, 5).Return(1, nil).Once()
+
+		s := &handler.Server{Repository: mockRepo, PasswordResetMaxAttempts: 5}
+		assertInvalidCode(t, rec, s.PostPasswordReset(c))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Too Many Attempts", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest("123456", "@Python12345@")
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(user, nil)
+		mockRepo.On("GetActivePasswordResetCode", mock.Anything, 111).Return(activeCode(5, time.Now().Add(time.Minute)), nil)
+
+		s := &handler.Server{Repository: mockRepo, PasswordResetMaxAttempts: 5}
+		assertInvalidCode(t, rec, s.PostPasswordReset(c))
+		mockRepo.AssertNotCalled(t, "UsePasswordResetCode", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Expired Code", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest("123456", "@Python12345@")
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(user, nil)
+		mockRepo.On("GetActivePasswordResetCode", mock.Anything, 111).Return(activeCode(0, time.Now().Add(-time.Minute)), nil)
+
+		s := &handler.Server{Repository: mockRepo, PasswordResetMaxAttempts: 5}
+		assertInvalidCode(t, rec, s.PostPasswordReset(c))
+		mockRepo.AssertNotCalled(t, "UsePasswordResetCode", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Code Already Used", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest("123456", "@Python12345@")
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(user, nil)
+		mockRepo.On("GetActivePasswordResetCode", mock.Anything, 111).Return(activeCode(0, time.Now().Add(time.Minute)), nil)
+		mockRepo.On("UsePasswordResetCode", mock.Anything, 5).Return(apperrors.NotFound(commons.ErrorNoData))
+
+		s := &handler.Server{Repository: mockRepo, PasswordResetMaxAttempts: 5}
+		assertInvalidCode(t, rec, s.PostPasswordReset(c))
+		mockRepo.AssertNotCalled(t, "ReplacePassword", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unverified Email", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		reqBodyBytes, _ := json.Marshal(map[string]interface{}{"email": "a@example.com", "code": "123456", "password": "@Python12345@"})
+		req := httptest.NewRequest(http.MethodPost, "/password/reset", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		email := "a@example.com"
+		mockRepo.On("GetUser", mock.Anything, repository.GetUserInput{Email: &email}).Return(&repository.UserModel{ID: 111, Email: &email}, nil)
+
+		s := &handler.Server{Repository: mockRepo}
+		assertInvalidCode(t, rec, s.PostPasswordReset(c))
+		mockRepo.AssertNotCalled(t, "GetActivePasswordResetCode", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Success Revokes Sessions", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockPwd := new(pwdMocks.PasswordManagerInterface)
+		mockRevocation := new(authMocks.RevocationStoreInterface)
+		c, rec := newRequest("123456", "@Python12345@")
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(user, nil)
+		mockRepo.On("GetActivePasswordResetCode", mock.Anything, 111).Return(activeCode(2, time.Now().Add(time.Minute)), nil)
+		mockRepo.On("UsePasswordResetCode", mock.Anything, 5).Return(nil).Once()
+		mockPwd.On("CreateSalt").Return("new-salt")
+		mockPwd.On("GenerateHash", "@Python12345@", "new-salt").Return("new-hash", 1, nil)
+		mockRepo.On("ReplacePassword", mock.Anything, repository.UserInput{ID: 111, Password: "new-hash", SaltKey: "new-salt", PepperVersion: 1}, 0).Return(nil).Once()
+		mockRevocation.On("RevokeAll", mock.Anything, 111).Return(nil).Once()
+		mockRepo.On("RevokeUserRefreshTokens", mock.Anything, 111).Return(nil).Once()
+
+		s := &handler.Server{Repository: mockRepo, Pwd: mockPwd, Revocation: mockRevocation, PasswordResetMaxAttempts: 5}
+		err := s.PostPasswordReset(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+		}
+		mockRepo.AssertExpectations(t)
+		mockRevocation.AssertExpectations(t)
+	})
+}
+
+func TestPatchUserIdPassword(t *testing.T) {
+	e := echo.New()
+
+	user := &repository.UserModel{ID: 1, PhoneNumber: "+628123456789", Password: "current-hash", SaltKey: "current-salt", TokenVersion: 1}
+
+	newRequest := func(principalID int, currentPassword, newPassword string) (echo.Context, *httptest.ResponseRecorder) {
+		reqBodyBytes, _ := json.Marshal(map[string]interface{}{"currentPassword": currentPassword, "newPassword": newPassword})
+		req := httptest.NewRequest(http.MethodPatch, "/user/1/password", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/user/:id/password")
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		if principalID != 0 {
+			middleware.SetPrincipal(c, &middleware.Principal{UserID: principalID})
+		}
+		return c, rec
+	}
+
+	t.Run("Missing Principal", func(t *testing.T) {
+		c, rec := newRequest(0, "@Current123@", "@Python12345@")
+		s := &handler.Server{}
+
+		if assert.NoError(t, s.PatchUserIdPassword(c, 1)) {
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		}
+	})
+
+	t.Run("Forbidden", func(t *testing.T) {
+		c, rec := newRequest(2, "@Current123@", "@Python12345@")
+		s := &handler.Server{}
+
+		if assert.NoError(t, s.PatchUserIdPassword(c, 1)) {
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		}
+	})
+
+	t.Run("Bad Request - Weak Password", func(t *testing.T) {
+		c, rec := newRequest(1, "@Current123@", "password")
+		s := &handler.Server{}
+
+		if assert.NoError(t, s.PatchUserIdPassword(c, 1)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Wrong Current Password", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockPwd := new(pwdMocks.PasswordManagerInterface)
+		c, rec := newRequest(1, "@Wrong12345@", "@Python12345@")
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(user, nil)
+		mockPwd.On("VerifyPassword", "@Wrong12345@", "current-hash", "current-salt", 0).Return(false)
+
+		s := &handler.Server{Repository: mockRepo, Pwd: mockPwd, PasswordHistorySize: 5}
+		if assert.NoError(t, s.PatchUserIdPassword(c, 1)) {
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		}
+		mockRepo.AssertNotCalled(t, "ReplacePassword", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Reuse Current Password", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockPwd := new(pwdMocks.PasswordManagerInterface)
+		c, rec := newRequest(1, "@Current123@", "@Current123@")
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(user, nil)
+		mockPwd.On("VerifyPassword", "@Current123@", "current-hash", "current-salt", 0).Return(true)
+
+		s := &handler.Server{Repository: mockRepo, Pwd: mockPwd, PasswordHistorySize: 5}
+		if assert.NoError(t, s.PatchUserIdPassword(c, 1)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), commons.ErrorPasswordReused)
+		}
+		mockRepo.AssertNotCalled(t, "ReplacePassword", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Reuse Previous Password", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockPwd := new(pwdMocks.PasswordManagerInterface)
+		c, rec := newRequest(1, "@Current123@", "@Python12345@")
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(user, nil)
+		mockRepo.On("GetPasswordHistory", mock.Anything, 1, 4).Return([]repository.PasswordHistoryModel{
+			{ID: 2, UserID: 1, Password: "old-hash-2", SaltKey: "old-salt-2", PepperVersion: 1},
+			{ID: 1, UserID: 1, Password: "old-hash-1", SaltKey: "old-salt-1"},
+		}, nil)
+		mockPwd.On("VerifyPassword", "@Current123@", "current-hash", "current-salt", 0).Return(true)
+		mockPwd.On("VerifyPassword", "@Python12345@", "current-hash", "current-salt", 0).Return(false)
+		mockPwd.On("VerifyPassword", "@Python12345@", "old-hash-2", "old-salt-2", 1).Return(false)
+		mockPwd.On("VerifyPassword", "@Python12345@", "old-hash-1", "old-salt-1", 0).Return(true)
+
+		s := &handler.Server{Repository: mockRepo, Pwd: mockPwd, PasswordHistorySize: 5}
+		if assert.NoError(t, s.PatchUserIdPassword(c, 1)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+		mockRepo.AssertNotCalled(t, "ReplacePassword", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Success Revokes Other Sessions", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockPwd := new(pwdMocks.PasswordManagerInterface)
+		mockJwt := new(authMocks.JwtInterface)
+		mockRevocation := new(authMocks.RevocationStoreInterface)
+		c, rec := newRequest(1, "@Current123@", "@Python12345@")
+
+		updated := *user
+		updated.Password = "new-hash"
+		updated.SaltKey = "new-salt"
+		updated.TokenVersion = 2
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(user, nil).Once()
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&updated, nil).Once()
+		mockRepo.On("GetPasswordHistory", mock.Anything, 1, 4).Return(nil, nil)
+		mockPwd.On("VerifyPassword", "@Current123@", "current-hash", "current-salt", 0).Return(true)
+		mockPwd.On("VerifyPassword", "@Python12345@", "current-hash", "current-salt", 0).Return(false)
+		mockPwd.On("CreateSalt").Return("new-salt")
+		mockPwd.On("GenerateHash", "@Python12345@", "new-salt").Return("new-hash", 2, nil)
+		mockRepo.On("ReplacePassword", mock.Anything, repository.UserInput{ID: 1, Password: "new-hash", SaltKey: "new-salt", PepperVersion: 2}, 4).Return(nil).Once()
+		mockRevocation.On("RevokeAll", mock.Anything, 1).Return(nil).Once()
+		mockRepo.On("RevokeUserRefreshTokens", mock.Anything, 1).Return(nil).Once()
+		mockJwt.On("CreateToken", middleware.UserJwtPayload{ID: 1, TokenVersion: 2}, 15*time.Minute).Return("new-jwt", nil)
+		mockRepo.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(1, nil)
+
+		s := &handler.Server{
+			Repository:          mockRepo,
+			Pwd:                 mockPwd,
+			Jwt:                 mockJwt,
+			Revocation:          mockRevocation,
+			AccessTokenTTL:      15 * time.Minute,
+			PasswordHistorySize: 5,
+		}
+		if assert.NoError(t, s.PatchUserIdPassword(c, 1)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			response := generated.LoginResponse{}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			assert.Equal(t, "new-jwt", response.Jwt)
+			assert.NotEmpty(t, response.RefreshToken)
+		}
+		mockRepo.AssertExpectations(t)
+		mockRevocation.AssertExpectations(t)
+	})
+}
+
+func TestPostAdminUsersIdUnlock(t *testing.T) {
+	e := echo.New()
+
+	newRequest := func() (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodPost, "/admin/users/111/unlock", nil)
+		rec := httptest.NewRecorder()
+		return e.NewContext(req, rec), rec
+	}
+
+	t.Run("User Not Found", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockPhoneThrottle := new(throttleMocks.ThrottlerInterface)
+		c, rec := newRequest()
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+
+		s := &handler.Server{Repository: mockRepo, PhoneThrottle: mockPhoneThrottle}
+
+		if assert.NoError(t, s.PostAdminUsersIdUnlock(c, 111)) {
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+		}
+		mockPhoneThrottle.AssertNotCalled(t, "Reset", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockPhoneThrottle := new(throttleMocks.ThrottlerInterface)
+		c, rec := newRequest()
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111, PhoneNumber: "+628222667727"}, nil)
+		mockPhoneThrottle.On("Reset", mock.Anything, "phone:+628222667727").Return(nil).Once()
+
+		s := &handler.Server{Repository: mockRepo, PhoneThrottle: mockPhoneThrottle}
+
+		if assert.NoError(t, s.PostAdminUsersIdUnlock(c, 111)) {
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+		}
+		mockPhoneThrottle.AssertExpectations(t)
+	})
+}
+
+func TestGetAdminUsers(t *testing.T) {
+	e := echo.New()
+	ctx := context.Background()
+
+	repo := repository.NewMemoryRepository()
+	var ids []int
+	for i, fullName := range []string{"Dani", "Budi", "Ani", "Budi", "Eka"} {
+		id, err := repo.CreateUser(ctx, repository.UserInput{PhoneNumber: fmt.Sprintf("+62811000000%d", i), FullName: fullName})
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	require.NoError(t, repo.VerifyPhoneNumber(ctx, ids[4], "+628110000004"))
+	s := &handler.Server{Repository: repo}
+
+	list := func(params generated.GetAdminUsersParams) (*httptest.ResponseRecorder, generated.UserListResponse) {
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/admin/users", nil), rec)
+		require.NoError(t, s.GetAdminUsers(c, params))
+
+		response := generated.UserListResponse{}
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		}
+		return rec, response
+	}
+	idsOf := func(response generated.UserListResponse) []int {
+		found := []int{}
+		for _, user := range response.Users {
+			found = append(found, user.UserId)
+		}
+		return found
+	}
+
+	t.Run("Pages", func(t *testing.T) {
+		limit, includeTotal := 2, true
+		sort, order := generated.FullName, generated.Desc
+		params := generated.GetAdminUsersParams{Sort: &sort, Order: &order, Limit: &limit, IncludeTotal: &includeTotal}
+
+		var paged []int
+		for page := 0; ; page++ {
+			require.Less(t, page, 5, "the pages end")
+			rec, response := list(params)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			if assert.NotNil(t, response.Total) {
+				assert.Equal(t, 5, *response.Total)
+			}
+			paged = append(paged, idsOf(response)...)
+			if response.NextCursor == nil {
+				break
+			}
+			params.Cursor = response.NextCursor
+		}
+		assert.Equal(t, []int{ids[4], ids[0], ids[3], ids[1], ids[2]}, paged)
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		phonePrefix, name := "+6281100000", "BUD"
+		rec, response := list(generated.GetAdminUsersParams{PhonePrefix: &phonePrefix, Name: &name})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, []int{ids[1], ids[3]}, idsOf(response))
+		assert.Nil(t, response.Total, "no total unless asked")
+		assert.Nil(t, response.NextCursor)
+
+		status := generated.Verified
+		_, response = list(generated.GetAdminUsersParams{Status: &status})
+		assert.Equal(t, []int{ids[4]}, idsOf(response))
+		if assert.Len(t, response.Users, 1) {
+			assert.True(t, response.Users[0].PhoneVerified)
+		}
+
+		createdTo := time.Now().Add(-time.Hour)
+		_, response = list(generated.GetAdminUsersParams{CreatedTo: &createdTo})
+		assert.Empty(t, response.Users)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		zero, tooMany := 0, 101
+		sort, otherSort, order := generated.GetAdminUsersParamsSort("password"), generated.CreatedAt, generated.GetAdminUsersParamsOrder("sideways")
+		status := generated.GetAdminUsersParamsStatus("deleted")
+		garbage := "not a cursor"
+
+		limit := 1
+		_, response := list(generated.GetAdminUsersParams{Limit: &limit})
+		require.NotNil(t, response.NextCursor)
+
+		invalid := map[string]generated.GetAdminUsersParams{
+			"Zero Limit":           {Limit: &zero},
+			"Limit Too High":       {Limit: &tooMany},
+			"Unknown Sort":         {Sort: &sort},
+			"Unknown Order":        {Order: &order},
+			"Unknown Status":       {Status: &status},
+			"Garbage Cursor":       {Cursor: &garbage},
+			"Cursor Of Other Sort": {Sort: &otherSort, Cursor: response.NextCursor},
+		}
+		for name, params := range invalid {
+			rec, _ := list(params)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, name)
+		}
+	})
+}
+
+// testSecretBox encrypts with a fixed key, like MFA_ENCRYPTION_KEYS does
+func testSigner(t *testing.T) *commons.Signer {
+	signer, err := commons.NewSigner(map[int]string{1: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func testSecretBox(t *testing.T) *commons.SecretBox {
+	box, err := commons.NewSecretBox(map[int]string{1: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return box
+}
+
+func TestPostUserIdMfaTotp(t *testing.T) {
+	e := echo.New()
+
+	newRequest := func() (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodPost, "/user/111/mfa/totp", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 111})
+		return c, rec
+	}
+
+	t.Run("Forbidden", func(t *testing.T) {
+		c, rec := newRequest()
+
+		s := &handler.Server{}
+
+		if assert.NoError(t, s.PostUserIdMfaTotp(c, 222)) {
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		}
+	})
+
+	t.Run("Already Enabled", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest()
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111, PhoneNumber: "+628222667727"}, nil)
+		mockRepo.On("SaveMfaSecret", mock.Anything, mock.Anything).Return(apperrors.NotFound(commons.ErrorNoData))
+
+		s := &handler.Server{Repository: mockRepo, Secrets: testSecretBox(t)}
+
+		if assert.NoError(t, s.PostUserIdMfaTotp(c, 111)) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+		}
+	})
+
+	t.Run("Secret Stored Encrypted", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest()
+		box := testSecretBox(t)
+
+		var stored string
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111, PhoneNumber: "+628222667727"}, nil)
+		mockRepo.On("SaveMfaSecret", mock.Anything, mock.MatchedBy(func(input repository.MfaInput) bool {
+			stored = input.Secret
+			return input.UserID == 111
+		})).Return(nil).Once()
+
+		s := &handler.Server{Repository: mockRepo, Secrets: box, MfaIssuer: "UserService"}
+
+		if assert.NoError(t, s.PostUserIdMfaTotp(c, 111)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+			resp := generated.TotpEnrollmentResponse{}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Contains(t, resp.OtpauthUri, "otpauth://totp/UserService:")
+			assert.NotContains(t, stored, resp.Secret)
+
+			decrypted, err := box.Decrypt(stored, "user_mfa:111")
+			assert.NoError(t, err)
+			assert.Equal(t, resp.Secret, decrypted)
+		}
+	})
+}
+
+func TestPostUserIdMfaTotpConfirm(t *testing.T) {
+	e := echo.New()
+	box := testSecretBox(t)
+	secret, _ := commons.GenerateTOTPSecret()
+	encrypted, _ := box.Encrypt(secret, "user_mfa:111")
+
+	newRequest := func(code string) (echo.Context, *httptest.ResponseRecorder) {
+		reqBodyBytes, _ := json.Marshal(map[string]interface{}{"code": code})
+		req := httptest.NewRequest(http.MethodPost, "/user/111/mfa/totp/confirm", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 111})
+		return c, rec
+	}
+
+	t.Run("Not Enrolled", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest("123456")
+
+		mockRepo.On("GetMfa", mock.Anything, 111).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+
+		s := &handler.Server{Repository: mockRepo, Secrets: box}
+
+		if assert.NoError(t, s.PostUserIdMfaTotpConfirm(c, 111)) {
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+		}
+	})
+
+	t.Run("Wrong Code", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		current, _ := commons.TOTPCode(secret, commons.TOTPStep(time.Now())+5)
+		c, rec := newRequest(current)
+
+		mockRepo.On("GetMfa", mock.Anything, 111).Return(&repository.MfaModel{UserID: 111, Secret: encrypted}, nil)
+
+		s := &handler.Server{Repository: mockRepo, Secrets: box}
+
+		if assert.NoError(t, s.PostUserIdMfaTotpConfirm(c, 111)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+		mockRepo.AssertNotCalled(t, "ConfirmMfa", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Success Returns Recovery Codes", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		step := commons.TOTPStep(time.Now())
+		current, _ := commons.TOTPCode(secret, step)
+		c, rec := newRequest(current)
+
+		var hashes []string
+		mockRepo.On("GetMfa", mock.Anything, 111).Return(&repository.MfaModel{UserID: 111, Secret: encrypted}, nil)
+		mockRepo.On("ConfirmMfa", mock.Anything, 111, mock.AnythingOfType("int64"), mock.MatchedBy(func(codeHashes []string) bool {
+			hashes = codeHashes
+			return len(codeHashes) == 3
+		})).Return(nil).Once()
+
+		s := &handler.Server{Repository: mockRepo, Secrets: box, MfaRecoveryCodes: 3}
+
+		if assert.NoError(t, s.PostUserIdMfaTotpConfirm(c, 111)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			resp := generated.RecoveryCodesResponse{}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.Len(t, resp.RecoveryCodes, 3) {
+				assert.NotContains(t, hashes, resp.RecoveryCodes[0], "only hashes are stored")
+			}
+		}
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestPostLoginMfa(t *testing.T) {
+	e := echo.New()
+	box := testSecretBox(t)
+	secret, _ := commons.GenerateTOTPSecret()
+	encrypted, _ := box.Encrypt(secret, "user_mfa:111")
+	confirmedAt := time.Now()
+	enabled := &repository.MfaModel{UserID: 111, Secret: encrypted, ConfirmedAt: &confirmedAt}
+
+	newRequest := func(body map[string]interface{}) (echo.Context, *httptest.ResponseRecorder) {
+		reqBodyBytes, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/login/mfa", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "10.0.0.1:5000"
+		rec := httptest.NewRecorder()
+		return e.NewContext(req, rec), rec
+	}
+	activeChallenge := func() *repository.MfaChallengeModel {
+		return &repository.MfaChallengeModel{ID: 7, UserID: 111, ExpiresAt: time.Now().Add(time.Minute)}
+	}
+	assertStatus := func(t *testing.T, rec *httptest.ResponseRecorder, err error, status int) {
+		if assert.NoError(t, err) {
+			assert.Equal(t, status, rec.Code)
+		}
+	}
+
+	t.Run("Bad Request - Both Codes", func(t *testing.T) {
+		c, rec := newRequest(map[string]interface{}{"mfaToken": "token", "code": "123456", "recoveryCode": "abcde-fghij"})
+
+		s := &handler.Server{}
+
+		assertStatus(t, rec, s.PostLoginMfa(c), http.StatusBadRequest)
+	})
+
+	t.Run("Expired Challenge", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest(map[string]interface{}{"mfaToken": "token", "code": "123456"})
+
+		mockRepo.On("GetMfaChallenge", mock.Anything, commons.HashToken("token")).Return(&repository.MfaChallengeModel{
+			ID: 7, UserID: 111, ExpiresAt: time.Now().Add(-time.Second),
+		}, nil)
+
+		s := &handler.Server{Repository: mockRepo, MfaMaxAttempts: 5}
+
+		assertStatus(t, rec, s.PostLoginMfa(c), http.StatusUnauthorized)
+	})
+
+	t.Run("Exhausted Challenge", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest(map[string]interface{}{"mfaToken": "token", "code": "123456"})
+
+		challenge := activeChallenge()
+		challenge.Attempts = 5
+		mockRepo.On("GetMfaChallenge", mock.Anything, mock.Anything).Return(challenge, nil)
+
+		s := &handler.Server{Repository: mockRepo, MfaMaxAttempts: 5}
+
+		assertStatus(t, rec, s.PostLoginMfa(c), http.StatusUnauthorized)
+		mockRepo.AssertNotCalled(t, "GetUser", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Wrong Code Counts Attempt", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockPhoneThrottle := new(throttleMocks.ThrottlerInterface)
+		wrong, _ := commons.TOTPCode(secret, commons.TOTPStep(time.Now())+5)
+		c, rec := newRequest(map[string]interface{}{"mfaToken": "token", "code": wrong})
+
+		challenge := activeChallenge()
+		challenge.AccountKey = "email:a@example.com"
+		mockRepo.On("GetMfaChallenge", mock.Anything, mock.Anything).Return(challenge, nil)
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111, PhoneNumber: "+628222667727"}, nil)
+		mockRepo.On("GetMfa", mock.Anything, 111).Return(enabled, nil)
+		mockRepo.On("IncrementMfaChallengeAttempts", mock.Anything, 7).Return(1, nil).Once()
+		mockPhoneThrottle.On("Check", mock.Anything, "email:a@example.com").Return(time.Duration(0), nil)
+		mockPhoneThrottle.On("Fail", mock.Anything, "email:a@example.com").Return(time.Duration(0), nil).Once()
+
+		s := &handler.Server{Repository: mockRepo, Secrets: box, MfaMaxAttempts: 5, PhoneThrottle: mockPhoneThrottle}
+
+		assertStatus(t, rec, s.PostLoginMfa(c), http.StatusUnauthorized)
+		mockRepo.AssertExpectations(t)
+		mockPhoneThrottle.AssertExpectations(t)
+	})
+
+	t.Run("Locked Account", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockPhoneThrottle := new(throttleMocks.ThrottlerInterface)
+		current, _ := commons.TOTPCode(secret, commons.TOTPStep(time.Now()))
+		c, rec := newRequest(map[string]interface{}{"mfaToken": "token", "code": current})
+
+		challenge := activeChallenge()
+		challenge.AccountKey = "phone:+628222667727"
+		mockRepo.On("GetMfaChallenge", mock.Anything, mock.Anything).Return(challenge, nil)
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111, PhoneNumber: "+628222667727"}, nil)
+		mockPhoneThrottle.On("Check", mock.Anything, "phone:+628222667727").Return(15*time.Minute, nil)
+
+		s := &handler.Server{Repository: mockRepo, Secrets: box, MfaMaxAttempts: 5, PhoneThrottle: mockPhoneThrottle}
+
+		assertStatus(t, rec, s.PostLoginMfa(c), http.StatusTooManyRequests)
+		assert.Equal(t, "900", rec.Header().Get("Retry-After"))
+		mockRepo.AssertNotCalled(t, "GetMfa", mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "UseMfaChallenge", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Replayed Code", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		current, _ := commons.TOTPCode(secret, commons.TOTPStep(time.Now()))
+		c, rec := newRequest(map[string]interface{}{"mfaToken": "token", "code": current})
+
+		mockRepo.On("GetMfaChallenge", mock.Anything, mock.Anything).Return(activeChallenge(), nil)
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111}, nil)
+		mockRepo.On("GetMfa", mock.Anything, 111).Return(enabled, nil)
+		mockRepo.On("UseMfaStep", mock.Anything, 111, mock.Anything).Return(apperrors.NotFound(commons.ErrorNoData))
+		mockRepo.On("IncrementMfaChallengeAttempts", mock.Anything, 7).Return(1, nil).Once()
+
+		s := &handler.Server{Repository: mockRepo, Secrets: box, MfaMaxAttempts: 5}
+
+		assertStatus(t, rec, s.PostLoginMfa(c), http.StatusUnauthorized)
+		mockRepo.AssertNotCalled(t, "UseMfaChallenge", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Success With Code", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockJwt := new(authMocks.JwtInterface)
+		step := commons.TOTPStep(time.Now())
+		current, _ := commons.TOTPCode(secret, step)
+		c, rec := newRequest(map[string]interface{}{"mfaToken": "token", "code": current})
+
+		challenge := activeChallenge()
+		challenge.AccountKey = "email:a@example.com"
+		mockRepo.On("GetMfaChallenge", mock.Anything, mock.Anything).Return(challenge, nil)
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111}, nil)
+		mockRepo.On("GetMfa", mock.Anything, 111).Return(enabled, nil)
+		mockRepo.On("UseMfaStep", mock.Anything, 111, mock.MatchedBy(func(used int64) bool {
+			return used >= step-1 && used <= step+1
+		})).Return(nil).Once()
+		mockRepo.On("UseMfaChallenge", mock.Anything, 7).Return(nil).Once()
+		mockJwt.On("CreateToken", mock.Anything, mock.Anything).Return("ok", nil)
+		mockRepo.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(1, nil)
+		mockPhoneThrottle := new(throttleMocks.ThrottlerInterface)
+		mockPhoneThrottle.On("Check", mock.Anything, "email:a@example.com").Return(time.Duration(0), nil)
+		mockPhoneThrottle.On("Reset", mock.Anything, "email:a@example.com").Return(nil).Once()
+
+		s := &handler.Server{Repository: mockRepo, Jwt: mockJwt, Secrets: box, MfaMaxAttempts: 5, PhoneThrottle: mockPhoneThrottle}
+
+		if assert.NoError(t, s.PostLoginMfa(c)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			resp := generated.LoginResponse{}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "ok", resp.Jwt)
+		}
+		mockRepo.AssertExpectations(t)
+		mockPhoneThrottle.AssertExpectations(t)
+	})
+
+	t.Run("Success With Recovery Code", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockJwt := new(authMocks.JwtInterface)
+		c, rec := newRequest(map[string]interface{}{"mfaToken": "token", "recoveryCode": "ABCDE FGHIJ"})
+
+		mockRepo.On("GetMfaChallenge", mock.Anything, mock.Anything).Return(activeChallenge(), nil)
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111}, nil)
+		mockRepo.On("UseRecoveryCode", mock.Anything, 111, commons.HashToken("111:abcde-fghij")).Return(nil).Once()
+		mockRepo.On("UseMfaChallenge", mock.Anything, 7).Return(nil).Once()
+		mockJwt.On("CreateToken", mock.Anything, mock.Anything).Return("ok", nil)
+		mockRepo.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(1, nil)
+
+		s := &handler.Server{Repository: mockRepo, Jwt: mockJwt, Secrets: box, MfaMaxAttempts: 5}
+
+		if assert.NoError(t, s.PostLoginMfa(c)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestDeleteAdminUsersIdMfa(t *testing.T) {
+	e := echo.New()
+
+	t.Run("User Not Found", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodDelete, "/admin/users/111/mfa", nil), rec)
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+
+		s := &handler.Server{Repository: mockRepo}
+
+		if assert.NoError(t, s.DeleteAdminUsersIdMfa(c, 111)) {
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+		}
+		mockRepo.AssertNotCalled(t, "DeleteMfa", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodDelete, "/admin/users/111/mfa", nil), rec)
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111}, nil)
+		mockRepo.On("DeleteMfa", mock.Anything, 111).Return(nil).Once()
+
+		s := &handler.Server{Repository: mockRepo}
+
+		if assert.NoError(t, s.DeleteAdminUsersIdMfa(c, 111)) {
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestPostUserIdPhoneVerification(t *testing.T) {
+	e := echo.New()
+
+	newRequest := func() (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodPost, "/user/111/phone/verification", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 111})
+		return c, rec
+	}
+
+	t.Run("Forbidden", func(t *testing.T) {
+		c, rec := newRequest()
+
+		s := &handler.Server{}
+
+		if assert.NoError(t, s.PostUserIdPhoneVerification(c, 222)) {
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		}
+	})
+
+	t.Run("Already Verified", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest()
+
+		verifiedAt := time.Now()
+		mockRepo.On("GetActiveOtpCode", mock.Anything, 111, handler.OtpPurposePhoneVerification).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111, PhoneVerifiedAt: &verifiedAt}, nil)
+
+		s := &handler.Server{Repository: mockRepo}
+
+		if assert.NoError(t, s.PostUserIdPhoneVerification(c, 111)) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+		}
+	})
+
+	t.Run("Resent To Pending Phone Change", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockNotifier := new(notifierMocks.NotifierInterface)
+		c, rec := newRequest()
+
+		mockRepo.On("GetActiveOtpCode", mock.Anything, 111, handler.OtpPurposePhoneVerification).Return(&repository.OtpCodeModel{
+			ID: 5, UserID: 111, PhoneNumber: "+628999999999", CreatedAt: time.Now().Add(-2 * time.Minute),
+		}, nil)
+		mockRepo.On("CreateOtpCode", mock.Anything, mock.MatchedBy(func(input repository.OtpCodeInput) bool {
+			return input.PhoneNumber == "+628999999999"
+		})).Return(6, nil).Once()
+		mockNotifier.On("Send", mock.Anything, mock.Anything).Return(nil).Once()
+
+		s := &handler.Server{Repository: mockRepo, Notifier: mockNotifier, OtpResendCooldown: time.Minute}
+
+		if assert.NoError(t, s.PostUserIdPhoneVerification(c, 111)) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+		}
+		mockRepo.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "GetUser", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Sent To Unverified Phone", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockNotifier := new(notifierMocks.NotifierInterface)
+		c, rec := newRequest()
+
+		mockRepo.On("GetActiveOtpCode", mock.Anything, 111, handler.OtpPurposePhoneVerification).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111, PhoneNumber: "+628222667727"}, nil)
+		mockRepo.On("CreateOtpCode", mock.Anything, mock.MatchedBy(func(input repository.OtpCodeInput) bool {
+			return input.PhoneNumber == "+628222667727"
+		})).Return(6, nil).Once()
+		mockNotifier.On("Send", mock.Anything, mock.Anything).Return(nil).Once()
+
+		s := &handler.Server{Repository: mockRepo, Notifier: mockNotifier}
+
+		if assert.NoError(t, s.PostUserIdPhoneVerification(c, 111)) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestPostUserIdPhoneVerificationConfirm(t *testing.T) {
+	e := echo.New()
+
+	newRequest := func(code string) (echo.Context, *httptest.ResponseRecorder) {
+		reqBodyBytes, _ := json.Marshal(map[string]interface{}{"code": code})
+		req := httptest.NewRequest(http.MethodPost, "/user/111/phone/verification/confirm", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 111})
+		return c, rec
+	}
+	activeCode := func() *repository.OtpCodeModel {
+		return &repository.OtpCodeModel{
+			ID:          5,
+			UserID:      111,
+			PhoneNumber: "+628999999999",
+			CodeHash:    commons.HashToken("111:123456"),
+			ExpiresAt:   time.Now().Add(time.Minute),
+		}
+	}
+
+	t.Run("Bad Request", func(t *testing.T) {
+		c, rec := newRequest("12ab")
+
+		s := &handler.Server{}
+
+		if assert.NoError(t, s.PostUserIdPhoneVerificationConfirm(c, 111)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Wrong Code Counts Attempt", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest("654321")
+
+		mockRepo.On("GetActiveOtpCode", mock.Anything, 111, handler.OtpPurposePhoneVerification).Return(activeCode(), nil)
+		mockRepo.On("IncrementOtpCodeAttempts", mock.Anything, 5).Return(1, nil).Once()
+
+		s := &handler.Server{Repository: mockRepo, OtpMaxAttempts: 5}
+
+		if assert.NoError(t, s.PostUserIdPhoneVerificationConfirm(c, 111)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+		mockRepo.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "VerifyPhoneNumber", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Exhausted Code", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest("123456")
+
+		exhausted := activeCode()
+		exhausted.Attempts = 5
+		mockRepo.On("GetActiveOtpCode", mock.Anything, 111, handler.OtpPurposePhoneVerification).Return(exhausted, nil)
+
+		s := &handler.Server{Repository: mockRepo, OtpMaxAttempts: 5}
+
+		if assert.NoError(t, s.PostUserIdPhoneVerificationConfirm(c, 111)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+		mockRepo.AssertNotCalled(t, "UseOtpCode", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Phone Number Taken Meanwhile", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest("123456")
+
+		mockRepo.On("GetActiveOtpCode", mock.Anything, 111, handler.OtpPurposePhoneVerification).Return(activeCode(), nil)
+		mockRepo.On("UseOtpCode", mock.Anything, 5).Return(nil)
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 222, PhoneNumber: "+628999999999"}, nil)
+
+		s := &handler.Server{Repository: mockRepo, OtpMaxAttempts: 5}
+
+		if assert.NoError(t, s.PostUserIdPhoneVerificationConfirm(c, 111)) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+		}
+		mockRepo.AssertNotCalled(t, "VerifyPhoneNumber", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest("123456")
+
+		mockRepo.On("GetActiveOtpCode", mock.Anything, 111, handler.OtpPurposePhoneVerification).Return(activeCode(), nil)
+		mockRepo.On("UseOtpCode", mock.Anything, 5).Return(nil).Once()
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+		mockRepo.On("VerifyPhoneNumber", mock.Anything, 111, "+628999999999").Return(nil).Once()
+
+		s := &handler.Server{Repository: mockRepo, OtpMaxAttempts: 5}
+
+		if assert.NoError(t, s.PostUserIdPhoneVerificationConfirm(c, 111)) {
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestPostLoginOtpStart(t *testing.T) {
+	e := echo.New()
+
+	newRequest := func(phoneNumber string) (echo.Context, *httptest.ResponseRecorder) {
+		reqBodyBytes, _ := json.Marshal(map[string]interface{}{"phoneNumber": phoneNumber})
+		req := httptest.NewRequest(http.MethodPost, "/login/otp/start", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		return e.NewContext(req, rec), rec
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		c, rec := newRequest("+628222667727")
+
+		s := &handler.Server{}
+
+		err := s.PostLoginOtpStart(c)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+		}
+	})
+
+	t.Run("Unverified Phone Number", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockNotifier := new(notifierMocks.NotifierInterface)
+		c, rec := newRequest("+628222667727")
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111, PhoneNumber: "+628222667727"}, nil)
+
+		s := &handler.Server{Repository: mockRepo, Notifier: mockNotifier, OtpLoginEnabled: true}
+		err := s.PostLoginOtpStart(c)
+		s.WaitBackground()
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+			assert.Contains(t, rec.Body.String(), commons.MessageLoginCodeRequested)
+		}
+		mockRepo.AssertNotCalled(t, "CreateOtpCode", mock.Anything, mock.Anything)
+		mockNotifier.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Within Cooldown", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockNotifier := new(notifierMocks.NotifierInterface)
+		c, rec := newRequest("+628222667727")
+
+		verifiedAt := time.Now()
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111, PhoneNumber: "+628222667727", PhoneVerifiedAt: &verifiedAt}, nil)
+		mockRepo.On("GetActiveOtpCode", mock.Anything, 111, handler.OtpPurposeLogin).Return(&repository.OtpCodeModel{ID: 5, CreatedAt: time.Now()}, nil)
+
+		s := &handler.Server{Repository: mockRepo, Notifier: mockNotifier, OtpLoginEnabled: true, OtpResendCooldown: time.Minute}
+		err := s.PostLoginOtpStart(c)
+		s.WaitBackground()
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusAccepted, rec.Code, "the response does not reveal the cooldown")
+		}
+		mockNotifier.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Code Sent", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockNotifier := new(notifierMocks.NotifierInterface)
+		c, rec := newRequest("+628222667727")
+
+		verifiedAt := time.Now()
+		var stored repository.OtpCodeInput
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111, PhoneNumber: "+628222667727", PhoneVerifiedAt: &verifiedAt}, nil)
+		mockRepo.On("GetActiveOtpCode", mock.Anything, 111, handler.OtpPurposeLogin).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+		mockRepo.On("CreateOtpCode", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			stored = args.Get(1).(repository.OtpCodeInput)
+		}).Return(1, nil)
+
+		var sent notifier.Message
+		mockNotifier.On("Send", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			sent = args.Get(1).(notifier.Message)
+		}).Return(nil)
+
+		s := &handler.Server{Repository: mockRepo, Notifier: mockNotifier, OtpLoginEnabled: true, OtpTTL: 10 * time.Minute}
+		err := s.PostLoginOtpStart(c)
+		s.WaitBackground()
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+		}
+		assert.Equal(t, "+628222667727", sent.Recipient)
+		assert.Equal(t, handler.OtpPurposeLogin, stored.Purpose)
+		assert.WithinDuration(t, time.Now().Add(10*time.Minute), stored.ExpiresAt, time.Minute)
+
+		code := regexp.MustCompile(`\d{6}`).FindString(sent.Body)
+		assert.Equal(t, commons.HashToken("111:"+code), stored.CodeHash)
+	})
+}
+
+func TestPostLoginOtpVerify(t *testing.T) {
+	e := echo.New()
+	verifiedAt := time.Now()
+	verifiedUser := &repository.UserModel{ID: 111, PhoneNumber: "+628222667727", PhoneVerifiedAt: &verifiedAt}
+
+	newRequest := func(code string) (echo.Context, *httptest.ResponseRecorder) {
+		reqBodyBytes, _ := json.Marshal(map[string]interface{}{"phoneNumber": "+628222667727", "code": code})
+		req := httptest.NewRequest(http.MethodPost, "/login/otp/verify", bytes.NewBuffer(reqBodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "10.0.0.1:5000"
+		rec := httptest.NewRecorder()
+		return e.NewContext(req, rec), rec
+	}
+	activeCode := func() *repository.OtpCodeModel {
+		return &repository.OtpCodeModel{
+			ID:          5,
+			UserID:      111,
+			Purpose:     handler.OtpPurposeLogin,
+			PhoneNumber: "+628222667727",
+			CodeHash:    commons.HashToken("111:123456"),
+			ExpiresAt:   time.Now().Add(time.Minute),
+		}
+	}
+	assertStatus := func(t *testing.T, rec *httptest.ResponseRecorder, err error, status int) {
+		if assert.NoError(t, err) {
+			assert.Equal(t, status, rec.Code)
+		}
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		c, rec := newRequest("123456")
+
+		s := &handler.Server{}
+
+		assertStatus(t, rec, s.PostLoginOtpVerify(c), http.StatusNotFound)
+	})
+
+	t.Run("Throttled", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockPhoneThrottle := new(throttleMocks.ThrottlerInterface)
+		c, rec := newRequest("123456")
+
+		mockPhoneThrottle.On("Check", mock.Anything, "phone:+628222667727").Return(30*time.Second, nil)
+
+		s := &handler.Server{Repository: mockRepo, PhoneThrottle: mockPhoneThrottle, OtpLoginEnabled: true}
+
+		assertStatus(t, rec, s.PostLoginOtpVerify(c), http.StatusTooManyRequests)
+		assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+		mockRepo.AssertNotCalled(t, "GetActiveOtpCode", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unknown Phone Number", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest("123456")
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+
+		s := &handler.Server{Repository: mockRepo, OtpLoginEnabled: true}
+
+		assertStatus(t, rec, s.PostLoginOtpVerify(c), http.StatusUnauthorized)
+	})
+
+	t.Run("Wrong Code Counts Failure", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockPhoneThrottle := new(throttleMocks.ThrottlerInterface)
+		c, rec := newRequest("654321")
+
+		mockPhoneThrottle.On("Check", mock.Anything, "phone:+628222667727").Return(time.Duration(0), nil)
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(verifiedUser, nil)
+		mockRepo.On("GetActiveOtpCode", mock.Anything, 111, handler.OtpPurposeLogin).Return(activeCode(), nil)
+		mockRepo.On("IncrementOtpCodeAttempts", mock.Anything, 5).Return(1, nil).Once()
+		mockPhoneThrottle.On("Fail", mock.Anything, "phone:+628222667727").Return(time.Duration(0), nil).Once()
+
+		s := &handler.Server{Repository: mockRepo, PhoneThrottle: mockPhoneThrottle, OtpLoginEnabled: true, OtpMaxAttempts: 5}
+
+		assertStatus(t, rec, s.PostLoginOtpVerify(c), http.StatusUnauthorized)
+		mockRepo.AssertExpectations(t)
+		mockPhoneThrottle.AssertExpectations(t)
+	})
+
+	t.Run("Code Sent To Previous Phone Number", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest("123456")
+
+		previous := activeCode()
+		previous.PhoneNumber = "+628999999999"
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(verifiedUser, nil)
+		mockRepo.On("GetActiveOtpCode", mock.Anything, 111, handler.OtpPurposeLogin).Return(previous, nil)
+		mockRepo.On("UseOtpCode", mock.Anything, 5).Return(nil)
+
+		s := &handler.Server{Repository: mockRepo, OtpLoginEnabled: true, OtpMaxAttempts: 5}
+
+		assertStatus(t, rec, s.PostLoginOtpVerify(c), http.StatusUnauthorized)
+		mockRepo.AssertNotCalled(t, "CreateRefreshToken", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockJwt := new(authMocks.JwtInterface)
+		mockPhoneThrottle := new(throttleMocks.ThrottlerInterface)
+		c, rec := newRequest("123456")
+
+		mockPhoneThrottle.On("Check", mock.Anything, mock.Anything).Return(time.Duration(0), nil)
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(verifiedUser, nil)
+		mockRepo.On("GetActiveOtpCode", mock.Anything, 111, handler.OtpPurposeLogin).Return(activeCode(), nil)
+		mockRepo.On("UseOtpCode", mock.Anything, 5).Return(nil).Once()
+		mockRepo.On("GetMfa", mock.Anything, 111).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+		mockJwt.On("CreateToken", mock.Anything, mock.Anything).Return("ok", nil)
+		mockRepo.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(1, nil).Once()
+		mockPhoneThrottle.On("Reset", mock.Anything, "phone:+628222667727").Return(nil).Once()
+
+		s := &handler.Server{Repository: mockRepo, Jwt: mockJwt, PhoneThrottle: mockPhoneThrottle, OtpLoginEnabled: true, OtpMaxAttempts: 5}
+
+		if assert.NoError(t, s.PostLoginOtpVerify(c)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			resp := generated.LoginResponse{}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "ok", resp.Jwt)
+		}
+		mockRepo.AssertExpectations(t)
+		mockPhoneThrottle.AssertExpectations(t)
+	})
+
+	t.Run("MFA Enabled Returns Challenge", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockJwt := new(authMocks.JwtInterface)
+		c, rec := newRequest("123456")
+
+		confirmedAt := time.Now()
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(verifiedUser, nil)
+		mockRepo.On("GetActiveOtpCode", mock.Anything, 111, handler.OtpPurposeLogin).Return(activeCode(), nil)
+		mockRepo.On("UseOtpCode", mock.Anything, 5).Return(nil)
+		mockRepo.On("GetMfa", mock.Anything, 111).Return(&repository.MfaModel{UserID: 111, ConfirmedAt: &confirmedAt}, nil)
+		mockRepo.On("CreateMfaChallenge", mock.Anything, mock.Anything).Return(1, nil).Once()
+
+		s := &handler.Server{Repository: mockRepo, Jwt: mockJwt, OtpLoginEnabled: true, OtpMaxAttempts: 5, MfaChallengeTTL: 5 * time.Minute}
+
+		if assert.NoError(t, s.PostLoginOtpVerify(c)) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+		}
+		mockJwt.AssertNotCalled(t, "CreateToken", mock.Anything, mock.Anything)
+	})
+}
+
+func TestPostUserIdEmailVerification(t *testing.T) {
+	e := echo.New()
+
+	newRequest := func() (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodPost, "/user/111/email/verification", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		middleware.SetPrincipal(c, &middleware.Principal{UserID: 111})
+		return c, rec
+	}
+
+	t.Run("No Email", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest()
+
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111}, nil)
+
+		s := &handler.Server{Repository: mockRepo}
+
+		if assert.NoError(t, s.PostUserIdEmailVerification(c, 111)) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+			assert.Contains(t, rec.Body.String(), commons.ErrorNoEmail)
+		}
+	})
+
+	t.Run("Already Verified", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest()
+
+		email, verifiedAt := "a@example.com", time.Now()
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111, Email: &email, EmailVerifiedAt: &verifiedAt}, nil)
+
+		s := &handler.Server{Repository: mockRepo}
+
+		if assert.NoError(t, s.PostUserIdEmailVerification(c, 111)) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+			assert.Contains(t, rec.Body.String(), commons.ErrorEmailAlreadyVerified)
+		}
+	})
+
+	t.Run("Sent To Unverified Email", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockMailer := new(mailerMocks.MailerInterface)
+		c, rec := newRequest()
+
+		email := "a@example.com"
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 111, Email: &email}, nil)
+		mockMailer.On("Send", mock.Anything, mock.MatchedBy(func(mail mailer.Mail) bool {
+			return mail.To == "a@example.com"
+		})).Return(nil).Once()
+
+		s := &handler.Server{Repository: mockRepo, Mailer: mockMailer, EmailTokens: testSigner(t), EmailVerificationURL: "https://example.com/email/verify"}
+
+		if assert.NoError(t, s.PostUserIdEmailVerification(c, 111)) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+		}
+		mockMailer.AssertExpectations(t)
+	})
+}
+
+func TestGetEmailVerify(t *testing.T) {
+	e := echo.New()
+	signer := testSigner(t)
+
+	newRequest := func() (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodGet, "/email/verify", nil)
+		rec := httptest.NewRecorder()
+		return e.NewContext(req, rec), rec
+	}
+
+	t.Run("Tampered Token", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest()
+
+		token := signer.Sign(handler.EmailVerificationPurpose, "111:a@example.com", time.Now().Add(time.Hour))
+		tampered := strings.Replace(token, ".", ".x", 1)
+
+		s := &handler.Server{Repository: mockRepo, EmailTokens: signer}
+
+		if assert.NoError(t, s.GetEmailVerify(c, generated.GetEmailVerifyParams{Token: tampered})) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+		mockRepo.AssertNotCalled(t, "VerifyEmail", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Expired Token", func(t *testing.T) {
+		c, rec := newRequest()
+
+		token := signer.Sign(handler.EmailVerificationPurpose, "111:a@example.com", time.Now().Add(-time.Minute))
+
+		s := &handler.Server{EmailTokens: signer}
+
+		if assert.NoError(t, s.GetEmailVerify(c, generated.GetEmailVerifyParams{Token: token})) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Email Since Replaced", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest()
+
+		token := signer.Sign(handler.EmailVerificationPurpose, "111:a@example.com", time.Now().Add(time.Hour))
+		mockRepo.On("VerifyEmail", mock.Anything, 111, "a@example.com").Return(apperrors.NotFound(commons.ErrorNoData))
+
+		s := &handler.Server{Repository: mockRepo, EmailTokens: signer}
+
+		if assert.NoError(t, s.GetEmailVerify(c, generated.GetEmailVerifyParams{Token: token})) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), commons.ErrorInvalidEmailToken)
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		c, rec := newRequest()
+
+		token := signer.Sign(handler.EmailVerificationPurpose, "111:a@example.com", time.Now().Add(time.Hour))
+		mockRepo.On("VerifyEmail", mock.Anything, 111, "a@example.com").Return(nil).Once()
+
+		s := &handler.Server{Repository: mockRepo, EmailTokens: signer}
+
+		if assert.NoError(t, s.GetEmailVerify(c, generated.GetEmailVerifyParams{Token: token})) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+// TestEndToEndMemoryRepository runs requests through the routes and the Auth middleware of the
+// service, on the in-memory repository instead of mocks
+func TestEndToEndMemoryRepository(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keys, err := middleware.NewStaticKeyManager("default", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)}))
+	require.NoError(t, err)
+	jwt := &middleware.Jwt{Keys: keys, Issuer: "user-service", Audience: "user-service"}
+
+	pwd, err := commons.NewPasswordManager(commons.PasswordManagerOptions{
+		Algorithm:     commons.AlgorithmBcrypt,
+		BcryptCost:    4,
+		Peppers:       map[int]string{1: "pepper"},
+		PepperVersion: 1,
+	})
+	require.NoError(t, err)
+	spec, err := generated.GetSwagger()
+	require.NoError(t, err)
+
+	repo := repository.NewMemoryRepository()
+	revocation := middleware.NewRevocationStore(repo, time.Minute)
+	s := handler.NewServer(handler.NewServerOptions{
+		Middleware:     middleware.NewMiddleware(jwt, repo, revocation, spec),
+		Repository:     repo,
+		Pwd:            pwd,
+		Jwt:            jwt,
+		Revocation:     revocation,
+		AccessTokenTTL: 15 * time.Minute,
+		Notifier:       notifier.NewFileNotifier(filepath.Join(t.TempDir(), "notifications.jsonl")),
+		OtpTTL:         10 * time.Minute,
+	})
+
+	e := echo.New()
+	e.Use(s.Middleware.Auth)
+	generated.RegisterHandlers(e, s)
+
+	do := func(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+		var reqBody bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&reqBody).Encode(body))
+		}
+		req := httptest.NewRequest(method, path, &reqBody)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/register", "", map[string]interface{}{"phoneNumber": "+628222667727", "fullName": "Budi", "password": "@Python12345@"})
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	rec = do(http.MethodPost, "/register", "", map[string]interface{}{"phoneNumber": "+628222667727", "fullName": "Budi", "password": "@Python12345@"})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = do(http.MethodPost, "/login", "", map[string]interface{}{"phoneNumber": "+628222667727", "password": "@Wrong12345@"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = do(http.MethodPost, "/login", "", map[string]interface{}{"phoneNumber": "+628222667727", "password": "@Python12345@"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	login := generated.LoginResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &login))
+
+	phoneNumber := "+628222667727"
+	user, err := repo.GetUser(context.Background(), repository.GetUserInput{PhoneNumber: &phoneNumber})
+	require.NoError(t, err)
+	path := fmt.Sprintf("/user/%d", user.ID)
+
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, path, "", nil).Code)
+
+	rec = do(http.MethodPatch, path+"/edit", login.Jwt, map[string]interface{}{"fullName": "Budi Santoso"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = do(http.MethodGet, path, login.Jwt, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"fullName":"Budi Santoso"`)
+	assert.Equal(t, `"2"`, rec.Header().Get(handler.HeaderETag))
+
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/admin/users", login.Jwt, nil).Code, "listing users is for admins")
+
+	rec = do(http.MethodPost, "/logout-all", login.Jwt, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, path, login.Jwt, nil).Code, "logging out everywhere revokes the token")
 }
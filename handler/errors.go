@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/middleware"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+// errorStatuses is the HTTP status of every error kind, the first kind an error matches wins
+var errorStatuses = []struct {
+	kind   error
+	status int
+}{
+	{apperrors.ErrValidation, http.StatusBadRequest},
+	{apperrors.ErrInvalidCredentials, http.StatusUnauthorized},
+	{apperrors.ErrUnauthorized, http.StatusUnauthorized},
+	{apperrors.ErrForbidden, http.StatusForbidden},
+	{apperrors.ErrNotFound, http.StatusNotFound},
+	{apperrors.ErrConflict, http.StatusConflict},
+	{apperrors.ErrPreconditionFailed, http.StatusPreconditionFailed},
+	{apperrors.ErrUnsupportedMediaType, http.StatusUnsupportedMediaType},
+	{apperrors.ErrTooManyRequests, http.StatusTooManyRequests},
+	{apperrors.ErrUnavailable, http.StatusServiceUnavailable},
+}
+
+// errorStatus returns the HTTP status of err, 500 when it is of no known kind
+func errorStatus(err error) int {
+	for _, errorStatus := range errorStatuses {
+		if errors.Is(err, errorStatus.kind) {
+			return errorStatus.status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// errorResponse writes err as the error response of the request, it is how every handler fails.
+// The kind of err picks the status and its message is sent to the client. Errors of no known
+// kind are logged and answered with ErrSystemError so that nothing internal leaks.
+func errorResponse(ctx echo.Context, err error) error {
+	status := errorStatus(err)
+	if status == http.StatusInternalServerError {
+		log.Errorf("%s %s, unexpected error err:%s", ctx.Request().Method, ctx.Path(), err.Error())
+		return ctx.JSON(status, generated.ErrorResponse{Message: commons.ErrSystemError})
+	}
+
+	response := generated.ErrorResponse{Message: apperrors.MessageOf(err)}
+	var appErr *apperrors.Error
+	if errors.As(err, &appErr) {
+		if appErr.RetryAfter > 0 {
+			// rounded up, retrying early only earns another 429
+			retryAfter := int(math.Ceil(appErr.RetryAfter.Seconds()))
+			ctx.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		}
+		if len(appErr.Fields) > 0 {
+			fields := make([]generated.FieldError, 0, len(appErr.Fields))
+			for _, field := range appErr.Fields {
+				fields = append(fields, generated.FieldError{Field: field.Field, Message: field.Message})
+			}
+			response.Fields = &fields
+		}
+	}
+	return ctx.JSON(status, response)
+}
+
+// validationError turns the errors of commons.Validate into an apperrors.ErrValidation naming each invalid field
+func validationError(err error) error {
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return apperrors.Validation(fmt.Sprintf(commons.InValidData, err))
+	}
+
+	fields := make([]apperrors.FieldError, 0, len(invalid))
+	for _, fieldErr := range invalid {
+		rule := fieldErr.Tag()
+		if fieldErr.Param() != "" {
+			rule += "=" + fieldErr.Param()
+		}
+		fields = append(fields, apperrors.FieldError{
+			Field:   fieldErr.Field(),
+			Message: fmt.Sprintf("failed on the %s rule", rule),
+		})
+	}
+	return apperrors.Validation(fmt.Sprintf("invalid data %v", invalid), fields...)
+}
+
+// ownPrincipal returns the caller when it is the user id, the /user/{id} endpoints only serve
+// the user themselves
+func ownPrincipal(ctx echo.Context, id int) (*middleware.Principal, error) {
+	principal, ok := middleware.GetPrincipal(ctx)
+	if !ok {
+		return nil, apperrors.Unauthorized("Unauthorized")
+	}
+	if principal.UserID != id {
+		return nil, apperrors.Forbidden("Forbidden")
+	}
+	return principal, nil
+}
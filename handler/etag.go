@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+// HeaderETag ...
+const HeaderETag = "ETag"
+
+// userETag is the strong entity tag of the profile of a user, every change to the profile
+// increments its version
+func userETag(user *repository.UserModel) string {
+	return strconv.Quote(strconv.Itoa(user.Version))
+}
+
+// etagMatches tells whether an If-Match or If-None-Match header lists etag. If-None-Match uses
+// the weak comparison, which ignores the W/ prefix; If-Match uses the strong one, which never
+// matches a weak tag.
+func etagMatches(header string, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
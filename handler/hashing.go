@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+
+	"github.com/SawitProRecruitment/UserService/commons"
+)
+
+// ServerBusyRetryAfter is the Retry-After in seconds sent when the hashing queue is full, hashes take well under a second
+const ServerBusyRetryAfter = 1
+
+// hashPassword runs GenerateHash on the hashing pool, it fails with apperrors.ErrUnavailable when the pool is saturated.
+func (s *Server) hashPassword(ctx context.Context, password string, salt string) (string, int, error) {
+	var hash string
+	var pepperVersion int
+	var hashErr error
+	err := s.runHashing(ctx, func() {
+		hash, pepperVersion, hashErr = s.Pwd.GenerateHash(password, salt)
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return hash, pepperVersion, hashErr
+}
+
+// verifyPassword runs VerifyPassword on the hashing pool.
+func (s *Server) verifyPassword(ctx context.Context, password string, hash string, salt string, pepperVersion int) (bool, error) {
+	var ok bool
+	err := s.runHashing(ctx, func() {
+		ok = s.Pwd.VerifyPassword(password, hash, salt, pepperVersion)
+	})
+	return ok, err
+}
+
+func (s *Server) runHashing(ctx context.Context, fn func()) error {
+	if s.HashPool == nil {
+		fn()
+		return nil
+	}
+	err := s.HashPool.Do(ctx, fn)
+	if errors.Is(err, apperrors.ErrUnavailable) {
+		return apperrors.Unavailable(commons.ErrorServerBusy, ServerBusyRetryAfter*time.Second)
+	}
+	return err
+}
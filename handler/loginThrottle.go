@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/gommon/log"
+)
+
+// checkLoginThrottle returns how long logins for the account, as keyed by phoneThrottleKey or
+// emailThrottleKey, or from the client IP are still blocked.
+func (s *Server) checkLoginThrottle(ctx context.Context, accountKey string, clientIP string) (time.Duration, error) {
+	var blocked time.Duration
+
+	if s.PhoneThrottle != nil {
+		remaining, err := s.PhoneThrottle.Check(ctx, accountKey)
+		if err != nil {
+			log.Errorf("checkLoginThrottle, error when checking account err:%s", err.Error())
+			return 0, err
+		}
+		blocked = remaining
+	}
+
+	if s.IPThrottle != nil {
+		remaining, err := s.IPThrottle.Check(ctx, ipThrottleKey(clientIP))
+		if err != nil {
+			log.Errorf("checkLoginThrottle, error when checking client ip err:%s", err.Error())
+			return 0, err
+		}
+		if remaining > blocked {
+			blocked = remaining
+		}
+	}
+
+	return blocked, nil
+}
+
+// recordLoginFailure counts a failed login against both the account and the client IP.
+func (s *Server) recordLoginFailure(ctx context.Context, accountKey string, clientIP string) {
+	if s.PhoneThrottle != nil {
+		if _, err := s.PhoneThrottle.Fail(ctx, accountKey); err != nil {
+			log.Errorf("recordLoginFailure, error when counting account err:%s", err.Error())
+		}
+	}
+
+	if s.IPThrottle != nil {
+		if _, err := s.IPThrottle.Fail(ctx, ipThrottleKey(clientIP)); err != nil {
+			log.Errorf("recordLoginFailure, error when counting client ip err:%s", err.Error())
+		}
+	}
+}
+
+// UnlockLogin clears the failed logins of the account keys. The counter of the client IP is
+// left alone, a successful login on one account must not reset guesses on others.
+func (s *Server) UnlockLogin(ctx context.Context, accountKeys ...string) error {
+	if s.PhoneThrottle == nil {
+		return nil
+	}
+
+	for _, accountKey := range accountKeys {
+		if err := s.PhoneThrottle.Reset(ctx, accountKey); err != nil {
+			log.Errorf("UnlockLogin, error when resetting account err:%s", err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyDummyPassword spends the time of a real password verification.
+func (s *Server) verifyDummyPassword(ctx context.Context, password string) error {
+	s.dummyHashOnce.Do(func() {
+		// made once outside the pool, a canceled request must not leave the dummy hash empty
+		hash, pepperVersion, err := s.Pwd.GenerateHash(s.Pwd.CreateSalt(), s.Pwd.CreateSalt())
+		if err != nil {
+			log.Errorf("verifyDummyPassword, error hashing password err:%s", err.Error())
+			return
+		}
+		s.dummyHash, s.dummyPepperVersion = hash, pepperVersion
+	})
+	_, err := s.verifyPassword(ctx, password, s.dummyHash, "", s.dummyPepperVersion)
+	return err
+}
+
+func phoneThrottleKey(phoneNumber string) string {
+	return "phone:" + phoneNumber
+}
+
+func emailThrottleKey(email string) string {
+	return "email:" + email
+}
+
+// accountThrottleKey keys the identifier a login request gave, exactly one of phoneNumber and email is set
+func accountThrottleKey(phoneNumber, email *string) string {
+	if email != nil {
+		return emailThrottleKey(*email)
+	}
+	return phoneThrottleKey(*phoneNumber)
+}
+
+// userThrottleKeys are the keys of every identifier the user can log in with
+func userThrottleKeys(user *repository.UserModel) []string {
+	keys := []string{phoneThrottleKey(user.PhoneNumber)}
+	if user.Email != nil && user.EmailVerifiedAt != nil {
+		keys = append(keys, emailThrottleKey(*user.Email))
+	}
+	return keys
+}
+
+func ipThrottleKey(clientIP string) string {
+	return "ip:" + clientIP
+}
@@ -0,0 +1,278 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/gommon/log"
+)
+
+// EnrollTotp creates a TOTP secret for the user, MFA is only enabled once ConfirmTotp accepted a code of it.
+func (s *Server) EnrollTotp(ctx context.Context, userId int) (*generated.TotpEnrollmentResponse, error) {
+	user, err := s.FetchUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := commons.GenerateTOTPSecret()
+	if err != nil {
+		log.Errorf("EnrollTotp, error when generating secret err:%s", err.Error())
+		return nil, err
+	}
+
+	encrypted, err := s.Secrets.Encrypt(secret, mfaAssociatedData(user.ID))
+	if err != nil {
+		log.Errorf("EnrollTotp, error when encrypting secret err:%s", err.Error())
+		return nil, err
+	}
+
+	err = s.Repository.SaveMfaSecret(ctx, repository.MfaInput{UserID: user.ID, Secret: encrypted})
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return nil, apperrors.Conflict(commons.ErrorMfaAlreadyEnabled)
+		}
+		log.Errorf("EnrollTotp, error when storing secret err:%s", err.Error())
+		return nil, err
+	}
+
+	return &generated.TotpEnrollmentResponse{
+		Secret:     secret,
+		OtpauthUri: commons.TOTPURI(s.MfaIssuer, user.PhoneNumber, secret),
+	}, nil
+}
+
+// ConfirmTotp enables MFA when code matches the enrolled secret and returns new recovery codes.
+func (s *Server) ConfirmTotp(ctx context.Context, userId int, code string) ([]string, error) {
+	mfa, err := s.Repository.GetMfa(ctx, userId)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return nil, apperrors.NotFound(commons.ErrorMfaNotEnrolled)
+		}
+		log.Errorf("ConfirmTotp, error when fetching mfa err:%s", err.Error())
+		return nil, err
+	}
+	if mfa.Enabled() {
+		return nil, apperrors.Conflict(commons.ErrorMfaAlreadyEnabled)
+	}
+
+	step, ok, err := s.validateTotp(mfa, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, apperrors.Validation(commons.ErrorInvalidMfaCode)
+	}
+
+	codes := make([]string, 0, s.MfaRecoveryCodes)
+	hashes := make([]string, 0, s.MfaRecoveryCodes)
+	for i := 0; i < s.MfaRecoveryCodes; i++ {
+		recoveryCode, err := commons.GenerateRecoveryCode()
+		if err != nil {
+			log.Errorf("ConfirmTotp, error when generating recovery code err:%s", err.Error())
+			return nil, err
+		}
+		codes = append(codes, recoveryCode)
+		hashes = append(hashes, hashRecoveryCode(userId, recoveryCode))
+	}
+
+	if err := s.Repository.ConfirmMfa(ctx, userId, step, hashes); err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			// confirmed by a concurrent request
+			return nil, apperrors.Conflict(commons.ErrorMfaAlreadyEnabled)
+		}
+		log.Errorf("ConfirmTotp, error when enabling mfa err:%s", err.Error())
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// ResetMfa disables MFA of a user, e.g. after they lost their authenticator and recovery codes.
+func (s *Server) ResetMfa(ctx context.Context, userId int) error {
+	if _, err := s.FetchUserById(ctx, userId); err != nil {
+		return err
+	}
+
+	if err := s.Repository.DeleteMfa(ctx, userId); err != nil {
+		log.Errorf("ResetMfa, error when deleting mfa userId:%d err:%s", userId, err.Error())
+		return err
+	}
+	return nil
+}
+
+// mfaEnabled reports whether logging in as the user needs a second factor.
+func (s *Server) mfaEnabled(ctx context.Context, userId int) (bool, error) {
+	mfa, err := s.Repository.GetMfa(ctx, userId)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return false, nil
+		}
+		log.Errorf("mfaEnabled, error when fetching mfa err:%s", err.Error())
+		return false, err
+	}
+	return mfa.Enabled(), nil
+}
+
+// startMfaChallenge returns the token that /login/mfa exchanges for a token pair together with a code.
+func (s *Server) startMfaChallenge(ctx context.Context, user *repository.UserModel, accountKey string) (*generated.MfaChallengeResponse, error) {
+	token, err := commons.GenerateOpaqueToken()
+	if err != nil {
+		log.Errorf("startMfaChallenge, error when generating token err:%s", err.Error())
+		return nil, err
+	}
+
+	_, err = s.Repository.CreateMfaChallenge(ctx, repository.MfaChallengeInput{
+		UserID:     user.ID,
+		TokenHash:  commons.HashToken(token),
+		AccountKey: accountKey,
+		ExpiresAt:  time.Now().Add(s.MfaChallengeTTL),
+	})
+	if err != nil {
+		log.Errorf("startMfaChallenge, error when storing challenge err:%s", err.Error())
+		return nil, err
+	}
+
+	return &generated.MfaChallengeResponse{
+		MfaToken:  token,
+		ExpiresIn: int(s.MfaChallengeTTL.Seconds()),
+	}, nil
+}
+
+// CompleteMfaLogin finishes a login started by PerformLogin. A wrong code counts against the
+// challenge and, like a wrong password, against the login throttles of the account the login used
+// and clientIP. A locked account gets no further codes checked, and its failed attempts are only
+// cleared once the tokens are issued.
+func (s *Server) CompleteMfaLogin(ctx context.Context, req *generated.MfaLoginRequest, clientIP string) (*generated.LoginResponse, error) {
+	challenge, err := s.Repository.GetMfaChallenge(ctx, commons.HashToken(req.MfaToken))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return nil, apperrors.InvalidCredentials(commons.ErrorInvalidMfaChallenge)
+		}
+		log.Errorf("CompleteMfaLogin, error when fetching challenge err:%s", err.Error())
+		return nil, err
+	}
+
+	if challenge.UsedAt != nil || !challenge.ExpiresAt.After(time.Now()) || challenge.Attempts >= s.MfaMaxAttempts {
+		return nil, apperrors.InvalidCredentials(commons.ErrorInvalidMfaChallenge)
+	}
+
+	user, err := s.FetchUserById(ctx, challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	accountKey := challenge.AccountKey
+	if accountKey == "" {
+		// started before challenges kept the key of their login
+		accountKey = phoneThrottleKey(user.PhoneNumber)
+	}
+	blocked, err := s.checkLoginThrottle(ctx, accountKey, clientIP)
+	if err != nil {
+		return nil, err
+	}
+	if blocked > 0 {
+		return nil, apperrors.TooManyRequests(commons.ErrorTooManyAttempts, blocked)
+	}
+
+	ok, err := s.verifySecondFactor(ctx, user.ID, req)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		s.recordLoginFailure(ctx, accountKey, clientIP)
+		if _, err := s.Repository.IncrementMfaChallengeAttempts(ctx, challenge.ID); err != nil {
+			log.Errorf("CompleteMfaLogin, error when counting attempt err:%s", err.Error())
+			return nil, err
+		}
+		return nil, apperrors.InvalidCredentials(commons.ErrorInvalidMfaCode)
+	}
+
+	if err := s.Repository.UseMfaChallenge(ctx, challenge.ID); err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return nil, apperrors.InvalidCredentials(commons.ErrorInvalidMfaChallenge)
+		}
+		log.Errorf("CompleteMfaLogin, error when using challenge err:%s", err.Error())
+		return nil, err
+	}
+
+	tokens, err := s.IssueTokens(ctx, user, commons.GenerateTokenFamily())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.UnlockLogin(ctx, accountKey); err != nil {
+		log.Warnf("CompleteMfaLogin, failed attempts were not cleared err:%s", err.Error())
+	}
+	return tokens, nil
+}
+
+// verifySecondFactor accepts a TOTP code once per time step, or an unused recovery code.
+func (s *Server) verifySecondFactor(ctx context.Context, userId int, req *generated.MfaLoginRequest) (bool, error) {
+	if req.RecoveryCode != nil {
+		err := s.Repository.UseRecoveryCode(ctx, userId, hashRecoveryCode(userId, *req.RecoveryCode))
+		if err != nil {
+			if errors.Is(err, apperrors.ErrNotFound) {
+				return false, nil
+			}
+			log.Errorf("verifySecondFactor, error when using recovery code err:%s", err.Error())
+			return false, err
+		}
+		return true, nil
+	}
+
+	mfa, err := s.Repository.GetMfa(ctx, userId)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			// reset by an admin after the challenge was issued
+			return false, nil
+		}
+		log.Errorf("verifySecondFactor, error when fetching mfa err:%s", err.Error())
+		return false, err
+	}
+	if !mfa.Enabled() {
+		return false, nil
+	}
+
+	step, ok, err := s.validateTotp(mfa, *req.Code)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if err := s.Repository.UseMfaStep(ctx, userId, step); err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			// the code was already used
+			return false, nil
+		}
+		log.Errorf("verifySecondFactor, error when using code err:%s", err.Error())
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Server) validateTotp(mfa *repository.MfaModel, code string) (int64, bool, error) {
+	secret, err := s.Secrets.Decrypt(mfa.Secret, mfaAssociatedData(mfa.UserID))
+	if err != nil {
+		log.Errorf("validateTotp, error when decrypting secret userId:%d err:%s", mfa.UserID, err.Error())
+		return 0, false, err
+	}
+
+	step, ok := commons.ValidateTOTP(secret, code, time.Now())
+	if !ok || step <= mfa.LastUsedStep {
+		return 0, false, nil
+	}
+	return step, true, nil
+}
+
+// mfaAssociatedData binds an encrypted secret to its user, a secret copied to another row does not decrypt.
+func mfaAssociatedData(userId int) string {
+	return "user_mfa:" + strconv.Itoa(userId)
+}
+
+func hashRecoveryCode(userId int, code string) string {
+	return commons.HashToken(strconv.Itoa(userId) + ":" + commons.NormalizeRecoveryCode(code))
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/SawitProRecruitment/UserService/notifier"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	// OtpCodeDigits is the length of the one-time codes sent by SMS
+	OtpCodeDigits = 6
+	// OtpPurposePhoneVerification proves that the user owns a phone number
+	OtpPurposePhoneVerification = "phone_verification"
+	// OtpPurposeLogin replaces the password for /login/otp/verify
+	OtpPurposeLogin = "login"
+)
+
+// otpSubjects names the code of each purpose in the message that delivers it
+var otpSubjects = map[string]string{
+	OtpPurposePhoneVerification: "Phone verification code",
+	OtpPurposeLogin:             "Login code",
+}
+
+// sendOtp texts a new code for the purpose to phoneNumber, the codes of that purpose sent to the
+// user before stop working. When the previous code is younger than OtpResendCooldown nothing is
+// sent and the time left is returned instead.
+func (s *Server) sendOtp(ctx context.Context, userID int, purpose string, phoneNumber string) (time.Duration, error) {
+	wait, err := s.otpCooldown(ctx, userID, purpose)
+	if err != nil || wait > 0 {
+		return wait, err
+	}
+
+	code, err := commons.GenerateNumericCode(OtpCodeDigits)
+	if err != nil {
+		log.Errorf("sendOtp, error when generating code err:%s", err.Error())
+		return 0, err
+	}
+
+	_, err = s.Repository.CreateOtpCode(ctx, repository.OtpCodeInput{
+		UserID:      userID,
+		Purpose:     purpose,
+		PhoneNumber: phoneNumber,
+		CodeHash:    hashOtpCode(userID, code),
+		ExpiresAt:   time.Now().Add(s.OtpTTL),
+	})
+	if err != nil {
+		log.Errorf("sendOtp, error when storing code err:%s", err.Error())
+		return 0, err
+	}
+
+	subject := otpSubjects[purpose]
+	err = s.Notifier.Send(ctx, notifier.Message{
+		Recipient: phoneNumber,
+		Subject:   subject,
+		Body:      fmt.Sprintf("%s: %s. It expires in %s.", subject, code, s.OtpTTL),
+	})
+	if err != nil {
+		log.Errorf("sendOtp, error when sending code err:%s", err.Error())
+		return 0, err
+	}
+	return 0, nil
+}
+
+// otpCooldown returns how long the user must still wait before a new code of the purpose is sent
+func (s *Server) otpCooldown(ctx context.Context, userID int, purpose string) (time.Duration, error) {
+	previous, err := s.Repository.GetActiveOtpCode(ctx, userID, purpose)
+	if err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+		log.Errorf("otpCooldown, error when fetching previous code err:%s", err.Error())
+		return 0, err
+	}
+	if previous == nil {
+		return 0, nil
+	}
+	if wait := time.Until(previous.CreatedAt.Add(s.OtpResendCooldown)); wait > 0 {
+		return wait, nil
+	}
+	return 0, nil
+}
+
+// verifyOtp uses the latest code of the purpose when it matches and returns it. All code
+// failures return an apperrors.ErrValidation of ErrorInvalidOtpCode, a wrong code counts
+// against OtpMaxAttempts.
+func (s *Server) verifyOtp(ctx context.Context, userID int, purpose string, code string) (*repository.OtpCodeModel, error) {
+	stored, err := s.Repository.GetActiveOtpCode(ctx, userID, purpose)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return nil, apperrors.Validation(commons.ErrorInvalidOtpCode)
+		}
+		log.Errorf("verifyOtp, error when fetching code err:%s", err.Error())
+		return nil, err
+	}
+
+	if !stored.ExpiresAt.After(time.Now()) || stored.Attempts >= s.OtpMaxAttempts {
+		return nil, apperrors.Validation(commons.ErrorInvalidOtpCode)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashOtpCode(userID, code)), []byte(stored.CodeHash)) != 1 {
+		if _, err := s.Repository.IncrementOtpCodeAttempts(ctx, stored.ID); err != nil {
+			log.Errorf("verifyOtp, error when counting attempt err:%s", err.Error())
+			return nil, err
+		}
+		return nil, apperrors.Validation(commons.ErrorInvalidOtpCode)
+	}
+
+	if err := s.Repository.UseOtpCode(ctx, stored.ID); err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			// a concurrent request used the same code first
+			return nil, apperrors.Validation(commons.ErrorInvalidOtpCode)
+		}
+		log.Errorf("verifyOtp, error when using code err:%s", err.Error())
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+func hashOtpCode(userID int, code string) string {
+	return commons.HashToken(strconv.Itoa(userID) + ":" + code)
+}
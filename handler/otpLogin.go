@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/labstack/gommon/log"
+)
+
+// StartOtpLogin texts a login code when the phone number is the verified phone number of a user.
+// Other phone numbers, and requests within the resend cooldown, are ignored so the caller learns nothing.
+func (s *Server) StartOtpLogin(ctx context.Context, phoneNumber string) error {
+	user, err := s.FetchUserByPhoneNumber(ctx, phoneNumber)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.PhoneVerifiedAt == nil {
+		return nil
+	}
+
+	retryAfter, err := s.sendOtp(ctx, user.ID, OtpPurposeLogin, user.PhoneNumber)
+	if err != nil {
+		return err
+	}
+	if retryAfter > 0 {
+		log.Infof("StartOtpLogin, code not sent within cooldown userId:%d", user.ID)
+	}
+	return nil
+}
+
+// PerformOtpLogin is PerformLogin with a texted code in place of the password. All code
+// failures, an unknown phone number included, return apperrors.ErrInvalidCredentials.
+func (s *Server) PerformOtpLogin(ctx context.Context, req *generated.OtpLoginVerifyRequest) (*LoginResult, error) {
+	user, err := s.FetchUserByPhoneNumber(ctx, req.PhoneNumber)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.PhoneVerifiedAt == nil {
+		return nil, apperrors.InvalidCredentials(commons.ErrorInvalidOtpCode)
+	}
+
+	code, err := s.verifyOtp(ctx, user.ID, OtpPurposeLogin, req.Code)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrValidation) {
+			// the code is the credential here
+			return nil, apperrors.InvalidCredentials(commons.ErrorInvalidOtpCode)
+		}
+		return nil, err
+	}
+	if code.PhoneNumber != user.PhoneNumber {
+		// the user changed their phone number after the code was sent
+		return nil, apperrors.InvalidCredentials(commons.ErrorInvalidOtpCode)
+	}
+
+	return s.completeLogin(ctx, user, phoneThrottleKey(req.PhoneNumber))
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/gommon/log"
+)
+
+// ChangePassword replaces the password of the user after checking the current one, every
+// other session is revoked and a new token pair is returned for the caller.
+func (s *Server) ChangePassword(ctx context.Context, userId int, req *generated.ChangePasswordRequest) (*generated.LoginResponse, error) {
+	user, err := s.FetchUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.verifyPassword(ctx, req.CurrentPassword, user.Password, user.SaltKey, user.PepperVersion)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, apperrors.Forbidden(commons.ErrorInvalidPassword)
+	}
+
+	reused, err := s.isRecentPassword(ctx, user, req.NewPassword)
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, apperrors.Validation(commons.ErrorPasswordReused, apperrors.FieldError{Field: "newPassword", Message: commons.ErrorPasswordReused})
+	}
+
+	if err := s.setPassword(ctx, user, req.NewPassword); err != nil {
+		return nil, err
+	}
+
+	if err := s.LogoutAll(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	// reload the user to sign the new token with the bumped token version
+	user, err = s.FetchUserById(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.IssueTokens(ctx, user, commons.GenerateTokenFamily())
+}
+
+// isRecentPassword reports whether password is the current password of the user or one of
+// the PasswordHistorySize - 1 before it.
+func (s *Server) isRecentPassword(ctx context.Context, user *repository.UserModel, password string) (bool, error) {
+	if s.PasswordHistorySize <= 0 {
+		return false, nil
+	}
+
+	reused, err := s.verifyPassword(ctx, password, user.Password, user.SaltKey, user.PepperVersion)
+	if err != nil || reused {
+		return reused, err
+	}
+
+	if s.PasswordHistorySize == 1 {
+		return false, nil
+	}
+
+	history, err := s.Repository.GetPasswordHistory(ctx, user.ID, s.PasswordHistorySize-1)
+	if err != nil {
+		log.Errorf("isRecentPassword, error when fetching password history err:%s", err.Error())
+		return false, err
+	}
+
+	for _, previous := range history {
+		reused, err := s.verifyPassword(ctx, password, previous.Password, previous.SaltKey, previous.PepperVersion)
+		if err != nil || reused {
+			return reused, err
+		}
+	}
+	return false, nil
+}
+
+// setPassword hashes password with a new salt and moves the current hash into the history.
+func (s *Server) setPassword(ctx context.Context, user *repository.UserModel, password string) error {
+	saltKey := s.Pwd.CreateSalt()
+	hashedPass, pepperVersion, err := s.hashPassword(ctx, password, saltKey)
+	if err != nil {
+		log.Errorf("setPassword, error hashing password err:%s", err.Error())
+		return err
+	}
+
+	keepHistory := 0
+	if s.PasswordHistorySize > 1 {
+		keepHistory = s.PasswordHistorySize - 1
+	}
+
+	err = s.Repository.ReplacePassword(ctx, repository.UserInput{
+		ID:            user.ID,
+		Password:      hashedPass,
+		SaltKey:       saltKey,
+		PepperVersion: pepperVersion,
+	}, keepHistory)
+	if err != nil {
+		log.Errorf("setPassword, error when updating password err:%s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// rehashPassword upgrades the stored hash of a password that was just verified to the current
+// algorithm, parameters and pepper. Failing to do so does not fail the login, it is retried next time.
+func (s *Server) rehashPassword(ctx context.Context, user *repository.UserModel, password string) {
+	saltKey := s.Pwd.CreateSalt()
+	hashedPass, pepperVersion, err := s.hashPassword(ctx, password, saltKey)
+	if err != nil {
+		log.Errorf("rehashPassword, error hashing password userId:%d err:%s", user.ID, err.Error())
+		return
+	}
+
+	err = s.Repository.UpdatePassword(ctx, repository.UserInput{
+		ID:            user.ID,
+		Password:      hashedPass,
+		SaltKey:       saltKey,
+		PepperVersion: pepperVersion,
+	})
+	if err != nil {
+		log.Errorf("rehashPassword, error when updating password userId:%d err:%s", user.ID, err.Error())
+	}
+}
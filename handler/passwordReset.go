@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/mailer"
+	"github.com/SawitProRecruitment/UserService/notifier"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	// PasswordResetCodeDigits is the length of the code sent by /password/forgot
+	PasswordResetCodeDigits = 6
+	// backgroundTimeout bounds work that outlives the request that started it
+	backgroundTimeout = 30 * time.Second
+)
+
+// RequestPasswordReset texts a reset code to the phone number, or mails it to the verified email,
+// when it belongs to a user. Anything else is ignored so the caller learns nothing from the outcome.
+func (s *Server) RequestPasswordReset(ctx context.Context, req *generated.ForgotPasswordRequest) error {
+	user, err := s.FetchUserByPhoneNumberOrEmail(ctx, req.PhoneNumber, req.Email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+
+	code, err := commons.GenerateNumericCode(PasswordResetCodeDigits)
+	if err != nil {
+		log.Errorf("RequestPasswordReset, error when generating code err:%s", err.Error())
+		return err
+	}
+
+	_, err = s.Repository.CreatePasswordResetCode(ctx, repository.PasswordResetCodeInput{
+		UserID:    user.ID,
+		CodeHash:  hashResetCode(user.ID, code),
+		ExpiresAt: time.Now().Add(s.PasswordResetTTL),
+	})
+	if err != nil {
+		log.Errorf("RequestPasswordReset, error when storing code err:%s", err.Error())
+		return err
+	}
+
+	subject := "Password reset code"
+	body := fmt.Sprintf("Your password reset code is %s. It expires in %s.", code, s.PasswordResetTTL)
+	if req.Email != nil {
+		err = s.Mailer.Send(ctx, mailer.Mail{To: *user.Email, Subject: subject, Body: body})
+	} else {
+		err = s.Notifier.Send(ctx, notifier.Message{Recipient: user.PhoneNumber, Subject: subject, Body: body})
+	}
+	if err != nil {
+		log.Errorf("RequestPasswordReset, error when sending code err:%s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// ResetPassword sets a new password when the code matches the latest one sent to the user,
+// then revokes every session of that user. All code failures return ErrorInvalidResetCode.
+func (s *Server) ResetPassword(ctx context.Context, req *generated.ResetPasswordRequest) error {
+	user, err := s.FetchUserByPhoneNumberOrEmail(ctx, req.PhoneNumber, req.Email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return apperrors.Validation(commons.ErrorInvalidResetCode)
+	}
+
+	stored, err := s.Repository.GetActivePasswordResetCode(ctx, user.ID)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return apperrors.Validation(commons.ErrorInvalidResetCode)
+		}
+		log.Errorf("ResetPassword, error when fetching code err:%s", err.Error())
+		return err
+	}
+
+	if !stored.ExpiresAt.After(time.Now()) || stored.Attempts >= s.PasswordResetMaxAttempts {
+		return apperrors.Validation(commons.ErrorInvalidResetCode)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashResetCode(user.ID, req.Code)), []byte(stored.CodeHash)) != 1 {
+		if _, err := s.Repository.IncrementPasswordResetAttempts(ctx, stored.ID); err != nil {
+			log.Errorf("ResetPassword, error when counting attempt err:%s", err.Error())
+			return err
+		}
+		return apperrors.Validation(commons.ErrorInvalidResetCode)
+	}
+
+	if err := s.Repository.UsePasswordResetCode(ctx, stored.ID); err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			// a concurrent request used the same code first
+			return apperrors.Validation(commons.ErrorInvalidResetCode)
+		}
+		log.Errorf("ResetPassword, error when using code err:%s", err.Error())
+		return err
+	}
+
+	if err := s.setPassword(ctx, user, req.Password); err != nil {
+		return err
+	}
+
+	return s.LogoutAll(ctx, user.ID)
+}
+
+// runInBackground runs fn after the response is sent, WaitBackground waits for it.
+func (s *Server) runInBackground(fn func(ctx context.Context)) {
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), backgroundTimeout)
+		defer cancel()
+		fn(ctx)
+	}()
+}
+
+// WaitBackground blocks until work started by runInBackground has finished.
+func (s *Server) WaitBackground() {
+	s.background.Wait()
+}
+
+// hashResetCode binds the code to the user, the same code sent to two users hashes differently.
+func hashResetCode(userID int, code string) string {
+	return commons.HashToken(strconv.Itoa(userID) + ":" + code)
+}
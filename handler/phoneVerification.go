@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/gommon/log"
+)
+
+// PhoneVerificationResult holds either where a code was sent or, when the previous code is
+// too recent, how long until another one can be sent
+type PhoneVerificationResult struct {
+	Sent       *generated.PhoneVerificationResponse
+	RetryAfter time.Duration
+}
+
+// StartPhoneVerification texts a code to phoneNumber, ConfirmPhoneVerification then makes it
+// the verified phone number of the user.
+func (s *Server) StartPhoneVerification(ctx context.Context, userId int, phoneNumber string) (*PhoneVerificationResult, error) {
+	retryAfter, err := s.sendOtp(ctx, userId, OtpPurposePhoneVerification, phoneNumber)
+	if err != nil {
+		return nil, err
+	}
+	if retryAfter > 0 {
+		return &PhoneVerificationResult{RetryAfter: retryAfter}, nil
+	}
+
+	return &PhoneVerificationResult{Sent: &generated.PhoneVerificationResponse{
+		PhoneNumber: phoneNumber,
+		ExpiresIn:   int(s.OtpTTL.Seconds()),
+	}}, nil
+}
+
+// ResendPhoneVerification sends a new code to the number of a pending phone change, or else to
+// the phone number of the user when it was never verified.
+func (s *Server) ResendPhoneVerification(ctx context.Context, userId int) (*PhoneVerificationResult, error) {
+	pending, err := s.Repository.GetActiveOtpCode(ctx, userId, OtpPurposePhoneVerification)
+	if err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+		log.Errorf("ResendPhoneVerification, error when fetching pending code err:%s", err.Error())
+		return nil, err
+	}
+	if pending != nil {
+		return s.StartPhoneVerification(ctx, userId, pending.PhoneNumber)
+	}
+
+	user, err := s.FetchUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if user.PhoneVerifiedAt != nil {
+		return nil, apperrors.Conflict(commons.ErrorPhoneAlreadyVerified)
+	}
+	return s.StartPhoneVerification(ctx, userId, user.PhoneNumber)
+}
+
+// ConfirmPhoneVerification marks the number the code was sent to as the verified phone number
+// of the user. It returns repository.ErrUserExists when another user took that number in the meantime.
+func (s *Server) ConfirmPhoneVerification(ctx context.Context, userId int, code string) error {
+	verified, err := s.verifyOtp(ctx, userId, OtpPurposePhoneVerification, code)
+	if err != nil {
+		return err
+	}
+
+	owner, err := s.FetchUserByPhoneNumber(ctx, verified.PhoneNumber)
+	if err != nil {
+		return err
+	}
+	if owner != nil && owner.ID != userId {
+		return repository.ErrUserExists
+	}
+
+	if err := s.Repository.VerifyPhoneNumber(ctx, userId, verified.PhoneNumber); err != nil {
+		log.Errorf("ConfirmPhoneVerification, error when updating phone number userId:%d err:%s", userId, err.Error())
+		return err
+	}
+	return nil
+}
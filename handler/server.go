@@ -1,30 +1,133 @@
 package handler
 
 import (
+	"sync"
+	"time"
+
 	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/SawitProRecruitment/UserService/mailer"
 	"github.com/SawitProRecruitment/UserService/middleware"
+	"github.com/SawitProRecruitment/UserService/notifier"
+	"github.com/SawitProRecruitment/UserService/phone"
 	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/throttle"
 )
 
 type Server struct {
-	Repository repository.RepositoryInterface
-	Jwt        middleware.JwtInterface
-	Pwd        commons.PasswordManagerInterface
-	Middleware middleware.IMiddlewareInterface
+	Repository      repository.RepositoryInterface
+	Jwt             middleware.JwtInterface
+	Pwd             commons.PasswordManagerInterface
+	Middleware      middleware.IMiddlewareInterface
+	Revocation      middleware.RevocationStoreInterface
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	Notifier        notifier.NotifierInterface
+	// PasswordResetTTL is how long a code sent by /password/forgot stays valid
+	PasswordResetTTL time.Duration
+	// PasswordResetMaxAttempts is how many wrong codes are accepted before the code is burnt
+	PasswordResetMaxAttempts int
+	// PasswordHistorySize is how many recent passwords, the current one included, cannot be reused
+	PasswordHistorySize int
+	// PhoneThrottle and IPThrottle slow down failed logins per phone number and per client IP, nil disables them
+	PhoneThrottle throttle.ThrottlerInterface
+	IPThrottle    throttle.ThrottlerInterface
+	RateLimiter   *middleware.RateLimiter
+	// HashPool runs password hashing and verification, nil runs them on the request goroutine
+	HashPool *commons.HashPool
+	// Secrets encrypts TOTP secrets at rest
+	Secrets *commons.SecretBox
+	// MfaIssuer names the service in authenticator apps
+	MfaIssuer        string
+	MfaChallengeTTL  time.Duration
+	MfaMaxAttempts   int
+	MfaRecoveryCodes int
+	// OtpTTL is how long a code sent by SMS stays valid
+	OtpTTL time.Duration
+	// OtpMaxAttempts is how many wrong guesses a code survives
+	OtpMaxAttempts int
+	// OtpResendCooldown is how long after a code is sent before another one can be
+	OtpResendCooldown time.Duration
+	// OtpLoginEnabled turns on /login/otp/start and /login/otp/verify
+	OtpLoginEnabled bool
+	// Phones normalizes every phone number the API accepts, nil accepts Indonesian numbers only
+	Phones *phone.Normalizer
+	// Mailer delivers email verification links, and reset codes requested by email
+	Mailer mailer.MailerInterface
+	// EmailTokens signs the tokens of email verification links
+	EmailTokens *commons.Signer
+	// EmailVerificationTTL is how long a verification link stays valid
+	EmailVerificationTTL time.Duration
+	// EmailVerificationURL is where verification links point, the token is added as the token query parameter
+	EmailVerificationURL string
+
+	background         sync.WaitGroup
+	dummyHashOnce      sync.Once
+	dummyHash          string
+	dummyPepperVersion int
 }
 
 type NewServerOptions struct {
-	Repository repository.RepositoryInterface
-	Jwt        middleware.JwtInterface
-	Pwd        commons.PasswordManagerInterface
-	Middleware middleware.IMiddlewareInterface
+	Repository               repository.RepositoryInterface
+	Jwt                      middleware.JwtInterface
+	Pwd                      commons.PasswordManagerInterface
+	Middleware               middleware.IMiddlewareInterface
+	Revocation               middleware.RevocationStoreInterface
+	AccessTokenTTL           time.Duration
+	RefreshTokenTTL          time.Duration
+	Notifier                 notifier.NotifierInterface
+	PasswordResetTTL         time.Duration
+	PasswordResetMaxAttempts int
+	PasswordHistorySize      int
+	PhoneThrottle            throttle.ThrottlerInterface
+	IPThrottle               throttle.ThrottlerInterface
+	RateLimiter              *middleware.RateLimiter
+	HashPool                 *commons.HashPool
+	Secrets                  *commons.SecretBox
+	MfaIssuer                string
+	MfaChallengeTTL          time.Duration
+	MfaMaxAttempts           int
+	MfaRecoveryCodes         int
+	OtpTTL                   time.Duration
+	OtpMaxAttempts           int
+	OtpResendCooldown        time.Duration
+	OtpLoginEnabled          bool
+	Phones                   *phone.Normalizer
+	Mailer                   mailer.MailerInterface
+	EmailTokens              *commons.Signer
+	EmailVerificationTTL     time.Duration
+	EmailVerificationURL     string
 }
 
 func NewServer(opts NewServerOptions) *Server {
 	return &Server{
-		Repository: opts.Repository,
-		Jwt:        opts.Jwt,
-		Pwd:        opts.Pwd,
-		Middleware: opts.Middleware,
+		Repository:               opts.Repository,
+		Jwt:                      opts.Jwt,
+		Pwd:                      opts.Pwd,
+		Middleware:               opts.Middleware,
+		Revocation:               opts.Revocation,
+		AccessTokenTTL:           opts.AccessTokenTTL,
+		RefreshTokenTTL:          opts.RefreshTokenTTL,
+		Notifier:                 opts.Notifier,
+		PasswordResetTTL:         opts.PasswordResetTTL,
+		PasswordResetMaxAttempts: opts.PasswordResetMaxAttempts,
+		PasswordHistorySize:      opts.PasswordHistorySize,
+		PhoneThrottle:            opts.PhoneThrottle,
+		IPThrottle:               opts.IPThrottle,
+		RateLimiter:              opts.RateLimiter,
+		HashPool:                 opts.HashPool,
+		Secrets:                  opts.Secrets,
+		MfaIssuer:                opts.MfaIssuer,
+		MfaChallengeTTL:          opts.MfaChallengeTTL,
+		MfaMaxAttempts:           opts.MfaMaxAttempts,
+		MfaRecoveryCodes:         opts.MfaRecoveryCodes,
+		OtpTTL:                   opts.OtpTTL,
+		OtpMaxAttempts:           opts.OtpMaxAttempts,
+		OtpResendCooldown:        opts.OtpResendCooldown,
+		OtpLoginEnabled:          opts.OtpLoginEnabled,
+		Phones:                   opts.Phones,
+		Mailer:                   opts.Mailer,
+		EmailTokens:              opts.EmailTokens,
+		EmailVerificationTTL:     opts.EmailVerificationTTL,
+		EmailVerificationURL:     opts.EmailVerificationURL,
 	}
 }
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/middleware"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/gommon/log"
+)
+
+// IssueTokens creates a short-lived access token and a refresh token that belongs to familyID.
+func (s *Server) IssueTokens(ctx context.Context, user *repository.UserModel, familyID string) (*generated.LoginResponse, error) {
+	token, err := s.Jwt.CreateToken(middleware.UserJwtPayload{
+		ID:           user.ID,
+		TokenVersion: user.TokenVersion,
+		Role:         user.Role,
+	}, s.AccessTokenTTL)
+	if err != nil {
+		log.Errorf("CreateToken, error when creating token err:%s", err.Error())
+		return nil, err
+	}
+
+	refreshToken, err := commons.GenerateOpaqueToken()
+	if err != nil {
+		log.Errorf("IssueTokens, error when generating refresh token err:%s", err.Error())
+		return nil, err
+	}
+
+	_, err = s.Repository.CreateRefreshToken(ctx, repository.RefreshTokenInput{
+		UserID:    user.ID,
+		TokenHash: commons.HashToken(refreshToken),
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(s.RefreshTokenTTL),
+	})
+	if err != nil {
+		log.Errorf("IssueTokens, error when storing refresh token err:%s", err.Error())
+		return nil, err
+	}
+
+	return &generated.LoginResponse{
+		UserId:       user.ID,
+		Jwt:          token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// RefreshTokens exchanges a refresh token for a new token pair. The presented
+// token is revoked on use; presenting a revoked token again revokes its whole family.
+func (s *Server) RefreshTokens(ctx context.Context, refreshToken string) (*generated.LoginResponse, error) {
+	stored, err := s.Repository.GetRefreshToken(ctx, commons.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return nil, apperrors.InvalidCredentials(commons.ErrorInvalidRefreshToken)
+		}
+		log.Errorf("RefreshTokens, error when fetching refresh token err:%s", err.Error())
+		return nil, err
+	}
+
+	if stored.RevokedAt != nil {
+		return nil, s.revokeTokenFamily(ctx, stored)
+	}
+
+	if !stored.ExpiresAt.After(time.Now()) {
+		return nil, apperrors.InvalidCredentials(commons.ErrorInvalidRefreshToken)
+	}
+
+	if err := s.Repository.RevokeRefreshToken(ctx, stored.ID); err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			// another request rotated the same token first
+			return nil, s.revokeTokenFamily(ctx, stored)
+		}
+		log.Errorf("RefreshTokens, error when revoking refresh token err:%s", err.Error())
+		return nil, err
+	}
+
+	user, err := s.FetchUserById(ctx, stored.UserID)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return nil, apperrors.InvalidCredentials(commons.ErrorInvalidRefreshToken)
+		}
+		return nil, err
+	}
+
+	return s.IssueTokens(ctx, user, stored.FamilyID)
+}
+
+// revokeTokenFamily is called when a rotated refresh token is replayed.
+func (s *Server) revokeTokenFamily(ctx context.Context, stored *repository.RefreshTokenModel) error {
+	log.Warnf("RefreshTokens, reuse detected userId:%d family:%s", stored.UserID, stored.FamilyID)
+	if err := s.Repository.RevokeRefreshTokenFamily(ctx, stored.FamilyID); err != nil {
+		log.Errorf("RefreshTokens, error when revoking token family err:%s", err.Error())
+		return err
+	}
+	return apperrors.InvalidCredentials(commons.ErrorRefreshTokenReused)
+}
+
+// Logout revokes the presented access token and, when given, the refresh token family it came with.
+func (s *Server) Logout(ctx context.Context, data *middleware.JwtParsedPayload, refreshToken *string) error {
+	if err := s.Revocation.Revoke(ctx, data); err != nil {
+		log.Errorf("Logout, error when revoking access token err:%s", err.Error())
+		return err
+	}
+
+	if refreshToken == nil {
+		return nil
+	}
+
+	stored, err := s.Repository.GetRefreshToken(ctx, commons.HashToken(*refreshToken))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return nil
+		}
+		log.Errorf("Logout, error when fetching refresh token err:%s", err.Error())
+		return err
+	}
+	if stored.UserID != data.ID {
+		return nil
+	}
+
+	if err := s.Repository.RevokeRefreshTokenFamily(ctx, stored.FamilyID); err != nil {
+		log.Errorf("Logout, error when revoking token family err:%s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// LogoutAll invalidates every access token and refresh token issued to the user.
+func (s *Server) LogoutAll(ctx context.Context, userId int) error {
+	if err := s.Revocation.RevokeAll(ctx, userId); err != nil {
+		log.Errorf("LogoutAll, error when bumping token version err:%s", err.Error())
+		return err
+	}
+
+	if err := s.Repository.RevokeUserRefreshTokens(ctx, userId); err != nil {
+		log.Errorf("LogoutAll, error when revoking refresh tokens err:%s", err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	// defaultUserListLimit and maxUserListLimit bound a page of GET /admin/users
+	defaultUserListLimit = 20
+	maxUserListLimit     = 100
+)
+
+// userListSorts maps the sort parameter of GET /admin/users to the column users are ordered by
+var userListSorts = map[generated.GetAdminUsersParamsSort]repository.UserColumn{
+	generated.Id:          repository.UserColumnID,
+	generated.FullName:    repository.UserColumnFullName,
+	generated.PhoneNumber: repository.UserColumnPhoneNumber,
+	generated.CreatedAt:   repository.UserColumnCreatedAt,
+	generated.UpdatedAt:   repository.UserColumnUpdatedAt,
+}
+
+// listCursor is what a nextCursor encodes. It names the order it was made for, a cursor makes no
+// sense in another one.
+type listCursor struct {
+	Sort  repository.UserColumn    `json:"s"`
+	Order repository.SortDirection `json:"o"`
+	Value json.RawMessage          `json:"v"`
+	ID    int                      `json:"id"`
+}
+
+// ListUsers returns a page of the users matching params, see GET /admin/users
+func (s *Server) ListUsers(ctx context.Context, params generated.GetAdminUsersParams) (*generated.UserListResponse, error) {
+	filter, limit, err := userListFilter(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// one more user than the page tells whether there is a page after it
+	users, err := s.Repository.ListUsers(ctx, filter.Limit(limit+1))
+	if err != nil {
+		log.Errorf("error listing users: %v", err)
+		return nil, err
+	}
+
+	response := &generated.UserListResponse{Users: []generated.AdminUserResponse{}}
+	if len(users) > limit {
+		users = users[:limit]
+		cursor, err := encodeListCursor(filter, users[limit-1])
+		if err != nil {
+			return nil, err
+		}
+		response.NextCursor = &cursor
+	}
+	for _, user := range users {
+		response.Users = append(response.Users, adminUserResponse(user))
+	}
+
+	if params.IncludeTotal != nil && *params.IncludeTotal {
+		total, err := s.Repository.CountUsers(ctx, filter)
+		if err != nil {
+			log.Errorf("error counting users: %v", err)
+			return nil, err
+		}
+		response.Total = &total
+	}
+
+	return response, nil
+}
+
+// userListFilter returns the filter of params and the size of the page
+func userListFilter(params generated.GetAdminUsersParams) (*repository.UserFilter, int, error) {
+	limit := defaultUserListLimit
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+	if limit < 1 || limit > maxUserListLimit {
+		return nil, 0, apperrors.Validation(fmt.Sprintf(commons.ErrorInvalidListLimit, maxUserListLimit))
+	}
+
+	filter := repository.NewUserFilter()
+	if params.PhonePrefix != nil {
+		filter.HasPrefix(repository.UserColumnPhoneNumber, *params.PhonePrefix)
+	}
+	if params.Name != nil {
+		filter.Contains(repository.UserColumnFullName, *params.Name)
+	}
+	if params.CreatedFrom != nil {
+		filter.From(repository.UserColumnCreatedAt, *params.CreatedFrom)
+	}
+	if params.CreatedTo != nil {
+		filter.Before(repository.UserColumnCreatedAt, *params.CreatedTo)
+	}
+	if params.Status != nil {
+		switch *params.Status {
+		case generated.Verified:
+			filter.IsNotNull(repository.UserColumnPhoneVerifiedAt)
+		case generated.Unverified:
+			filter.IsNull(repository.UserColumnPhoneVerifiedAt)
+		default:
+			return nil, 0, apperrors.Validation(commons.ErrorInvalidFilter)
+		}
+	}
+
+	column, direction := repository.UserColumnID, repository.Ascending
+	if params.Sort != nil {
+		var ok bool
+		if column, ok = userListSorts[*params.Sort]; !ok {
+			return nil, 0, apperrors.Validation(commons.ErrorInvalidFilter)
+		}
+	}
+	if params.Order != nil {
+		direction = repository.SortDirection(*params.Order)
+	}
+	filter.OrderBy(column, direction)
+
+	if params.Cursor != nil {
+		cursor, err := decodeListCursor(*params.Cursor, column, direction)
+		if err != nil {
+			return nil, 0, apperrors.Wrap(apperrors.ErrValidation, commons.ErrorInvalidCursor, err)
+		}
+		filter.After(cursor)
+	}
+
+	return filter, limit, filter.Err()
+}
+
+// encodeListCursor returns the nextCursor of a page that ends with user
+func encodeListCursor(filter *repository.UserFilter, user repository.UserModel) (string, error) {
+	cursor := filter.CursorOf(user)
+	value, err := json.Marshal(cursor.Value)
+	if err != nil {
+		return "", err
+	}
+
+	encoded, err := json.Marshal(listCursor{Sort: filter.SortColumn(), Order: filter.SortDirection(), Value: value, ID: cursor.ID})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(encoded), nil
+}
+
+// decodeListCursor reads a nextCursor made for users ordered by column in direction
+func decodeListCursor(encoded string, column repository.UserColumn, direction repository.SortDirection) (repository.UserCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return repository.UserCursor{}, err
+	}
+	var decoded listCursor
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return repository.UserCursor{}, err
+	}
+	if decoded.Sort != column || decoded.Order != direction {
+		return repository.UserCursor{}, fmt.Errorf("cursor of %s %s used for %s %s", decoded.Sort, decoded.Order, column, direction)
+	}
+
+	// the value has the Go type of the column, a time comes back from JSON as a string
+	var value interface{}
+	switch column {
+	case repository.UserColumnID:
+		var id int
+		err = json.Unmarshal(decoded.Value, &id)
+		value = id
+	case repository.UserColumnCreatedAt, repository.UserColumnUpdatedAt:
+		var t time.Time
+		err = json.Unmarshal(decoded.Value, &t)
+		value = t
+	default:
+		var text string
+		err = json.Unmarshal(decoded.Value, &text)
+		value = text
+	}
+	if err != nil {
+		return repository.UserCursor{}, err
+	}
+	return repository.UserCursor{Value: value, ID: decoded.ID}, nil
+}
+
+func adminUserResponse(user repository.UserModel) generated.AdminUserResponse {
+	response := generated.AdminUserResponse{
+		UserId:        user.ID,
+		FullName:      user.FullName,
+		PhoneNumber:   user.PhoneNumber,
+		PhoneVerified: user.PhoneVerifiedAt != nil,
+		Role:          user.Role,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+	}
+	if user.Email != nil {
+		emailVerified := user.EmailVerifiedAt != nil
+		response.Email, response.EmailVerified = user.Email, &emailVerified
+	}
+	return response
+}
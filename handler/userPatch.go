@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"reflect"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// MIMEMergePatch is the content type of a JSON Merge Patch (RFC 7396)
+	MIMEMergePatch = "application/merge-patch+json"
+	// MIMEJSONPatch is the content type of a JSON Patch (RFC 6902)
+	MIMEJSONPatch = "application/json-patch+json"
+)
+
+// userPatch changes the JSON document of a user, see userDocument
+type userPatch func(doc interface{}) (interface{}, error)
+
+// UserEdit is a validated edit of a user profile, only its non-nil fields change
+type UserEdit struct {
+	generated.UserEditRequest
+	// RemoveEmail is set when the patch removes the email of the user
+	RemoveEmail bool `json:"-"`
+}
+
+// parseUserPatch reads the body of PATCH /user/{id}/edit by its content type, a plain JSON
+// body is a merge patch. It returns an apperrors.ErrUnsupportedMediaType for any other content type.
+func parseUserPatch(ctx echo.Context) (userPatch, error) {
+	mediaType := echo.MIMEApplicationJSON
+	if contentType := ctx.Request().Header.Get(echo.HeaderContentType); contentType != "" {
+		parsed, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, apperrors.UnsupportedMediaType(commons.ErrorUnsupportedMediaType)
+		}
+		mediaType = parsed
+	}
+
+	body, err := io.ReadAll(ctx.Request().Body)
+	if err != nil {
+		return nil, apperrors.Validation(fmt.Sprintf(commons.InValidData, err))
+	}
+
+	switch mediaType {
+	case echo.MIMEApplicationJSON, MIMEMergePatch:
+		var patch map[string]interface{}
+		if err := json.Unmarshal(body, &patch); err != nil {
+			return nil, apperrors.Validation(fmt.Sprintf(commons.InValidData, err))
+		}
+		return func(doc interface{}) (interface{}, error) {
+			return commons.MergePatch(doc, patch), nil
+		}, nil
+	case MIMEJSONPatch:
+		var operations []commons.PatchOperation
+		if err := json.Unmarshal(body, &operations); err != nil {
+			return nil, apperrors.Validation(fmt.Sprintf(commons.InValidData, err))
+		}
+		return func(doc interface{}) (interface{}, error) {
+			return commons.ApplyJSONPatch(doc, operations)
+		}, nil
+	}
+	return nil, apperrors.UnsupportedMediaType(commons.ErrorUnsupportedMediaType)
+}
+
+// userDocument is the JSON document a patch of the user is applied to, it holds the fields
+// PATCH /user/{id}/edit may change
+func userDocument(user *repository.UserModel) map[string]interface{} {
+	doc := map[string]interface{}{
+		"phoneNumber": user.PhoneNumber,
+		"fullName":    user.FullName,
+	}
+	if user.Email != nil {
+		doc["email"] = *user.Email
+	}
+	return doc
+}
+
+// userEdit applies patch to the document of the user and returns the fields it changed,
+// validated. Fields the patch leaves as they are are neither validated nor written.
+func userEdit(user *repository.UserModel, patch userPatch) (*UserEdit, error) {
+	current := userDocument(user)
+	patched, err := patch(current)
+	if err != nil {
+		return nil, err
+	}
+	target, ok := patched.(map[string]interface{})
+	if !ok {
+		return nil, apperrors.Validation(fmt.Sprintf(commons.InValidData, "the patched user is not an object"))
+	}
+
+	edit := &UserEdit{}
+	for name := range current {
+		if target[name] != nil {
+			continue
+		}
+		if name != "email" {
+			return nil, apperrors.Validation(fmt.Sprintf(commons.InValidData, name+" cannot be removed"), apperrors.FieldError{Field: name, Message: "cannot be removed"})
+		}
+		edit.RemoveEmail = true
+	}
+
+	changes := map[string]interface{}{}
+	for name, value := range target {
+		if value != nil && !reflect.DeepEqual(current[name], value) {
+			changes[name] = value
+		}
+	}
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return nil, apperrors.Validation(fmt.Sprintf(commons.InValidData, err))
+	}
+	if err := json.Unmarshal(changesJSON, &edit.UserEditRequest); err != nil {
+		return nil, apperrors.Validation(fmt.Sprintf(commons.InValidData, err))
+	}
+
+	if err := commons.Validate.Struct(edit); err != nil {
+		return nil, validationError(err)
+	}
+	return edit, nil
+}
+
+// userResponse is the profile of a user as returned by the API
+func userResponse(user *repository.UserModel) generated.UserResponse {
+	phoneVerified := user.PhoneVerifiedAt != nil
+	response := generated.UserResponse{
+		UserId:        &user.ID,
+		FullName:      &user.FullName,
+		PhoneNumber:   &user.PhoneNumber,
+		PhoneVerified: &phoneVerified,
+	}
+	if user.Email != nil {
+		emailVerified := user.EmailVerifiedAt != nil
+		response.Email, response.EmailVerified = user.Email, &emailVerified
+	}
+	return response
+}
@@ -3,32 +3,43 @@ package handler
 import (
 	"context"
 	"errors"
-	"fmt"
+	"github.com/SawitProRecruitment/UserService/apperrors"
 	"github.com/SawitProRecruitment/UserService/commons"
 	"github.com/SawitProRecruitment/UserService/generated"
-	"github.com/SawitProRecruitment/UserService/middleware"
 	"github.com/SawitProRecruitment/UserService/repository"
 	"github.com/labstack/gommon/log"
 )
 
 func (s *Server) RegisterNewUser(ctx context.Context, req *generated.UserRegisterRequest) error {
 	saltKey := s.Pwd.CreateSalt()
-	hashedPass, err := s.Pwd.GenerateHash(req.Password, saltKey)
+	hashedPass, pepperVersion, err := s.hashPassword(ctx, req.Password, saltKey)
 	if err != nil {
 		log.Errorf("error hashing password: %v", err)
 		return err
 	}
 
-	_, err = s.Repository.CreateUser(ctx, repository.UserInput{
-		PhoneNumber: req.PhoneNumber,
-		Password:    hashedPass,
-		FullName:    req.FullName,
-		SaltKey:     saltKey,
+	userID, err := s.Repository.CreateUser(ctx, repository.UserInput{
+		PhoneNumber:   req.PhoneNumber,
+		Password:      hashedPass,
+		FullName:      req.FullName,
+		SaltKey:       saltKey,
+		PepperVersion: pepperVersion,
+		Email:         req.Email,
 	})
 	if err != nil {
 		log.Errorf("error creating user: %v", err)
 		return err
 	}
+
+	// the user exists either way, a code or link that failed to send can be resent once logged in
+	if _, err := s.StartPhoneVerification(ctx, userID, req.PhoneNumber); err != nil {
+		log.Warnf("RegisterNewUser, verification code was not sent userId:%d err:%s", userID, err.Error())
+	}
+	if req.Email != nil {
+		if err := s.SendEmailVerification(ctx, userID, *req.Email); err != nil {
+			log.Warnf("RegisterNewUser, verification link was not sent userId:%d err:%s", userID, err.Error())
+		}
+	}
 	return nil
 }
 
@@ -37,42 +48,69 @@ func (s *Server) FetchUserByPhoneNumber(ctx context.Context, phoneNumber string)
 		PhoneNumber: &phoneNumber,
 	})
 
-	if err != nil && err.Error() != commons.ErrorNoData {
+	if err != nil && !errors.Is(err, apperrors.ErrNotFound) {
 		log.Errorf("error fetching user: %v", err)
 		return nil, err
 	}
 	return user, nil
 }
 
-func (s *Server) PerformLogin(ctx context.Context, req *generated.LoginRequest) (*repository.UserModel, string, error) {
-	user, err := s.Repository.GetUser(ctx, repository.GetUserInput{
-		PhoneNumber: &req.PhoneNumber,
-	})
+// LoginResult holds either the token pair or, when the user enabled MFA, the challenge to complete with /login/mfa
+type LoginResult struct {
+	Tokens    *generated.LoginResponse
+	Challenge *generated.MfaChallengeResponse
+}
 
+func (s *Server) PerformLogin(ctx context.Context, req *generated.LoginRequest) (*LoginResult, error) {
+	user, err := s.FetchUserByPhoneNumberOrEmail(ctx, req.PhoneNumber, req.Email)
 	if err != nil {
-		if err.Error() == commons.ErrorNoRow { // assuming commons.ErrorNoRow is a constant for "user not found"
-			return nil, "", errors.New("user not found")
+		return nil, err
+	}
+	if user == nil {
+		// unknown phone numbers and emails take as long to reject as wrong passwords
+		if err := s.verifyDummyPassword(ctx, req.Password); err != nil {
+			return nil, err
 		}
-		log.Errorf("Error when checking phone number from DB: %s", err.Error())
-		return nil, "", err
+		return nil, apperrors.InvalidCredentials(commons.ErrorInvalidCredentials)
 	}
 
 	// Validate the password
-	ok := s.Pwd.VerifyPassword(req.Password, user.Password, user.SaltKey)
+	ok, err := s.verifyPassword(ctx, req.Password, user.Password, user.SaltKey, user.PepperVersion)
+	if err != nil {
+		return nil, err
+	}
 	if !ok {
-		return nil, "", errors.New("invalid password")
+		return nil, apperrors.InvalidCredentials(commons.ErrorInvalidCredentials)
 	}
 
-	// Create JWT Token
-	token, err := s.Jwt.CreateToken(middleware.UserJwtPayload{
-		ID: user.ID,
-	}, 9)
+	if s.Pwd.NeedsRehash(user.Password, user.PepperVersion) {
+		s.rehashPassword(ctx, user, req.Password)
+	}
+
+	return s.completeLogin(ctx, user, accountThrottleKey(req.PhoneNumber, req.Email))
+}
+
+// completeLogin issues the token pair to a user who passed the first factor, or starts the MFA challenge.
+// accountKey is the login throttle key of the login, the challenge counts wrong codes against it.
+func (s *Server) completeLogin(ctx context.Context, user *repository.UserModel, accountKey string) (*LoginResult, error) {
+	mfaEnabled, err := s.mfaEnabled(ctx, user.ID)
 	if err != nil {
-		log.Errorf("CreateToken, error when creating token err:%s", err.Error())
-		return nil, "", err
+		return nil, err
+	}
+	if mfaEnabled {
+		challenge, err := s.startMfaChallenge(ctx, user, accountKey)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{Challenge: challenge}, nil
 	}
 
-	return user, token, nil
+	// Every login starts a new refresh token family
+	tokens, err := s.IssueTokens(ctx, user, commons.GenerateTokenFamily())
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{Tokens: tokens}, nil
 }
 
 func (s *Server) FetchUserById(ctx context.Context, userId int) (*repository.UserModel, error) {
@@ -80,29 +118,95 @@ func (s *Server) FetchUserById(ctx context.Context, userId int) (*repository.Use
 		ID: &userId,
 	})
 	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return nil, apperrors.Wrap(apperrors.ErrNotFound, commons.ErrorUserNotFound, err)
+		}
 		log.Errorf("FetchUserById, found error fetching user by id: %v", err)
 		return nil, err
 	}
 	return user, nil
 }
 
-func (s *Server) EditUser(ctx context.Context, userId int, req *generated.UserEditRequest) error {
-	user, err := s.Repository.GetUser(ctx, repository.GetUserInput{PhoneNumber: req.PhoneNumber})
-	if err != nil && err.Error() != commons.ErrorNoRow {
-		return err
+// EditUser writes the fields of edit that differ from current and returns the user as edited. The
+// write fails with repository.ErrVersionConflict when the user is no longer at the version of current.
+// The full name is saved right away. A new phone number only replaces the current one once the code
+// texted to it is confirmed, the returned result tells where that code went. The code is only sent
+// once the write went through, a phone change alone still moves the user to a new version. A new
+// email is saved unverified and a verification link is mailed to it.
+func (s *Server) EditUser(ctx context.Context, current *repository.UserModel, edit *UserEdit) (*repository.UserModel, *PhoneVerificationResult, error) {
+	userId := current.ID
+	user := *current
+
+	newEmail := edit.Email != nil && (current.Email == nil || *edit.Email != *current.Email)
+	if newEmail {
+		owner, err := s.FetchUserByEmail(ctx, *edit.Email)
+		if err != nil {
+			return nil, nil, err
+		}
+		if owner != nil {
+			return nil, nil, repository.ErrEmailExists
+		}
 	}
-	if user != nil && user.ID != userId {
-		return fmt.Errorf("phone number already exist")
+
+	newPhone := edit.PhoneNumber != nil && *edit.PhoneNumber != current.PhoneNumber
+	if newPhone {
+		owner, err := s.FetchUserByPhoneNumber(ctx, *edit.PhoneNumber)
+		if err != nil {
+			return nil, nil, err
+		}
+		if owner != nil && owner.ID != userId {
+			return nil, nil, repository.ErrUserExists
+		}
+
+		// nothing is saved when no code can be sent yet
+		wait, err := s.otpCooldown(ctx, userId, OtpPurposePhoneVerification)
+		if err != nil {
+			return nil, nil, err
+		}
+		if wait > 0 {
+			return &user, &PhoneVerificationResult{RetryAfter: wait}, nil
+		}
 	}
 
-	err = s.Repository.UpdateUser(ctx, repository.UserInput{
-		ID:          userId,
-		PhoneNumber: *req.PhoneNumber,
-		FullName:    *req.FullName,
-	})
-	if err != nil && err.Error() != commons.ErrorNoRow {
-		return err
+	update := repository.UpdateUserInput{ID: userId, Version: current.Version}
+	if edit.FullName != nil && *edit.FullName != current.FullName {
+		update.FullName = edit.FullName
+		user.FullName = *edit.FullName
+	}
+	if newEmail {
+		update.Email = edit.Email
+		user.Email, user.EmailVerifiedAt = edit.Email, nil
+	} else if edit.RemoveEmail && current.Email != nil {
+		update.RemoveEmail = true
+		user.Email, user.EmailVerifiedAt = nil, nil
+	}
+	// a phone change alone is a write without fields, the code must not go out for a stale version
+	if newPhone || update.FullName != nil || update.Email != nil || update.RemoveEmail {
+		version, err := s.Repository.UpdateUser(ctx, update)
+		if err != nil {
+			if !errors.Is(err, repository.ErrVersionConflict) {
+				log.Errorf("EditUser, error when updating user userId:%d err:%s", userId, err.Error())
+			}
+			return nil, nil, err
+		}
+		user.Version = version
 	}
 
-	return nil
+	var verification *PhoneVerificationResult
+	if newPhone {
+		var err error
+		verification, err = s.StartPhoneVerification(ctx, userId, *edit.PhoneNumber)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if newEmail {
+		// the email is saved either way, a link that failed to send can be resent
+		if err := s.SendEmailVerification(ctx, userId, *edit.Email); err != nil {
+			log.Warnf("EditUser, verification link was not sent userId:%d err:%s", userId, err.Error())
+		}
+	}
+
+	return &user, verification, nil
 }
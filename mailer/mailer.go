@@ -0,0 +1,105 @@
+// mailer package delivers emails such as verification links to users.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mail ...
+type Mail struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// MailerInterface is implemented by every mail sender
+type MailerInterface interface {
+	Send(ctx context.Context, mail Mail) error
+}
+
+// SMTPMailer sends mails through an SMTP relay, STARTTLS is used when the server offers it
+type SMTPMailer struct {
+	// Addr is "host:port" of the relay
+	Addr string
+	From string
+	// Username and Password authenticate with PLAIN auth, empty sends unauthenticated
+	Username string
+	Password string
+}
+
+// NewSMTPMailer ...
+func NewSMTPMailer(addr, from, username, password string) *SMTPMailer {
+	return &SMTPMailer{Addr: addr, From: from, Username: username, Password: password}
+}
+
+// Send ...
+func (m *SMTPMailer) Send(ctx context.Context, mail Mail) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return fmt.Errorf("invalid smtp address: %w", err)
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	// net/smtp takes no context, at least do not start once the caller gave up
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return smtp.SendMail(m.Addr, auth, m.From, []string{mail.To}, Format(m.From, mail, time.Now()))
+}
+
+// FileMailer writes every mail as an .eml file into Dir, tests and local setups open the
+// links from there instead of a mailbox
+type FileMailer struct {
+	Dir  string
+	From string
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewFileMailer ...
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{Dir: dir, From: from}
+}
+
+// Send ...
+func (m *FileMailer) Send(_ context.Context, mail Mail) error {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create mail directory: %w", err)
+	}
+	m.seq++
+	name := fmt.Sprintf("%s-%d.eml", now.UTC().Format("20060102T150405.000000000"), m.seq)
+	return os.WriteFile(filepath.Join(m.Dir, name), Format(m.From, mail, now), 0600)
+}
+
+// Format renders a plain text mail with its headers
+func Format(from string, mail Mail, date time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", mail.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", mail.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(mail.Body, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
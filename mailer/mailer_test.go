@@ -0,0 +1,45 @@
+package mailer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/mailer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	raw := string(mailer.Format("no-reply@example.com", mailer.Mail{
+		To:      "a@example.com",
+		Subject: "Verify your email",
+		Body:    "Open\nhttps://example.com/verify",
+	}, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+
+	assert.Contains(t, raw, "From: no-reply@example.com\r\n")
+	assert.Contains(t, raw, "To: a@example.com\r\n")
+	assert.Contains(t, raw, "Subject: Verify your email\r\n")
+	assert.Contains(t, raw, "Date: Tue, 02 Jan 2024 03:04:05 +0000\r\n")
+	assert.True(t, strings.HasSuffix(raw, "\r\n\r\nOpen\r\nhttps://example.com/verify\r\n"))
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m := mailer.NewFileMailer(dir, "no-reply@example.com")
+
+	require.NoError(t, m.Send(context.Background(), mailer.Mail{To: "a@example.com", Subject: "First", Body: "one"}))
+	require.NoError(t, m.Send(context.Background(), mailer.Mail{To: "b@example.com", Subject: "Second", Body: "two"}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	second, err := os.ReadFile(files[1])
+	require.NoError(t, err)
+	assert.Contains(t, string(second), "To: b@example.com")
+	assert.Contains(t, string(second), "two")
+}
@@ -4,33 +4,59 @@ import (
 	"fmt"
 	"github.com/SawitProRecruitment/UserService/commons"
 	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/gommon/log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // UserJwtPayload ...
 type UserJwtPayload struct {
-	ID int
+	ID           int
+	TokenVersion int
+	Role         string
 }
 
 // JwtParsedPayload ...
 type JwtParsedPayload struct {
-	ID     int
-	Expire int64
+	ID           int
+	Expire       int64
+	TokenID      string
+	TokenVersion int
+	Role         string
 }
 
 // Middleware ...
 type Middleware struct {
 	Jwt        JwtInterface
 	Repository repository.RepositoryInterface
+	Revocation RevocationStoreInterface
+
+	routes map[string]routeSecurity
 }
 
 type Jwt struct {
-	PrivateKey []byte `json:"private_key"`
-	PublicKey  []byte `json:"public_key"`
+	Keys     *KeyManager
+	Issuer   string
+	Audience string
+	// Leeway tolerates clock skew between instances when checking exp, nbf and iat
+	Leeway time.Duration
+	// AcceptLegacy keeps accepting tokens that carry the user ID in the "id" claim
+	AcceptLegacy bool
+}
+
+// UserClaims ...
+type UserClaims struct {
+	jwt.RegisteredClaims
+	TokenVersion int    `json:"ver"`
+	Role         string `json:"role,omitempty"`
+	// LegacyID is only set on tokens issued before the "sub" claim was used
+	LegacyID string `json:"id,omitempty"`
 }
 
 // Payload ...
@@ -40,9 +66,10 @@ type Payload struct {
 
 // JwtInterface ...
 type JwtInterface interface {
-	CreateToken(jwtData UserJwtPayload, expireInHour int) (string, error)
+	CreateToken(jwtData UserJwtPayload, expiresIn time.Duration) (string, error)
 	ParseToken(tokenString string) (*JwtParsedPayload, error)
 	IsValid(tokenString string) (bool, error)
+	JWKS() JSONWebKeySet
 }
 
 // IMiddlewareInterface ...
@@ -50,51 +77,110 @@ type IMiddlewareInterface interface {
 	Auth(next echo.HandlerFunc) echo.HandlerFunc
 }
 
-// NewMiddleware for creating new middleware
-func NewMiddleware(jwt JwtInterface, repo repository.RepositoryInterface) *Middleware {
-	return &Middleware{Jwt: jwt, Repository: repo}
+// NewMiddleware for creating new middleware, spec is the OpenAPI document whose
+// security requirements decide which routes Auth protects
+func NewMiddleware(jwt JwtInterface, repo repository.RepositoryInterface, revocation RevocationStoreInterface, spec *openapi3.T) *Middleware {
+	return &Middleware{Jwt: jwt, Repository: repo, Revocation: revocation, routes: securityRoutes(spec)}
 }
 
-// Auth function
+// Auth validates the Bearer token on every route api.yml does not declare
+// public, checks the scopes the route requires and stores the caller as a
+// Principal on the request. Routes missing from the spec require authentication as well.
 func (m Middleware) Auth(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		headers := c.Request().Header
-		valueList, found := headers[http.CanonicalHeaderKey("Authorization")]
-		if !found {
-			return echo.NewHTTPError(http.StatusForbidden, "missing Authorization Header")
+		// unmatched routes are answered by echo's 404/405 handlers
+		if c.Path() == "" {
+			return next(c)
 		}
 
-		ok, err := m.Jwt.IsValid(valueList[0]) // Assuming IsValid returns an additional payload if valid
-		if err != nil {
-			log.Errorf("Auth Error: %s", err.Error())
-			return echo.NewHTTPError(http.StatusForbidden, "invalid Authorization Token")
+		security, found := m.routes[c.Request().Method+" "+c.Path()]
+		if found && security.Public {
+			return next(c)
 		}
+
+		tokenString, ok := bearerToken(c.Request().Header.Get(echo.HeaderAuthorization))
 		if !ok {
-			return echo.NewHTTPError(http.StatusForbidden, "authorization Token is Not Valid")
+			return unauthorized(c, "", "missing bearer token")
+		}
+
+		payload, err := m.Jwt.ParseToken(tokenString)
+		if err != nil {
+			log.Warnf("Auth, invalid token err:%s", err.Error())
+			return unauthorized(c, "invalid_token", commons.ErrorInvalidToken)
+		}
+
+		revoked, err := m.Revocation.IsRevoked(c.Request().Context(), payload)
+		if err != nil {
+			log.Errorf("Auth, error when checking revocation err:%s", err.Error())
+			return echo.NewHTTPError(http.StatusInternalServerError, commons.ErrSystemError)
+		}
+		if revoked {
+			return unauthorized(c, "invalid_token", commons.ErrorTokenRevoked)
+		}
+
+		principal := &Principal{UserID: payload.ID, Role: payload.Role, Scopes: ScopesForRole(payload.Role), Token: payload}
+		for _, scope := range security.Scopes {
+			if !principal.HasScope(scope) {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, strings.Join(security.Scopes, " ")))
+				return echo.NewHTTPError(http.StatusForbidden, "Forbidden")
+			}
 		}
 
+		SetPrincipal(c, principal)
 		return next(c)
 	}
 }
 
-func (j *Jwt) CreateToken(jwtData UserJwtPayload, expireInHour int) (string, error) {
-	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(j.PrivateKey)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse private key: %w", err)
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header
+func bearerToken(header string) (string, bool) {
+	scheme, token, found := strings.Cut(strings.TrimSpace(header), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
 	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
 
-	token := jwt.New(jwt.SigningMethodRS256)
-	claims := token.Claims.(jwt.MapClaims)
-	claims[commons.IDClaimKey] = fmt.Sprint(jwtData)
-	claims[commons.ExpClaimKey] = time.Now().Add(time.Hour * time.Duration(expireInHour)).Unix()
+func unauthorized(c echo.Context, errorCode string, message string) error {
+	challenge := "Bearer"
+	if errorCode != "" {
+		challenge = fmt.Sprintf(`Bearer error="%s"`, errorCode)
+	}
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, challenge)
+	return echo.NewHTTPError(http.StatusUnauthorized, message)
+}
+
+func (j *Jwt) CreateToken(jwtData UserJwtPayload, expiresIn time.Duration) (string, error) {
+	signingKey := j.Keys.ActiveKey()
+	now := time.Now()
 
-	tokenString, err := token.SignedString(privateKey)
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, UserClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(jwtData.ID),
+			Issuer:    j.Issuer,
+			Audience:  jwt.ClaimStrings{j.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+			ID:        uuid.New().String(),
+		},
+		TokenVersion: jwtData.TokenVersion,
+		Role:         jwtData.Role,
+	})
+	token.Header[commons.KidHeaderKey] = signingKey.ID
+
+	tokenString, err := token.SignedString(signingKey.PrivateKey)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
 	return tokenString, nil
 }
 
+// JWKS returns the public keys tokens can currently be verified with
+func (j *Jwt) JWKS() JSONWebKeySet {
+	return j.Keys.JWKS()
+}
+
 func (j *Jwt) IsValid(tokenString string) (bool, error) {
 	jwtData, err := j.ParseToken(tokenString)
 	if err != nil {
@@ -104,31 +190,74 @@ func (j *Jwt) IsValid(tokenString string) (bool, error) {
 }
 
 func (j *Jwt) ParseToken(tokenString string) (*JwtParsedPayload, error) {
-	publicKey, err := jwt.ParseRSAPublicKeyFromPEM(j.PublicKey)
+	claims := &UserClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header[commons.KidHeaderKey].(string)
+		return j.Keys.VerificationKey(kid)
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}), jwt.WithIssuedAt(), jwt.WithLeeway(j.Leeway))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse public key: %w", err)
+		return nil, fmt.Errorf("failed to parse token: %w", err)
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+	if claims.ExpiresAt == nil {
+		return nil, fmt.Errorf("invalid token: missing exp")
 	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return publicKey, nil
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse token: %w", err)
+	if claims.Subject == "" {
+		return j.parseLegacyClaims(claims)
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
-		return nil, fmt.Errorf("invalid token")
+	if claims.Issuer != j.Issuer {
+		return nil, fmt.Errorf("invalid token: unexpected issuer %q", claims.Issuer)
+	}
+	if !containsAudience(claims.Audience, j.Audience) {
+		return nil, fmt.Errorf("invalid token: audience does not contain %q", j.Audience)
+	}
+	if claims.IssuedAt == nil || claims.NotBefore == nil || claims.ID == "" {
+		return nil, fmt.Errorf("invalid token: missing iat, nbf or jti")
 	}
-	id, err := commons.ConvertInterfaceToInt(claims[commons.IDClaimKey])
+
+	id, err := strconv.Atoi(claims.Subject)
 	if err != nil {
-		return nil, fmt.Errorf("failed to convert ID: %w", err)
+		return nil, fmt.Errorf("failed to convert subject: %w", err)
+	}
+
+	return &JwtParsedPayload{
+		ID:           id,
+		Expire:       claims.ExpiresAt.Unix(),
+		TokenID:      claims.ID,
+		TokenVersion: claims.TokenVersion,
+		Role:         claims.Role,
+	}, nil
+}
+
+// parseLegacyClaims reads tokens issued before registered claims were used,
+// they are accepted until they expire unless AcceptLegacy is turned off.
+func (j *Jwt) parseLegacyClaims(claims *UserClaims) (*JwtParsedPayload, error) {
+	if !j.AcceptLegacy || claims.LegacyID == "" {
+		return nil, fmt.Errorf("invalid token: missing sub")
 	}
 
-	exp, err := commons.ConvertInterfaceToInt64(claims[commons.ExpClaimKey])
+	id, err := commons.ConvertInterfaceToInt(claims.LegacyID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to convert Expire time: %w", err)
+		return nil, fmt.Errorf("failed to convert ID: %w", err)
 	}
 
-	return &JwtParsedPayload{ID: id, Expire: exp}, nil
+	return &JwtParsedPayload{
+		ID:           id,
+		Expire:       claims.ExpiresAt.Unix(),
+		TokenID:      claims.ID,
+		TokenVersion: claims.TokenVersion,
+	}, nil
+}
+
+func containsAudience(audience jwt.ClaimStrings, expected string) bool {
+	for _, aud := range audience {
+		if aud == expected {
+			return true
+		}
+	}
+	return false
 }
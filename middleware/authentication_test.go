@@ -0,0 +1,327 @@
+package middleware_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/middleware"
+	authMocks "github.com/SawitProRecruitment/UserService/middleware/mocks"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestJwt(t *testing.T) (*middleware.Jwt, *rsa.PrivateKey) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	content := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+	keys, err := middleware.NewStaticKeyManager("default", content)
+	require.NoError(t, err)
+
+	return &middleware.Jwt{
+		Keys:         keys,
+		Issuer:       "user-service",
+		Audience:     "user-service",
+		AcceptLegacy: true,
+	}, privateKey
+}
+
+func TestJwtClaims(t *testing.T) {
+	j, privateKey := newTestJwt(t)
+
+	sign := func(claims jwt.Claims, kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		tokenString, err := token.SignedString(privateKey)
+		require.NoError(t, err)
+		return tokenString
+	}
+
+	validClaims := func() *middleware.UserClaims {
+		now := time.Now()
+		return &middleware.UserClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "42",
+				Issuer:    "user-service",
+				Audience:  jwt.ClaimStrings{"user-service"},
+				IssuedAt:  jwt.NewNumericDate(now),
+				NotBefore: jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+				ID:        "jti",
+			},
+			TokenVersion: 3,
+		}
+	}
+
+	t.Run("Round Trip", func(t *testing.T) {
+		tokenString, err := j.CreateToken(middleware.UserJwtPayload{ID: 42, TokenVersion: 3}, time.Hour)
+		require.NoError(t, err)
+
+		claims := &middleware.UserClaims{}
+		_, _, err = jwt.NewParser().ParseUnverified(tokenString, claims)
+		require.NoError(t, err)
+		assert.Equal(t, "42", claims.Subject)
+		assert.Equal(t, "user-service", claims.Issuer)
+		assert.Equal(t, jwt.ClaimStrings{"user-service"}, claims.Audience)
+		assert.NotNil(t, claims.IssuedAt)
+		assert.NotNil(t, claims.NotBefore)
+		assert.NotEmpty(t, claims.ID)
+		assert.Empty(t, claims.LegacyID)
+
+		payload, err := j.ParseToken(tokenString)
+		require.NoError(t, err)
+		assert.Equal(t, 42, payload.ID)
+		assert.Equal(t, 3, payload.TokenVersion)
+		assert.Equal(t, claims.ID, payload.TokenID)
+	})
+
+	rejected := map[string]func(c *middleware.UserClaims){
+		"Wrong Issuer":     func(c *middleware.UserClaims) { c.Issuer = "someone-else" },
+		"Wrong Audience":   func(c *middleware.UserClaims) { c.Audience = jwt.ClaimStrings{"another-service"} },
+		"Missing Jti":      func(c *middleware.UserClaims) { c.ID = "" },
+		"Missing Iat":      func(c *middleware.UserClaims) { c.IssuedAt = nil },
+		"Missing Nbf":      func(c *middleware.UserClaims) { c.NotBefore = nil },
+		"Missing Exp":      func(c *middleware.UserClaims) { c.ExpiresAt = nil },
+		"Expired":          func(c *middleware.UserClaims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) },
+		"Not Yet Valid":    func(c *middleware.UserClaims) { c.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Hour)) },
+		"Issued In Future": func(c *middleware.UserClaims) { c.IssuedAt = jwt.NewNumericDate(time.Now().Add(time.Hour)) },
+		"Non Numeric Sub":  func(c *middleware.UserClaims) { c.Subject = "abc" },
+		"No Sub And No Id": func(c *middleware.UserClaims) { c.Subject = "" },
+	}
+	for name, mutate := range rejected {
+		t.Run(name, func(t *testing.T) {
+			claims := validClaims()
+			mutate(claims)
+
+			_, err := j.ParseToken(sign(claims, "default"))
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("Unknown Kid", func(t *testing.T) {
+		_, err := j.ParseToken(sign(validClaims(), "retired"))
+		assert.Error(t, err)
+	})
+
+	t.Run("HMAC Algorithm Rejected", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims())
+		tokenString, err := token.SignedString([]byte("secret"))
+		require.NoError(t, err)
+
+		_, err = j.ParseToken(tokenString)
+		assert.Error(t, err)
+	})
+
+	t.Run("Legacy Token Accepted Until Expiry", func(t *testing.T) {
+		legacy := sign(jwt.MapClaims{"id": "{42}", "exp": time.Now().Add(time.Hour).Unix()}, "")
+
+		payload, err := j.ParseToken(legacy)
+		require.NoError(t, err)
+		assert.Equal(t, 42, payload.ID)
+
+		expired := sign(jwt.MapClaims{"id": "{42}", "exp": time.Now().Add(-time.Hour).Unix()}, "")
+		_, err = j.ParseToken(expired)
+		assert.Error(t, err)
+	})
+
+	t.Run("Legacy Token Rejected When Disabled", func(t *testing.T) {
+		strict := *j
+		strict.AcceptLegacy = false
+
+		legacy := sign(jwt.MapClaims{"id": "{42}", "exp": time.Now().Add(time.Hour).Unix()}, "")
+		_, err := strict.ParseToken(legacy)
+		assert.Error(t, err)
+	})
+}
+
+const testSpec = `
+openapi: 3.0.0
+info:
+  title: test
+  version: 1.0.0
+security:
+  - bearerAuth: []
+paths:
+  /health:
+    get:
+      security: []
+      responses:
+        "200":
+          description: ok
+  /user/{id}:
+    get:
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: integer
+      responses:
+        "200":
+          description: ok
+  /admin/users:
+    get:
+      security:
+        - bearerAuth: [admin]
+      responses:
+        "200":
+          description: ok
+components:
+  securitySchemes:
+    bearerAuth:
+      type: http
+      scheme: bearer
+      bearerFormat: JWT
+`
+
+func TestAuth(t *testing.T) {
+	spec, err := openapi3.NewLoader().LoadFromData([]byte(testSpec))
+	require.NoError(t, err)
+
+	payload := &middleware.JwtParsedPayload{ID: 42, TokenID: "jti"}
+
+	newServer := func(jwtMock *authMocks.JwtInterface, revocation *authMocks.RevocationStoreInterface) *echo.Echo {
+		m := middleware.NewMiddleware(jwtMock, nil, revocation, spec)
+
+		e := echo.New()
+		e.Use(m.Auth)
+		handler := func(c echo.Context) error {
+			principal, ok := middleware.GetPrincipal(c)
+			if !ok {
+				return c.NoContent(http.StatusOK)
+			}
+			return c.String(http.StatusOK, strconv.Itoa(principal.UserID))
+		}
+		e.GET("/health", handler)
+		e.GET("/user/:id", handler)
+		e.GET("/undocumented", handler)
+		e.GET("/admin/users", handler)
+		return e
+	}
+
+	serve := func(e *echo.Echo, path string, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if authorization != "" {
+			req.Header.Set(echo.HeaderAuthorization, authorization)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Public Route", func(t *testing.T) {
+		jwtMock := new(authMocks.JwtInterface)
+		rec := serve(newServer(jwtMock, nil), "/health", "")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		jwtMock.AssertNotCalled(t, "ParseToken", mock.Anything)
+	})
+
+	t.Run("Missing Token", func(t *testing.T) {
+		rec := serve(newServer(new(authMocks.JwtInterface), nil), "/user/42", "")
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "Bearer", rec.Header().Get(echo.HeaderWWWAuthenticate))
+	})
+
+	t.Run("Wrong Scheme", func(t *testing.T) {
+		rec := serve(newServer(new(authMocks.JwtInterface), nil), "/user/42", "Basic abc")
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("Invalid Token", func(t *testing.T) {
+		jwtMock := new(authMocks.JwtInterface)
+		jwtMock.On("ParseToken", "bad").Return(nil, errors.New("simulate err"))
+
+		rec := serve(newServer(jwtMock, nil), "/user/42", "Bearer bad")
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, `Bearer error="invalid_token"`, rec.Header().Get(echo.HeaderWWWAuthenticate))
+	})
+
+	t.Run("Revoked Token", func(t *testing.T) {
+		jwtMock := new(authMocks.JwtInterface)
+		revocation := new(authMocks.RevocationStoreInterface)
+		jwtMock.On("ParseToken", "token").Return(payload, nil)
+		revocation.On("IsRevoked", mock.Anything, payload).Return(true, nil)
+
+		rec := serve(newServer(jwtMock, revocation), "/user/42", "Bearer token")
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("Revocation Store Error", func(t *testing.T) {
+		jwtMock := new(authMocks.JwtInterface)
+		revocation := new(authMocks.RevocationStoreInterface)
+		jwtMock.On("ParseToken", "token").Return(payload, nil)
+		revocation.On("IsRevoked", mock.Anything, payload).Return(false, errors.New("simulate err"))
+
+		rec := serve(newServer(jwtMock, revocation), "/user/42", "Bearer token")
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+
+	t.Run("Principal Injected", func(t *testing.T) {
+		jwtMock := new(authMocks.JwtInterface)
+		revocation := new(authMocks.RevocationStoreInterface)
+		jwtMock.On("ParseToken", "token").Return(payload, nil)
+		revocation.On("IsRevoked", mock.Anything, payload).Return(false, nil)
+
+		rec := serve(newServer(jwtMock, revocation), "/user/42", "bearer token")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "42", rec.Body.String())
+	})
+
+	t.Run("Missing Scope", func(t *testing.T) {
+		jwtMock := new(authMocks.JwtInterface)
+		revocation := new(authMocks.RevocationStoreInterface)
+		jwtMock.On("ParseToken", "token").Return(payload, nil)
+		revocation.On("IsRevoked", mock.Anything, payload).Return(false, nil)
+
+		rec := serve(newServer(jwtMock, revocation), "/admin/users", "Bearer token")
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, `Bearer error="insufficient_scope", scope="admin"`, rec.Header().Get(echo.HeaderWWWAuthenticate))
+	})
+
+	t.Run("Admin Scope Granted", func(t *testing.T) {
+		admin := &middleware.JwtParsedPayload{ID: 1, TokenID: "jti", Role: "admin"}
+		jwtMock := new(authMocks.JwtInterface)
+		revocation := new(authMocks.RevocationStoreInterface)
+		jwtMock.On("ParseToken", "token").Return(admin, nil)
+		revocation.On("IsRevoked", mock.Anything, admin).Return(false, nil)
+
+		rec := serve(newServer(jwtMock, revocation), "/admin/users", "Bearer token")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "1", rec.Body.String())
+	})
+
+	t.Run("Undocumented Route Requires Token", func(t *testing.T) {
+		rec := serve(newServer(new(authMocks.JwtInterface), nil), "/undocumented", "")
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("Unknown Route", func(t *testing.T) {
+		rec := serve(newServer(new(authMocks.JwtInterface), nil), "/missing", "")
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
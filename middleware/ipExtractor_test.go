@@ -0,0 +1,51 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/middleware"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIPExtractor(t *testing.T) {
+	newRequest := func(remoteAddr string, forwardedFor string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set(echo.HeaderXForwardedFor, forwardedFor)
+		req.Header.Set(echo.HeaderXRealIP, forwardedFor)
+		return req
+	}
+
+	t.Run("Headers Ignored Without Trusted Proxies", func(t *testing.T) {
+		extract, err := middleware.NewIPExtractor(nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, "10.0.0.1", extract(newRequest("10.0.0.1:5000", "203.0.113.1")))
+	})
+
+	t.Run("Client Behind Trusted Proxy", func(t *testing.T) {
+		extract, err := middleware.NewIPExtractor([]string{"10.0.0.0/8", " 192.168.1.1"})
+		require.NoError(t, err)
+
+		assert.Equal(t, "203.0.113.1", extract(newRequest("10.0.0.1:5000", "203.0.113.1")))
+		assert.Equal(t, "203.0.113.1", extract(newRequest("192.168.1.1:5000", "203.0.113.1")))
+		// a forged address in front of the one the proxy appended is skipped
+		assert.Equal(t, "203.0.113.1", extract(newRequest("10.0.0.1:5000", "198.51.100.7, 203.0.113.1")))
+	})
+
+	t.Run("Headers Ignored From Untrusted Peer", func(t *testing.T) {
+		extract, err := middleware.NewIPExtractor([]string{"10.0.0.0/8"})
+		require.NoError(t, err)
+
+		assert.Equal(t, "192.168.1.1", extract(newRequest("192.168.1.1:5000", "203.0.113.1")))
+	})
+
+	t.Run("Invalid Trusted Proxy", func(t *testing.T) {
+		_, err := middleware.NewIPExtractor([]string{"10.0.0.0/33"})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// keyFileExtension marks the files KeyManager loads from its directory
+	keyFileExtension = ".pem"
+	// activeKeyFile optionally names the kid used for signing
+	activeKeyFile = "active"
+)
+
+// SigningKey ...
+type SigningKey struct {
+	ID         string
+	PrivateKey *rsa.PrivateKey
+}
+
+// JSONWebKey is the public part of a signing key as published in the JWKS document
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JSONWebKeySet ...
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// KeyManager holds every key tokens may be verified with and the one new tokens are signed with.
+//
+// Keys are RSA private keys stored as <kid>.pem inside Dir. The key used for
+// signing is the one named in the "active" file, or the last kid in
+// lexical order when that file is absent. A key is retired by removing its
+// file, after which tokens signed with it are rejected. Reload picks up changes
+// without a restart.
+type KeyManager struct {
+	Dir string
+	// LegacyKid names the key that signed tokens issued before kid headers
+	// existed, such tokens are rejected when it is empty or its key is retired
+	LegacyKid string
+
+	mu     sync.RWMutex
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// NewKeyManager loads the keys found in dir, tokens without a kid are verified with legacyKid.
+func NewKeyManager(dir, legacyKid string) (*KeyManager, error) {
+	manager := &KeyManager{Dir: dir, LegacyKid: legacyKid}
+	if err := manager.Reload(); err != nil {
+		return nil, err
+	}
+	return manager, nil
+}
+
+// NewStaticKeyManager serves a single key pair, this is what the service used before key rotation existed.
+func NewStaticKeyManager(kid string, privateKeyPEM []byte) (*KeyManager, error) {
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privateKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	key := &SigningKey{ID: kid, PrivateKey: privateKey}
+	return &KeyManager{LegacyKid: kid, active: key, keys: map[string]*SigningKey{kid: key}}, nil
+}
+
+// Reload re-reads Dir. On error the previously loaded keys stay in use.
+func (k *KeyManager) Reload() error {
+	if k.Dir == "" {
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(k.Dir, "*"+keyFileExtension))
+	if err != nil {
+		return err
+	}
+
+	keys := map[string]*SigningKey{}
+	var kids []string
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(content)
+		if err != nil {
+			return fmt.Errorf("failed to parse private key %s: %w", file, err)
+		}
+
+		kid := strings.TrimSuffix(filepath.Base(file), keyFileExtension)
+		keys[kid] = &SigningKey{ID: kid, PrivateKey: privateKey}
+		kids = append(kids, kid)
+	}
+
+	if len(kids) == 0 {
+		return fmt.Errorf("no signing keys found in %s", k.Dir)
+	}
+	sort.Strings(kids)
+
+	activeKid := kids[len(kids)-1]
+	content, err := os.ReadFile(filepath.Join(k.Dir, activeKeyFile))
+	if err == nil {
+		activeKid = strings.TrimSpace(string(content))
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	active, found := keys[activeKid]
+	if !found {
+		return fmt.Errorf("active key %q not found in %s", activeKid, k.Dir)
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.active = active
+	k.mu.Unlock()
+	return nil
+}
+
+// ActiveKey returns the key new tokens are signed with.
+func (k *KeyManager) ActiveKey() *SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// VerificationKey returns the public key for kid. Tokens issued before kid
+// headers existed are verified with the LegacyKid key, never the active one.
+func (k *KeyManager) VerificationKey(kid string) (*rsa.PublicKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if kid == "" {
+		if k.LegacyKid == "" {
+			return nil, fmt.Errorf("token has no kid and no legacy signing key is configured")
+		}
+		kid = k.LegacyKid
+	}
+
+	key, found := k.keys[kid]
+	if !found {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return &key.PrivateKey.PublicKey, nil
+}
+
+// JWKS returns every key that may still be used for verification.
+func (k *KeyManager) JWKS() JSONWebKeySet {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(k.keys))}
+	for kid, key := range k.keys {
+		publicKey := key.PrivateKey.PublicKey
+		set.Keys = append(set.Keys, JSONWebKey{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: jwt.SigningMethodRS256.Alg(),
+			Kid: kid,
+			N:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+		})
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
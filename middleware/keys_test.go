@@ -0,0 +1,138 @@
+package middleware_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/middleware"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKey(t *testing.T, dir, kid string) *rsa.PrivateKey {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	content := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, kid+".pem"), content, 0600))
+	return privateKey
+}
+
+func TestKeyManagerRotation(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "2024-01")
+
+	keys, err := middleware.NewKeyManager(dir, "")
+	require.NoError(t, err)
+	jwt := &middleware.Jwt{Keys: keys}
+
+	oldToken, err := jwt.CreateToken(middleware.UserJwtPayload{ID: 1}, time.Hour)
+	require.NoError(t, err)
+
+	// a newer key becomes active without invalidating tokens signed with the old one
+	writeKey(t, dir, "2024-02")
+	require.NoError(t, keys.Reload())
+	assert.Equal(t, "2024-02", keys.ActiveKey().ID)
+
+	newToken, err := jwt.CreateToken(middleware.UserJwtPayload{ID: 2}, time.Hour)
+	require.NoError(t, err)
+
+	payload, err := jwt.ParseToken(oldToken)
+	require.NoError(t, err)
+	assert.Equal(t, 1, payload.ID)
+
+	payload, err = jwt.ParseToken(newToken)
+	require.NoError(t, err)
+	assert.Equal(t, 2, payload.ID)
+
+	jwks := jwt.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "2024-01", jwks.Keys[0].Kid)
+	assert.Equal(t, "RS256", jwks.Keys[0].Alg)
+	assert.Equal(t, "AQAB", jwks.Keys[0].E)
+
+	// the active file overrides lexical order
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "active"), []byte("2024-01\n"), 0600))
+	require.NoError(t, keys.Reload())
+	assert.Equal(t, "2024-01", keys.ActiveKey().ID)
+
+	// retiring a key rejects the tokens it signed
+	require.NoError(t, os.Remove(filepath.Join(dir, "2024-02.pem")))
+	require.NoError(t, keys.Reload())
+
+	_, err = jwt.ParseToken(newToken)
+	assert.Error(t, err)
+	assert.Len(t, jwt.JWKS().Keys, 1)
+}
+
+func TestKeyManagerReloadKeepsKeysOnError(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "2024-01")
+
+	keys, err := middleware.NewKeyManager(dir, "")
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "active"), []byte("missing"), 0600))
+	assert.Error(t, keys.Reload())
+	assert.Equal(t, "2024-01", keys.ActiveKey().ID)
+}
+
+func TestKeyManagerLegacyKid(t *testing.T) {
+	dir := t.TempDir()
+	legacyKey := writeKey(t, dir, "default")
+
+	// tokens from before kid headers existed carry no kid
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"id":  "{42}",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString(legacyKey)
+	require.NoError(t, err)
+
+	t.Run("Verified With The Legacy Key After Rotation", func(t *testing.T) {
+		keys, err := middleware.NewKeyManager(dir, "default")
+		require.NoError(t, err)
+		j := &middleware.Jwt{Keys: keys, AcceptLegacy: true}
+
+		writeKey(t, dir, "2024-01")
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "active"), []byte("2024-01"), 0600))
+		require.NoError(t, keys.Reload())
+		assert.Equal(t, "2024-01", keys.ActiveKey().ID)
+
+		payload, err := j.ParseToken(legacy)
+		require.NoError(t, err)
+		assert.Equal(t, 42, payload.ID)
+
+		// a kid-less token signed with the active key is not one of them
+		forged, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"id":  "{1}",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}).SignedString(keys.ActiveKey().PrivateKey)
+		require.NoError(t, err)
+		_, err = j.ParseToken(forged)
+		assert.Error(t, err)
+
+		// retiring the legacy key rejects its tokens
+		require.NoError(t, os.Remove(filepath.Join(dir, "default.pem")))
+		require.NoError(t, keys.Reload())
+		_, err = j.ParseToken(legacy)
+		assert.Error(t, err)
+	})
+
+	t.Run("Rejected Without A Legacy Kid", func(t *testing.T) {
+		dir := t.TempDir()
+		writeKey(t, dir, "2024-01")
+
+		keys, err := middleware.NewKeyManager(dir, "")
+		require.NoError(t, err)
+
+		_, err = keys.VerificationKey("")
+		assert.Error(t, err)
+	})
+}
@@ -3,6 +3,8 @@
 package mocks
 
 import (
+	time "time"
+
 	middleware "github.com/SawitProRecruitment/UserService/middleware"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -12,23 +14,23 @@ type JwtInterface struct {
 	mock.Mock
 }
 
-// CreateToken provides a mock function with given fields: jwtData, expireInHour
-func (_m *JwtInterface) CreateToken(jwtData middleware.UserJwtPayload, expireInHour int) (string, error) {
-	ret := _m.Called(jwtData, expireInHour)
+// CreateToken provides a mock function with given fields: jwtData, expiresIn
+func (_m *JwtInterface) CreateToken(jwtData middleware.UserJwtPayload, expiresIn time.Duration) (string, error) {
+	ret := _m.Called(jwtData, expiresIn)
 
 	var r0 string
 	var r1 error
-	if rf, ok := ret.Get(0).(func(middleware.UserJwtPayload, int) (string, error)); ok {
-		return rf(jwtData, expireInHour)
+	if rf, ok := ret.Get(0).(func(middleware.UserJwtPayload, time.Duration) (string, error)); ok {
+		return rf(jwtData, expiresIn)
 	}
-	if rf, ok := ret.Get(0).(func(middleware.UserJwtPayload, int) string); ok {
-		r0 = rf(jwtData, expireInHour)
+	if rf, ok := ret.Get(0).(func(middleware.UserJwtPayload, time.Duration) string); ok {
+		r0 = rf(jwtData, expiresIn)
 	} else {
 		r0 = ret.Get(0).(string)
 	}
 
-	if rf, ok := ret.Get(1).(func(middleware.UserJwtPayload, int) error); ok {
-		r1 = rf(jwtData, expireInHour)
+	if rf, ok := ret.Get(1).(func(middleware.UserJwtPayload, time.Duration) error); ok {
+		r1 = rf(jwtData, expiresIn)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -60,6 +62,20 @@ func (_m *JwtInterface) IsValid(tokenString string) (bool, error) {
 	return r0, r1
 }
 
+// JWKS provides a mock function with given fields:
+func (_m *JwtInterface) JWKS() middleware.JSONWebKeySet {
+	ret := _m.Called()
+
+	var r0 middleware.JSONWebKeySet
+	if rf, ok := ret.Get(0).(func() middleware.JSONWebKeySet); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(middleware.JSONWebKeySet)
+	}
+
+	return r0
+}
+
 // ParseToken provides a mock function with given fields: tokenString
 func (_m *JwtInterface) ParseToken(tokenString string) (*middleware.JwtParsedPayload, error) {
 	ret := _m.Called(tokenString)
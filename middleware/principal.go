@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/labstack/echo/v4"
+)
+
+// principalKey is the key the authenticated caller is stored under in both
+// echo.Context and context.Context
+type principalKey struct{}
+
+// PrincipalContextKey ...
+const PrincipalContextKey = "principal"
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID int
+	Role   string
+	// Scopes are granted by Role and matched against the scopes of bearerAuth in api.yml
+	Scopes []string
+	Token  *JwtParsedPayload
+}
+
+// HasScope ...
+func (p *Principal) HasScope(scope string) bool {
+	for _, granted := range p.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopesForRole returns the scopes a role is granted
+func ScopesForRole(role string) []string {
+	if role == commons.RoleAdmin {
+		return []string{commons.RoleAdmin}
+	}
+	return nil
+}
+
+// WithPrincipal returns a copy of ctx carrying p
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the caller stored by Auth, if any
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// SetPrincipal stores p on the echo context and on its request context
+func SetPrincipal(c echo.Context, p *Principal) {
+	c.Set(PrincipalContextKey, p)
+	c.SetRequest(c.Request().WithContext(WithPrincipal(c.Request().Context(), p)))
+}
+
+// GetPrincipal returns the caller of the current request, if it was authenticated
+func GetPrincipal(c echo.Context) (*Principal, bool) {
+	if p, ok := c.Get(PrincipalContextKey).(*Principal); ok && p != nil {
+		return p, true
+	}
+	return PrincipalFromContext(c.Request().Context())
+}
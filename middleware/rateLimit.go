@@ -0,0 +1,244 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	// RateLimitKeyIP limits each client IP
+	RateLimitKeyIP = "ip"
+	// RateLimitKeyUser limits each authenticated user, anonymous requests are limited per client IP
+	RateLimitKeyUser = "user"
+	// RateLimitKeyFieldPrefix followed by a JSON field name limits each value of that field of the request
+	// body, requests without the field are not limited by the rule
+	RateLimitKeyFieldPrefix = "field:"
+
+	// maxRateLimitBody caps how much of a request body is read to find a field
+	maxRateLimitBody = 64 << 10
+)
+
+// RateLimitRule is one policy of the rate limiter, as declared in the rules file
+type RateLimitRule struct {
+	// Route is the method and api.yml path, e.g. "POST /register" or "PATCH /user/{id}/password"
+	Route string `json:"route"`
+	// Key is RateLimitKeyIP, RateLimitKeyUser or RateLimitKeyFieldPrefix + field
+	Key string `json:"key"`
+	// Limit requests are allowed per Period on average
+	Limit  int      `json:"limit"`
+	Period Duration `json:"period"`
+	// Burst is how many requests may be made at once, defaults to Limit
+	Burst int `json:"burst,omitempty"`
+}
+
+// Bucket returns the token bucket the rule describes
+func (r RateLimitRule) Bucket() TokenBucket {
+	burst := r.Burst
+	if burst == 0 {
+		burst = r.Limit
+	}
+	return TokenBucket{Capacity: burst, Interval: time.Duration(r.Period) / time.Duration(r.Limit)}
+}
+
+// Policy is the RateLimit-Policy header value of the rule
+func (r RateLimitRule) Policy() string {
+	return fmt.Sprintf("%d;w=%d;burst=%d", r.Limit, int(time.Duration(r.Period).Seconds()), r.Bucket().Capacity)
+}
+
+func (r RateLimitRule) validate() error {
+	if len(strings.Fields(r.Route)) != 2 {
+		return fmt.Errorf("rate limit route %q must be \"METHOD /path\"", r.Route)
+	}
+	if r.Key != RateLimitKeyIP && r.Key != RateLimitKeyUser &&
+		(!strings.HasPrefix(r.Key, RateLimitKeyFieldPrefix) || r.Key == RateLimitKeyFieldPrefix) {
+		return fmt.Errorf("rate limit key %q of %s is not ip, user or field:<name>", r.Key, r.Route)
+	}
+	if r.Limit <= 0 || r.Period <= 0 || r.Burst < 0 {
+		return fmt.Errorf("rate limit of %s needs a positive limit and period", r.Route)
+	}
+	return nil
+}
+
+// Duration reads durations such as "15m" from JSON
+type Duration time.Duration
+
+// UnmarshalJSON ...
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string such as \"1m\": %w", err)
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON ...
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// ParseRateLimitRules reads a JSON array of rules
+func ParseRateLimitRules(data []byte) ([]RateLimitRule, error) {
+	var rules []RateLimitRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid rate limit rules: %w", err)
+	}
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// RateLimiter rejects requests once the token bucket of a matching rule is empty
+type RateLimiter struct {
+	Store RateLimitStoreInterface
+	Now   func() time.Time
+	// FieldNormalizers canonicalize body fields before they become keys, e.g. so that one phone number
+	// typed two ways shares a bucket
+	FieldNormalizers map[string]func(string) string
+	// rules are keyed by "METHOD /echo/:path" like the security routes
+	rules map[string][]RateLimitRule
+}
+
+// NewRateLimiter ...
+func NewRateLimiter(store RateLimitStoreInterface, rules []RateLimitRule) (*RateLimiter, error) {
+	limiter := &RateLimiter{Store: store, Now: time.Now, rules: map[string][]RateLimitRule{}}
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(rule.Route)
+		route := strings.ToUpper(fields[0]) + " " + echoPath(fields[1])
+		limiter.rules[route] = append(limiter.rules[route], rule)
+	}
+	return limiter, nil
+}
+
+// LongestRefill is how long the slowest bucket takes to fill up, buckets untouched for longer can be purged
+func (l *RateLimiter) LongestRefill() time.Duration {
+	var longest time.Duration
+	for _, rules := range l.rules {
+		for _, rule := range rules {
+			if refill := rule.Bucket().RefillTime(); refill > longest {
+				longest = refill
+			}
+		}
+	}
+	return longest
+}
+
+// Limit must run after Auth so that rules keyed by user see the principal. A failing store lets requests through.
+func (l *RateLimiter) Limit(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		rules := l.rules[c.Request().Method+" "+c.Path()]
+		if len(rules) == 0 {
+			return next(c)
+		}
+
+		var reported *RateLimitResult
+		var reportedRule RateLimitRule
+		for _, rule := range rules {
+			requestKey, ok := l.requestKey(c, rule.Key)
+			if !ok {
+				continue
+			}
+			bucketKey := rule.Route + " " + requestKey
+			result, err := l.Store.Take(c.Request().Context(), bucketKey, rule.Bucket(), l.Now())
+			if err != nil {
+				log.Errorf("RateLimiter, error when taking a token key:%s err:%s", bucketKey, err.Error())
+				continue
+			}
+			// the headers describe the rule closest to rejecting the request
+			if reported == nil || !result.Allowed || (reported.Allowed && result.Remaining < reported.Remaining) {
+				reported, reportedRule = &result, rule
+			}
+			if !result.Allowed {
+				break
+			}
+		}
+		if reported == nil {
+			return next(c)
+		}
+
+		header := c.Response().Header()
+		header.Set("RateLimit-Limit", strconv.Itoa(reportedRule.Bucket().Capacity))
+		header.Set("RateLimit-Remaining", strconv.Itoa(reported.Remaining))
+		header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(reported.Reset)))
+		header.Set("RateLimit-Policy", reportedRule.Policy())
+		if !reported.Allowed {
+			header.Set("Retry-After", strconv.Itoa(ceilSeconds(reported.RetryAfter)))
+			return echo.NewHTTPError(http.StatusTooManyRequests, commons.ErrorRateLimited)
+		}
+		return next(c)
+	}
+}
+
+// requestKey identifies who a rule limits, e.g. "ip:10.0.0.1" or "field:phoneNumber=+6281...". A
+// field rule does not apply to a request without the field, a route limits such requests with its
+// ip rule. Anonymous requests of a user rule are limited per client IP in a bucket of their own,
+// e.g. "user@ip:10.0.0.1", apart from the one of an ip rule of the same route.
+func (l *RateLimiter) requestKey(c echo.Context, key string) (string, bool) {
+	switch {
+	case key == RateLimitKeyUser:
+		if principal, ok := GetPrincipal(c); ok {
+			return "user:" + strconv.Itoa(principal.UserID), true
+		}
+		return key + "@ip:" + c.RealIP(), true
+	case strings.HasPrefix(key, RateLimitKeyFieldPrefix):
+		field := strings.TrimPrefix(key, RateLimitKeyFieldPrefix)
+		value, ok := bodyField(c, field)
+		if !ok {
+			return "", false
+		}
+		if normalize, ok := l.FieldNormalizers[field]; ok {
+			value = normalize(value)
+		}
+		return key + "=" + value, true
+	}
+	return "ip:" + c.RealIP(), true
+}
+
+// bodyField reads a top level field of a JSON body and puts the body back for the handler
+func bodyField(c echo.Context, field string) (string, bool) {
+	req := c.Request()
+	if req.Body == nil {
+		return "", false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxRateLimitBody))
+	if err != nil {
+		return "", false
+	}
+	req.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), req.Body))
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return "", false
+	}
+	value, found := fields[field]
+	if !found || value == nil {
+		return "", false
+	}
+	return fmt.Sprint(value), true
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
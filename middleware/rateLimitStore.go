@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// rateLimitBucketsTable is created by migration 0007_create_rate_limit_buckets
+const rateLimitBucketsTable = "rate_limit_buckets"
+
+// TokenBucket holds up to Capacity tokens and gains one every Interval, a request takes one
+type TokenBucket struct {
+	Capacity int
+	Interval time.Duration
+}
+
+// RefillTime is how long an empty bucket takes to fill up
+func (b TokenBucket) RefillTime() time.Duration {
+	return time.Duration(b.Capacity) * b.Interval
+}
+
+// take refills a bucket last seen at updatedAt holding tokens and takes one if it can
+func (b TokenBucket) take(tokens float64, updatedAt time.Time, now time.Time) (float64, RateLimitResult) {
+	if elapsed := now.Sub(updatedAt); elapsed > 0 {
+		tokens += float64(elapsed) / float64(b.Interval)
+	}
+	tokens = math.Min(tokens, float64(b.Capacity))
+
+	result := RateLimitResult{}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - tokens) * float64(b.Interval))
+	}
+	result.Remaining = int(tokens)
+	result.Reset = time.Duration((float64(b.Capacity) - tokens) * float64(b.Interval))
+	return tokens, result
+}
+
+// RateLimitResult is the outcome of taking a token
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until a token is available, zero when Allowed
+	RetryAfter time.Duration
+}
+
+// RateLimitStoreInterface keeps token buckets by key
+type RateLimitStoreInterface interface {
+	Take(ctx context.Context, key string, bucket TokenBucket, now time.Time) (RateLimitResult, error)
+	// Purge drops buckets last used before the given time
+	Purge(ctx context.Context, before time.Time) error
+}
+
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// MemoryRateLimitStore keeps buckets in process, every instance limits on its own
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+// NewMemoryRateLimitStore ...
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: map[string]*memoryBucket{}}
+}
+
+// Take ...
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, bucket TokenBucket, now time.Time) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, found := s.buckets[key]
+	if !found {
+		state = &memoryBucket{tokens: float64(bucket.Capacity), updatedAt: now}
+		s.buckets[key] = state
+	}
+
+	tokens, result := bucket.take(state.tokens, state.updatedAt, now)
+	state.tokens, state.updatedAt = tokens, now
+	return result, nil
+}
+
+// Purge ...
+func (s *MemoryRateLimitStore) Purge(_ context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, state := range s.buckets {
+		if state.updatedAt.Before(before) {
+			delete(s.buckets, key)
+		}
+	}
+	return nil
+}
+
+// PostgresRateLimitStore keeps buckets in the rate_limit_buckets table so that every instance shares them
+type PostgresRateLimitStore struct {
+	Db *sql.DB
+}
+
+// NewPostgresRateLimitStore ...
+func NewPostgresRateLimitStore(db *sql.DB) *PostgresRateLimitStore {
+	return &PostgresRateLimitStore{Db: db}
+}
+
+// Take locks the bucket row for the duration of the transaction
+func (s *PostgresRateLimitStore) Take(ctx context.Context, key string, bucket TokenBucket, now time.Time) (RateLimitResult, error) {
+	tx, err := s.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	defer tx.Rollback()
+
+	insert := fmt.Sprintf(`
+		INSERT INTO %s (key, tokens, updatedAt)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO NOTHING`, rateLimitBucketsTable)
+	if _, err := tx.ExecContext(ctx, insert, key, bucket.Capacity, now); err != nil {
+		return RateLimitResult{}, err
+	}
+
+	var tokens float64
+	var updatedAt time.Time
+	selectQuery := fmt.Sprintf(`SELECT tokens, updatedAt FROM %s WHERE key = $1 FOR UPDATE`, rateLimitBucketsTable)
+	if err := tx.QueryRowContext(ctx, selectQuery, key).Scan(&tokens, &updatedAt); err != nil {
+		return RateLimitResult{}, err
+	}
+
+	tokens, result := bucket.take(tokens, updatedAt, now)
+
+	update := fmt.Sprintf(`UPDATE %s SET tokens = $1, updatedAt = $2 WHERE key = $3`, rateLimitBucketsTable)
+	if _, err := tx.ExecContext(ctx, update, tokens, now, key); err != nil {
+		return RateLimitResult{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return RateLimitResult{}, err
+	}
+	return result, nil
+}
+
+// Purge ...
+func (s *PostgresRateLimitStore) Purge(ctx context.Context, before time.Time) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE updatedAt < $1`, rateLimitBucketsTable)
+
+	_, err := s.Db.ExecContext(ctx, query, before)
+	return err
+}
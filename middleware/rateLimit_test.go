@@ -0,0 +1,247 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/middleware"
+	authMocks "github.com/SawitProRecruitment/UserService/middleware/mocks"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRateLimitRules(t *testing.T) {
+	rules, err := middleware.ParseRateLimitRules([]byte(`[
+		{"route": "POST /register", "key": "field:phoneNumber", "limit": 3, "period": "1h"},
+		{"route": "PATCH /user/{id}/password", "key": "user", "limit": 5, "period": "15m", "burst": 2}
+	]`))
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+
+	assert.Equal(t, middleware.TokenBucket{Capacity: 3, Interval: 20 * time.Minute}, rules[0].Bucket())
+	assert.Equal(t, "3;w=3600;burst=3", rules[0].Policy())
+	assert.Equal(t, middleware.TokenBucket{Capacity: 2, Interval: 3 * time.Minute}, rules[1].Bucket())
+
+	invalid := map[string]string{
+		"Bad Json":     `{`,
+		"Bad Period":   `[{"route": "POST /login", "key": "ip", "limit": 1, "period": "often"}]`,
+		"Bad Key":      `[{"route": "POST /login", "key": "cookie", "limit": 1, "period": "1m"}]`,
+		"Empty Field":  `[{"route": "POST /login", "key": "field:", "limit": 1, "period": "1m"}]`,
+		"Bad Route":    `[{"route": "/login", "key": "ip", "limit": 1, "period": "1m"}]`,
+		"Zero Limit":   `[{"route": "POST /login", "key": "ip", "limit": 0, "period": "1m"}]`,
+		"Negative Gap": `[{"route": "POST /login", "key": "ip", "limit": 1, "period": "-1m"}]`,
+	}
+	for name, raw := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := middleware.ParseRateLimitRules([]byte(raw))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	ctx := context.Background()
+	store := middleware.NewMemoryRateLimitStore()
+	bucket := middleware.TokenBucket{Capacity: 2, Interval: time.Second}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	result, err := store.Take(ctx, "key", bucket, now)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 1, result.Remaining)
+	assert.Equal(t, time.Second, result.Reset)
+
+	result, _ = store.Take(ctx, "key", bucket, now)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	result, _ = store.Take(ctx, "key", bucket, now.Add(500*time.Millisecond))
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+
+	result, _ = store.Take(ctx, "key", bucket, now.Add(time.Second))
+	assert.True(t, result.Allowed, "a token is back after one interval")
+
+	result, _ = store.Take(ctx, "other", bucket, now)
+	assert.True(t, result.Allowed, "keys have their own bucket")
+
+	require.NoError(t, store.Purge(ctx, now.Add(time.Minute)))
+	result, _ = store.Take(ctx, "key", bucket, now.Add(time.Second))
+	assert.Equal(t, 1, result.Remaining, "purged buckets start full")
+}
+
+func TestRateLimiter(t *testing.T) {
+	rules := []middleware.RateLimitRule{
+		{Route: "POST /register", Key: "field:phoneNumber", Limit: 1, Period: middleware.Duration(time.Hour)},
+		{Route: "POST /register", Key: "ip", Limit: 3, Period: middleware.Duration(time.Hour)},
+		{Route: "PATCH /user/{id}/password", Key: "user", Limit: 1, Period: middleware.Duration(time.Hour)},
+	}
+
+	newServer := func(store middleware.RateLimitStoreInterface) *echo.Echo {
+		limiter, err := middleware.NewRateLimiter(store, rules)
+		require.NoError(t, err)
+
+		e := echo.New()
+		e.IPExtractor, err = middleware.NewIPExtractor(nil)
+		require.NoError(t, err)
+		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				if userID := c.Request().Header.Get("X-Test-User"); userID != "" {
+					middleware.SetPrincipal(c, &middleware.Principal{UserID: len(userID)})
+				}
+				return next(c)
+			}
+		})
+		e.Use(limiter.Limit)
+		echoBody := func(c echo.Context) error {
+			body, _ := io.ReadAll(c.Request().Body)
+			return c.String(http.StatusOK, string(body))
+		}
+		e.POST("/register", echoBody)
+		e.PATCH("/user/:id/password", echoBody)
+		e.GET("/health", echoBody)
+		return e
+	}
+
+	serve := func(e *echo.Echo, method, path, body, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("X-Test-User", user)
+		req.RemoteAddr = "10.0.0.1:5000"
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Limited By Body Field", func(t *testing.T) {
+		e := newServer(middleware.NewMemoryRateLimitStore())
+
+		rec := serve(e, http.MethodPost, "/register", `{"phoneNumber":"+6281234567"}`, "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `{"phoneNumber":"+6281234567"}`, rec.Body.String(), "the handler still reads the body")
+		assert.Equal(t, "1", rec.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "3600", rec.Header().Get("RateLimit-Reset"))
+		assert.Equal(t, "1;w=3600;burst=1", rec.Header().Get("RateLimit-Policy"))
+
+		rec = serve(e, http.MethodPost, "/register", `{"phoneNumber":"+6281234567"}`, "")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "3600", rec.Header().Get("Retry-After"))
+
+		rec = serve(e, http.MethodPost, "/register", `{"phoneNumber":"+6289999999"}`, "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Body Field Normalized", func(t *testing.T) {
+		limiter, err := middleware.NewRateLimiter(middleware.NewMemoryRateLimitStore(), rules[:1])
+		require.NoError(t, err)
+		limiter.FieldNormalizers = map[string]func(string) string{"phoneNumber": func(value string) string {
+			return strings.ReplaceAll(value, " ", "")
+		}}
+		e := echo.New()
+		e.Use(limiter.Limit)
+		e.POST("/register", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+		assert.Equal(t, http.StatusOK, serve(e, http.MethodPost, "/register", `{"phoneNumber":"+62 812 34567"}`, "").Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(e, http.MethodPost, "/register", `{"phoneNumber":"+6281234567"}`, "").Code)
+	})
+
+	t.Run("Every Rule Of A Route Applies", func(t *testing.T) {
+		e := newServer(middleware.NewMemoryRateLimitStore())
+
+		for _, phone := range []string{"+621", "+622", "+623"} {
+			assert.Equal(t, http.StatusOK, serve(e, http.MethodPost, "/register", `{"phoneNumber":"`+phone+`"}`, "").Code)
+		}
+		rec := serve(e, http.MethodPost, "/register", `{"phoneNumber":"+624"}`, "")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code, "the client IP ran out")
+		assert.Equal(t, "3", rec.Header().Get("RateLimit-Limit"))
+	})
+
+	t.Run("Forged X-Forwarded-For Is The Same Client", func(t *testing.T) {
+		e := newServer(middleware.NewMemoryRateLimitStore())
+
+		for i, forged := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3", "203.0.113.4"} {
+			req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(`{"phoneNumber":"+62`+strconv.Itoa(i)+`"}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set(echo.HeaderXForwardedFor, forged)
+			req.Header.Set(echo.HeaderXRealIP, forged)
+			req.RemoteAddr = "10.0.0.1:5000"
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if i < 3 {
+				assert.Equal(t, http.StatusOK, rec.Code)
+			} else {
+				assert.Equal(t, http.StatusTooManyRequests, rec.Code, "the client IP ran out")
+			}
+		}
+	})
+
+	t.Run("Missing Field Skips The Field Rule", func(t *testing.T) {
+		e := newServer(middleware.NewMemoryRateLimitStore())
+
+		// the field rule allows 1 request, the ip rule 3, each request takes one token of the ip rule only
+		for i := 0; i < 3; i++ {
+			rec := serve(e, http.MethodPost, "/register", `{}`, "")
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "3", rec.Header().Get("RateLimit-Limit"))
+			assert.Equal(t, strconv.Itoa(2-i), rec.Header().Get("RateLimit-Remaining"))
+		}
+		rec := serve(e, http.MethodPost, "/register", `{}`, "")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code, "the client IP ran out")
+		assert.Equal(t, "3", rec.Header().Get("RateLimit-Limit"))
+	})
+
+	t.Run("Anonymous User Has A Bucket Of Its Own", func(t *testing.T) {
+		limiter, err := middleware.NewRateLimiter(middleware.NewMemoryRateLimitStore(), []middleware.RateLimitRule{
+			{Route: "POST /login", Key: "ip", Limit: 3, Period: middleware.Duration(time.Hour)},
+			{Route: "POST /login", Key: "user", Limit: 1, Period: middleware.Duration(time.Hour)},
+		})
+		require.NoError(t, err)
+		e := echo.New()
+		e.Use(limiter.Limit)
+		e.POST("/login", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+		rec := serve(e, http.MethodPost, "/login", `{}`, "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+
+		rec = serve(e, http.MethodPost, "/login", `{}`, "")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("RateLimit-Limit"), "rejected by the user rule")
+	})
+
+	t.Run("Limited By User", func(t *testing.T) {
+		e := newServer(middleware.NewMemoryRateLimitStore())
+
+		assert.Equal(t, http.StatusOK, serve(e, http.MethodPatch, "/user/1/password", `{}`, "a").Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(e, http.MethodPatch, "/user/1/password", `{}`, "a").Code)
+		assert.Equal(t, http.StatusOK, serve(e, http.MethodPatch, "/user/2/password", `{}`, "bb").Code)
+	})
+
+	t.Run("Route Without Rules", func(t *testing.T) {
+		rec := serve(newServer(middleware.NewMemoryRateLimitStore()), http.MethodGet, "/health", "", "")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+	})
+
+	t.Run("Store Error Lets Requests Through", func(t *testing.T) {
+		store := new(authMocks.RateLimitStoreInterface)
+		store.On("Take", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(middleware.RateLimitResult{}, errors.New("simulate err"))
+
+		rec := serve(newServer(store), http.MethodPost, "/register", `{"phoneNumber":"+621"}`, "")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/gommon/log"
+)
+
+// RevocationStoreInterface ...
+type RevocationStoreInterface interface {
+	Revoke(ctx context.Context, payload *JwtParsedPayload) error
+	RevokeAll(ctx context.Context, userID int) error
+	IsRevoked(ctx context.Context, payload *JwtParsedPayload) (bool, error)
+}
+
+// RevocationStore keeps revoked token ids in Postgres and caches them in memory
+// until the token would have expired anyway. Token versions are cached for
+// VersionTTL so "logout all" done on another instance is picked up quickly.
+type RevocationStore struct {
+	Repository repository.RepositoryInterface
+	VersionTTL time.Duration
+
+	mu       sync.RWMutex
+	revoked  map[string]time.Time
+	versions map[int]cachedTokenVersion
+}
+
+type cachedTokenVersion struct {
+	version   int
+	fetchedAt time.Time
+}
+
+// NewRevocationStore ...
+func NewRevocationStore(repo repository.RepositoryInterface, versionTTL time.Duration) *RevocationStore {
+	return &RevocationStore{
+		Repository: repo,
+		VersionTTL: versionTTL,
+		revoked:    map[string]time.Time{},
+		versions:   map[int]cachedTokenVersion{},
+	}
+}
+
+// Revoke rejects a single token until it expires.
+func (r *RevocationStore) Revoke(ctx context.Context, payload *JwtParsedPayload) error {
+	if payload.TokenID == "" {
+		// legacy tokens without jti can only be invalidated through RevokeAll
+		return nil
+	}
+
+	expiresAt := time.Unix(payload.Expire, 0)
+	err := r.Repository.RevokeToken(ctx, repository.RevokedTokenInput{
+		TokenID:   payload.TokenID,
+		UserID:    payload.ID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.revoked[payload.TokenID] = expiresAt
+	r.mu.Unlock()
+	return nil
+}
+
+// RevokeAll bumps the user's token version so every token issued before is rejected.
+func (r *RevocationStore) RevokeAll(ctx context.Context, userID int) error {
+	version, err := r.Repository.IncrementTokenVersion(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.versions[userID] = cachedTokenVersion{version: version, fetchedAt: time.Now()}
+	r.mu.Unlock()
+	return nil
+}
+
+// IsRevoked ...
+func (r *RevocationStore) IsRevoked(ctx context.Context, payload *JwtParsedPayload) (bool, error) {
+	now := time.Now()
+
+	version, err := r.tokenVersion(ctx, payload.ID, now)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			// the user no longer exists
+			return true, nil
+		}
+		return false, err
+	}
+	if payload.TokenVersion < version {
+		return true, nil
+	}
+
+	if payload.TokenID == "" {
+		return false, nil
+	}
+
+	r.mu.RLock()
+	expiresAt, found := r.revoked[payload.TokenID]
+	r.mu.RUnlock()
+	if found && expiresAt.After(now) {
+		return true, nil
+	}
+
+	revoked, err := r.Repository.IsTokenRevoked(ctx, payload.TokenID)
+	if err != nil {
+		return false, err
+	}
+	if revoked {
+		r.mu.Lock()
+		r.revoked[payload.TokenID] = time.Unix(payload.Expire, 0)
+		r.mu.Unlock()
+	}
+
+	return revoked, nil
+}
+
+func (r *RevocationStore) tokenVersion(ctx context.Context, userID int, now time.Time) (int, error) {
+	r.mu.RLock()
+	cached, found := r.versions[userID]
+	r.mu.RUnlock()
+	if found && now.Sub(cached.fetchedAt) < r.VersionTTL {
+		return cached.version, nil
+	}
+
+	user, err := r.Repository.GetUser(ctx, repository.GetUserInput{ID: &userID})
+	if err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	r.versions[userID] = cachedTokenVersion{version: user.TokenVersion, fetchedAt: now}
+	r.mu.Unlock()
+	return user.TokenVersion, nil
+}
+
+// Purge drops cache entries and rows for tokens that have expired. It is meant
+// to be run periodically.
+func (r *RevocationStore) Purge(ctx context.Context) {
+	now := time.Now()
+
+	r.mu.Lock()
+	for tokenID, expiresAt := range r.revoked {
+		if !expiresAt.After(now) {
+			delete(r.revoked, tokenID)
+		}
+	}
+	for userID, cached := range r.versions {
+		if now.Sub(cached.fetchedAt) >= r.VersionTTL {
+			delete(r.versions, userID)
+		}
+	}
+	r.mu.Unlock()
+
+	if err := r.Repository.PurgeRevokedTokens(ctx, now); err != nil {
+		log.Errorf("Purge, error when removing expired revoked tokens err:%s", err.Error())
+	}
+}
@@ -0,0 +1,95 @@
+package middleware_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/SawitProRecruitment/UserService/middleware"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRevocationStore(t *testing.T) {
+	ctx := context.Background()
+	expire := time.Now().Add(time.Hour).Unix()
+
+	t.Run("Older Token Version Is Revoked", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 1, TokenVersion: 3}, nil).Once()
+
+		store := middleware.NewRevocationStore(mockRepo, time.Minute)
+
+		revoked, err := store.IsRevoked(ctx, &middleware.JwtParsedPayload{ID: 1, Expire: expire, TokenVersion: 2})
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+		mockRepo.AssertNotCalled(t, "IsTokenRevoked", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Revoked Token Id Is Cached", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 1}, nil).Once()
+		mockRepo.On("IsTokenRevoked", mock.Anything, "jti-1").Return(true, nil).Once()
+
+		store := middleware.NewRevocationStore(mockRepo, time.Minute)
+		payload := &middleware.JwtParsedPayload{ID: 1, Expire: expire, TokenID: "jti-1"}
+
+		for i := 0; i < 2; i++ {
+			revoked, err := store.IsRevoked(ctx, payload)
+			assert.NoError(t, err)
+			assert.True(t, revoked)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Revoke And RevokeAll Update The Cache", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockRepo.On("RevokeToken", mock.Anything, repository.RevokedTokenInput{
+			TokenID:   "jti-1",
+			UserID:    1,
+			ExpiresAt: time.Unix(expire, 0),
+		}).Return(nil).Once()
+		mockRepo.On("IncrementTokenVersion", mock.Anything, 1).Return(1, nil).Once()
+
+		store := middleware.NewRevocationStore(mockRepo, time.Minute)
+
+		assert.NoError(t, store.RevokeAll(ctx, 1))
+		assert.NoError(t, store.Revoke(ctx, &middleware.JwtParsedPayload{ID: 1, Expire: expire, TokenID: "jti-1", TokenVersion: 1}))
+
+		revoked, err := store.IsRevoked(ctx, &middleware.JwtParsedPayload{ID: 1, Expire: expire, TokenID: "jti-1", TokenVersion: 1})
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+
+		revoked, err = store.IsRevoked(ctx, &middleware.JwtParsedPayload{ID: 1, Expire: expire, TokenID: "jti-0", TokenVersion: 0})
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+		mockRepo.AssertNotCalled(t, "GetUser", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Legacy Token Without Jti", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(&repository.UserModel{ID: 1}, nil).Once()
+
+		store := middleware.NewRevocationStore(mockRepo, time.Minute)
+
+		revoked, err := store.IsRevoked(ctx, &middleware.JwtParsedPayload{ID: 1, Expire: expire})
+		assert.NoError(t, err)
+		assert.False(t, revoked)
+		mockRepo.AssertNotCalled(t, "IsTokenRevoked", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Deleted User", func(t *testing.T) {
+		mockRepo := new(mocks.RepositoryInterface)
+		mockRepo.On("GetUser", mock.Anything, mock.Anything).Return(nil, apperrors.NotFound(commons.ErrorNoData))
+
+		store := middleware.NewRevocationStore(mockRepo, time.Minute)
+
+		revoked, err := store.IsRevoked(ctx, &middleware.JwtParsedPayload{ID: 1, Expire: expire, TokenID: "jti-1"})
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+	})
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+const (
+	// BearerSecurityScheme is the name of the JWT security scheme in api.yml
+	BearerSecurityScheme = "bearerAuth"
+)
+
+// routeSecurity is what api.yml requires from the caller of one operation
+type routeSecurity struct {
+	Public bool
+	Scopes []string
+}
+
+// securityRoutes maps "METHOD /echo/:path" to the security declared for the
+// operation, falling back to the document level requirement.
+func securityRoutes(spec *openapi3.T) map[string]routeSecurity {
+	routes := map[string]routeSecurity{}
+	if spec == nil {
+		return routes
+	}
+
+	for path, item := range spec.Paths {
+		for method, operation := range item.Operations() {
+			requirements := spec.Security
+			if operation.Security != nil {
+				requirements = *operation.Security
+			}
+			routes[method+" "+echoPath(path)] = toRouteSecurity(requirements)
+		}
+	}
+	return routes
+}
+
+func toRouteSecurity(requirements openapi3.SecurityRequirements) routeSecurity {
+	if len(requirements) == 0 {
+		return routeSecurity{Public: true}
+	}
+
+	security := routeSecurity{}
+	for _, requirement := range requirements {
+		// an empty requirement object makes authentication optional
+		if len(requirement) == 0 {
+			return routeSecurity{Public: true}
+		}
+		if scopes, found := requirement[BearerSecurityScheme]; found {
+			security.Scopes = append(security.Scopes, scopes...)
+		}
+	}
+	return security
+}
+
+// echoPath turns "/user/{id}" into "/user/:id" as reported by echo.Context.Path
+func echoPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			segments[i] = ":" + strings.TrimSuffix(strings.TrimPrefix(segment, "{"), "}")
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,105 @@
+// migrations package contains the versioned schema of the service and the runner that applies it.
+// Every migration is a pair of files in sql/<dialect>/, <version>_<name>.up.sql and <version>_<name>.down.sql,
+// embedded in the binary so that the schema always matches the code it ships with. Each dialect has
+// a migration set of its own with versions of its own.
+package migrations
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+//go:embed sql/postgres/*.sql sql/sqlite/*.sql
+var embedded embed.FS
+
+// fileName matches the file of one direction of a migration, e.g. 0001_create_users.up.sql
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one step of the schema, Up applies it and Down reverts it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Checksum is the SHA-256 of Up, recorded when the migration is applied so that a later edit of
+// an applied migration is noticed
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+// String ...
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Embedded returns the migrations of dialect shipped with the binary
+func Embedded(dialect repository.Dialect) ([]Migration, error) {
+	sqlFiles, err := fs.Sub(embedded, "sql/"+string(dialect))
+	if err != nil {
+		return nil, err
+	}
+	return Load(sqlFiles)
+}
+
+// Load reads the migrations in the root of fsys ordered by version. Every version needs both an up
+// and a down file and one name, files of another pattern are an error rather than silently skipped.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q, expected <version>_<name>.up.sql or .down.sql", entry.Name())
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %q", entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %q and %q", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %s needs both an up and a down file", migration)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
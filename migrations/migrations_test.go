@@ -0,0 +1,103 @@
+package migrations_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/SawitProRecruitment/UserService/migrations"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("Ordered By Version", func(t *testing.T) {
+		loaded, err := migrations.Load(fstest.MapFS{
+			"0002_add_email.up.sql":      {Data: []byte("ALTER TABLE users ADD email TEXT;")},
+			"0002_add_email.down.sql":    {Data: []byte("ALTER TABLE users DROP email;")},
+			"0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id INT);")},
+			"0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		})
+		require.NoError(t, err)
+		if assert.Len(t, loaded, 2) {
+			assert.Equal(t, migrations.Migration{Version: 1, Name: "create_users", Up: "CREATE TABLE users (id INT);", Down: "DROP TABLE users;"}, loaded[0])
+			assert.Equal(t, "0002_add_email", loaded[1].String())
+		}
+	})
+
+	invalid := map[string]fstest.MapFS{
+		"Missing Down": {
+			"0001_create_users.up.sql": {Data: []byte("CREATE TABLE users (id INT);")},
+		},
+		"Two Names": {
+			"0001_create_users.up.sql":    {Data: []byte("CREATE TABLE users (id INT);")},
+			"0001_create_people.down.sql": {Data: []byte("DROP TABLE users;")},
+		},
+		"Unknown File": {
+			"0001_create_users.sql": {Data: []byte("CREATE TABLE users (id INT);")},
+		},
+		"Version Zero": {
+			"0000_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id INT);")},
+			"0000_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		},
+	}
+	for name, fsys := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := migrations.Load(fsys)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEmbedded(t *testing.T) {
+	for _, dialect := range []repository.Dialect{repository.DialectPostgres, repository.DialectSQLite} {
+		t.Run(string(dialect), func(t *testing.T) {
+			embedded, err := migrations.Embedded(dialect)
+			require.NoError(t, err)
+			require.NotEmpty(t, embedded)
+
+			assert.Equal(t, "0001_create_users", embedded[0].String())
+			for i, migration := range embedded {
+				assert.Equal(t, i+1, migration.Version, "versions have no gaps")
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	all := []migrations.Migration{
+		{Version: 1, Name: "create_users", Up: "CREATE TABLE users (id INT);", Down: "DROP TABLE users;"},
+		{Version: 2, Name: "add_email", Up: "ALTER TABLE users ADD email TEXT;", Down: "ALTER TABLE users DROP email;"},
+	}
+	applied := func(migration migrations.Migration) migrations.Applied {
+		return migrations.Applied{Version: migration.Version, Name: migration.Name, Checksum: migration.Checksum()}
+	}
+
+	t.Run("Pending", func(t *testing.T) {
+		recorded := []migrations.Applied{applied(all[0])}
+		assert.NoError(t, migrations.Verify(all, recorded))
+		assert.Equal(t, all[1:], migrations.Pending(all, recorded))
+		assert.Empty(t, migrations.Pending(all, []migrations.Applied{applied(all[0]), applied(all[1])}))
+	})
+
+	t.Run("Changed After Applied", func(t *testing.T) {
+		edited := all[0]
+		edited.Up = "CREATE TABLE users (id BIGINT);"
+
+		err := migrations.Verify(all, []migrations.Applied{applied(edited)})
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "0001_create_users was changed")
+		}
+	})
+
+	t.Run("Missing After Applied", func(t *testing.T) {
+		err := migrations.Verify(all[1:], []migrations.Applied{applied(all[0]), applied(all[1])})
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "0001_create_users was applied but is missing")
+		}
+	})
+
+	t.Run("Applied By A Newer Release", func(t *testing.T) {
+		assert.NoError(t, migrations.Verify(all[:1], []migrations.Applied{applied(all[0]), applied(all[1])}))
+	})
+}
@@ -0,0 +1,344 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+// schemaMigrationsTable records every applied migration
+const schemaMigrationsTable = "schema_migrations"
+
+// advisoryLockID is the Postgres advisory lock held while migrating, any constant works as long as
+// every instance uses the same one
+const advisoryLockID int64 = 4_920_163_358
+
+// Applied is a migration recorded in schema_migrations
+type Applied struct {
+	Version   int
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Status is a migration of the binary and when it was applied, AppliedAt is zero while it is pending
+type Status struct {
+	Migration
+	AppliedAt time.Time
+}
+
+// Migrator applies migrations to a database. On Postgres runs are serialized with an advisory lock
+// so that instances started together migrate once. A SQLite file has a single instance, the primary
+// key of schema_migrations is enough there to fail a second run. Either way it refuses to go on when
+// an applied migration no longer matches the one in the binary.
+type Migrator struct {
+	Db         *sql.DB
+	Dialect    repository.Dialect
+	Migrations []Migration
+}
+
+// MigratorOptions ...
+type MigratorOptions struct {
+	Db *sql.DB
+	// Dialect defaults to Postgres
+	Dialect repository.Dialect
+	// Migrations defaults to the embedded ones of Dialect
+	Migrations []Migration
+}
+
+// NewMigrator ...
+func NewMigrator(opts MigratorOptions) (*Migrator, error) {
+	dialect := opts.Dialect
+	if dialect == "" {
+		dialect = repository.DialectPostgres
+	}
+
+	migrations := opts.Migrations
+	if migrations == nil {
+		var err error
+		if migrations, err = Embedded(dialect); err != nil {
+			return nil, err
+		}
+	}
+	return &Migrator{Db: opts.Db, Dialect: dialect, Migrations: migrations}, nil
+}
+
+// Up applies every pending migration in version order and returns them. Each migration runs in a
+// transaction of its own together with its schema_migrations row, a failed one leaves the ones before applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := listApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := Verify(m.Migrations, applied); err != nil {
+			return err
+		}
+
+		todo := Pending(m.Migrations, applied)
+		if len(applied) == 0 && len(todo) > 0 {
+			if err := m.checkUnmanaged(ctx, conn); err != nil {
+				return err
+			}
+		}
+
+		insert := m.Dialect.Rebind(fmt.Sprintf(`INSERT INTO %s (version, name, checksum) VALUES ($1, $2, $3)`, schemaMigrationsTable))
+		for _, migration := range todo {
+			err := inTx(ctx, conn, migration.Up, insert, migration.Version, migration.Name, migration.Checksum())
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", migration, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the last steps applied migrations, latest first, and returns them
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := listApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := Verify(m.Migrations, applied); err != nil {
+			return err
+		}
+
+		known := byVersion(m.Migrations)
+		remove := m.Dialect.Rebind(fmt.Sprintf(`DELETE FROM %s WHERE version = $1`, schemaMigrationsTable))
+		for i := len(applied) - 1; i >= 0 && len(done) < steps; i-- {
+			migration, ok := known[applied[i].Version]
+			if !ok {
+				return fmt.Errorf("migration %04d_%s is not in this binary, revert it with the release that applied it", applied[i].Version, applied[i].Name)
+			}
+			if err := inTx(ctx, conn, migration.Down, remove, migration.Version); err != nil {
+				return fmt.Errorf("migration %s: %w", migration, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status returns every migration of the binary and whether it was applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := listApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		appliedAt := map[int]time.Time{}
+		for _, record := range applied {
+			appliedAt[record.Version] = record.AppliedAt
+		}
+		for _, migration := range m.Migrations {
+			statuses = append(statuses, Status{Migration: migration, AppliedAt: appliedAt[migration.Version]})
+		}
+		return Verify(m.Migrations, applied)
+	})
+	return statuses, err
+}
+
+// Baseline records the migrations up to version as applied without running them. It is how a
+// database created before migrations, whose schema already has those tables, is brought under the runner.
+func (m *Migrator) Baseline(ctx context.Context, version int) ([]Migration, error) {
+	if _, ok := byVersion(m.Migrations)[version]; !ok {
+		return nil, fmt.Errorf("unknown migration version %d", version)
+	}
+
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := listApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(applied) > 0 {
+			return fmt.Errorf("the database already has %d applied migrations, baseline is only for a database without any", len(applied))
+		}
+
+		insert := m.Dialect.Rebind(fmt.Sprintf(`INSERT INTO %s (version, name, checksum) VALUES ($1, $2, $3)`, schemaMigrationsTable))
+		for _, migration := range m.Migrations {
+			if migration.Version > version {
+				continue
+			}
+			if _, err := conn.ExecContext(ctx, insert, migration.Version, migration.Name, migration.Checksum()); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// locked runs fn on a connection holding the advisory lock, with schema_migrations created. The lock
+// belongs to the session, so everything has to go through that one connection.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.Db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.Dialect == repository.DialectPostgres {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, advisoryLockID); err != nil {
+			return fmt.Errorf("failed to take the migration lock: %w", err)
+		}
+		defer func() {
+			// ctx may be done already, the lock has to be released regardless
+			if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, advisoryLockID); err != nil {
+				// a connection returned to the pool would keep holding the lock, drop it instead
+				_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+			}
+		}()
+	}
+
+	timestamp := "TIMESTAMPTZ"
+	if m.Dialect == repository.DialectSQLite {
+		// the SQLite driver reads TIMESTAMP columns back as time.Time
+		timestamp = "TIMESTAMP"
+	}
+	create := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		(
+			version   INT PRIMARY KEY,
+			name      VARCHAR(255)                   NOT NULL,
+			checksum  CHAR(64)                       NOT NULL,
+			appliedAt %s DEFAULT CURRENT_TIMESTAMP NOT NULL
+		)`, schemaMigrationsTable, timestamp)
+	if _, err := conn.ExecContext(ctx, create); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// listApplied returns the applied migrations ordered by version
+func listApplied(ctx context.Context, conn *sql.Conn) ([]Applied, error) {
+	query := fmt.Sprintf(`SELECT version, name, checksum, appliedAt FROM %s ORDER BY version`, schemaMigrationsTable)
+
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applied []Applied
+	for rows.Next() {
+		var record Applied
+		if err := rows.Scan(&record.Version, &record.Name, &record.Checksum, &record.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied = append(applied, record)
+	}
+	return applied, rows.Err()
+}
+
+// checkUnmanaged refuses to migrate a database that has tables but no applied migrations, running
+// 0001 there would fail half way on a table that already exists
+func (m *Migrator) checkUnmanaged(ctx context.Context, conn *sql.Conn) error {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.tables
+			WHERE table_schema = current_schema() AND table_name <> $1
+		)`
+	if m.Dialect == repository.DialectSQLite {
+		// sqlite_sequence is created by SQLite itself for AUTOINCREMENT
+		query = `
+			SELECT EXISTS (
+				SELECT 1 FROM sqlite_master
+				WHERE type = 'table' AND name NOT IN ($1, 'sqlite_sequence')
+			)`
+	}
+
+	var hasTables bool
+	if err := conn.QueryRowContext(ctx, m.Dialect.Rebind(query), schemaMigrationsTable).Scan(&hasTables); err != nil {
+		return err
+	}
+	if hasTables {
+		return fmt.Errorf("the database has tables but no applied migrations, record the migrations its schema already has with `migrate baseline -version <version>`")
+	}
+	return nil
+}
+
+// inTx runs statements and then record with args in one transaction
+func inTx(ctx context.Context, conn *sql.Conn, statements, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Verify returns an error naming every applied migration that drifted from the binary: one whose
+// Up changed since it was applied, or one that was removed. Versions past the latest migration of
+// the binary were applied by a newer release and are left alone, so that an older instance still starts.
+func Verify(migrations []Migration, applied []Applied) error {
+	known := byVersion(migrations)
+	latest := 0
+	for _, migration := range migrations {
+		if migration.Version > latest {
+			latest = migration.Version
+		}
+	}
+
+	var drift []string
+	for _, record := range applied {
+		migration, ok := known[record.Version]
+		switch {
+		case !ok && record.Version < latest:
+			drift = append(drift, fmt.Sprintf("%04d_%s was applied but is missing", record.Version, record.Name))
+		case ok && migration.Checksum() != strings.TrimSpace(record.Checksum):
+			drift = append(drift, fmt.Sprintf("%s was changed after it was applied", migration))
+		}
+	}
+	if len(drift) > 0 {
+		return fmt.Errorf("schema drift: %s, add a new migration instead of editing an applied one", strings.Join(drift, ", "))
+	}
+	return nil
+}
+
+// Pending returns the migrations not applied yet in version order
+func Pending(migrations []Migration, applied []Applied) []Migration {
+	done := map[int]bool{}
+	for _, record := range applied {
+		done[record.Version] = true
+	}
+
+	var todo []Migration
+	for _, migration := range migrations {
+		if !done[migration.Version] {
+			todo = append(todo, migration)
+		}
+	}
+	sort.Slice(todo, func(i, j int) bool { return todo[i].Version < todo[j].Version })
+	return todo
+}
+
+func byVersion(migrations []Migration) map[int]Migration {
+	known := make(map[int]Migration, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Version] = migration
+	}
+	return known
+}
@@ -0,0 +1,98 @@
+package migrations_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/migrations"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMigratorSQLite runs the embedded SQLite migrations up, down and up again on a temporary file
+func TestMigratorSQLite(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewRepository(repository.NewRepositoryOptions{
+		Dsn: "sqlite://" + filepath.Join(t.TempDir(), "users.db"),
+	})
+	defer repo.Db.Close()
+
+	migrator, err := migrations.NewMigrator(migrations.MigratorOptions{Db: repo.Db, Dialect: repo.Dialect})
+	require.NoError(t, err)
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, migrator.Migrations, applied)
+
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied, "nothing is pending")
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, status := range statuses {
+		assert.False(t, status.AppliedAt.IsZero(), "%s is applied", status.Migration)
+	}
+
+	reverted, err := migrator.Down(ctx, len(migrator.Migrations))
+	require.NoError(t, err)
+	assert.Len(t, reverted, len(migrator.Migrations))
+
+	_, err = migrator.Up(ctx)
+	assert.NoError(t, err, "down reverted every table")
+}
+
+func TestMigratorUnmanagedSQLite(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewRepository(repository.NewRepositoryOptions{
+		Dsn: "sqlite://" + filepath.Join(t.TempDir(), "users.db"),
+	})
+	defer repo.Db.Close()
+
+	_, err := repo.Db.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY)`)
+	require.NoError(t, err)
+
+	migrator, err := migrations.NewMigrator(migrations.MigratorOptions{Db: repo.Db, Dialect: repo.Dialect})
+	require.NoError(t, err)
+
+	_, err = migrator.Up(ctx)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "migrate baseline")
+	}
+
+	baselined, err := migrator.Baseline(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, baselined, 1)
+}
+
+// TestMigratorBaselineSQLite upgrades a database of the original users table, recorded at version 1
+func TestMigratorBaselineSQLite(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewRepository(repository.NewRepositoryOptions{
+		Dsn: "sqlite://" + filepath.Join(t.TempDir(), "users.db"),
+	})
+	defer repo.Db.Close()
+
+	migrator, err := migrations.NewMigrator(migrations.MigratorOptions{Db: repo.Db, Dialect: repo.Dialect})
+	require.NoError(t, err)
+
+	_, err = repo.Db.Exec(migrator.Migrations[0].Up)
+	require.NoError(t, err)
+	_, err = repo.Db.Exec(`INSERT INTO users (phoneNumber, fullName, password, saltKey) VALUES ('+628123456789', 'Old User', 'hash', 'salt')`)
+	require.NoError(t, err)
+
+	_, err = migrator.Baseline(ctx, 1)
+	require.NoError(t, err)
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, len(migrator.Migrations)-1)
+
+	phoneNumber := "+628123456789"
+	user, err := repo.GetUser(ctx, repository.GetUserInput{PhoneNumber: &phoneNumber})
+	require.NoError(t, err)
+	assert.Equal(t, "Old User", user.FullName)
+	assert.Equal(t, "user", user.Role)
+	assert.Equal(t, 1, user.Version)
+}
@@ -0,0 +1,13 @@
+CREATE TABLE refresh_tokens
+(
+    id        SERIAL PRIMARY KEY,
+    userId    INT                                   NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    tokenHash CHAR(64)                              NOT NULL,
+    familyId  CHAR(32)                              NOT NULL,
+    expiresAt TIMESTAMPTZ                           NOT NULL,
+    revokedAt TIMESTAMPTZ,
+    createdAt TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CONSTRAINT idx_refresh_token_hash UNIQUE (tokenHash)
+);
+
+CREATE INDEX idx_refresh_token_family ON refresh_tokens (familyId);
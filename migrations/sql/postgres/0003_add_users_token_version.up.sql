@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN tokenVersion INT DEFAULT 0 NOT NULL;
@@ -0,0 +1,9 @@
+CREATE TABLE revoked_tokens
+(
+    jti       CHAR(36) PRIMARY KEY,
+    userId    INT                                   NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expiresAt TIMESTAMPTZ                           NOT NULL,
+    createdAt TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_revoked_token_expires_at ON revoked_tokens (expiresAt);
@@ -0,0 +1,10 @@
+CREATE TABLE password_history
+(
+    id        SERIAL PRIMARY KEY,
+    userId    INT                                   NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    password  CHAR(60)                              NOT NULL,
+    saltKey   CHAR(36)                              NOT NULL,
+    createdAt TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_password_history_user ON password_history (userId, id DESC);
@@ -0,0 +1,3 @@
+-- fails while a hash longer than 60 characters is stored, such passwords have to be reset first
+ALTER TABLE password_history ALTER COLUMN password TYPE CHAR(60);
+ALTER TABLE users ALTER COLUMN password TYPE CHAR(60);
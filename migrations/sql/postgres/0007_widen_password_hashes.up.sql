@@ -0,0 +1,3 @@
+-- PHC strings of argon2id are longer than the 60 characters of a bcrypt hash
+ALTER TABLE users ALTER COLUMN password TYPE VARCHAR(255);
+ALTER TABLE password_history ALTER COLUMN password TYPE VARCHAR(255);
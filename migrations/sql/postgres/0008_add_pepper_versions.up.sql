@@ -0,0 +1,6 @@
+-- pepperVersion 0 is the pepper used before peppers were configurable
+ALTER TABLE users ADD COLUMN pepperVersion INT DEFAULT 0 NOT NULL;
+
+-- the history has no default, the default only fills the rows stored before the column
+ALTER TABLE password_history ADD COLUMN pepperVersion INT DEFAULT 0 NOT NULL;
+ALTER TABLE password_history ALTER COLUMN pepperVersion DROP DEFAULT;
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN role VARCHAR(20) DEFAULT 'user' NOT NULL CHECK (role IN ('user', 'admin'));
@@ -0,0 +1,7 @@
+CREATE TABLE login_attempts
+(
+    key          VARCHAR(100) PRIMARY KEY,
+    failures     INT         DEFAULT 0                 NOT NULL,
+    blockedUntil TIMESTAMPTZ,
+    updatedAt    TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
@@ -0,0 +1,6 @@
+CREATE TABLE rate_limit_buckets
+(
+    key       VARCHAR(255) PRIMARY KEY,
+    tokens    DOUBLE PRECISION                      NOT NULL,
+    updatedAt TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
@@ -0,0 +1,31 @@
+CREATE TABLE user_mfa
+(
+    userId       INT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    -- TOTP secret encrypted with MFA_ENCRYPTION_KEYS, "<key version>:<ciphertext>"
+    secret       VARCHAR(255)                          NOT NULL,
+    confirmedAt  TIMESTAMPTZ,
+    lastUsedStep BIGINT      DEFAULT 0                 NOT NULL,
+    createdAt    TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE TABLE mfa_recovery_codes
+(
+    id        SERIAL PRIMARY KEY,
+    userId    INT                                   NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    codeHash  CHAR(64)                              NOT NULL,
+    usedAt    TIMESTAMPTZ,
+    createdAt TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CONSTRAINT idx_mfa_recovery_code UNIQUE (userId, codeHash)
+);
+
+CREATE TABLE mfa_challenges
+(
+    id        SERIAL PRIMARY KEY,
+    userId    INT                                   NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    tokenHash CHAR(64)                              NOT NULL,
+    attempts  INT         DEFAULT 0                 NOT NULL,
+    expiresAt TIMESTAMPTZ                           NOT NULL,
+    usedAt    TIMESTAMPTZ,
+    createdAt TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CONSTRAINT idx_mfa_challenge_token_hash UNIQUE (tokenHash)
+);
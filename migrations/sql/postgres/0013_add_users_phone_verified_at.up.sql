@@ -0,0 +1,2 @@
+-- set once the user proved they own phoneNumber with a code sent to it
+ALTER TABLE users ADD COLUMN phoneVerifiedAt TIMESTAMPTZ;
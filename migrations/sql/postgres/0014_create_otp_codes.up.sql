@@ -0,0 +1,15 @@
+CREATE TABLE otp_codes
+(
+    id          SERIAL PRIMARY KEY,
+    userId      INT                                   NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    purpose     VARCHAR(30)                           NOT NULL,
+    -- the number the code was sent to, for a phone change it is not users.phoneNumber yet
+    phoneNumber VARCHAR(35)                           NOT NULL,
+    codeHash    CHAR(64)                              NOT NULL,
+    attempts    INT         DEFAULT 0                 NOT NULL,
+    expiresAt   TIMESTAMPTZ                           NOT NULL,
+    usedAt      TIMESTAMPTZ,
+    createdAt   TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_otp_code_user_purpose ON otp_codes (userId, purpose) WHERE usedAt IS NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP CONSTRAINT idx_user_email;
+ALTER TABLE users DROP COLUMN emailVerifiedAt;
+ALTER TABLE users DROP COLUMN email;
@@ -0,0 +1,5 @@
+-- stored lower case so that the unique constraint ignores case
+ALTER TABLE users ADD COLUMN email VARCHAR(254) CHECK (email = lower(email));
+-- set once the user opened the verification link mailed to email
+ALTER TABLE users ADD COLUMN emailVerifiedAt TIMESTAMPTZ;
+ALTER TABLE users ADD CONSTRAINT idx_user_email UNIQUE (email);
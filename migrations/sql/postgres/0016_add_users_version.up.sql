@@ -0,0 +1,2 @@
+-- incremented by every change to the profile, the ETag of GET /user/{id}
+ALTER TABLE users ADD COLUMN version INT DEFAULT 1 NOT NULL;
@@ -0,0 +1,2 @@
+-- the login throttle key of the login that started the challenge, e.g. email:a@example.com
+ALTER TABLE mfa_challenges ADD COLUMN accountKey VARCHAR(300) DEFAULT '' NOT NULL;
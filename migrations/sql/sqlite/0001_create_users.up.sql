@@ -0,0 +1,13 @@
+-- SQLite has no TIMESTAMPTZ, TIMESTAMP columns hold UTC text that the driver reads back as time.
+-- It does not enforce the length of a type either, password also holds the longer PHC strings.
+CREATE TABLE users
+(
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    phoneNumber VARCHAR(35) NOT NULL,
+    fullName    VARCHAR(60) NOT NULL,
+    password    CHAR(60)    NOT NULL,
+    saltKey     CHAR(36)    NOT NULL,
+    createdAt   TIMESTAMP   DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')) NOT NULL,
+    updatedAt   TIMESTAMP   DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')) NOT NULL,
+    CONSTRAINT idx_user_phone_number UNIQUE (phoneNumber)
+);
@@ -0,0 +1,9 @@
+CREATE TABLE revoked_tokens
+(
+    jti       CHAR(36) PRIMARY KEY,
+    userId    INT       NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expiresAt TIMESTAMP NOT NULL,
+    createdAt TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')) NOT NULL
+);
+
+CREATE INDEX idx_revoked_token_expires_at ON revoked_tokens (expiresAt);
@@ -0,0 +1,12 @@
+CREATE TABLE password_reset_codes
+(
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    userId    INT       NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    codeHash  CHAR(64)  NOT NULL,
+    attempts  INT       DEFAULT 0 NOT NULL,
+    expiresAt TIMESTAMP NOT NULL,
+    usedAt    TIMESTAMP,
+    createdAt TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')) NOT NULL
+);
+
+CREATE INDEX idx_password_reset_code_user ON password_reset_codes (userId) WHERE usedAt IS NULL;
@@ -0,0 +1,10 @@
+CREATE TABLE password_history
+(
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    userId    INT       NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    password  CHAR(60)  NOT NULL,
+    saltKey   CHAR(36)  NOT NULL,
+    createdAt TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')) NOT NULL
+);
+
+CREATE INDEX idx_password_history_user ON password_history (userId, id DESC);
@@ -0,0 +1,2 @@
+ALTER TABLE password_history DROP COLUMN pepperVersion;
+ALTER TABLE users DROP COLUMN pepperVersion;
@@ -0,0 +1,5 @@
+-- pepperVersion 0 is the pepper used before peppers were configurable
+ALTER TABLE users ADD COLUMN pepperVersion INT DEFAULT 0 NOT NULL;
+
+-- SQLite cannot drop the default again, the history is always written with a pepper version
+ALTER TABLE password_history ADD COLUMN pepperVersion INT DEFAULT 0 NOT NULL;
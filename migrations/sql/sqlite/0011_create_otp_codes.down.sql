@@ -0,0 +1 @@
+DROP TABLE otp_codes;
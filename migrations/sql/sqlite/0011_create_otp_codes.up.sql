@@ -0,0 +1,15 @@
+CREATE TABLE otp_codes
+(
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    userId      INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    purpose     VARCHAR(30) NOT NULL,
+    -- the number the code was sent to, for a phone change it is not users.phoneNumber yet
+    phoneNumber VARCHAR(35) NOT NULL,
+    codeHash    CHAR(64)    NOT NULL,
+    attempts    INT         DEFAULT 0 NOT NULL,
+    expiresAt   TIMESTAMP   NOT NULL,
+    usedAt      TIMESTAMP,
+    createdAt   TIMESTAMP   DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')) NOT NULL
+);
+
+CREATE INDEX idx_otp_code_user_purpose ON otp_codes (userId, purpose) WHERE usedAt IS NULL;
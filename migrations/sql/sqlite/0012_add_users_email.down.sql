@@ -0,0 +1,3 @@
+DROP INDEX idx_user_email;
+ALTER TABLE users DROP COLUMN emailVerifiedAt;
+ALTER TABLE users DROP COLUMN email;
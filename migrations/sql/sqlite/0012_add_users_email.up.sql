@@ -0,0 +1,6 @@
+-- stored lower case so that the unique index ignores case
+ALTER TABLE users ADD COLUMN email VARCHAR(254) CHECK (email = lower(email));
+-- set once the user opened the verification link mailed to email
+ALTER TABLE users ADD COLUMN emailVerifiedAt TIMESTAMP;
+-- SQLite cannot add a column with a unique constraint, the index enforces the same
+CREATE UNIQUE INDEX idx_user_email ON users (email);
@@ -0,0 +1,103 @@
+// notifier package delivers messages such as one-time codes to users.
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Message ...
+type Message struct {
+	Recipient string `json:"recipient"`
+	Subject   string `json:"subject"`
+	Body      string `json:"body"`
+}
+
+// NotifierInterface is implemented by every delivery channel
+type NotifierInterface interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// ConsoleNotifier writes messages to a writer, meant for local development
+type ConsoleNotifier struct {
+	Writer io.Writer
+
+	mu sync.Mutex
+}
+
+// NewConsoleNotifier writes to stdout
+func NewConsoleNotifier() *ConsoleNotifier {
+	return &ConsoleNotifier{Writer: os.Stdout}
+}
+
+// Send ...
+func (n *ConsoleNotifier) Send(_ context.Context, message Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	_, err := fmt.Fprintf(n.Writer, "[notifier] to=%s subject=%q\n%s\n", message.Recipient, message.Subject, message.Body)
+	return err
+}
+
+// FileNotifier appends every message as a JSON line to Path, tests and local
+// setups can read the delivered codes back from it
+type FileNotifier struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// NewFileNotifier ...
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{Path: path}
+}
+
+type fileEntry struct {
+	Message
+	SentAt time.Time `json:"sentAt"`
+}
+
+// Send ...
+func (n *FileNotifier) Send(_ context.Context, message Message) error {
+	line, err := json.Marshal(fileEntry{Message: message, SentAt: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	file, err := os.OpenFile(n.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open notifier file: %w", err)
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// ReadFile returns the messages written by a FileNotifier, oldest first
+func ReadFile(path string) ([]Message, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []Message
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	for decoder.More() {
+		entry := fileEntry{}
+		if err := decoder.Decode(&entry); err != nil {
+			return nil, err
+		}
+		messages = append(messages, entry.Message)
+	}
+	return messages, nil
+}
@@ -0,0 +1,35 @@
+package notifier_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/notifier"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsoleNotifier(t *testing.T) {
+	out := &bytes.Buffer{}
+	n := &notifier.ConsoleNotifier{Writer: out}
+
+	require.NoError(t, n.Send(context.Background(), notifier.Message{Recipient: "+6281234567", Subject: "Code", Body: "123456"}))
+	assert.Contains(t, out.String(), "to=+6281234567")
+	assert.Contains(t, out.String(), "123456")
+}
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.jsonl")
+	n := notifier.NewFileNotifier(path)
+
+	require.NoError(t, n.Send(context.Background(), notifier.Message{Recipient: "a", Body: "first"}))
+	require.NoError(t, n.Send(context.Background(), notifier.Message{Recipient: "b", Body: "second"}))
+
+	messages, err := notifier.ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	assert.Equal(t, "a", messages[0].Recipient)
+	assert.Equal(t, "second", messages[1].Body)
+}
@@ -0,0 +1,81 @@
+package phone
+
+import "sort"
+
+// Change replaces the stored phone number of a user with its E.164 form
+type Change struct {
+	UserID int
+	From   string
+	To     string
+}
+
+// Collision lists users whose stored phone numbers are the same number once normalized
+type Collision struct {
+	PhoneNumber string
+	UserIDs     []int
+	// Stored are the phone numbers as stored, in the order of UserIDs
+	Stored []string
+}
+
+// Invalid is a stored phone number that does not normalize
+type Invalid struct {
+	UserID      int
+	PhoneNumber string
+	Reason      string
+}
+
+// Plan is what normalizing the stored phone numbers would do, users in Collisions and Invalid are left alone
+type Plan struct {
+	Changes    []Change
+	Collisions []Collision
+	Invalid    []Invalid
+	Unchanged  int
+}
+
+// PlanNormalization decides which of the stored phone numbers, keyed by user ID, to rewrite
+func PlanNormalization(n *Normalizer, stored map[int]string) Plan {
+	userIDs := make([]int, 0, len(stored))
+	for userID := range stored {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Ints(userIDs)
+
+	plan := Plan{}
+	byNumber := make(map[string][]int)
+	normalized := make(map[int]string, len(stored))
+	for _, userID := range userIDs {
+		number, err := n.Normalize(stored[userID])
+		if err != nil {
+			plan.Invalid = append(plan.Invalid, Invalid{UserID: userID, PhoneNumber: stored[userID], Reason: err.Error()})
+			continue
+		}
+		normalized[userID] = number
+		byNumber[number] = append(byNumber[number], userID)
+	}
+
+	for _, userID := range userIDs {
+		number, ok := normalized[userID]
+		if !ok {
+			continue
+		}
+
+		owners := byNumber[number]
+		switch {
+		case len(owners) > 1:
+			// reported once, by its first user
+			if owners[0] == userID {
+				collision := Collision{PhoneNumber: number, UserIDs: owners}
+				for _, owner := range owners {
+					collision.Stored = append(collision.Stored, stored[owner])
+				}
+				plan.Collisions = append(plan.Collisions, collision)
+			}
+		case number == stored[userID]:
+			plan.Unchanged++
+		default:
+			plan.Changes = append(plan.Changes, Change{UserID: userID, From: stored[userID], To: number})
+		}
+	}
+
+	return plan
+}
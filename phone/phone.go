@@ -0,0 +1,209 @@
+// phone package parses phone numbers into their E.164 form, e.g. "+6281234567890", so that
+// one number is stored and looked up the same way however it was typed.
+package phone
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+	"github.com/SawitProRecruitment/UserService/commons"
+)
+
+// countriesJSON describes the mobile numbers of every supported country, codes are texted to them
+//
+//go:embed countries.json
+var countriesJSON []byte
+
+// Country holds the rules of the national numbers of one country
+type Country struct {
+	// Region is the ISO 3166-1 alpha-2 code
+	Region      string `json:"region"`
+	Name        string `json:"name"`
+	CallingCode string `json:"callingCode"`
+	// TrunkPrefix is dialed before national numbers inside the country, it is dropped from E.164
+	TrunkPrefix string `json:"trunkPrefix"`
+	// MinLength and MaxLength bound the digits after the calling code
+	MinLength int `json:"minLength"`
+	MaxLength int `json:"maxLength"`
+	// Prefixes are the leading digits of valid national numbers
+	Prefixes []string `json:"prefixes"`
+}
+
+var countries = mustLoadCountries()
+
+// Default accepts Indonesian numbers only, which is what the service accepted before countries were configurable
+var Default = mustNewNormalizer(NormalizerOptions{AllowedRegions: []string{"ID"}, DefaultRegion: "ID"})
+
+// Countries returns the supported countries ordered by region
+func Countries() []Country {
+	list := make([]Country, 0, len(countries))
+	for _, country := range countries {
+		list = append(list, country)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Region < list[j].Region })
+	return list
+}
+
+// NormalizerOptions ...
+type NormalizerOptions struct {
+	// AllowedRegions are the countries whose numbers are accepted, empty accepts every supported country
+	AllowedRegions []string
+	// DefaultRegion is the country of numbers typed without a calling code, empty requires a calling code
+	DefaultRegion string
+}
+
+// Normalizer turns phone numbers of the allowed countries into E.164
+type Normalizer struct {
+	byCallingCode map[string]Country
+	allowed       map[string]bool
+	defaultRegion *Country
+}
+
+// NewNormalizer ...
+func NewNormalizer(opts NormalizerOptions) (*Normalizer, error) {
+	n := &Normalizer{
+		byCallingCode: make(map[string]Country, len(countries)),
+		allowed:       make(map[string]bool, len(countries)),
+	}
+	for _, country := range countries {
+		n.byCallingCode[country.CallingCode] = country
+	}
+
+	if len(opts.AllowedRegions) == 0 {
+		for region := range countries {
+			n.allowed[region] = true
+		}
+	}
+	for _, region := range opts.AllowedRegions {
+		region = strings.ToUpper(strings.TrimSpace(region))
+		if _, ok := countries[region]; !ok {
+			return nil, fmt.Errorf("unsupported phone region: %q", region)
+		}
+		n.allowed[region] = true
+	}
+
+	if opts.DefaultRegion != "" {
+		country, ok := countries[strings.ToUpper(opts.DefaultRegion)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported default phone region: %q", opts.DefaultRegion)
+		}
+		n.defaultRegion = &country
+	}
+
+	return n, nil
+}
+
+// Normalize returns raw in E.164. Spaces, dashes, dots and parentheses are ignored, a calling code is
+// given as "+62" or "0062", and a trunk prefix after it, as in "+620812...", is dropped. It returns
+// an apperrors.ErrValidation of ErrorInvalidPhoneNumber or ErrorPhoneRegionNotAllowed.
+func (n *Normalizer) Normalize(raw string) (string, error) {
+	digits, international, ok := clean(raw)
+	if !ok {
+		return "", apperrors.Validation(commons.ErrorInvalidPhoneNumber)
+	}
+
+	var country Country
+	var national string
+	switch {
+	case international:
+		country, national, ok = n.splitCallingCode(digits)
+	case n.defaultRegion != nil:
+		country, national, ok = *n.defaultRegion, digits, true
+		// the calling code typed without "+"
+		if !country.valid(strings.TrimPrefix(national, country.TrunkPrefix)) && strings.HasPrefix(national, country.CallingCode) {
+			national = strings.TrimPrefix(national, country.CallingCode)
+		}
+	}
+	if !ok {
+		return "", apperrors.Validation(commons.ErrorInvalidPhoneNumber)
+	}
+
+	if country.TrunkPrefix != "" {
+		national = strings.TrimPrefix(national, country.TrunkPrefix)
+	}
+	if !country.valid(national) {
+		return "", apperrors.Validation(commons.ErrorInvalidPhoneNumber)
+	}
+	if !n.allowed[country.Region] {
+		return "", apperrors.Validation(commons.ErrorPhoneRegionNotAllowed)
+	}
+
+	return "+" + country.CallingCode + national, nil
+}
+
+// splitCallingCode finds the country of an international number, calling codes are prefix free
+func (n *Normalizer) splitCallingCode(digits string) (Country, string, bool) {
+	for length := 1; length <= 3 && length < len(digits); length++ {
+		if country, ok := n.byCallingCode[digits[:length]]; ok {
+			return country, digits[length:], true
+		}
+	}
+	return Country{}, "", false
+}
+
+func (c Country) valid(national string) bool {
+	if len(national) < c.MinLength || len(national) > c.MaxLength {
+		return false
+	}
+	for _, prefix := range c.Prefixes {
+		if strings.HasPrefix(national, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// clean drops separators and the international prefix, ok is false when anything but digits is left
+func clean(raw string) (digits string, international bool, ok bool) {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, "+") {
+		international, raw = true, raw[1:]
+	}
+
+	var b strings.Builder
+	for _, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", false, false
+		}
+	}
+
+	digits = b.String()
+	if !international && strings.HasPrefix(digits, "00") {
+		international, digits = true, digits[2:]
+	}
+	return digits, international, digits != ""
+}
+
+func mustLoadCountries() map[string]Country {
+	var list []Country
+	if err := json.Unmarshal(countriesJSON, &list); err != nil {
+		panic(fmt.Sprintf("invalid phone metadata: %v", err))
+	}
+
+	loaded := make(map[string]Country, len(list))
+	callingCodes := make(map[string]string, len(list))
+	for _, country := range list {
+		if other, ok := callingCodes[country.CallingCode]; ok {
+			panic(fmt.Sprintf("invalid phone metadata: %s and %s share calling code %s", other, country.Region, country.CallingCode))
+		}
+		callingCodes[country.CallingCode] = country.Region
+		loaded[country.Region] = country
+	}
+	return loaded
+}
+
+func mustNewNormalizer(opts NormalizerOptions) *Normalizer {
+	n, err := NewNormalizer(opts)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
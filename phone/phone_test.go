@@ -0,0 +1,122 @@
+package phone_test
+
+import (
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/SawitProRecruitment/UserService/phone"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	n, err := phone.NewNormalizer(phone.NormalizerOptions{AllowedRegions: []string{"ID", "SG", "US"}, DefaultRegion: "ID"})
+	require.NoError(t, err)
+
+	valid := map[string]string{
+		"+6281234567890":     "+6281234567890",
+		"+62 812-3456-7890":  "+6281234567890",
+		"+620812 3456 7890":  "+6281234567890",
+		"006281234567890":    "+6281234567890",
+		"081234567890":       "+6281234567890",
+		"6281234567890":      "+6281234567890",
+		"(0812) 3456.7890":   "+6281234567890",
+		"+65 9123 4567":      "+6591234567",
+		"+1 (212) 555-0100":  "+12125550100",
+		"+1 1 212 555 0100":  "+12125550100",
+		" +62 822 2667 727 ": "+628222667727",
+	}
+	for raw, expected := range valid {
+		t.Run(raw, func(t *testing.T) {
+			normalized, err := n.Normalize(raw)
+			if assert.NoError(t, err) {
+				assert.Equal(t, expected, normalized)
+			}
+		})
+	}
+
+	invalid := map[string]string{
+		"":                   commons.ErrorInvalidPhoneNumber,
+		"+":                  commons.ErrorInvalidPhoneNumber,
+		"+62812abc4567":      commons.ErrorInvalidPhoneNumber,
+		"+62 21 555 0100":    commons.ErrorInvalidPhoneNumber,
+		"+62812345":          commons.ErrorInvalidPhoneNumber,
+		"+628123456789012":   commons.ErrorInvalidPhoneNumber,
+		"+65 6123 4567":      commons.ErrorInvalidPhoneNumber,
+		"+999 1234 5678":     commons.ErrorInvalidPhoneNumber,
+		"+60 12 345 6789":    commons.ErrorPhoneRegionNotAllowed,
+		"+44 7700 900123":    commons.ErrorPhoneRegionNotAllowed,
+		"+62 812 3456 7890#": commons.ErrorInvalidPhoneNumber,
+	}
+	for raw, expected := range invalid {
+		t.Run(raw, func(t *testing.T) {
+			_, err := n.Normalize(raw)
+			if assert.Error(t, err) {
+				assert.Equal(t, expected, err.Error())
+			}
+		})
+	}
+}
+
+func TestNormalizeWithoutDefaultRegion(t *testing.T) {
+	n, err := phone.NewNormalizer(phone.NormalizerOptions{})
+	require.NoError(t, err)
+
+	_, err = n.Normalize("081234567890")
+	assert.Error(t, err, "a national number needs a default region")
+
+	normalized, err := n.Normalize("+44 07700 900123")
+	if assert.NoError(t, err, "every country is allowed") {
+		assert.Equal(t, "+447700900123", normalized)
+	}
+}
+
+func TestNewNormalizer(t *testing.T) {
+	_, err := phone.NewNormalizer(phone.NormalizerOptions{AllowedRegions: []string{"XX"}})
+	assert.Error(t, err)
+
+	_, err = phone.NewNormalizer(phone.NormalizerOptions{DefaultRegion: "XX"})
+	assert.Error(t, err)
+
+	n, err := phone.NewNormalizer(phone.NormalizerOptions{AllowedRegions: []string{" sg "}})
+	require.NoError(t, err)
+	_, err = n.Normalize("+65 9123 4567")
+	assert.NoError(t, err)
+}
+
+func TestCountries(t *testing.T) {
+	countries := phone.Countries()
+	require.NotEmpty(t, countries)
+
+	for i, country := range countries {
+		assert.NotEmpty(t, country.CallingCode, country.Region)
+		assert.NotEmpty(t, country.Prefixes, country.Region)
+		assert.LessOrEqual(t, country.MinLength, country.MaxLength, country.Region)
+		if i > 0 {
+			assert.Less(t, countries[i-1].Region, country.Region)
+		}
+	}
+}
+
+func TestPlanNormalization(t *testing.T) {
+	plan := phone.PlanNormalization(phone.Default, map[int]string{
+		1: "+6281234567890",
+		2: "+62 812 3456 7890",
+		3: "+620812 3456 7890",
+		4: "+628222667727",
+		5: "+620822 2667 728",
+		6: "not a number",
+	})
+
+	assert.Equal(t, 1, plan.Unchanged)
+	assert.Equal(t, []phone.Change{{UserID: 5, From: "+620822 2667 728", To: "+628222667728"}}, plan.Changes)
+	assert.Equal(t, []phone.Collision{{
+		PhoneNumber: "+6281234567890",
+		UserIDs:     []int{1, 2, 3},
+		Stored:      []string{"+6281234567890", "+62 812 3456 7890", "+620812 3456 7890"},
+	}}, plan.Collisions)
+	if assert.Len(t, plan.Invalid, 1) {
+		assert.Equal(t, 6, plan.Invalid[0].UserID)
+		assert.Equal(t, commons.ErrorInvalidPhoneNumber, plan.Invalid[0].Reason)
+	}
+}
@@ -0,0 +1,107 @@
+// This file contains the SQL dialects the repository runs on.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Dialect is the database behind a Repository, queries are written for Postgres and rewritten for the others
+type Dialect string
+
+const (
+	DialectPostgres Dialect = "postgres"
+	// DialectSQLite stores everything in one file, for single-instance and edge deployments
+	DialectSQLite Dialect = "sqlite"
+)
+
+// sqliteScheme prefixes a DSN that points at a SQLite file, e.g. sqlite:///var/lib/users.db
+const sqliteScheme = "sqlite:"
+
+// sqliteParams are appended to every SQLite DSN: foreign keys are off by default in SQLite and a
+// writer waits for another one instead of failing right away
+const sqliteParams = "_foreign_keys=on&_busy_timeout=5000"
+
+// placeholder matches a Postgres positional parameter, e.g. $1
+var placeholder = regexp.MustCompile(`\$(\d+)`)
+
+// ParseDsn returns the dialect of dsn and the name and DSN to open it with database/sql. A DSN
+// starting with sqlite: is a SQLite file, anything else is handed to Postgres.
+func ParseDsn(dsn string) (Dialect, string, string) {
+	if !strings.HasPrefix(dsn, sqliteScheme) {
+		return DialectPostgres, "postgres", dsn
+	}
+
+	path := strings.TrimPrefix(strings.TrimPrefix(dsn, sqliteScheme), "//")
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return DialectSQLite, "sqlite3", "file:" + path + separator + sqliteParams
+}
+
+// Rebind rewrites the $n parameters of a Postgres query into the ones of the dialect
+func (d Dialect) Rebind(query string) string {
+	if d != DialectSQLite {
+		return query
+	}
+	return placeholder.ReplaceAllString(query, "?$1")
+}
+
+// sqliteTimeFormat is the text of a time in SQLite, the one of the createdAt defaults of the SQLite migrations
+const sqliteTimeFormat = "2006-01-02 15:04:05.000"
+
+// Args converts the arguments of a query into values the dialect compares correctly. SQLite stores
+// times as text, they are written in UTC with a fixed width so that their order and equality are
+// the ones of the text, at the cost of precision below a millisecond.
+func (d Dialect) Args(args []interface{}) []interface{} {
+	if d != DialectSQLite {
+		return args
+	}
+	converted := make([]interface{}, len(args))
+	for i, arg := range args {
+		if t, ok := arg.(time.Time); ok {
+			arg = t.UTC().Format(sqliteTimeFormat)
+		}
+		converted[i] = arg
+	}
+	return converted
+}
+
+// isSQLiteUniqueViolation reports whether err is a SQLite unique violation, and returns the columns it
+// names, e.g. users.phoneNumber
+func isSQLiteUniqueViolation(err error) (string, bool) {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.ExtendedCode != sqlite3.ErrConstraintUnique {
+		return "", false
+	}
+	return strings.TrimPrefix(sqliteErr.Error(), "UNIQUE constraint failed: "), true
+}
+
+// execer runs queries, it is a *sql.DB or a *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// exec runs a Postgres query on db in the dialect of the repository
+func (r *Repository) exec(ctx context.Context, db execer, query string, args ...interface{}) (sql.Result, error) {
+	return db.ExecContext(ctx, r.Dialect.Rebind(query), r.Dialect.Args(args)...)
+}
+
+// query ...
+func (r *Repository) query(ctx context.Context, db execer, query string, args ...interface{}) (*sql.Rows, error) {
+	return db.QueryContext(ctx, r.Dialect.Rebind(query), r.Dialect.Args(args)...)
+}
+
+// queryRow ...
+func (r *Repository) queryRow(ctx context.Context, db execer, query string, args ...interface{}) *sql.Row {
+	return db.QueryRowContext(ctx, r.Dialect.Rebind(query), r.Dialect.Args(args)...)
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+	"github.com/SawitProRecruitment/UserService/commons"
+	"github.com/lib/pq"
+)
+
+// pqUniqueViolation is the SQLSTATE Postgres fails an insert or update with when it would duplicate a unique key
+const pqUniqueViolation = "23505"
+
+var (
+	// ErrUserExists is returned when the phone number belongs to another user
+	ErrUserExists = apperrors.Conflict(commons.ErrUserExists)
+	// ErrEmailExists is returned when the email belongs to another user
+	ErrEmailExists = apperrors.Conflict(commons.ErrEmailExists)
+	// ErrVersionConflict is returned by UpdateUser when the user changed since it was read
+	ErrVersionConflict = apperrors.Conflict(commons.ErrorVersionConflict)
+)
+
+// uniqueConflicts names the conflict each unique constraint of the schema stands for
+var uniqueConflicts = map[string]error{
+	"idx_user_phone_number": ErrUserExists,
+	"idx_user_email":        ErrEmailExists,
+}
+
+// sqliteUniqueConflicts is uniqueConflicts for SQLite, which names the columns of the violated
+// constraint rather than the constraint
+var sqliteUniqueConflicts = map[string]error{
+	"users.phoneNumber": ErrUserExists,
+	"users.email":       ErrEmailExists,
+}
+
+// dbError translates an error of the database into a domain error: sql.ErrNoRows becomes
+// apperrors.ErrNotFound and a unique violation apperrors.ErrConflict. Other errors are returned as they are.
+func dbError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperrors.Wrap(apperrors.ErrNotFound, commons.ErrorNoData, err)
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation {
+		if conflict, ok := uniqueConflicts[pqErr.Constraint]; ok {
+			return conflict
+		}
+		return apperrors.Wrap(apperrors.ErrConflict, "already exists", err)
+	}
+	if columns, ok := isSQLiteUniqueViolation(err); ok {
+		if conflict, ok := sqliteUniqueConflicts[columns]; ok {
+			return conflict
+		}
+		return apperrors.Wrap(apperrors.ErrConflict, "already exists", err)
+	}
+	return err
+}
+
+// errNoData is returned by updates that matched no row
+func errNoData() error {
+	return apperrors.NotFound(commons.ErrorNoData)
+}
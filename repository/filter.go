@@ -0,0 +1,483 @@
+// This file contains the filter users are queried with.
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+	"github.com/SawitProRecruitment/UserService/commons"
+)
+
+// UserColumn is a column of users a UserFilter may name
+type UserColumn string
+
+const (
+	UserColumnID          UserColumn = "id"
+	UserColumnPhoneNumber UserColumn = "phoneNumber"
+	UserColumnFullName    UserColumn = "fullName"
+	UserColumnEmail       UserColumn = "email"
+	UserColumnRole        UserColumn = "role"
+	// UserColumnPhoneVerifiedAt is NULL until the user verified their phone number
+	UserColumnPhoneVerifiedAt UserColumn = "phoneVerifiedAt"
+	UserColumnCreatedAt       UserColumn = "createdAt"
+	UserColumnUpdatedAt       UserColumn = "updatedAt"
+)
+
+type columnKind int
+
+const (
+	kindInt columnKind = iota
+	kindText
+	kindTime
+)
+
+// userColumn describes what a filter may do with a column
+type userColumn struct {
+	kind columnKind
+	// sortable columns are NOT NULL, keyset pagination cannot step over NULLs
+	sortable bool
+	// nullable columns take IsNull and IsNotNull
+	nullable bool
+	// value is the value of the column for user, nil for NULL
+	value func(user *UserModel) interface{}
+}
+
+// userColumns is the allowlist of the columns a UserFilter may name. Every column name a filter
+// writes into SQL is a key of it, neither struct tags nor caller input reach the query.
+var userColumns = map[UserColumn]userColumn{
+	UserColumnID:          {kind: kindInt, sortable: true, value: func(user *UserModel) interface{} { return user.ID }},
+	UserColumnPhoneNumber: {kind: kindText, sortable: true, value: func(user *UserModel) interface{} { return user.PhoneNumber }},
+	UserColumnFullName:    {kind: kindText, sortable: true, value: func(user *UserModel) interface{} { return user.FullName }},
+	UserColumnEmail: {kind: kindText, nullable: true, value: func(user *UserModel) interface{} {
+		if user.Email == nil {
+			return nil
+		}
+		return *user.Email
+	}},
+	UserColumnRole: {kind: kindText, value: func(user *UserModel) interface{} { return user.Role }},
+	UserColumnPhoneVerifiedAt: {kind: kindTime, nullable: true, value: func(user *UserModel) interface{} {
+		if user.PhoneVerifiedAt == nil {
+			return nil
+		}
+		return *user.PhoneVerifiedAt
+	}},
+	UserColumnCreatedAt: {kind: kindTime, sortable: true, value: func(user *UserModel) interface{} { return user.CreatedAt }},
+	UserColumnUpdatedAt: {kind: kindTime, sortable: true, value: func(user *UserModel) interface{} { return user.UpdatedAt }},
+}
+
+// SortDirection ...
+type SortDirection string
+
+const (
+	Ascending  SortDirection = "asc"
+	Descending SortDirection = "desc"
+)
+
+type operator int
+
+const (
+	opEqual operator = iota
+	opIn
+	opPrefix
+	opContains
+	opFrom
+	opBefore
+	opNull
+	opNotNull
+)
+
+type condition struct {
+	column UserColumn
+	op     operator
+	values []interface{}
+}
+
+// UserCursor is where a page of users starts: right after the user whose sort column has Value
+// and whose ID is ID, the last user of the page before
+type UserCursor struct {
+	Value interface{}
+	ID    int
+}
+
+// UserFilter selects, orders and pages users. Conditions are ANDed together, users are ordered by
+// one sortable column with the ID breaking ties so that the order is stable and pages neither skip
+// nor repeat users. A misuse, e.g. a column out of the allowlist, is kept and returned by Err.
+type UserFilter struct {
+	conditions []condition
+	sortColumn UserColumn
+	direction  SortDirection
+	after      *UserCursor
+	limit      int
+	err        error
+}
+
+// NewUserFilter returns a filter matching every user ordered by ID
+func NewUserFilter() *UserFilter {
+	return &UserFilter{sortColumn: UserColumnID, direction: Ascending}
+}
+
+// Equal keeps the users whose column is value, an int for the ID and a string otherwise
+func (f *UserFilter) Equal(column UserColumn, value interface{}) *UserFilter {
+	return f.add(column, opEqual, value)
+}
+
+// In keeps the users whose column is one of values, none keeps no user
+func (f *UserFilter) In(column UserColumn, values ...interface{}) *UserFilter {
+	return f.add(column, opIn, values...)
+}
+
+// HasPrefix keeps the users whose text column starts with prefix ignoring case. SQLite only
+// ignores the case of ASCII letters.
+func (f *UserFilter) HasPrefix(column UserColumn, prefix string) *UserFilter {
+	return f.add(column, opPrefix, prefix)
+}
+
+// Contains keeps the users whose text column contains substring ignoring case, like HasPrefix
+func (f *UserFilter) Contains(column UserColumn, substring string) *UserFilter {
+	return f.add(column, opContains, substring)
+}
+
+// From keeps the users whose time column is at or after t
+func (f *UserFilter) From(column UserColumn, t time.Time) *UserFilter {
+	return f.add(column, opFrom, t)
+}
+
+// Before keeps the users whose time column is before t
+func (f *UserFilter) Before(column UserColumn, t time.Time) *UserFilter {
+	return f.add(column, opBefore, t)
+}
+
+// IsNull keeps the users whose nullable column is NULL
+func (f *UserFilter) IsNull(column UserColumn) *UserFilter {
+	return f.add(column, opNull)
+}
+
+// IsNotNull keeps the users whose nullable column is set
+func (f *UserFilter) IsNotNull(column UserColumn) *UserFilter {
+	return f.add(column, opNotNull)
+}
+
+// OrderBy orders users by a sortable column, then by ID in the same direction
+func (f *UserFilter) OrderBy(column UserColumn, direction SortDirection) *UserFilter {
+	spec, ok := userColumns[column]
+	switch {
+	case !ok || !spec.sortable:
+		f.fail("users cannot be ordered by %q", column)
+	case direction != Ascending && direction != Descending:
+		f.fail("invalid sort direction %q", direction)
+	default:
+		f.sortColumn, f.direction = column, direction
+	}
+	return f
+}
+
+// After starts the users after cursor in the order of the filter, see CursorOf
+func (f *UserFilter) After(cursor UserCursor) *UserFilter {
+	f.after = &cursor
+	return f
+}
+
+// Limit caps the number of users, 0 is no cap
+func (f *UserFilter) Limit(limit int) *UserFilter {
+	if limit < 0 {
+		f.fail("invalid limit %d", limit)
+		return f
+	}
+	f.limit = limit
+	return f
+}
+
+// CursorOf returns the cursor of the page that starts after user
+func (f *UserFilter) CursorOf(user UserModel) UserCursor {
+	return UserCursor{Value: userColumns[f.sortColumn].value(&user), ID: user.ID}
+}
+
+// SortColumn ...
+func (f *UserFilter) SortColumn() UserColumn {
+	return f.sortColumn
+}
+
+// SortDirection ...
+func (f *UserFilter) SortDirection() SortDirection {
+	return f.direction
+}
+
+// Err returns the first misuse of the filter, an apperrors.ErrValidation
+func (f *UserFilter) Err() error {
+	if f.err != nil {
+		return f.err
+	}
+	// ordered by ID the cursor is its ID alone
+	if f.after != nil && f.sortColumn != UserColumnID {
+		if err := checkValue(userColumns[f.sortColumn].kind, f.after.Value); err != nil {
+			return apperrors.Wrap(apperrors.ErrValidation, commons.ErrorInvalidFilter, fmt.Errorf("cursor: %w", err))
+		}
+	}
+	return nil
+}
+
+func (f *UserFilter) add(column UserColumn, op operator, values ...interface{}) *UserFilter {
+	spec, ok := userColumns[column]
+	if !ok {
+		f.fail("unknown user column %q", column)
+		return f
+	}
+
+	allowed := spec.kind != kindTime
+	switch op {
+	case opPrefix, opContains:
+		allowed = spec.kind == kindText
+	case opFrom, opBefore:
+		allowed = spec.kind == kindTime
+	case opNull, opNotNull:
+		allowed = spec.nullable
+	}
+	if !allowed {
+		f.fail("column %q does not support this condition", column)
+		return f
+	}
+	for _, value := range values {
+		if err := checkValue(spec.kind, value); err != nil {
+			f.fail("column %q: %v", column, err)
+			return f
+		}
+	}
+
+	f.conditions = append(f.conditions, condition{column: column, op: op, values: values})
+	return f
+}
+
+func (f *UserFilter) fail(format string, args ...interface{}) {
+	if f.err == nil {
+		f.err = apperrors.Wrap(apperrors.ErrValidation, commons.ErrorInvalidFilter, fmt.Errorf(format, args...))
+	}
+}
+
+// checkValue returns an error unless value has the Go type of kind
+func checkValue(kind columnKind, value interface{}) error {
+	var ok bool
+	switch kind {
+	case kindInt:
+		_, ok = value.(int)
+	case kindText:
+		_, ok = value.(string)
+	case kindTime:
+		_, ok = value.(time.Time)
+	}
+	if !ok {
+		return fmt.Errorf("unexpected value %v of type %T", value, value)
+	}
+	return nil
+}
+
+// build returns the WHERE, ORDER BY and LIMIT clauses of the filter for dialect, with their args
+// numbered from $1
+func (f *UserFilter) build(dialect Dialect) (string, []interface{}, error) {
+	if err := f.Err(); err != nil {
+		return "", nil, err
+	}
+
+	var args []interface{}
+	param := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	conditions := f.conditionsSQL(dialect, param)
+
+	comparison, direction := ">", "ASC"
+	if f.direction == Descending {
+		comparison, direction = "<", "DESC"
+	}
+
+	if f.after != nil {
+		if f.sortColumn == UserColumnID {
+			conditions = append(conditions, fmt.Sprintf("id %s %s", comparison, param(f.after.ID)))
+		} else {
+			value, id := param(f.after.Value), param(f.after.ID)
+			conditions = append(conditions, fmt.Sprintf("(%[1]s %[2]s %[3]s OR (%[1]s = %[3]s AND id %[2]s %[4]s))", f.sortColumn, comparison, value, id))
+		}
+	}
+
+	var clauses []string
+	if len(conditions) > 0 {
+		clauses = append(clauses, "WHERE "+strings.Join(conditions, " AND "))
+	}
+	if f.sortColumn == UserColumnID {
+		clauses = append(clauses, "ORDER BY id "+direction)
+	} else {
+		clauses = append(clauses, fmt.Sprintf("ORDER BY %s %s, id %s", f.sortColumn, direction, direction))
+	}
+	if f.limit > 0 {
+		clauses = append(clauses, "LIMIT "+param(f.limit))
+	}
+	return strings.Join(clauses, " "), args, nil
+}
+
+// buildCount returns the WHERE clause of the conditions of the filter, its cursor aside, for dialect
+func (f *UserFilter) buildCount(dialect Dialect) (string, []interface{}, error) {
+	if err := f.Err(); err != nil {
+		return "", nil, err
+	}
+
+	var args []interface{}
+	param := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	conditions := f.conditionsSQL(dialect, param)
+
+	if len(conditions) == 0 {
+		return "", args, nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+// conditionsSQL returns the conditions of the filter for dialect, param adds an arg and returns its placeholder
+func (f *UserFilter) conditionsSQL(dialect Dialect, param func(value interface{}) string) []string {
+	like := "ILIKE"
+	if dialect == DialectSQLite {
+		// LIKE of SQLite ignores case already, it has no ILIKE
+		like = "LIKE"
+	}
+
+	var conditions []string
+	for _, cond := range f.conditions {
+		column := string(cond.column)
+		switch cond.op {
+		case opEqual:
+			conditions = append(conditions, fmt.Sprintf("%s = %s", column, param(cond.values[0])))
+		case opIn:
+			if len(cond.values) == 0 {
+				conditions = append(conditions, "1 = 0")
+				continue
+			}
+			params := make([]string, len(cond.values))
+			for i, value := range cond.values {
+				params[i] = param(value)
+			}
+			conditions = append(conditions, fmt.Sprintf("%s IN (%s)", column, strings.Join(params, ", ")))
+		case opPrefix:
+			pattern := escapeLike(cond.values[0].(string)) + "%"
+			conditions = append(conditions, fmt.Sprintf(`%s %s %s ESCAPE '\'`, column, like, param(pattern)))
+		case opContains:
+			pattern := "%" + escapeLike(cond.values[0].(string)) + "%"
+			conditions = append(conditions, fmt.Sprintf(`%s %s %s ESCAPE '\'`, column, like, param(pattern)))
+		case opFrom:
+			conditions = append(conditions, fmt.Sprintf("%s >= %s", column, param(cond.values[0])))
+		case opBefore:
+			conditions = append(conditions, fmt.Sprintf("%s < %s", column, param(cond.values[0])))
+		case opNull:
+			conditions = append(conditions, column+" IS NULL")
+		case opNotNull:
+			conditions = append(conditions, column+" IS NOT NULL")
+		}
+	}
+	return conditions
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern so that text matches itself
+func escapeLike(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(text)
+}
+
+// apply is the in-memory counterpart of build: it returns the users of the filter in its order
+func (f *UserFilter) apply(users []*UserModel) ([]*UserModel, error) {
+	if err := f.Err(); err != nil {
+		return nil, err
+	}
+
+	var matched []*UserModel
+	for _, user := range users {
+		if f.matches(user) && (f.after == nil || f.compare(user, *f.after) > 0) {
+			matched = append(matched, user)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return f.compare(matched[i], f.CursorOf(*matched[j])) < 0 })
+
+	if f.limit > 0 && len(matched) > f.limit {
+		matched = matched[:f.limit]
+	}
+	return matched, nil
+}
+
+// matches reports whether user meets the conditions of the filter, its cursor aside
+func (f *UserFilter) matches(user *UserModel) bool {
+	for _, cond := range f.conditions {
+		value := userColumns[cond.column].value(user)
+		switch cond.op {
+		case opNull, opNotNull:
+			if (value == nil) != (cond.op == opNull) {
+				return false
+			}
+			continue
+		}
+		if value == nil {
+			// NULL matches no condition
+			return false
+		}
+
+		var ok bool
+		switch cond.op {
+		case opEqual:
+			ok = compareValues(value, cond.values[0]) == 0
+		case opIn:
+			for _, candidate := range cond.values {
+				ok = ok || compareValues(value, candidate) == 0
+			}
+		case opPrefix:
+			ok = strings.HasPrefix(strings.ToLower(value.(string)), strings.ToLower(cond.values[0].(string)))
+		case opContains:
+			ok = strings.Contains(strings.ToLower(value.(string)), strings.ToLower(cond.values[0].(string)))
+		case opFrom:
+			ok = compareValues(value, cond.values[0]) >= 0
+		case opBefore:
+			ok = compareValues(value, cond.values[0]) < 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// compare returns whether user comes before, -1, or after, 1, the cursor in the order of the filter
+func (f *UserFilter) compare(user *UserModel, cursor UserCursor) int {
+	result := compareValues(userColumns[f.sortColumn].value(user), cursor.Value)
+	if result == 0 {
+		result = compareValues(user.ID, cursor.ID)
+	}
+	if f.direction == Descending {
+		result = -result
+	}
+	return result
+}
+
+// compareValues compares two values of the same column kind
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case int:
+		b := b.(int)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(a, b.(string))
+	case time.Time:
+		b := b.(time.Time)
+		switch {
+		case a.Before(b):
+			return -1
+		case a.After(b):
+			return 1
+		}
+		return 0
+	}
+	return 0
+}
@@ -4,10 +4,49 @@
 // interfaces using mockgen. See the Makefile for more information.
 package repository
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type RepositoryInterface interface {
 	CreateUser(ctx context.Context, input UserInput) (int, error)
 	GetUser(ctx context.Context, input GetUserInput) (*UserModel, error)
-	UpdateUser(ctx context.Context, input UserInput) error
+	ListUsers(ctx context.Context, filter *UserFilter) ([]UserModel, error)
+	CountUsers(ctx context.Context, filter *UserFilter) (int, error)
+	UpdateUser(ctx context.Context, input UpdateUserInput) (int, error)
+	UpdatePassword(ctx context.Context, input UserInput) error
+	VerifyPhoneNumber(ctx context.Context, userID int, phoneNumber string) error
+	ListPhoneNumbers(ctx context.Context) (map[int]string, error)
+	UpdatePhoneNumber(ctx context.Context, userID int, phoneNumber string) error
+	VerifyEmail(ctx context.Context, userID int, email string) error
+	CreateRefreshToken(ctx context.Context, input RefreshTokenInput) (int, error)
+	GetRefreshToken(ctx context.Context, tokenHash string) (*RefreshTokenModel, error)
+	RevokeRefreshToken(ctx context.Context, id int) error
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID int) error
+	RevokeToken(ctx context.Context, input RevokedTokenInput) error
+	IsTokenRevoked(ctx context.Context, tokenID string) (bool, error)
+	PurgeRevokedTokens(ctx context.Context, before time.Time) error
+	IncrementTokenVersion(ctx context.Context, userID int) (int, error)
+	CreatePasswordResetCode(ctx context.Context, input PasswordResetCodeInput) (int, error)
+	GetActivePasswordResetCode(ctx context.Context, userID int) (*PasswordResetCodeModel, error)
+	IncrementPasswordResetAttempts(ctx context.Context, id int) (int, error)
+	UsePasswordResetCode(ctx context.Context, id int) error
+	ReplacePassword(ctx context.Context, input UserInput, keepHistory int) error
+	GetPasswordHistory(ctx context.Context, userID int, limit int) ([]PasswordHistoryModel, error)
+	SaveMfaSecret(ctx context.Context, input MfaInput) error
+	GetMfa(ctx context.Context, userID int) (*MfaModel, error)
+	ConfirmMfa(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error
+	UseMfaStep(ctx context.Context, userID int, step int64) error
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) error
+	DeleteMfa(ctx context.Context, userID int) error
+	CreateMfaChallenge(ctx context.Context, input MfaChallengeInput) (int, error)
+	GetMfaChallenge(ctx context.Context, tokenHash string) (*MfaChallengeModel, error)
+	IncrementMfaChallengeAttempts(ctx context.Context, id int) (int, error)
+	UseMfaChallenge(ctx context.Context, id int) error
+	CreateOtpCode(ctx context.Context, input OtpCodeInput) (int, error)
+	GetActiveOtpCode(ctx context.Context, userID int, purpose string) (*OtpCodeModel, error)
+	IncrementOtpCodeAttempts(ctx context.Context, id int) (int, error)
+	UseOtpCode(ctx context.Context, id int) error
 }
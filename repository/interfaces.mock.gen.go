@@ -7,6 +7,7 @@ package repository
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -34,6 +35,95 @@ func (m *MockRepositoryInterface) EXPECT() *MockRepositoryInterfaceMockRecorder
 	return m.recorder
 }
 
+// ConfirmMfa mocks base method.
+func (m *MockRepositoryInterface) ConfirmMfa(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmMfa", ctx, userID, step, recoveryCodeHashes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConfirmMfa indicates an expected call of ConfirmMfa.
+func (mr *MockRepositoryInterfaceMockRecorder) ConfirmMfa(ctx, userID, step, recoveryCodeHashes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmMfa", reflect.TypeOf((*MockRepositoryInterface)(nil).ConfirmMfa), ctx, userID, step, recoveryCodeHashes)
+}
+
+// CountUsers mocks base method.
+func (m *MockRepositoryInterface) CountUsers(ctx context.Context, filter *UserFilter) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountUsers", ctx, filter)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountUsers indicates an expected call of CountUsers.
+func (mr *MockRepositoryInterfaceMockRecorder) CountUsers(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountUsers", reflect.TypeOf((*MockRepositoryInterface)(nil).CountUsers), ctx, filter)
+}
+
+// CreateMfaChallenge mocks base method.
+func (m *MockRepositoryInterface) CreateMfaChallenge(ctx context.Context, input MfaChallengeInput) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMfaChallenge", ctx, input)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateMfaChallenge indicates an expected call of CreateMfaChallenge.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateMfaChallenge(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMfaChallenge", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateMfaChallenge), ctx, input)
+}
+
+// CreateOtpCode mocks base method.
+func (m *MockRepositoryInterface) CreateOtpCode(ctx context.Context, input OtpCodeInput) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOtpCode", ctx, input)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateOtpCode indicates an expected call of CreateOtpCode.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateOtpCode(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOtpCode", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateOtpCode), ctx, input)
+}
+
+// CreatePasswordResetCode mocks base method.
+func (m *MockRepositoryInterface) CreatePasswordResetCode(ctx context.Context, input PasswordResetCodeInput) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePasswordResetCode", ctx, input)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePasswordResetCode indicates an expected call of CreatePasswordResetCode.
+func (mr *MockRepositoryInterfaceMockRecorder) CreatePasswordResetCode(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePasswordResetCode", reflect.TypeOf((*MockRepositoryInterface)(nil).CreatePasswordResetCode), ctx, input)
+}
+
+// CreateRefreshToken mocks base method.
+func (m *MockRepositoryInterface) CreateRefreshToken(ctx context.Context, input RefreshTokenInput) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRefreshToken", ctx, input)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateRefreshToken indicates an expected call of CreateRefreshToken.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateRefreshToken(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRefreshToken", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateRefreshToken), ctx, input)
+}
+
 // CreateUser mocks base method.
 func (m *MockRepositoryInterface) CreateUser(ctx context.Context, input UserInput) (int, error) {
 	m.ctrl.T.Helper()
@@ -49,6 +139,110 @@ func (mr *MockRepositoryInterfaceMockRecorder) CreateUser(ctx, input interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateUser), ctx, input)
 }
 
+// DeleteMfa mocks base method.
+func (m *MockRepositoryInterface) DeleteMfa(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMfa", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteMfa indicates an expected call of DeleteMfa.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteMfa(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMfa", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteMfa), ctx, userID)
+}
+
+// GetActiveOtpCode mocks base method.
+func (m *MockRepositoryInterface) GetActiveOtpCode(ctx context.Context, userID int, purpose string) (*OtpCodeModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetActiveOtpCode", ctx, userID, purpose)
+	ret0, _ := ret[0].(*OtpCodeModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetActiveOtpCode indicates an expected call of GetActiveOtpCode.
+func (mr *MockRepositoryInterfaceMockRecorder) GetActiveOtpCode(ctx, userID, purpose interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetActiveOtpCode", reflect.TypeOf((*MockRepositoryInterface)(nil).GetActiveOtpCode), ctx, userID, purpose)
+}
+
+// GetActivePasswordResetCode mocks base method.
+func (m *MockRepositoryInterface) GetActivePasswordResetCode(ctx context.Context, userID int) (*PasswordResetCodeModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetActivePasswordResetCode", ctx, userID)
+	ret0, _ := ret[0].(*PasswordResetCodeModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetActivePasswordResetCode indicates an expected call of GetActivePasswordResetCode.
+func (mr *MockRepositoryInterfaceMockRecorder) GetActivePasswordResetCode(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetActivePasswordResetCode", reflect.TypeOf((*MockRepositoryInterface)(nil).GetActivePasswordResetCode), ctx, userID)
+}
+
+// GetMfa mocks base method.
+func (m *MockRepositoryInterface) GetMfa(ctx context.Context, userID int) (*MfaModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMfa", ctx, userID)
+	ret0, _ := ret[0].(*MfaModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMfa indicates an expected call of GetMfa.
+func (mr *MockRepositoryInterfaceMockRecorder) GetMfa(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMfa", reflect.TypeOf((*MockRepositoryInterface)(nil).GetMfa), ctx, userID)
+}
+
+// GetMfaChallenge mocks base method.
+func (m *MockRepositoryInterface) GetMfaChallenge(ctx context.Context, tokenHash string) (*MfaChallengeModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMfaChallenge", ctx, tokenHash)
+	ret0, _ := ret[0].(*MfaChallengeModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMfaChallenge indicates an expected call of GetMfaChallenge.
+func (mr *MockRepositoryInterfaceMockRecorder) GetMfaChallenge(ctx, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMfaChallenge", reflect.TypeOf((*MockRepositoryInterface)(nil).GetMfaChallenge), ctx, tokenHash)
+}
+
+// GetPasswordHistory mocks base method.
+func (m *MockRepositoryInterface) GetPasswordHistory(ctx context.Context, userID, limit int) ([]PasswordHistoryModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPasswordHistory", ctx, userID, limit)
+	ret0, _ := ret[0].([]PasswordHistoryModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPasswordHistory indicates an expected call of GetPasswordHistory.
+func (mr *MockRepositoryInterfaceMockRecorder) GetPasswordHistory(ctx, userID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPasswordHistory", reflect.TypeOf((*MockRepositoryInterface)(nil).GetPasswordHistory), ctx, userID, limit)
+}
+
+// GetRefreshToken mocks base method.
+func (m *MockRepositoryInterface) GetRefreshToken(ctx context.Context, tokenHash string) (*RefreshTokenModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRefreshToken", ctx, tokenHash)
+	ret0, _ := ret[0].(*RefreshTokenModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRefreshToken indicates an expected call of GetRefreshToken.
+func (mr *MockRepositoryInterfaceMockRecorder) GetRefreshToken(ctx, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefreshToken", reflect.TypeOf((*MockRepositoryInterface)(nil).GetRefreshToken), ctx, tokenHash)
+}
+
 // GetUser mocks base method.
 func (m *MockRepositoryInterface) GetUser(ctx context.Context, input GetUserInput) (*UserModel, error) {
 	m.ctrl.T.Helper()
@@ -64,16 +258,346 @@ func (mr *MockRepositoryInterfaceMockRecorder) GetUser(ctx, input interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockRepositoryInterface)(nil).GetUser), ctx, input)
 }
 
-// UpdateUser mocks base method.
-func (m *MockRepositoryInterface) UpdateUser(ctx context.Context, input UserInput) error {
+// IncrementMfaChallengeAttempts mocks base method.
+func (m *MockRepositoryInterface) IncrementMfaChallengeAttempts(ctx context.Context, id int) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateUser", ctx, input)
+	ret := m.ctrl.Call(m, "IncrementMfaChallengeAttempts", ctx, id)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrementMfaChallengeAttempts indicates an expected call of IncrementMfaChallengeAttempts.
+func (mr *MockRepositoryInterfaceMockRecorder) IncrementMfaChallengeAttempts(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementMfaChallengeAttempts", reflect.TypeOf((*MockRepositoryInterface)(nil).IncrementMfaChallengeAttempts), ctx, id)
+}
+
+// IncrementOtpCodeAttempts mocks base method.
+func (m *MockRepositoryInterface) IncrementOtpCodeAttempts(ctx context.Context, id int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementOtpCodeAttempts", ctx, id)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrementOtpCodeAttempts indicates an expected call of IncrementOtpCodeAttempts.
+func (mr *MockRepositoryInterfaceMockRecorder) IncrementOtpCodeAttempts(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementOtpCodeAttempts", reflect.TypeOf((*MockRepositoryInterface)(nil).IncrementOtpCodeAttempts), ctx, id)
+}
+
+// IncrementPasswordResetAttempts mocks base method.
+func (m *MockRepositoryInterface) IncrementPasswordResetAttempts(ctx context.Context, id int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementPasswordResetAttempts", ctx, id)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrementPasswordResetAttempts indicates an expected call of IncrementPasswordResetAttempts.
+func (mr *MockRepositoryInterfaceMockRecorder) IncrementPasswordResetAttempts(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementPasswordResetAttempts", reflect.TypeOf((*MockRepositoryInterface)(nil).IncrementPasswordResetAttempts), ctx, id)
+}
+
+// IncrementTokenVersion mocks base method.
+func (m *MockRepositoryInterface) IncrementTokenVersion(ctx context.Context, userID int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementTokenVersion", ctx, userID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrementTokenVersion indicates an expected call of IncrementTokenVersion.
+func (mr *MockRepositoryInterfaceMockRecorder) IncrementTokenVersion(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementTokenVersion", reflect.TypeOf((*MockRepositoryInterface)(nil).IncrementTokenVersion), ctx, userID)
+}
+
+// IsTokenRevoked mocks base method.
+func (m *MockRepositoryInterface) IsTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsTokenRevoked", ctx, tokenID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsTokenRevoked indicates an expected call of IsTokenRevoked.
+func (mr *MockRepositoryInterfaceMockRecorder) IsTokenRevoked(ctx, tokenID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsTokenRevoked", reflect.TypeOf((*MockRepositoryInterface)(nil).IsTokenRevoked), ctx, tokenID)
+}
+
+// ListPhoneNumbers mocks base method.
+func (m *MockRepositoryInterface) ListPhoneNumbers(ctx context.Context) (map[int]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPhoneNumbers", ctx)
+	ret0, _ := ret[0].(map[int]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPhoneNumbers indicates an expected call of ListPhoneNumbers.
+func (mr *MockRepositoryInterfaceMockRecorder) ListPhoneNumbers(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPhoneNumbers", reflect.TypeOf((*MockRepositoryInterface)(nil).ListPhoneNumbers), ctx)
+}
+
+// ListUsers mocks base method.
+func (m *MockRepositoryInterface) ListUsers(ctx context.Context, filter *UserFilter) ([]UserModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsers", ctx, filter)
+	ret0, _ := ret[0].([]UserModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsers indicates an expected call of ListUsers.
+func (mr *MockRepositoryInterfaceMockRecorder) ListUsers(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsers", reflect.TypeOf((*MockRepositoryInterface)(nil).ListUsers), ctx, filter)
+}
+
+// PurgeRevokedTokens mocks base method.
+func (m *MockRepositoryInterface) PurgeRevokedTokens(ctx context.Context, before time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeRevokedTokens", ctx, before)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgeRevokedTokens indicates an expected call of PurgeRevokedTokens.
+func (mr *MockRepositoryInterfaceMockRecorder) PurgeRevokedTokens(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeRevokedTokens", reflect.TypeOf((*MockRepositoryInterface)(nil).PurgeRevokedTokens), ctx, before)
+}
+
+// ReplacePassword mocks base method.
+func (m *MockRepositoryInterface) ReplacePassword(ctx context.Context, input UserInput, keepHistory int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplacePassword", ctx, input, keepHistory)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReplacePassword indicates an expected call of ReplacePassword.
+func (mr *MockRepositoryInterfaceMockRecorder) ReplacePassword(ctx, input, keepHistory interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplacePassword", reflect.TypeOf((*MockRepositoryInterface)(nil).ReplacePassword), ctx, input, keepHistory)
+}
+
+// RevokeRefreshToken mocks base method.
+func (m *MockRepositoryInterface) RevokeRefreshToken(ctx context.Context, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeRefreshToken", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeRefreshToken indicates an expected call of RevokeRefreshToken.
+func (mr *MockRepositoryInterfaceMockRecorder) RevokeRefreshToken(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeRefreshToken", reflect.TypeOf((*MockRepositoryInterface)(nil).RevokeRefreshToken), ctx, id)
+}
+
+// RevokeRefreshTokenFamily mocks base method.
+func (m *MockRepositoryInterface) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeRefreshTokenFamily", ctx, familyID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeRefreshTokenFamily indicates an expected call of RevokeRefreshTokenFamily.
+func (mr *MockRepositoryInterfaceMockRecorder) RevokeRefreshTokenFamily(ctx, familyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeRefreshTokenFamily", reflect.TypeOf((*MockRepositoryInterface)(nil).RevokeRefreshTokenFamily), ctx, familyID)
+}
+
+// RevokeToken mocks base method.
+func (m *MockRepositoryInterface) RevokeToken(ctx context.Context, input RevokedTokenInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeToken", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeToken indicates an expected call of RevokeToken.
+func (mr *MockRepositoryInterfaceMockRecorder) RevokeToken(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeToken", reflect.TypeOf((*MockRepositoryInterface)(nil).RevokeToken), ctx, input)
+}
+
+// RevokeUserRefreshTokens mocks base method.
+func (m *MockRepositoryInterface) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserRefreshTokens", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUserRefreshTokens indicates an expected call of RevokeUserRefreshTokens.
+func (mr *MockRepositoryInterfaceMockRecorder) RevokeUserRefreshTokens(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserRefreshTokens", reflect.TypeOf((*MockRepositoryInterface)(nil).RevokeUserRefreshTokens), ctx, userID)
+}
+
+// SaveMfaSecret mocks base method.
+func (m *MockRepositoryInterface) SaveMfaSecret(ctx context.Context, input MfaInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveMfaSecret", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveMfaSecret indicates an expected call of SaveMfaSecret.
+func (mr *MockRepositoryInterfaceMockRecorder) SaveMfaSecret(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveMfaSecret", reflect.TypeOf((*MockRepositoryInterface)(nil).SaveMfaSecret), ctx, input)
+}
+
+// UpdatePassword mocks base method.
+func (m *MockRepositoryInterface) UpdatePassword(ctx context.Context, input UserInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePassword", ctx, input)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// UpdatePassword indicates an expected call of UpdatePassword.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdatePassword(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePassword", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdatePassword), ctx, input)
+}
+
+// UpdatePhoneNumber mocks base method.
+func (m *MockRepositoryInterface) UpdatePhoneNumber(ctx context.Context, userID int, phoneNumber string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePhoneNumber", ctx, userID, phoneNumber)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePhoneNumber indicates an expected call of UpdatePhoneNumber.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdatePhoneNumber(ctx, userID, phoneNumber interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePhoneNumber", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdatePhoneNumber), ctx, userID, phoneNumber)
+}
+
+// UpdateUser mocks base method.
+func (m *MockRepositoryInterface) UpdateUser(ctx context.Context, input UpdateUserInput) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, input)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // UpdateUser indicates an expected call of UpdateUser.
 func (mr *MockRepositoryInterfaceMockRecorder) UpdateUser(ctx, input interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateUser), ctx, input)
 }
+
+// UseMfaChallenge mocks base method.
+func (m *MockRepositoryInterface) UseMfaChallenge(ctx context.Context, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseMfaChallenge", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseMfaChallenge indicates an expected call of UseMfaChallenge.
+func (mr *MockRepositoryInterfaceMockRecorder) UseMfaChallenge(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseMfaChallenge", reflect.TypeOf((*MockRepositoryInterface)(nil).UseMfaChallenge), ctx, id)
+}
+
+// UseMfaStep mocks base method.
+func (m *MockRepositoryInterface) UseMfaStep(ctx context.Context, userID int, step int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseMfaStep", ctx, userID, step)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseMfaStep indicates an expected call of UseMfaStep.
+func (mr *MockRepositoryInterfaceMockRecorder) UseMfaStep(ctx, userID, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseMfaStep", reflect.TypeOf((*MockRepositoryInterface)(nil).UseMfaStep), ctx, userID, step)
+}
+
+// UseOtpCode mocks base method.
+func (m *MockRepositoryInterface) UseOtpCode(ctx context.Context, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseOtpCode", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseOtpCode indicates an expected call of UseOtpCode.
+func (mr *MockRepositoryInterfaceMockRecorder) UseOtpCode(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseOtpCode", reflect.TypeOf((*MockRepositoryInterface)(nil).UseOtpCode), ctx, id)
+}
+
+// UsePasswordResetCode mocks base method.
+func (m *MockRepositoryInterface) UsePasswordResetCode(ctx context.Context, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UsePasswordResetCode", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UsePasswordResetCode indicates an expected call of UsePasswordResetCode.
+func (mr *MockRepositoryInterfaceMockRecorder) UsePasswordResetCode(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UsePasswordResetCode", reflect.TypeOf((*MockRepositoryInterface)(nil).UsePasswordResetCode), ctx, id)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockRepositoryInterface) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", ctx, userID, codeHash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockRepositoryInterfaceMockRecorder) UseRecoveryCode(ctx, userID, codeHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockRepositoryInterface)(nil).UseRecoveryCode), ctx, userID, codeHash)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockRepositoryInterface) VerifyEmail(ctx context.Context, userID int, email string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", ctx, userID, email)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockRepositoryInterfaceMockRecorder) VerifyEmail(ctx, userID, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockRepositoryInterface)(nil).VerifyEmail), ctx, userID, email)
+}
+
+// VerifyPhoneNumber mocks base method.
+func (m *MockRepositoryInterface) VerifyPhoneNumber(ctx context.Context, userID int, phoneNumber string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyPhoneNumber", ctx, userID, phoneNumber)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyPhoneNumber indicates an expected call of VerifyPhoneNumber.
+func (mr *MockRepositoryInterfaceMockRecorder) VerifyPhoneNumber(ctx, userID, phoneNumber interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyPhoneNumber", reflect.TypeOf((*MockRepositoryInterface)(nil).VerifyPhoneNumber), ctx, userID, phoneNumber)
+}
@@ -0,0 +1,757 @@
+// This file contains an in-memory implementation of the repository layer for local development and tests.
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRepository keeps every table in process with the semantics of the Postgres Repository: the same
+// unique constraints, apperrors.ErrNotFound on misses and timestamps set on write. Data is lost on exit
+// and is not shared between instances.
+type MemoryRepository struct {
+	mu sync.Mutex
+
+	users              map[int]*UserModel
+	refreshTokens      map[int]*RefreshTokenModel
+	revokedTokens      map[string]*RevokedTokenModel
+	passwordResetCodes map[int]*PasswordResetCodeModel
+	passwordHistory    map[int]*PasswordHistoryModel
+	mfa                map[int]*MfaModel
+	recoveryCodes      map[int]*RecoveryCodeModel
+	mfaChallenges      map[int]*MfaChallengeModel
+	otpCodes           map[int]*OtpCodeModel
+
+	// lastID is the last ID handed out per table, like a SERIAL column
+	lastID map[string]int
+}
+
+// NewMemoryRepository ...
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		users:              map[int]*UserModel{},
+		refreshTokens:      map[int]*RefreshTokenModel{},
+		revokedTokens:      map[string]*RevokedTokenModel{},
+		passwordResetCodes: map[int]*PasswordResetCodeModel{},
+		passwordHistory:    map[int]*PasswordHistoryModel{},
+		mfa:                map[int]*MfaModel{},
+		recoveryCodes:      map[int]*RecoveryCodeModel{},
+		mfaChallenges:      map[int]*MfaChallengeModel{},
+		otpCodes:           map[int]*OtpCodeModel{},
+		lastID:             map[string]int{},
+	}
+}
+
+func (r *MemoryRepository) nextID(table string) int {
+	r.lastID[table]++
+	return r.lastID[table]
+}
+
+// CreateUser ...
+func (r *MemoryRepository) CreateUser(_ context.Context, input UserInput) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.checkUnique(0, &input.PhoneNumber, input.Email); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	user := &UserModel{
+		ID:            r.nextID(UserModel{}.TableName()),
+		PhoneNumber:   input.PhoneNumber,
+		FullName:      input.FullName,
+		Password:      input.Password,
+		SaltKey:       input.SaltKey,
+		PepperVersion: input.PepperVersion,
+		Role:          "user",
+		Email:         copyString(input.Email),
+		Version:       1,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	r.users[user.ID] = user
+	return user.ID, nil
+}
+
+// GetUser returns the user matching every non-nil field of input, the lowest ID when several do
+func (r *MemoryRepository) GetUser(_ context.Context, input GetUserInput) (*UserModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	found, err := input.filter().Limit(1).apply(r.userList())
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, errNoData()
+	}
+	return copyUser(found[0]), nil
+}
+
+// ListUsers ...
+func (r *MemoryRepository) ListUsers(_ context.Context, filter *UserFilter) ([]UserModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	found, err := filter.apply(r.userList())
+	if err != nil {
+		return nil, err
+	}
+	users := make([]UserModel, len(found))
+	for i, user := range found {
+		users[i] = *copyUser(user)
+	}
+	return users, nil
+}
+
+// CountUsers ...
+func (r *MemoryRepository) CountUsers(_ context.Context, filter *UserFilter) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := filter.Err(); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, user := range r.users {
+		if filter.matches(user) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// userList returns every user, the caller holds mu
+func (r *MemoryRepository) userList() []*UserModel {
+	users := make([]*UserModel, 0, len(r.users))
+	for _, user := range r.users {
+		users = append(users, user)
+	}
+	return users
+}
+
+// UpdateUser ...
+func (r *MemoryRepository) UpdateUser(_ context.Context, input UpdateUserInput) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[input.ID]
+	if !ok || user.Version != input.Version {
+		return 0, ErrVersionConflict
+	}
+	if err := r.checkUnique(user.ID, input.PhoneNumber, input.Email); err != nil {
+		return 0, err
+	}
+
+	if input.PhoneNumber != nil {
+		user.PhoneNumber = *input.PhoneNumber
+	}
+	if input.FullName != nil {
+		user.FullName = *input.FullName
+	}
+	if input.Email != nil {
+		user.Email = copyString(input.Email)
+	}
+	if input.RemoveEmail {
+		user.Email = nil
+	}
+	if input.Email != nil || input.RemoveEmail {
+		user.EmailVerifiedAt = nil
+	}
+	user.Version++
+	user.UpdatedAt = time.Now()
+	return user.Version, nil
+}
+
+// UpdatePassword ...
+func (r *MemoryRepository) UpdatePassword(_ context.Context, input UserInput) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user, ok := r.users[input.ID]; ok {
+		user.Password = input.Password
+		user.SaltKey = input.SaltKey
+		user.PepperVersion = input.PepperVersion
+		user.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+// VerifyPhoneNumber ...
+func (r *MemoryRepository) VerifyPhoneNumber(_ context.Context, userID int, phoneNumber string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return nil
+	}
+	if err := r.checkUnique(userID, &phoneNumber, nil); err != nil {
+		return err
+	}
+	now := time.Now()
+	user.PhoneNumber = phoneNumber
+	user.PhoneVerifiedAt = &now
+	user.Version++
+	user.UpdatedAt = now
+	return nil
+}
+
+// ListPhoneNumbers ...
+func (r *MemoryRepository) ListPhoneNumbers(_ context.Context) (map[int]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	phoneNumbers := make(map[int]string, len(r.users))
+	for id, user := range r.users {
+		phoneNumbers[id] = user.PhoneNumber
+	}
+	return phoneNumbers, nil
+}
+
+// UpdatePhoneNumber ...
+func (r *MemoryRepository) UpdatePhoneNumber(_ context.Context, userID int, phoneNumber string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return nil
+	}
+	if err := r.checkUnique(userID, &phoneNumber, nil); err != nil {
+		return err
+	}
+	user.PhoneNumber = phoneNumber
+	user.Version++
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+// VerifyEmail ...
+func (r *MemoryRepository) VerifyEmail(_ context.Context, userID int, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok || user.Email == nil || *user.Email != email {
+		return errNoData()
+	}
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	user.Version++
+	user.UpdatedAt = now
+	return nil
+}
+
+// IncrementTokenVersion ...
+func (r *MemoryRepository) IncrementTokenVersion(_ context.Context, userID int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return 0, errNoData()
+	}
+	user.TokenVersion++
+	user.UpdatedAt = time.Now()
+	return user.TokenVersion, nil
+}
+
+// checkUnique enforces idx_user_phone_number and idx_user_email against every user but exceptID
+func (r *MemoryRepository) checkUnique(exceptID int, phoneNumber, email *string) error {
+	for id, user := range r.users {
+		if id == exceptID {
+			continue
+		}
+		if phoneNumber != nil && user.PhoneNumber == *phoneNumber {
+			return ErrUserExists
+		}
+		if email != nil && user.Email != nil && *user.Email == *email {
+			return ErrEmailExists
+		}
+	}
+	return nil
+}
+
+// CreateRefreshToken ...
+func (r *MemoryRepository) CreateRefreshToken(_ context.Context, input RefreshTokenInput) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token := &RefreshTokenModel{
+		ID:        r.nextID(RefreshTokenModel{}.TableName()),
+		UserID:    input.UserID,
+		TokenHash: input.TokenHash,
+		FamilyID:  input.FamilyID,
+		ExpiresAt: input.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+	r.refreshTokens[token.ID] = token
+	return token.ID, nil
+}
+
+// GetRefreshToken ...
+func (r *MemoryRepository) GetRefreshToken(_ context.Context, tokenHash string) (*RefreshTokenModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.refreshTokens {
+		if token.TokenHash == tokenHash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, errNoData()
+}
+
+// RevokeRefreshToken ...
+func (r *MemoryRepository) RevokeRefreshToken(_ context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.refreshTokens[id]
+	if !ok || token.RevokedAt != nil {
+		return errNoData()
+	}
+	now := time.Now()
+	token.RevokedAt = &now
+	return nil
+}
+
+// RevokeRefreshTokenFamily ...
+func (r *MemoryRepository) RevokeRefreshTokenFamily(_ context.Context, familyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, token := range r.refreshTokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// RevokeUserRefreshTokens ...
+func (r *MemoryRepository) RevokeUserRefreshTokens(_ context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, token := range r.refreshTokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// RevokeToken ...
+func (r *MemoryRepository) RevokeToken(_ context.Context, input RevokedTokenInput) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.revokedTokens[input.TokenID]; !ok {
+		r.revokedTokens[input.TokenID] = &RevokedTokenModel{
+			TokenID:   input.TokenID,
+			UserID:    input.UserID,
+			ExpiresAt: input.ExpiresAt,
+			CreatedAt: time.Now(),
+		}
+	}
+	return nil
+}
+
+// IsTokenRevoked ...
+func (r *MemoryRepository) IsTokenRevoked(_ context.Context, tokenID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.revokedTokens[tokenID]
+	return ok, nil
+}
+
+// PurgeRevokedTokens ...
+func (r *MemoryRepository) PurgeRevokedTokens(_ context.Context, before time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for tokenID, token := range r.revokedTokens {
+		if token.ExpiresAt.Before(before) {
+			delete(r.revokedTokens, tokenID)
+		}
+	}
+	return nil
+}
+
+// CreatePasswordResetCode ...
+func (r *MemoryRepository) CreatePasswordResetCode(_ context.Context, input PasswordResetCodeInput) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, code := range r.passwordResetCodes {
+		if code.UserID == input.UserID && code.UsedAt == nil {
+			code.UsedAt = &now
+		}
+	}
+
+	code := &PasswordResetCodeModel{
+		ID:        r.nextID(PasswordResetCodeModel{}.TableName()),
+		UserID:    input.UserID,
+		CodeHash:  input.CodeHash,
+		ExpiresAt: input.ExpiresAt,
+		CreatedAt: now,
+	}
+	r.passwordResetCodes[code.ID] = code
+	return code.ID, nil
+}
+
+// GetActivePasswordResetCode ...
+func (r *MemoryRepository) GetActivePasswordResetCode(_ context.Context, userID int) (*PasswordResetCodeModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var found *PasswordResetCodeModel
+	for _, code := range r.passwordResetCodes {
+		if code.UserID == userID && code.UsedAt == nil && (found == nil || code.ID > found.ID) {
+			found = code
+		}
+	}
+	if found == nil {
+		return nil, errNoData()
+	}
+	copied := *found
+	return &copied, nil
+}
+
+// IncrementPasswordResetAttempts ...
+func (r *MemoryRepository) IncrementPasswordResetAttempts(_ context.Context, id int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code, ok := r.passwordResetCodes[id]
+	if !ok {
+		return 0, errNoData()
+	}
+	code.Attempts++
+	return code.Attempts, nil
+}
+
+// UsePasswordResetCode ...
+func (r *MemoryRepository) UsePasswordResetCode(_ context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code, ok := r.passwordResetCodes[id]
+	if !ok || code.UsedAt != nil {
+		return errNoData()
+	}
+	now := time.Now()
+	code.UsedAt = &now
+	return nil
+}
+
+// ReplacePassword ...
+func (r *MemoryRepository) ReplacePassword(_ context.Context, input UserInput, keepHistory int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[input.ID]
+	if !ok {
+		return nil
+	}
+
+	if keepHistory > 0 {
+		entry := &PasswordHistoryModel{
+			ID:            r.nextID(PasswordHistoryModel{}.TableName()),
+			UserID:        user.ID,
+			Password:      user.Password,
+			SaltKey:       user.SaltKey,
+			PepperVersion: user.PepperVersion,
+			CreatedAt:     time.Now(),
+		}
+		r.passwordHistory[entry.ID] = entry
+
+		history := r.userPasswordHistory(user.ID)
+		for i := keepHistory; i < len(history); i++ {
+			delete(r.passwordHistory, history[i].ID)
+		}
+	}
+
+	user.Password = input.Password
+	user.SaltKey = input.SaltKey
+	user.PepperVersion = input.PepperVersion
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+// GetPasswordHistory ...
+func (r *MemoryRepository) GetPasswordHistory(_ context.Context, userID int, limit int) ([]PasswordHistoryModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	history := r.userPasswordHistory(userID)
+	if len(history) > limit {
+		history = history[:limit]
+	}
+	if len(history) == 0 {
+		return nil, nil
+	}
+	return history, nil
+}
+
+// userPasswordHistory returns the password history of the user newest first
+func (r *MemoryRepository) userPasswordHistory(userID int) []PasswordHistoryModel {
+	var history []PasswordHistoryModel
+	for _, entry := range r.passwordHistory {
+		if entry.UserID == userID {
+			history = append(history, *entry)
+		}
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].ID > history[j].ID })
+	return history
+}
+
+// SaveMfaSecret ...
+func (r *MemoryRepository) SaveMfaSecret(_ context.Context, input MfaInput) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.mfa[input.UserID]; ok && existing.Enabled() {
+		return errNoData()
+	}
+	r.mfa[input.UserID] = &MfaModel{UserID: input.UserID, Secret: input.Secret, CreatedAt: time.Now()}
+	return nil
+}
+
+// GetMfa ...
+func (r *MemoryRepository) GetMfa(_ context.Context, userID int) (*MfaModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	mfa, ok := r.mfa[userID]
+	if !ok {
+		return nil, errNoData()
+	}
+	copied := *mfa
+	return &copied, nil
+}
+
+// ConfirmMfa ...
+func (r *MemoryRepository) ConfirmMfa(_ context.Context, userID int, step int64, recoveryCodeHashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	mfa, ok := r.mfa[userID]
+	if !ok || mfa.Enabled() {
+		return errNoData()
+	}
+	now := time.Now()
+	mfa.ConfirmedAt = &now
+	mfa.LastUsedStep = step
+
+	for id, code := range r.recoveryCodes {
+		if code.UserID == userID {
+			delete(r.recoveryCodes, id)
+		}
+	}
+	for _, codeHash := range recoveryCodeHashes {
+		code := &RecoveryCodeModel{
+			ID:        r.nextID(RecoveryCodeModel{}.TableName()),
+			UserID:    userID,
+			CodeHash:  codeHash,
+			CreatedAt: now,
+		}
+		r.recoveryCodes[code.ID] = code
+	}
+	return nil
+}
+
+// UseMfaStep ...
+func (r *MemoryRepository) UseMfaStep(_ context.Context, userID int, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	mfa, ok := r.mfa[userID]
+	if !ok || !mfa.Enabled() || mfa.LastUsedStep >= step {
+		return errNoData()
+	}
+	mfa.LastUsedStep = step
+	return nil
+}
+
+// UseRecoveryCode ...
+func (r *MemoryRepository) UseRecoveryCode(_ context.Context, userID int, codeHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, code := range r.recoveryCodes {
+		if code.UserID == userID && code.CodeHash == codeHash && code.UsedAt == nil {
+			now := time.Now()
+			code.UsedAt = &now
+			return nil
+		}
+	}
+	return errNoData()
+}
+
+// DeleteMfa ...
+func (r *MemoryRepository) DeleteMfa(_ context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.mfa, userID)
+	for id, code := range r.recoveryCodes {
+		if code.UserID == userID {
+			delete(r.recoveryCodes, id)
+		}
+	}
+	return nil
+}
+
+// CreateMfaChallenge ...
+func (r *MemoryRepository) CreateMfaChallenge(_ context.Context, input MfaChallengeInput) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	challenge := &MfaChallengeModel{
+		ID:         r.nextID(MfaChallengeModel{}.TableName()),
+		UserID:     input.UserID,
+		TokenHash:  input.TokenHash,
+		AccountKey: input.AccountKey,
+		ExpiresAt:  input.ExpiresAt,
+		CreatedAt:  time.Now(),
+	}
+	r.mfaChallenges[challenge.ID] = challenge
+	return challenge.ID, nil
+}
+
+// GetMfaChallenge ...
+func (r *MemoryRepository) GetMfaChallenge(_ context.Context, tokenHash string) (*MfaChallengeModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, challenge := range r.mfaChallenges {
+		if challenge.TokenHash == tokenHash {
+			copied := *challenge
+			return &copied, nil
+		}
+	}
+	return nil, errNoData()
+}
+
+// IncrementMfaChallengeAttempts ...
+func (r *MemoryRepository) IncrementMfaChallengeAttempts(_ context.Context, id int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	challenge, ok := r.mfaChallenges[id]
+	if !ok {
+		return 0, errNoData()
+	}
+	challenge.Attempts++
+	return challenge.Attempts, nil
+}
+
+// UseMfaChallenge ...
+func (r *MemoryRepository) UseMfaChallenge(_ context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	challenge, ok := r.mfaChallenges[id]
+	if !ok || challenge.UsedAt != nil {
+		return errNoData()
+	}
+	now := time.Now()
+	challenge.UsedAt = &now
+	return nil
+}
+
+// CreateOtpCode ...
+func (r *MemoryRepository) CreateOtpCode(_ context.Context, input OtpCodeInput) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, code := range r.otpCodes {
+		if code.UserID == input.UserID && code.Purpose == input.Purpose && code.UsedAt == nil {
+			code.UsedAt = &now
+		}
+	}
+
+	code := &OtpCodeModel{
+		ID:          r.nextID(OtpCodeModel{}.TableName()),
+		UserID:      input.UserID,
+		Purpose:     input.Purpose,
+		PhoneNumber: input.PhoneNumber,
+		CodeHash:    input.CodeHash,
+		ExpiresAt:   input.ExpiresAt,
+		CreatedAt:   now,
+	}
+	r.otpCodes[code.ID] = code
+	return code.ID, nil
+}
+
+// GetActiveOtpCode ...
+func (r *MemoryRepository) GetActiveOtpCode(_ context.Context, userID int, purpose string) (*OtpCodeModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var found *OtpCodeModel
+	for _, code := range r.otpCodes {
+		if code.UserID == userID && code.Purpose == purpose && code.UsedAt == nil && (found == nil || code.ID > found.ID) {
+			found = code
+		}
+	}
+	if found == nil {
+		return nil, errNoData()
+	}
+	copied := *found
+	return &copied, nil
+}
+
+// IncrementOtpCodeAttempts ...
+func (r *MemoryRepository) IncrementOtpCodeAttempts(_ context.Context, id int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code, ok := r.otpCodes[id]
+	if !ok {
+		return 0, errNoData()
+	}
+	code.Attempts++
+	return code.Attempts, nil
+}
+
+// UseOtpCode ...
+func (r *MemoryRepository) UseOtpCode(_ context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code, ok := r.otpCodes[id]
+	if !ok || code.UsedAt != nil {
+		return errNoData()
+	}
+	now := time.Now()
+	code.UsedAt = &now
+	return nil
+}
+
+// copyUser returns a user the caller can change without touching the stored one
+func copyUser(user *UserModel) *UserModel {
+	copied := *user
+	copied.Email = copyString(user.Email)
+	return &copied
+}
+
+func copyString(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	copied := *value
+	return &copied
+}
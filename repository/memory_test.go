@@ -0,0 +1,44 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/repository/repositorytest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.RepositoryInterface {
+		return repository.NewMemoryRepository()
+	})
+}
+
+func TestMemoryRepositoryConcurrentCreate(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	created := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := repo.CreateUser(context.Background(), repository.UserInput{
+				PhoneNumber: "+628222667727",
+				FullName:    fmt.Sprintf("User %d", i),
+			})
+			if err == nil {
+				mu.Lock()
+				created++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, created, "only one user gets the phone number")
+}
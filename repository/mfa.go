@@ -0,0 +1,272 @@
+// This file contains the repository implementation for multi-factor authentication.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SaveMfaSecret starts an enrollment, replacing an unconfirmed one. It returns apperrors.ErrNotFound when
+// MFA is already enabled for the user so that an enabled secret is never overwritten.
+func (r *Repository) SaveMfaSecret(ctx context.Context, input MfaInput) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %[1]s (userId, secret, createdAt)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (userId) DO UPDATE
+		SET secret=$2, lastUsedStep=0, createdAt=$3
+		WHERE %[1]s.confirmedAt IS NULL`, MfaModel{}.TableName())
+
+	result, err := r.exec(ctx, r.Db, query, input.UserID, input.Secret, time.Now())
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errNoData()
+	}
+
+	return nil
+}
+
+// GetMfa ...
+func (r *Repository) GetMfa(ctx context.Context, userID int) (*MfaModel, error) {
+	model := &MfaModel{}
+
+	query := `
+        SELECT
+            userId,
+            secret,
+            confirmedAt,
+            lastUsedStep,
+            createdAt
+        FROM %s WHERE userId = $1`
+
+	query = fmt.Sprintf(query, model.TableName())
+
+	err := r.queryRow(ctx, r.Db, query, userID).Scan(
+		&model.UserID,
+		&model.Secret,
+		&model.ConfirmedAt,
+		&model.LastUsedStep,
+		&model.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	return model, nil
+}
+
+// ConfirmMfa enables MFA with the time step of the first code and replaces the recovery codes
+// of the user, it returns apperrors.ErrNotFound when there is no unconfirmed enrollment.
+func (r *Repository) ConfirmMfa(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error {
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	confirm := fmt.Sprintf(`
+		UPDATE %s
+		SET confirmedAt=$1, lastUsedStep=$2
+		WHERE userId=$3 AND confirmedAt IS NULL`, MfaModel{}.TableName())
+	result, err := r.exec(ctx, tx, confirm, time.Now(), step, userID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errNoData()
+	}
+
+	if err := r.replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseMfaStep records the time step of an accepted code, it returns apperrors.ErrNotFound when that
+// step or a later one was already used so that a code cannot be replayed.
+func (r *Repository) UseMfaStep(ctx context.Context, userID int, step int64) error {
+	query := `
+		UPDATE %s
+		SET lastUsedStep=$1
+		WHERE userId=$2 AND lastUsedStep < $1 AND confirmedAt IS NOT NULL`
+	query = fmt.Sprintf(query, MfaModel{}.TableName())
+
+	result, err := r.exec(ctx, r.Db, query, step, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errNoData()
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used, it returns apperrors.ErrNotFound when the user has no such code.
+func (r *Repository) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	query := `
+		UPDATE %s
+		SET usedAt=$1
+		WHERE userId=$2 AND codeHash=$3 AND usedAt IS NULL`
+	query = fmt.Sprintf(query, RecoveryCodeModel{}.TableName())
+
+	result, err := r.exec(ctx, r.Db, query, time.Now(), userID, codeHash)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errNoData()
+	}
+
+	return nil
+}
+
+// DeleteMfa disables MFA of the user and drops their recovery codes.
+func (r *Repository) DeleteMfa(ctx context.Context, userID int) error {
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{RecoveryCodeModel{}.TableName(), MfaModel{}.TableName()} {
+		if _, err := r.exec(ctx, tx, fmt.Sprintf(`DELETE FROM %s WHERE userId=$1`, table), userID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CreateMfaChallenge ...
+func (r *Repository) CreateMfaChallenge(ctx context.Context, input MfaChallengeInput) (int, error) {
+	query := fmt.Sprintf(`
+			INSERT INTO %s (userId, tokenHash, accountKey, expiresAt)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id
+		`, MfaChallengeModel{}.TableName())
+
+	var challengeID int
+	if err := r.queryRow(ctx, r.Db, query, input.UserID, input.TokenHash, input.AccountKey, input.ExpiresAt).Scan(&challengeID); err != nil {
+		return 0, err
+	}
+
+	return challengeID, nil
+}
+
+// GetMfaChallenge returns a challenge by the hash of its token, expiry and use are left to the caller.
+func (r *Repository) GetMfaChallenge(ctx context.Context, tokenHash string) (*MfaChallengeModel, error) {
+	model := &MfaChallengeModel{}
+
+	query := `
+        SELECT
+            id,
+            userId,
+            tokenHash,
+            accountKey,
+            attempts,
+            expiresAt,
+            usedAt,
+            createdAt
+        FROM %s WHERE tokenHash = $1`
+
+	query = fmt.Sprintf(query, model.TableName())
+
+	err := r.queryRow(ctx, r.Db, query, tokenHash).Scan(
+		&model.ID,
+		&model.UserID,
+		&model.TokenHash,
+		&model.AccountKey,
+		&model.Attempts,
+		&model.ExpiresAt,
+		&model.UsedAt,
+		&model.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	return model, nil
+}
+
+// IncrementMfaChallengeAttempts records a wrong code and returns the number of attempts so far.
+func (r *Repository) IncrementMfaChallengeAttempts(ctx context.Context, id int) (int, error) {
+	query := `
+		UPDATE %s
+		SET attempts=attempts + 1
+		WHERE id=$1
+		RETURNING attempts`
+	query = fmt.Sprintf(query, MfaChallengeModel{}.TableName())
+
+	var attempts int
+	if err := r.queryRow(ctx, r.Db, query, id).Scan(&attempts); err != nil {
+		return 0, dbError(err)
+	}
+
+	return attempts, nil
+}
+
+// UseMfaChallenge marks a challenge as used, it returns apperrors.ErrNotFound when it was already used.
+func (r *Repository) UseMfaChallenge(ctx context.Context, id int) error {
+	query := `
+		UPDATE %s
+		SET usedAt=$1
+		WHERE id=$2 AND usedAt IS NULL`
+	query = fmt.Sprintf(query, MfaChallengeModel{}.TableName())
+
+	result, err := r.exec(ctx, r.Db, query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errNoData()
+	}
+
+	return nil
+}
+
+func (r *Repository) replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, codeHashes []string) error {
+	table := RecoveryCodeModel{}.TableName()
+
+	if _, err := r.exec(ctx, tx, fmt.Sprintf(`DELETE FROM %s WHERE userId=$1`, table), userID); err != nil {
+		return err
+	}
+
+	insert := fmt.Sprintf(`INSERT INTO %s (userId, codeHash) VALUES ($1, $2)`, table)
+	for _, codeHash := range codeHashes {
+		if _, err := r.exec(ctx, tx, insert, userID, codeHash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ package mocks
 
 import (
 	context "context"
+	time "time"
 
 	repository "github.com/SawitProRecruitment/UserService/repository"
 	mock "github.com/stretchr/testify/mock"
@@ -14,6 +15,140 @@ type RepositoryInterface struct {
 	mock.Mock
 }
 
+// ConfirmMfa provides a mock function with given fields: ctx, userID, step, recoveryCodeHashes
+func (_m *RepositoryInterface) ConfirmMfa(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error {
+	ret := _m.Called(ctx, userID, step, recoveryCodeHashes)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int64, []string) error); ok {
+		r0 = rf(ctx, userID, step, recoveryCodeHashes)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CountUsers provides a mock function with given fields: ctx, filter
+func (_m *RepositoryInterface) CountUsers(ctx context.Context, filter *repository.UserFilter) (int, error) {
+	ret := _m.Called(ctx, filter)
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *repository.UserFilter) (int, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *repository.UserFilter) int); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *repository.UserFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateMfaChallenge provides a mock function with given fields: ctx, input
+func (_m *RepositoryInterface) CreateMfaChallenge(ctx context.Context, input repository.MfaChallengeInput) (int, error) {
+	ret := _m.Called(ctx, input)
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.MfaChallengeInput) (int, error)); ok {
+		return rf(ctx, input)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, repository.MfaChallengeInput) int); ok {
+		r0 = rf(ctx, input)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, repository.MfaChallengeInput) error); ok {
+		r1 = rf(ctx, input)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateOtpCode provides a mock function with given fields: ctx, input
+func (_m *RepositoryInterface) CreateOtpCode(ctx context.Context, input repository.OtpCodeInput) (int, error) {
+	ret := _m.Called(ctx, input)
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.OtpCodeInput) (int, error)); ok {
+		return rf(ctx, input)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, repository.OtpCodeInput) int); ok {
+		r0 = rf(ctx, input)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, repository.OtpCodeInput) error); ok {
+		r1 = rf(ctx, input)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreatePasswordResetCode provides a mock function with given fields: ctx, input
+func (_m *RepositoryInterface) CreatePasswordResetCode(ctx context.Context, input repository.PasswordResetCodeInput) (int, error) {
+	ret := _m.Called(ctx, input)
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.PasswordResetCodeInput) (int, error)); ok {
+		return rf(ctx, input)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, repository.PasswordResetCodeInput) int); ok {
+		r0 = rf(ctx, input)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, repository.PasswordResetCodeInput) error); ok {
+		r1 = rf(ctx, input)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateRefreshToken provides a mock function with given fields: ctx, input
+func (_m *RepositoryInterface) CreateRefreshToken(ctx context.Context, input repository.RefreshTokenInput) (int, error) {
+	ret := _m.Called(ctx, input)
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.RefreshTokenInput) (int, error)); ok {
+		return rf(ctx, input)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, repository.RefreshTokenInput) int); ok {
+		r0 = rf(ctx, input)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, repository.RefreshTokenInput) error); ok {
+		r1 = rf(ctx, input)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateUser provides a mock function with given fields: ctx, input
 func (_m *RepositoryInterface) CreateUser(ctx context.Context, input repository.UserInput) (int, error) {
 	ret := _m.Called(ctx, input)
@@ -38,6 +173,176 @@ func (_m *RepositoryInterface) CreateUser(ctx context.Context, input repository.
 	return r0, r1
 }
 
+// DeleteMfa provides a mock function with given fields: ctx, userID
+func (_m *RepositoryInterface) DeleteMfa(ctx context.Context, userID int) error {
+	ret := _m.Called(ctx, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetActiveOtpCode provides a mock function with given fields: ctx, userID, purpose
+func (_m *RepositoryInterface) GetActiveOtpCode(ctx context.Context, userID int, purpose string) (*repository.OtpCodeModel, error) {
+	ret := _m.Called(ctx, userID, purpose)
+
+	var r0 *repository.OtpCodeModel
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) (*repository.OtpCodeModel, error)); ok {
+		return rf(ctx, userID, purpose)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) *repository.OtpCodeModel); ok {
+		r0 = rf(ctx, userID, purpose)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*repository.OtpCodeModel)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string) error); ok {
+		r1 = rf(ctx, userID, purpose)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetActivePasswordResetCode provides a mock function with given fields: ctx, userID
+func (_m *RepositoryInterface) GetActivePasswordResetCode(ctx context.Context, userID int) (*repository.PasswordResetCodeModel, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 *repository.PasswordResetCodeModel
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) (*repository.PasswordResetCodeModel, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) *repository.PasswordResetCodeModel); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*repository.PasswordResetCodeModel)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetMfa provides a mock function with given fields: ctx, userID
+func (_m *RepositoryInterface) GetMfa(ctx context.Context, userID int) (*repository.MfaModel, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 *repository.MfaModel
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) (*repository.MfaModel, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) *repository.MfaModel); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*repository.MfaModel)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetMfaChallenge provides a mock function with given fields: ctx, tokenHash
+func (_m *RepositoryInterface) GetMfaChallenge(ctx context.Context, tokenHash string) (*repository.MfaChallengeModel, error) {
+	ret := _m.Called(ctx, tokenHash)
+
+	var r0 *repository.MfaChallengeModel
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*repository.MfaChallengeModel, error)); ok {
+		return rf(ctx, tokenHash)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *repository.MfaChallengeModel); ok {
+		r0 = rf(ctx, tokenHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*repository.MfaChallengeModel)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, tokenHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPasswordHistory provides a mock function with given fields: ctx, userID, limit
+func (_m *RepositoryInterface) GetPasswordHistory(ctx context.Context, userID int, limit int) ([]repository.PasswordHistoryModel, error) {
+	ret := _m.Called(ctx, userID, limit)
+
+	var r0 []repository.PasswordHistoryModel
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) ([]repository.PasswordHistoryModel, error)); ok {
+		return rf(ctx, userID, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) []repository.PasswordHistoryModel); ok {
+		r0 = rf(ctx, userID, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]repository.PasswordHistoryModel)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, int) error); ok {
+		r1 = rf(ctx, userID, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRefreshToken provides a mock function with given fields: ctx, tokenHash
+func (_m *RepositoryInterface) GetRefreshToken(ctx context.Context, tokenHash string) (*repository.RefreshTokenModel, error) {
+	ret := _m.Called(ctx, tokenHash)
+
+	var r0 *repository.RefreshTokenModel
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*repository.RefreshTokenModel, error)); ok {
+		return rf(ctx, tokenHash)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *repository.RefreshTokenModel); ok {
+		r0 = rf(ctx, tokenHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*repository.RefreshTokenModel)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, tokenHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetUser provides a mock function with given fields: ctx, input
 func (_m *RepositoryInterface) GetUser(ctx context.Context, input repository.GetUserInput) (*repository.UserModel, error) {
 	ret := _m.Called(ctx, input)
@@ -64,8 +369,278 @@ func (_m *RepositoryInterface) GetUser(ctx context.Context, input repository.Get
 	return r0, r1
 }
 
-// UpdateUser provides a mock function with given fields: ctx, input
-func (_m *RepositoryInterface) UpdateUser(ctx context.Context, input repository.UserInput) error {
+// IncrementMfaChallengeAttempts provides a mock function with given fields: ctx, id
+func (_m *RepositoryInterface) IncrementMfaChallengeAttempts(ctx context.Context, id int) (int, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) (int, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) int); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IncrementOtpCodeAttempts provides a mock function with given fields: ctx, id
+func (_m *RepositoryInterface) IncrementOtpCodeAttempts(ctx context.Context, id int) (int, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) (int, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) int); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IncrementPasswordResetAttempts provides a mock function with given fields: ctx, id
+func (_m *RepositoryInterface) IncrementPasswordResetAttempts(ctx context.Context, id int) (int, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) (int, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) int); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IncrementTokenVersion provides a mock function with given fields: ctx, userID
+func (_m *RepositoryInterface) IncrementTokenVersion(ctx context.Context, userID int) (int, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) (int, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) int); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IsTokenRevoked provides a mock function with given fields: ctx, tokenID
+func (_m *RepositoryInterface) IsTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	ret := _m.Called(ctx, tokenID)
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (bool, error)); ok {
+		return rf(ctx, tokenID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, tokenID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, tokenID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListPhoneNumbers provides a mock function with given fields: ctx
+func (_m *RepositoryInterface) ListPhoneNumbers(ctx context.Context) (map[int]string, error) {
+	ret := _m.Called(ctx)
+
+	var r0 map[int]string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (map[int]string, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) map[int]string); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[int]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListUsers provides a mock function with given fields: ctx, filter
+func (_m *RepositoryInterface) ListUsers(ctx context.Context, filter *repository.UserFilter) ([]repository.UserModel, error) {
+	ret := _m.Called(ctx, filter)
+
+	var r0 []repository.UserModel
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *repository.UserFilter) ([]repository.UserModel, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *repository.UserFilter) []repository.UserModel); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]repository.UserModel)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *repository.UserFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeRevokedTokens provides a mock function with given fields: ctx, before
+func (_m *RepositoryInterface) PurgeRevokedTokens(ctx context.Context, before time.Time) error {
+	ret := _m.Called(ctx, before)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) error); ok {
+		r0 = rf(ctx, before)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReplacePassword provides a mock function with given fields: ctx, input, keepHistory
+func (_m *RepositoryInterface) ReplacePassword(ctx context.Context, input repository.UserInput, keepHistory int) error {
+	ret := _m.Called(ctx, input, keepHistory)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.UserInput, int) error); ok {
+		r0 = rf(ctx, input, keepHistory)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeRefreshToken provides a mock function with given fields: ctx, id
+func (_m *RepositoryInterface) RevokeRefreshToken(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeRefreshTokenFamily provides a mock function with given fields: ctx, familyID
+func (_m *RepositoryInterface) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	ret := _m.Called(ctx, familyID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, familyID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeToken provides a mock function with given fields: ctx, input
+func (_m *RepositoryInterface) RevokeToken(ctx context.Context, input repository.RevokedTokenInput) error {
+	ret := _m.Called(ctx, input)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.RevokedTokenInput) error); ok {
+		r0 = rf(ctx, input)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeUserRefreshTokens provides a mock function with given fields: ctx, userID
+func (_m *RepositoryInterface) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	ret := _m.Called(ctx, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveMfaSecret provides a mock function with given fields: ctx, input
+func (_m *RepositoryInterface) SaveMfaSecret(ctx context.Context, input repository.MfaInput) error {
+	ret := _m.Called(ctx, input)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.MfaInput) error); ok {
+		r0 = rf(ctx, input)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdatePassword provides a mock function with given fields: ctx, input
+func (_m *RepositoryInterface) UpdatePassword(ctx context.Context, input repository.UserInput) error {
 	ret := _m.Called(ctx, input)
 
 	var r0 error
@@ -78,6 +653,142 @@ func (_m *RepositoryInterface) UpdateUser(ctx context.Context, input repository.
 	return r0
 }
 
+// UpdatePhoneNumber provides a mock function with given fields: ctx, userID, phoneNumber
+func (_m *RepositoryInterface) UpdatePhoneNumber(ctx context.Context, userID int, phoneNumber string) error {
+	ret := _m.Called(ctx, userID, phoneNumber)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) error); ok {
+		r0 = rf(ctx, userID, phoneNumber)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateUser provides a mock function with given fields: ctx, input
+func (_m *RepositoryInterface) UpdateUser(ctx context.Context, input repository.UpdateUserInput) (int, error) {
+	ret := _m.Called(ctx, input)
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.UpdateUserInput) (int, error)); ok {
+		return rf(ctx, input)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, repository.UpdateUserInput) int); ok {
+		r0 = rf(ctx, input)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, repository.UpdateUserInput) error); ok {
+		r1 = rf(ctx, input)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UseMfaChallenge provides a mock function with given fields: ctx, id
+func (_m *RepositoryInterface) UseMfaChallenge(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UseMfaStep provides a mock function with given fields: ctx, userID, step
+func (_m *RepositoryInterface) UseMfaStep(ctx context.Context, userID int, step int64) error {
+	ret := _m.Called(ctx, userID, step)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int64) error); ok {
+		r0 = rf(ctx, userID, step)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UseOtpCode provides a mock function with given fields: ctx, id
+func (_m *RepositoryInterface) UseOtpCode(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UsePasswordResetCode provides a mock function with given fields: ctx, id
+func (_m *RepositoryInterface) UsePasswordResetCode(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UseRecoveryCode provides a mock function with given fields: ctx, userID, codeHash
+func (_m *RepositoryInterface) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	ret := _m.Called(ctx, userID, codeHash)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) error); ok {
+		r0 = rf(ctx, userID, codeHash)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// VerifyEmail provides a mock function with given fields: ctx, userID, email
+func (_m *RepositoryInterface) VerifyEmail(ctx context.Context, userID int, email string) error {
+	ret := _m.Called(ctx, userID, email)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) error); ok {
+		r0 = rf(ctx, userID, email)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// VerifyPhoneNumber provides a mock function with given fields: ctx, userID, phoneNumber
+func (_m *RepositoryInterface) VerifyPhoneNumber(ctx context.Context, userID int, phoneNumber string) error {
+	ret := _m.Called(ctx, userID, phoneNumber)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) error); ok {
+		r0 = rf(ctx, userID, phoneNumber)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewRepositoryInterface creates a new instance of RepositoryInterface. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewRepositoryInterface(t interface {
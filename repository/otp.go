@@ -0,0 +1,121 @@
+// This file contains the repository implementation for one-time codes sent by SMS.
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CreateOtpCode stores a new code and invalidates the codes of the same purpose issued to the user
+// before it, only the latest code can be used.
+func (r *Repository) CreateOtpCode(ctx context.Context, input OtpCodeInput) (int, error) {
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	invalidate := fmt.Sprintf(`
+			UPDATE %s SET usedAt=$1 WHERE userId=$2 AND purpose=$3 AND usedAt IS NULL
+		`, OtpCodeModel{}.TableName())
+	if _, err := r.exec(ctx, tx, invalidate, time.Now(), input.UserID, input.Purpose); err != nil {
+		return 0, err
+	}
+
+	insert := fmt.Sprintf(`
+			INSERT INTO %s (userId, purpose, phoneNumber, codeHash, expiresAt)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id
+		`, OtpCodeModel{}.TableName())
+
+	var codeID int
+	err = r.queryRow(ctx, tx, insert,
+		input.UserID, input.Purpose, input.PhoneNumber, input.CodeHash, input.ExpiresAt,
+	).Scan(&codeID)
+	if err != nil {
+		return 0, err
+	}
+
+	return codeID, tx.Commit()
+}
+
+// GetActiveOtpCode returns the latest unused code of the user for the purpose, expiry is left to the caller.
+func (r *Repository) GetActiveOtpCode(ctx context.Context, userID int, purpose string) (*OtpCodeModel, error) {
+	model := &OtpCodeModel{}
+
+	query := `
+        SELECT
+            id,
+            userId,
+            purpose,
+            phoneNumber,
+            codeHash,
+            attempts,
+            expiresAt,
+            usedAt,
+            createdAt
+        FROM %s WHERE userId = $1 AND purpose = $2 AND usedAt IS NULL
+        ORDER BY id DESC LIMIT 1`
+
+	query = fmt.Sprintf(query, model.TableName())
+
+	err := r.queryRow(ctx, r.Db, query, userID, purpose).Scan(
+		&model.ID,
+		&model.UserID,
+		&model.Purpose,
+		&model.PhoneNumber,
+		&model.CodeHash,
+		&model.Attempts,
+		&model.ExpiresAt,
+		&model.UsedAt,
+		&model.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	return model, nil
+}
+
+// IncrementOtpCodeAttempts records a wrong guess and returns the number of attempts so far.
+func (r *Repository) IncrementOtpCodeAttempts(ctx context.Context, id int) (int, error) {
+	query := `
+		UPDATE %s
+		SET attempts=attempts + 1
+		WHERE id=$1
+		RETURNING attempts`
+	query = fmt.Sprintf(query, OtpCodeModel{}.TableName())
+
+	var attempts int
+	if err := r.queryRow(ctx, r.Db, query, id).Scan(&attempts); err != nil {
+		return 0, dbError(err)
+	}
+
+	return attempts, nil
+}
+
+// UseOtpCode marks a code as used, it returns apperrors.ErrNotFound when the code was already used.
+func (r *Repository) UseOtpCode(ctx context.Context, id int) error {
+	query := `
+		UPDATE %s
+		SET usedAt=$1
+		WHERE id=$2 AND usedAt IS NULL`
+	query = fmt.Sprintf(query, OtpCodeModel{}.TableName())
+
+	result, err := r.exec(ctx, r.Db, query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errNoData()
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+// This file contains the repository implementation for the password history.
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ReplacePassword stores the new password hash of a user and, when keepHistory is positive, moves the
+// current one into the history and keeps only the keepHistory most recent entries, all in one transaction.
+func (r *Repository) ReplacePassword(ctx context.Context, input UserInput, keepHistory int) error {
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if keepHistory > 0 {
+		archive := fmt.Sprintf(`
+			INSERT INTO %s (userId, password, saltKey, pepperVersion)
+			SELECT id, password, saltKey, pepperVersion FROM %s WHERE id=$1
+		`, PasswordHistoryModel{}.TableName(), UserModel{}.TableName())
+		if _, err := r.exec(ctx, tx, archive, input.ID); err != nil {
+			return err
+		}
+	}
+
+	update := fmt.Sprintf(`
+		UPDATE %s
+		SET password=$1, saltKey=$2, pepperVersion=$3, updatedAt=$4
+		WHERE id=$5`, UserModel{}.TableName())
+	if _, err := r.exec(ctx, tx, update, input.Password, input.SaltKey, input.PepperVersion, time.Now(), input.ID); err != nil {
+		return err
+	}
+
+	if keepHistory > 0 {
+		prune := fmt.Sprintf(`
+			DELETE FROM %[1]s
+			WHERE userId = $1 AND id NOT IN (
+				SELECT id FROM %[1]s WHERE userId = $1 ORDER BY id DESC LIMIT $2
+			)`, PasswordHistoryModel{}.TableName())
+		if _, err := r.exec(ctx, tx, prune, input.ID, keepHistory); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetPasswordHistory returns up to limit previous passwords of the user, newest first.
+func (r *Repository) GetPasswordHistory(ctx context.Context, userID int, limit int) ([]PasswordHistoryModel, error) {
+	query := `
+        SELECT
+            id,
+            userId,
+            password,
+            saltKey,
+            pepperVersion,
+            createdAt
+        FROM %s WHERE userId = $1
+        ORDER BY id DESC LIMIT $2`
+	query = fmt.Sprintf(query, PasswordHistoryModel{}.TableName())
+
+	rows, err := r.query(ctx, r.Db, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []PasswordHistoryModel
+	for rows.Next() {
+		model := PasswordHistoryModel{}
+		if err := rows.Scan(&model.ID, &model.UserID, &model.Password, &model.SaltKey, &model.PepperVersion, &model.CreatedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, model)
+	}
+
+	return history, rows.Err()
+}
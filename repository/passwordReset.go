@@ -0,0 +1,115 @@
+// This file contains the repository implementation for password reset codes.
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CreatePasswordResetCode stores a new code and invalidates the codes issued to the user before it,
+// only the latest code can be used.
+func (r *Repository) CreatePasswordResetCode(ctx context.Context, input PasswordResetCodeInput) (int, error) {
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	invalidate := fmt.Sprintf(`
+			UPDATE %s SET usedAt=$1 WHERE userId=$2 AND usedAt IS NULL
+		`, PasswordResetCodeModel{}.TableName())
+	if _, err := r.exec(ctx, tx, invalidate, time.Now(), input.UserID); err != nil {
+		return 0, err
+	}
+
+	insert := fmt.Sprintf(`
+			INSERT INTO %s (userId, codeHash, expiresAt)
+			VALUES ($1, $2, $3)
+			RETURNING id
+		`, PasswordResetCodeModel{}.TableName())
+
+	var codeID int
+	if err := r.queryRow(ctx, tx, insert, input.UserID, input.CodeHash, input.ExpiresAt).Scan(&codeID); err != nil {
+		return 0, err
+	}
+
+	return codeID, tx.Commit()
+}
+
+// GetActivePasswordResetCode returns the latest unused code of the user, expiry is left to the caller.
+func (r *Repository) GetActivePasswordResetCode(ctx context.Context, userID int) (*PasswordResetCodeModel, error) {
+	model := &PasswordResetCodeModel{}
+
+	query := `
+        SELECT
+            id,
+            userId,
+            codeHash,
+            attempts,
+            expiresAt,
+            usedAt,
+            createdAt
+        FROM %s WHERE userId = $1 AND usedAt IS NULL
+        ORDER BY id DESC LIMIT 1`
+
+	query = fmt.Sprintf(query, model.TableName())
+
+	err := r.queryRow(ctx, r.Db, query, userID).Scan(
+		&model.ID,
+		&model.UserID,
+		&model.CodeHash,
+		&model.Attempts,
+		&model.ExpiresAt,
+		&model.UsedAt,
+		&model.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	return model, nil
+}
+
+// IncrementPasswordResetAttempts records a wrong guess and returns the number of attempts so far.
+func (r *Repository) IncrementPasswordResetAttempts(ctx context.Context, id int) (int, error) {
+	query := `
+		UPDATE %s
+		SET attempts=attempts + 1
+		WHERE id=$1
+		RETURNING attempts`
+	query = fmt.Sprintf(query, PasswordResetCodeModel{}.TableName())
+
+	var attempts int
+	if err := r.queryRow(ctx, r.Db, query, id).Scan(&attempts); err != nil {
+		return 0, dbError(err)
+	}
+
+	return attempts, nil
+}
+
+// UsePasswordResetCode marks a code as used, it returns apperrors.ErrNotFound when the
+// code was already used so that a code can only ever reset one password.
+func (r *Repository) UsePasswordResetCode(ctx context.Context, id int) error {
+	query := `
+		UPDATE %s
+		SET usedAt=$1
+		WHERE id=$2 AND usedAt IS NULL`
+	query = fmt.Sprintf(query, PasswordResetCodeModel{}.TableName())
+
+	result, err := r.exec(ctx, r.Db, query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errNoData()
+	}
+
+	return nil
+}
@@ -6,8 +6,6 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"github.com/SawitProRecruitment/UserService/commons"
-	"reflect"
 	"strings"
 	"time"
 
@@ -16,125 +14,291 @@ import (
 
 type Repository struct {
 	Db *sql.DB
+	// Dialect is the database behind Db, queries are written for Postgres
+	Dialect Dialect
 }
 
+// CreateUser inserts a user and returns its ID, it returns ErrUserExists or ErrEmailExists when
+// the phone number or email belongs to another user.
 func (r *Repository) CreateUser(ctx context.Context, input UserInput) (int, error) {
 	query := fmt.Sprintf(`
-			INSERT INTO %s (phoneNumber, fullName, password, saltKey)
-			VALUES ($1, $2, $3, $4)
+			INSERT INTO %s (phoneNumber, fullName, password, saltKey, pepperVersion, email)
+			VALUES ($1, $2, $3, $4, $5, $6)
 			RETURNING id
 		`, UserModel{}.TableName())
 
 	var userID int
-	if err := r.Db.QueryRowContext(ctx, query, input.PhoneNumber, input.FullName, input.Password, input.SaltKey).Scan(&userID); err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return 0, errors.New(commons.ErrorNoData)
-		default:
-			return 0, err
-		}
+	if err := r.queryRow(ctx, r.Db, query, input.PhoneNumber, input.FullName, input.Password, input.SaltKey, input.PepperVersion, input.Email).Scan(&userID); err != nil {
+		return 0, dbError(err)
 	}
 
 	return userID, nil
 }
 
-func (r *Repository) GetUser(ctx context.Context, input GetUserInput) (*UserModel, error) {
-	model := &UserModel{}
-
-	where, args := BuildQuery(input)
-
-	query := `
+// userSelect selects every column of users in the order scanUser reads them, followed by the
+// clauses of a UserFilter
+const userSelect = `
         SELECT
             id,
             phoneNumber,
             fullName,
             password,
             saltKey,
+            pepperVersion,
+            role,
+            tokenVersion,
+            phoneVerifiedAt,
+            email,
+            emailVerifiedAt,
+            version,
             createdAt,
             updatedAt
         FROM %s %s`
 
-	query = fmt.Sprintf(query, model.TableName(), where)
+// GetUser returns the user matching every non-nil field of input, the lowest ID when several do
+func (r *Repository) GetUser(ctx context.Context, input GetUserInput) (*UserModel, error) {
+	clauses, args, err := input.filter().Limit(1).build(r.Dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(userSelect, UserModel{}.TableName(), clauses)
+
+	model, err := scanUser(r.queryRow(ctx, r.Db, query, args...))
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	return model, nil
+}
+
+// ListUsers returns the users of filter in its order
+func (r *Repository) ListUsers(ctx context.Context, filter *UserFilter) ([]UserModel, error) {
+	clauses, args, err := filter.build(r.Dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(userSelect, UserModel{}.TableName(), clauses)
+
+	rows, err := r.query(ctx, r.Db, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	err := r.Db.QueryRowContext(ctx, query, args...).Scan(
+	var users []UserModel
+	for rows.Next() {
+		model, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *model)
+	}
+
+	return users, rows.Err()
+}
+
+// CountUsers returns how many users meet the conditions of filter, its cursor, order and limit aside
+func (r *Repository) CountUsers(ctx context.Context, filter *UserFilter) (int, error) {
+	where, args, err := filter.buildCount(r.Dialect)
+	if err != nil {
+		return 0, err
+	}
+
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM %s %s`, UserModel{}.TableName(), where)
+
+	var count int
+	if err := r.queryRow(ctx, r.Db, query, args...).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// scanUser reads a row of userSelect, row is a *sql.Row or *sql.Rows
+func scanUser(row interface {
+	Scan(dest ...interface{}) error
+}) (*UserModel, error) {
+	model := &UserModel{}
+	err := row.Scan(
 		&model.ID,
 		&model.PhoneNumber,
 		&model.FullName,
 		&model.Password,
 		&model.SaltKey,
+		&model.PepperVersion,
+		&model.Role,
+		&model.TokenVersion,
+		&model.PhoneVerifiedAt,
+		&model.Email,
+		&model.EmailVerifiedAt,
+		&model.Version,
 		&model.CreatedAt,
 		&model.UpdatedAt,
 	)
-
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New(commons.ErrorNoData)
-		}
 		return nil, err
 	}
-
 	return model, nil
 }
 
-func (r *Repository) UpdateUser(ctx context.Context, input UserInput) error {
-	currentTime := time.Now()
+// UpdateUser writes the fields set in input and returns the new version of the user. It is a
+// compare-and-swap: when the user no longer has input.Version nothing is written and
+// ErrVersionConflict is returned.
+func (r *Repository) UpdateUser(ctx context.Context, input UpdateUserInput) (int, error) {
+	var assignments []string
+	var args []interface{}
+	assign := func(column string, value interface{}) {
+		args = append(args, value)
+		assignments = append(assignments, fmt.Sprintf("%s=$%d", column, len(args)))
+	}
+	if input.PhoneNumber != nil {
+		assign("phoneNumber", *input.PhoneNumber)
+	}
+	if input.FullName != nil {
+		assign("fullName", *input.FullName)
+	}
+	if input.Email != nil {
+		assign("email", *input.Email)
+	}
+	if input.RemoveEmail {
+		assignments = append(assignments, "email=NULL")
+	}
+	if input.Email != nil || input.RemoveEmail {
+		assignments = append(assignments, "emailVerifiedAt=NULL")
+	}
+	assignments = append(assignments, "version=version + 1", fmt.Sprintf("updatedAt=$%d", len(args)+1))
+
 	query := `
-		UPDATE %s 
-		SET phoneNumber=$1, fullName=$2, updatedAt=$3 
-		WHERE id=$4`
+		UPDATE %s
+		SET %s
+		WHERE id=$%d AND version=$%d
+		RETURNING version`
+	query = fmt.Sprintf(query, UserModel{}.TableName(), strings.Join(assignments, ", "), len(args)+2, len(args)+3)
+
+	var version int
+	if err := r.queryRow(ctx, r.Db, query, append(args, time.Now(), input.ID, input.Version)...).Scan(&version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrVersionConflict
+		}
+		return 0, dbError(err)
+	}
+	return version, nil
+}
+
+// UpdatePassword replaces the password hash, salt and pepper version of a user
+func (r *Repository) UpdatePassword(ctx context.Context, input UserInput) error {
+	query := `
+		UPDATE %s
+		SET password=$1, saltKey=$2, pepperVersion=$3, updatedAt=$4
+		WHERE id=$5`
 	query = fmt.Sprintf(query, UserModel{}.TableName())
-	_, err := r.Db.ExecContext(ctx, query, input.PhoneNumber, input.FullName, currentTime, input.ID)
+	_, err := r.exec(ctx, r.Db, query, input.Password, input.SaltKey, input.PepperVersion, time.Now(), input.ID)
 	return err
 }
 
-func BuildQuery(input interface{}) (string, []interface{}) {
-	var conditions []string
-	var args []interface{}
-
-	inputValue := reflect.ValueOf(input)
-	inputType := inputValue.Type()
+// VerifyPhoneNumber sets the phone number of a user to one they proved they own
+func (r *Repository) VerifyPhoneNumber(ctx context.Context, userID int, phoneNumber string) error {
+	query := `
+		UPDATE %s
+		SET phoneNumber=$1, phoneVerifiedAt=$2, version=version + 1, updatedAt=$2
+		WHERE id=$3`
+	query = fmt.Sprintf(query, UserModel{}.TableName())
+	_, err := r.exec(ctx, r.Db, query, phoneNumber, time.Now(), userID)
+	return dbError(err)
+}
 
-	if inputType.Kind() != reflect.Struct {
-		return "", args
+// VerifyEmail marks the email of a user as verified, it returns apperrors.ErrNotFound when the user
+// no longer has that email
+func (r *Repository) VerifyEmail(ctx context.Context, userID int, email string) error {
+	query := `
+		UPDATE %s
+		SET emailVerifiedAt=$1, version=version + 1, updatedAt=$1
+		WHERE id=$2 AND email=$3`
+	query = fmt.Sprintf(query, UserModel{}.TableName())
+	result, err := r.exec(ctx, r.Db, query, time.Now(), userID, email)
+	if err != nil {
+		return dbError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
 	}
+	if affected == 0 {
+		return errNoData()
+	}
+	return nil
+}
 
-	argIndex := 1
-	for i := 0; i < inputValue.NumField(); i++ {
-		fieldValue := inputValue.Field(i)
-		fieldType := inputType.Field(i)
-		jsonTag := fieldType.Tag.Get("json")
+// ListPhoneNumbers returns the stored phone number of every user keyed by user ID
+func (r *Repository) ListPhoneNumbers(ctx context.Context) (map[int]string, error) {
+	query := `SELECT id, phoneNumber FROM %s`
+	query = fmt.Sprintf(query, UserModel{}.TableName())
 
-		if isNonNullPointer(fieldValue) {
-			condition := fmt.Sprintf("%s = $%d", jsonTag, argIndex)
-			conditions = append(conditions, condition)
-			args = append(args, fieldValue.Elem().Interface())
-			argIndex++
-		}
+	rows, err := r.query(ctx, r.Db, query)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	if len(conditions) == 0 {
-		return "", args
+	phoneNumbers := make(map[int]string)
+	for rows.Next() {
+		var userID int
+		var phoneNumber string
+		if err := rows.Scan(&userID, &phoneNumber); err != nil {
+			return nil, err
+		}
+		phoneNumbers[userID] = phoneNumber
 	}
 
-	query := " WHERE " + strings.Join(conditions, " AND ")
-	return query, args
+	return phoneNumbers, rows.Err()
 }
 
-// isNonNullPointer checks if a reflect.Value is a non-nil pointer.
-func isNonNullPointer(value reflect.Value) bool {
-	return value.Kind() == reflect.Ptr && !value.IsNil()
+// UpdatePhoneNumber rewrites the stored phone number of a user without touching its verification
+func (r *Repository) UpdatePhoneNumber(ctx context.Context, userID int, phoneNumber string) error {
+	query := `
+		UPDATE %s
+		SET phoneNumber=$1, version=version + 1, updatedAt=$2
+		WHERE id=$3`
+	query = fmt.Sprintf(query, UserModel{}.TableName())
+	_, err := r.exec(ctx, r.Db, query, phoneNumber, time.Now(), userID)
+	return dbError(err)
+}
+
+// IncrementTokenVersion invalidates every token issued to the user so far and returns the new version.
+func (r *Repository) IncrementTokenVersion(ctx context.Context, userID int) (int, error) {
+	query := `
+		UPDATE %s
+		SET tokenVersion=tokenVersion + 1, updatedAt=$1
+		WHERE id=$2
+		RETURNING tokenVersion`
+	query = fmt.Sprintf(query, UserModel{}.TableName())
+
+	var tokenVersion int
+	if err := r.queryRow(ctx, r.Db, query, time.Now(), userID).Scan(&tokenVersion); err != nil {
+		return 0, dbError(err)
+	}
+
+	return tokenVersion, nil
 }
 
 type NewRepositoryOptions struct {
+	// Dsn is a Postgres DSN, or sqlite:<path> for a SQLite file
 	Dsn string
 }
 
 func NewRepository(opts NewRepositoryOptions) *Repository {
-	db, err := sql.Open("postgres", opts.Dsn)
+	dialect, driverName, dsn := ParseDsn(opts.Dsn)
+	db, err := sql.Open(driverName, dsn)
 	if err != nil {
 		panic(err)
 	}
+	if dialect == DialectSQLite {
+		// SQLite takes one writer at a time, queuing in the pool avoids failing on a busy database
+		db.SetMaxOpenConns(1)
+	}
 	return &Repository{
-		Db: db,
+		Db:      db,
+		Dialect: dialect,
 	}
 }
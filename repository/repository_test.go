@@ -0,0 +1,36 @@
+package repository_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/migrations"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/repository/repositorytest"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRepository runs the conformance suite against the Postgres database of TEST_DATABASE_URL,
+// every table of it is emptied
+func TestRepository(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" || testing.Short() {
+		t.Skip("set TEST_DATABASE_URL to run against Postgres")
+	}
+
+	repo := repository.NewRepository(repository.NewRepositoryOptions{Dsn: dsn})
+	defer repo.Db.Close()
+
+	migrator, err := migrations.NewMigrator(migrations.MigratorOptions{Db: repo.Db})
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+
+	repositorytest.Run(t, func(t *testing.T) repository.RepositoryInterface {
+		// every other table references users
+		_, err := repo.Db.Exec(`TRUNCATE users RESTART IDENTITY CASCADE`)
+		require.NoError(t, err)
+		return repo
+	})
+}
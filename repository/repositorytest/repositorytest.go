@@ -0,0 +1,594 @@
+// repositorytest package contains the conformance suite every implementation of
+// repository.RepositoryInterface has to pass, so that the handlers behave the same on any of them.
+package repositorytest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/apperrors"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run runs the suite, newRepository is called once per test and has to return an empty repository
+func Run(t *testing.T, newRepository func(t *testing.T) repository.RepositoryInterface) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo repository.RepositoryInterface)
+	}{
+		{"Create And Get User", testCreateAndGetUser},
+		{"Unique Phone Number And Email", testUniqueUser},
+		{"Update User", testUpdateUser},
+		{"List Users", testListUsers},
+		{"Phone Numbers", testPhoneNumbers},
+		{"Verify Email", testVerifyEmail},
+		{"Passwords", testPasswords},
+		{"Token Version", testTokenVersion},
+		{"Refresh Tokens", testRefreshTokens},
+		{"Revoked Tokens", testRevokedTokens},
+		{"Password Reset Codes", testPasswordResetCodes},
+		{"Mfa", testMfa},
+		{"Mfa Challenges", testMfaChallenges},
+		{"Otp Codes", testOtpCodes},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepository(t))
+		})
+	}
+}
+
+// hashOf returns a SHA-256 hex digest like the ones stored in the CHAR(64) columns, shorter
+// values would come back from Postgres padded with spaces
+func hashOf(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// familyOf returns a refresh token family ID, CHAR(32) like a generated one
+func familyOf(value string) string {
+	return hashOf(value)[:32]
+}
+
+// tolerance absorbs the clock of the database and its timestamp precision
+const tolerance = 5 * time.Second
+
+func createUser(t *testing.T, repo repository.RepositoryInterface, phoneNumber string, email *string) int {
+	id, err := repo.CreateUser(context.Background(), repository.UserInput{
+		PhoneNumber:   phoneNumber,
+		FullName:      "Budi " + phoneNumber,
+		Password:      "$argon2id$hash",
+		SaltKey:       "6a1f3c9e-7d8b-4b4a-9c51-0e5d2f3a8b7c",
+		PepperVersion: 1,
+		Email:         email,
+	})
+	require.NoError(t, err)
+	return id
+}
+
+func getUser(t *testing.T, repo repository.RepositoryInterface, id int) *repository.UserModel {
+	user, err := repo.GetUser(context.Background(), repository.GetUserInput{ID: &id})
+	require.NoError(t, err)
+	return user
+}
+
+func testCreateAndGetUser(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	email := "budi@example.com"
+	id := createUser(t, repo, "+628222667727", &email)
+	createUser(t, repo, "+628222667728", nil)
+
+	user := getUser(t, repo, id)
+	assert.Equal(t, id, user.ID)
+	assert.Equal(t, "+628222667727", user.PhoneNumber)
+	assert.Equal(t, "Budi +628222667727", user.FullName)
+	assert.Equal(t, "$argon2id$hash", user.Password)
+	assert.Equal(t, 1, user.PepperVersion)
+	assert.Equal(t, "user", user.Role)
+	assert.Equal(t, 0, user.TokenVersion)
+	assert.Equal(t, 1, user.Version)
+	assert.Nil(t, user.PhoneVerifiedAt)
+	assert.Nil(t, user.EmailVerifiedAt)
+	if assert.NotNil(t, user.Email) {
+		assert.Equal(t, email, *user.Email)
+	}
+	assert.WithinDuration(t, time.Now(), user.CreatedAt, tolerance)
+	assert.WithinDuration(t, user.CreatedAt, user.UpdatedAt, time.Second)
+
+	phoneNumber := "+628222667727"
+	byPhone, err := repo.GetUser(ctx, repository.GetUserInput{PhoneNumber: &phoneNumber})
+	require.NoError(t, err)
+	assert.Equal(t, id, byPhone.ID)
+
+	byEmail, err := repo.GetUser(ctx, repository.GetUserInput{Email: &email})
+	require.NoError(t, err)
+	assert.Equal(t, id, byEmail.ID)
+
+	byBoth, err := repo.GetUser(ctx, repository.GetUserInput{ID: &id, PhoneNumber: &phoneNumber})
+	require.NoError(t, err)
+	assert.Equal(t, id, byBoth.ID)
+
+	otherPhoneNumber := "+628222667728"
+	_, err = repo.GetUser(ctx, repository.GetUserInput{ID: &id, PhoneNumber: &otherPhoneNumber})
+	assert.ErrorIs(t, err, apperrors.ErrNotFound, "every given field has to match")
+
+	missing := id + 100
+	_, err = repo.GetUser(ctx, repository.GetUserInput{ID: &missing})
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+}
+
+func testUniqueUser(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	email := "budi@example.com"
+	createUser(t, repo, "+628222667727", &email)
+
+	_, err := repo.CreateUser(ctx, repository.UserInput{PhoneNumber: "+628222667727", FullName: "Other", Password: "x", SaltKey: "x"})
+	assert.ErrorIs(t, err, repository.ErrUserExists)
+	assert.ErrorIs(t, err, apperrors.ErrConflict)
+
+	_, err = repo.CreateUser(ctx, repository.UserInput{PhoneNumber: "+628222667728", FullName: "Other", Password: "x", SaltKey: "x", Email: &email})
+	assert.ErrorIs(t, err, repository.ErrEmailExists)
+
+	// users without an email do not clash
+	createUser(t, repo, "+628222667729", nil)
+	createUser(t, repo, "+628222667730", nil)
+}
+
+func testUpdateUser(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	email := "budi@example.com"
+	id := createUser(t, repo, "+628222667727", &email)
+	createUser(t, repo, "+628222667728", nil)
+	require.NoError(t, repo.VerifyEmail(ctx, id, email))
+	verified := getUser(t, repo, id)
+
+	fullName := "Budi Santoso"
+	version, err := repo.UpdateUser(ctx, repository.UpdateUserInput{ID: id, Version: verified.Version, FullName: &fullName})
+	require.NoError(t, err)
+	assert.Equal(t, verified.Version+1, version)
+
+	updated := getUser(t, repo, id)
+	assert.Equal(t, fullName, updated.FullName)
+	assert.Equal(t, version, updated.Version)
+	assert.NotNil(t, updated.EmailVerifiedAt, "the email was not changed")
+	assert.False(t, updated.UpdatedAt.Before(verified.UpdatedAt))
+
+	_, err = repo.UpdateUser(ctx, repository.UpdateUserInput{ID: id, Version: verified.Version, FullName: &fullName})
+	assert.ErrorIs(t, err, repository.ErrVersionConflict, "a stale version writes nothing")
+
+	_, err = repo.UpdateUser(ctx, repository.UpdateUserInput{ID: id + 100, Version: 1, FullName: &fullName})
+	assert.ErrorIs(t, err, repository.ErrVersionConflict)
+
+	taken := "+628222667728"
+	_, err = repo.UpdateUser(ctx, repository.UpdateUserInput{ID: id, Version: version, PhoneNumber: &taken})
+	assert.ErrorIs(t, err, repository.ErrUserExists)
+	assert.Equal(t, "+628222667727", getUser(t, repo, id).PhoneNumber)
+
+	newEmail := "santoso@example.com"
+	version, err = repo.UpdateUser(ctx, repository.UpdateUserInput{ID: id, Version: version, Email: &newEmail})
+	require.NoError(t, err)
+	updated = getUser(t, repo, id)
+	if assert.NotNil(t, updated.Email) {
+		assert.Equal(t, newEmail, *updated.Email)
+	}
+	assert.Nil(t, updated.EmailVerifiedAt, "a new email is unverified")
+
+	_, err = repo.UpdateUser(ctx, repository.UpdateUserInput{ID: id, Version: version, RemoveEmail: true})
+	require.NoError(t, err)
+	assert.Nil(t, getUser(t, repo, id).Email)
+}
+
+func testListUsers(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	email := "dani@example.com"
+	var ids []int
+	for i, fullName := range []string{"Dani", "Budi", "Ani", "Budi"} {
+		input := repository.UserInput{PhoneNumber: fmt.Sprintf("+62811000000%d", i), FullName: fullName, Password: "x", SaltKey: "x"}
+		if fullName == "Dani" {
+			input.Email = &email
+		}
+		id, err := repo.CreateUser(ctx, input)
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	otherID, err := repo.CreateUser(ctx, repository.UserInput{PhoneNumber: "+6590001111", FullName: "Other", Password: "x", SaltKey: "x"})
+	require.NoError(t, err)
+
+	idsOf := func(filter *repository.UserFilter) []int {
+		users, err := repo.ListUsers(ctx, filter)
+		require.NoError(t, err)
+		found := []int{}
+		for _, user := range users {
+			found = append(found, user.ID)
+		}
+		return found
+	}
+
+	assert.Equal(t, append(ids, otherID), idsOf(repository.NewUserFilter()), "ordered by ID by default")
+	assert.Equal(t, ids, idsOf(repository.NewUserFilter().HasPrefix(repository.UserColumnPhoneNumber, "+62811")))
+	assert.Equal(t, []int{ids[0], ids[2]}, idsOf(repository.NewUserFilter().Contains(repository.UserColumnFullName, "aN")), "search ignores case")
+	assert.Empty(t, idsOf(repository.NewUserFilter().Contains(repository.UserColumnFullName, "%")), "wildcards are matched literally")
+	assert.Equal(t, []int{ids[0]}, idsOf(repository.NewUserFilter().Equal(repository.UserColumnEmail, email)))
+	assert.Equal(t, []int{ids[1], ids[3]}, idsOf(repository.NewUserFilter().In(repository.UserColumnFullName, "Budi", "Nobody")))
+	assert.Empty(t, idsOf(repository.NewUserFilter().In(repository.UserColumnRole, "admin")))
+	assert.Empty(t, idsOf(repository.NewUserFilter().In(repository.UserColumnRole)))
+
+	require.NoError(t, repo.VerifyPhoneNumber(ctx, ids[1], "+628110000001"))
+	assert.Equal(t, []int{ids[1]}, idsOf(repository.NewUserFilter().IsNotNull(repository.UserColumnPhoneVerifiedAt)))
+	assert.Equal(t, []int{ids[0], ids[2], ids[3], otherID}, idsOf(repository.NewUserFilter().IsNull(repository.UserColumnPhoneVerifiedAt)))
+
+	count, err := repo.CountUsers(ctx, repository.NewUserFilter().HasPrefix(repository.UserColumnPhoneNumber, "+62811").Limit(1).After(repository.UserCursor{ID: ids[3]}))
+	require.NoError(t, err)
+	assert.Equal(t, 4, count, "the count ignores the cursor and limit")
+
+	now := time.Now()
+	assert.Len(t, idsOf(repository.NewUserFilter().From(repository.UserColumnCreatedAt, now.Add(-time.Hour)).Before(repository.UserColumnCreatedAt, now.Add(time.Hour))), 5)
+	assert.Empty(t, idsOf(repository.NewUserFilter().From(repository.UserColumnUpdatedAt, now.Add(time.Hour))))
+
+	t.Run("Ordered", func(t *testing.T) {
+		byName := repository.NewUserFilter().HasPrefix(repository.UserColumnPhoneNumber, "+62811").OrderBy(repository.UserColumnFullName, repository.Ascending)
+		assert.Equal(t, []int{ids[2], ids[1], ids[3], ids[0]}, idsOf(byName), "ties are ordered by ID")
+
+		byNameDesc := repository.NewUserFilter().HasPrefix(repository.UserColumnPhoneNumber, "+62811").OrderBy(repository.UserColumnFullName, repository.Descending)
+		assert.Equal(t, []int{ids[0], ids[3], ids[1], ids[2]}, idsOf(byNameDesc))
+	})
+
+	t.Run("Pages", func(t *testing.T) {
+		for _, column := range []repository.UserColumn{repository.UserColumnID, repository.UserColumnFullName, repository.UserColumnCreatedAt} {
+			for _, direction := range []repository.SortDirection{repository.Ascending, repository.Descending} {
+				newFilter := func() *repository.UserFilter {
+					return repository.NewUserFilter().OrderBy(column, direction)
+				}
+				all := idsOf(newFilter())
+
+				var paged []int
+				page := newFilter().Limit(2)
+				for {
+					users, err := repo.ListUsers(ctx, page)
+					require.NoError(t, err)
+					for _, user := range users {
+						paged = append(paged, user.ID)
+					}
+					if len(users) < 2 {
+						break
+					}
+					page = newFilter().Limit(2).After(page.CursorOf(users[len(users)-1]))
+				}
+				assert.Equal(t, all, paged, "pages of %s %s neither skip nor repeat users", column, direction)
+			}
+		}
+	})
+
+	t.Run("Allowlist", func(t *testing.T) {
+		invalid := map[string]*repository.UserFilter{
+			"Unknown Column":    repository.NewUserFilter().Equal("password", "x"),
+			"Injected Column":   repository.NewUserFilter().Equal("id = id OR 1", 1),
+			"Not Sortable":      repository.NewUserFilter().OrderBy(repository.UserColumnEmail, repository.Ascending),
+			"Direction":         repository.NewUserFilter().OrderBy(repository.UserColumnID, "asc; DROP TABLE users"),
+			"Value Type":        repository.NewUserFilter().Equal(repository.UserColumnID, "1"),
+			"Range On Text":     repository.NewUserFilter().From(repository.UserColumnFullName, now),
+			"Search On Number":  repository.NewUserFilter().HasPrefix(repository.UserColumnID, "1"),
+			"Null On Not Null":  repository.NewUserFilter().IsNull(repository.UserColumnFullName),
+			"Cursor Value Type": repository.NewUserFilter().OrderBy(repository.UserColumnCreatedAt, repository.Ascending).After(repository.UserCursor{Value: "x", ID: 1}),
+		}
+		for name, filter := range invalid {
+			_, err := repo.ListUsers(ctx, filter)
+			assert.ErrorIs(t, err, apperrors.ErrValidation, name)
+			_, err = repo.CountUsers(ctx, filter)
+			assert.ErrorIs(t, err, apperrors.ErrValidation, name)
+		}
+	})
+}
+
+func testPhoneNumbers(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	id := createUser(t, repo, "08222667727", nil)
+	otherID := createUser(t, repo, "+628222667728", nil)
+
+	require.NoError(t, repo.UpdatePhoneNumber(ctx, id, "+628222667727"))
+	user := getUser(t, repo, id)
+	assert.Equal(t, "+628222667727", user.PhoneNumber)
+	assert.Nil(t, user.PhoneVerifiedAt)
+	assert.Equal(t, 2, user.Version)
+
+	assert.ErrorIs(t, repo.UpdatePhoneNumber(ctx, otherID, "+628222667727"), repository.ErrUserExists)
+
+	require.NoError(t, repo.VerifyPhoneNumber(ctx, id, "+628222667729"))
+	user = getUser(t, repo, id)
+	assert.Equal(t, "+628222667729", user.PhoneNumber)
+	if assert.NotNil(t, user.PhoneVerifiedAt) {
+		assert.WithinDuration(t, time.Now(), *user.PhoneVerifiedAt, tolerance)
+	}
+	assert.Equal(t, 3, user.Version)
+
+	assert.ErrorIs(t, repo.VerifyPhoneNumber(ctx, otherID, "+628222667729"), repository.ErrUserExists)
+
+	phoneNumbers, err := repo.ListPhoneNumbers(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{id: "+628222667729", otherID: "+628222667728"}, phoneNumbers)
+}
+
+func testVerifyEmail(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	email := "budi@example.com"
+	id := createUser(t, repo, "+628222667727", &email)
+
+	assert.ErrorIs(t, repo.VerifyEmail(ctx, id, "other@example.com"), apperrors.ErrNotFound, "the user no longer has that email")
+	assert.Nil(t, getUser(t, repo, id).EmailVerifiedAt)
+
+	require.NoError(t, repo.VerifyEmail(ctx, id, email))
+	user := getUser(t, repo, id)
+	if assert.NotNil(t, user.EmailVerifiedAt) {
+		assert.WithinDuration(t, time.Now(), *user.EmailVerifiedAt, tolerance)
+	}
+	assert.Equal(t, 2, user.Version)
+}
+
+func testPasswords(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	id := createUser(t, repo, "+628222667727", nil)
+
+	require.NoError(t, repo.UpdatePassword(ctx, repository.UserInput{ID: id, Password: "$argon2id$new", SaltKey: "0b9d2c4e-1f3a-4c5b-8d7e-6f9a0b1c2d3e", PepperVersion: 2}))
+	user := getUser(t, repo, id)
+	assert.Equal(t, "$argon2id$new", user.Password)
+	assert.Equal(t, "0b9d2c4e-1f3a-4c5b-8d7e-6f9a0b1c2d3e", user.SaltKey)
+	assert.Equal(t, 2, user.PepperVersion)
+
+	// the hash being replaced moves into the history, only the most recent entries are kept
+	for i, password := range []string{"first", "second", "third"} {
+		require.NoError(t, repo.ReplacePassword(ctx, repository.UserInput{ID: id, Password: password, SaltKey: "salt", PepperVersion: i}, 2))
+	}
+	user = getUser(t, repo, id)
+	assert.Equal(t, "third", user.Password)
+	assert.Equal(t, 2, user.PepperVersion)
+
+	history, err := repo.GetPasswordHistory(ctx, id, 10)
+	require.NoError(t, err)
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, "second", history[0].Password, "newest first")
+		assert.Equal(t, "first", history[1].Password)
+		assert.Equal(t, 1, history[0].PepperVersion)
+	}
+
+	history, err = repo.GetPasswordHistory(ctx, id, 1)
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	// without a history only the password changes
+	require.NoError(t, repo.ReplacePassword(ctx, repository.UserInput{ID: id, Password: "fourth", SaltKey: "salt", PepperVersion: 2}, 0))
+	assert.Equal(t, "fourth", getUser(t, repo, id).Password)
+	history, err = repo.GetPasswordHistory(ctx, id, 10)
+	require.NoError(t, err)
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, "second", history[0].Password)
+	}
+}
+
+func testTokenVersion(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	id := createUser(t, repo, "+628222667727", nil)
+
+	version, err := repo.IncrementTokenVersion(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+	version, err = repo.IncrementTokenVersion(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, 2, version)
+	assert.Equal(t, 2, getUser(t, repo, id).TokenVersion)
+	assert.Equal(t, 1, getUser(t, repo, id).Version, "logging out everywhere is no profile change")
+
+	_, err = repo.IncrementTokenVersion(ctx, id+100)
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+}
+
+func testRefreshTokens(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	id := createUser(t, repo, "+628222667727", nil)
+	expiresAt := time.Now().Add(time.Hour)
+
+	create := func(tokenHash, familyID string) int {
+		tokenID, err := repo.CreateRefreshToken(ctx, repository.RefreshTokenInput{UserID: id, TokenHash: tokenHash, FamilyID: familyID, ExpiresAt: expiresAt})
+		require.NoError(t, err)
+		return tokenID
+	}
+	first := create(hashOf("hash-1"), familyOf("family-a"))
+	create(hashOf("hash-2"), familyOf("family-a"))
+	create(hashOf("hash-3"), familyOf("family-b"))
+	create(hashOf("hash-4"), familyOf("family-c"))
+
+	token, err := repo.GetRefreshToken(ctx, hashOf("hash-1"))
+	require.NoError(t, err)
+	assert.Equal(t, first, token.ID)
+	assert.Equal(t, id, token.UserID)
+	assert.Equal(t, familyOf("family-a"), token.FamilyID)
+	assert.WithinDuration(t, expiresAt, token.ExpiresAt, time.Millisecond)
+	assert.Nil(t, token.RevokedAt)
+
+	_, err = repo.GetRefreshToken(ctx, hashOf("missing"))
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+
+	require.NoError(t, repo.RevokeRefreshToken(ctx, first))
+	assert.ErrorIs(t, repo.RevokeRefreshToken(ctx, first), apperrors.ErrNotFound, "a token is revoked once")
+
+	require.NoError(t, repo.RevokeRefreshTokenFamily(ctx, familyOf("family-a")))
+	token, err = repo.GetRefreshToken(ctx, hashOf("hash-2"))
+	require.NoError(t, err)
+	assert.NotNil(t, token.RevokedAt)
+	token, err = repo.GetRefreshToken(ctx, hashOf("hash-3"))
+	require.NoError(t, err)
+	assert.Nil(t, token.RevokedAt, "other families are left alone")
+
+	require.NoError(t, repo.RevokeUserRefreshTokens(ctx, id))
+	for _, tokenHash := range []string{hashOf("hash-3"), hashOf("hash-4")} {
+		token, err = repo.GetRefreshToken(ctx, tokenHash)
+		require.NoError(t, err)
+		assert.NotNil(t, token.RevokedAt, tokenHash)
+	}
+}
+
+func testRevokedTokens(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	id := createUser(t, repo, "+628222667727", nil)
+	now := time.Now()
+
+	require.NoError(t, repo.RevokeToken(ctx, repository.RevokedTokenInput{TokenID: "11111111-1111-1111-1111-111111111111", UserID: id, ExpiresAt: now.Add(-time.Minute)}))
+	require.NoError(t, repo.RevokeToken(ctx, repository.RevokedTokenInput{TokenID: "22222222-2222-2222-2222-222222222222", UserID: id, ExpiresAt: now.Add(time.Hour)}))
+	require.NoError(t, repo.RevokeToken(ctx, repository.RevokedTokenInput{TokenID: "22222222-2222-2222-2222-222222222222", UserID: id, ExpiresAt: now.Add(time.Hour)}), "revoking twice is no error")
+
+	revoked, err := repo.IsTokenRevoked(ctx, "11111111-1111-1111-1111-111111111111")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = repo.IsTokenRevoked(ctx, "33333333-3333-3333-3333-333333333333")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	require.NoError(t, repo.PurgeRevokedTokens(ctx, now))
+	revoked, err = repo.IsTokenRevoked(ctx, "11111111-1111-1111-1111-111111111111")
+	require.NoError(t, err)
+	assert.False(t, revoked, "expired entries are purged")
+	revoked, err = repo.IsTokenRevoked(ctx, "22222222-2222-2222-2222-222222222222")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func testPasswordResetCodes(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	id := createUser(t, repo, "+628222667727", nil)
+	expiresAt := time.Now().Add(15 * time.Minute)
+
+	_, err := repo.GetActivePasswordResetCode(ctx, id)
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+
+	first, err := repo.CreatePasswordResetCode(ctx, repository.PasswordResetCodeInput{UserID: id, CodeHash: hashOf("hash-1"), ExpiresAt: expiresAt})
+	require.NoError(t, err)
+	second, err := repo.CreatePasswordResetCode(ctx, repository.PasswordResetCodeInput{UserID: id, CodeHash: hashOf("hash-2"), ExpiresAt: expiresAt})
+	require.NoError(t, err)
+
+	code, err := repo.GetActivePasswordResetCode(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, second, code.ID, "a new code replaces the previous one")
+	assert.Equal(t, hashOf("hash-2"), code.CodeHash)
+	assert.Equal(t, 0, code.Attempts)
+	assert.ErrorIs(t, repo.UsePasswordResetCode(ctx, first), apperrors.ErrNotFound)
+
+	attempts, err := repo.IncrementPasswordResetAttempts(ctx, second)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempts)
+	attempts, err = repo.IncrementPasswordResetAttempts(ctx, second)
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	_, err = repo.IncrementPasswordResetAttempts(ctx, second+100)
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+
+	require.NoError(t, repo.UsePasswordResetCode(ctx, second))
+	assert.ErrorIs(t, repo.UsePasswordResetCode(ctx, second), apperrors.ErrNotFound, "a code is used once")
+	_, err = repo.GetActivePasswordResetCode(ctx, id)
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+}
+
+func testMfa(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	id := createUser(t, repo, "+628222667727", nil)
+
+	_, err := repo.GetMfa(ctx, id)
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+
+	require.NoError(t, repo.SaveMfaSecret(ctx, repository.MfaInput{UserID: id, Secret: "1:first"}))
+	require.NoError(t, repo.SaveMfaSecret(ctx, repository.MfaInput{UserID: id, Secret: "1:second"}), "an unconfirmed enrollment is replaced")
+	mfa, err := repo.GetMfa(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "1:second", mfa.Secret)
+	assert.False(t, mfa.Enabled())
+
+	assert.ErrorIs(t, repo.UseMfaStep(ctx, id, 100), apperrors.ErrNotFound, "MFA is not enabled yet")
+
+	require.NoError(t, repo.ConfirmMfa(ctx, id, 100, []string{hashOf("code-1"), hashOf("code-2")}))
+	mfa, err = repo.GetMfa(ctx, id)
+	require.NoError(t, err)
+	assert.True(t, mfa.Enabled())
+	assert.Equal(t, int64(100), mfa.LastUsedStep)
+	assert.ErrorIs(t, repo.ConfirmMfa(ctx, id, 101, nil), apperrors.ErrNotFound, "MFA is confirmed once")
+	assert.ErrorIs(t, repo.SaveMfaSecret(ctx, repository.MfaInput{UserID: id, Secret: "1:third"}), apperrors.ErrNotFound, "an enabled secret is never overwritten")
+
+	assert.ErrorIs(t, repo.UseMfaStep(ctx, id, 100), apperrors.ErrNotFound, "a step is used once")
+	require.NoError(t, repo.UseMfaStep(ctx, id, 102))
+	assert.ErrorIs(t, repo.UseMfaStep(ctx, id, 101), apperrors.ErrNotFound, "earlier steps are gone too")
+
+	require.NoError(t, repo.UseRecoveryCode(ctx, id, hashOf("code-1")))
+	assert.ErrorIs(t, repo.UseRecoveryCode(ctx, id, hashOf("code-1")), apperrors.ErrNotFound)
+	assert.ErrorIs(t, repo.UseRecoveryCode(ctx, id, hashOf("code-3")), apperrors.ErrNotFound)
+
+	require.NoError(t, repo.DeleteMfa(ctx, id))
+	_, err = repo.GetMfa(ctx, id)
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+	assert.ErrorIs(t, repo.UseRecoveryCode(ctx, id, hashOf("code-2")), apperrors.ErrNotFound, "recovery codes go with MFA")
+}
+
+func testMfaChallenges(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	id := createUser(t, repo, "+628222667727", nil)
+	expiresAt := time.Now().Add(5 * time.Minute)
+
+	challengeID, err := repo.CreateMfaChallenge(ctx, repository.MfaChallengeInput{UserID: id, TokenHash: hashOf("hash-1"), AccountKey: "phone:+628222667727", ExpiresAt: expiresAt})
+	require.NoError(t, err)
+
+	challenge, err := repo.GetMfaChallenge(ctx, hashOf("hash-1"))
+	require.NoError(t, err)
+	assert.Equal(t, challengeID, challenge.ID)
+	assert.Equal(t, id, challenge.UserID)
+	assert.Equal(t, "phone:+628222667727", challenge.AccountKey)
+	assert.WithinDuration(t, expiresAt, challenge.ExpiresAt, time.Millisecond)
+	assert.Nil(t, challenge.UsedAt)
+
+	_, err = repo.GetMfaChallenge(ctx, hashOf("missing"))
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+
+	attempts, err := repo.IncrementMfaChallengeAttempts(ctx, challengeID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempts)
+
+	require.NoError(t, repo.UseMfaChallenge(ctx, challengeID))
+	assert.ErrorIs(t, repo.UseMfaChallenge(ctx, challengeID), apperrors.ErrNotFound)
+	challenge, err = repo.GetMfaChallenge(ctx, hashOf("hash-1"))
+	require.NoError(t, err)
+	assert.NotNil(t, challenge.UsedAt)
+}
+
+func testOtpCodes(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	id := createUser(t, repo, "+628222667727", nil)
+	expiresAt := time.Now().Add(10 * time.Minute)
+
+	create := func(purpose, codeHash string) int {
+		codeID, err := repo.CreateOtpCode(ctx, repository.OtpCodeInput{UserID: id, Purpose: purpose, PhoneNumber: "+628222667727", CodeHash: codeHash, ExpiresAt: expiresAt})
+		require.NoError(t, err)
+		return codeID
+	}
+	create("login", hashOf("hash-1"))
+	verification := create("phone_verification", hashOf("hash-2"))
+	login := create("login", hashOf("hash-3"))
+
+	code, err := repo.GetActiveOtpCode(ctx, id, "login")
+	require.NoError(t, err)
+	assert.Equal(t, login, code.ID, "a new code replaces the previous one of its purpose")
+	assert.Equal(t, "+628222667727", code.PhoneNumber)
+
+	code, err = repo.GetActiveOtpCode(ctx, id, "phone_verification")
+	require.NoError(t, err)
+	assert.Equal(t, verification, code.ID, "codes of other purposes are left alone")
+
+	attempts, err := repo.IncrementOtpCodeAttempts(ctx, login)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempts)
+
+	require.NoError(t, repo.UseOtpCode(ctx, login))
+	assert.ErrorIs(t, repo.UseOtpCode(ctx, login), apperrors.ErrNotFound)
+	_, err = repo.GetActiveOtpCode(ctx, id, "login")
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+}
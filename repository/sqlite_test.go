@@ -0,0 +1,27 @@
+package repository_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/migrations"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/repository/repositorytest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteRepository(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.RepositoryInterface {
+		repo := repository.NewRepository(repository.NewRepositoryOptions{
+			Dsn: "sqlite://" + filepath.Join(t.TempDir(), "users.db"),
+		})
+		t.Cleanup(func() { repo.Db.Close() })
+
+		migrator, err := migrations.NewMigrator(migrations.MigratorOptions{Db: repo.Db, Dialect: repo.Dialect})
+		require.NoError(t, err)
+		_, err = migrator.Up(context.Background())
+		require.NoError(t, err)
+		return repo
+	})
+}
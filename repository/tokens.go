@@ -0,0 +1,133 @@
+// This file contains the repository implementation for refresh tokens and revoked access tokens.
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (r *Repository) CreateRefreshToken(ctx context.Context, input RefreshTokenInput) (int, error) {
+	query := fmt.Sprintf(`
+			INSERT INTO %s (userId, tokenHash, familyId, expiresAt)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id
+		`, RefreshTokenModel{}.TableName())
+
+	var tokenID int
+	if err := r.queryRow(ctx, r.Db, query, input.UserID, input.TokenHash, input.FamilyID, input.ExpiresAt).Scan(&tokenID); err != nil {
+		return 0, err
+	}
+
+	return tokenID, nil
+}
+
+func (r *Repository) GetRefreshToken(ctx context.Context, tokenHash string) (*RefreshTokenModel, error) {
+	model := &RefreshTokenModel{}
+
+	query := `
+        SELECT
+            id,
+            userId,
+            tokenHash,
+            familyId,
+            expiresAt,
+            revokedAt,
+            createdAt
+        FROM %s WHERE tokenHash = $1`
+
+	query = fmt.Sprintf(query, model.TableName())
+
+	err := r.queryRow(ctx, r.Db, query, tokenHash).Scan(
+		&model.ID,
+		&model.UserID,
+		&model.TokenHash,
+		&model.FamilyID,
+		&model.ExpiresAt,
+		&model.RevokedAt,
+		&model.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	return model, nil
+}
+
+// RevokeRefreshToken revokes a single token, it returns apperrors.ErrNotFound when the
+// token was already revoked so callers can detect a concurrent rotation.
+func (r *Repository) RevokeRefreshToken(ctx context.Context, id int) error {
+	query := `
+		UPDATE %s
+		SET revokedAt=$1
+		WHERE id=$2 AND revokedAt IS NULL`
+	query = fmt.Sprintf(query, RefreshTokenModel{}.TableName())
+
+	result, err := r.exec(ctx, r.Db, query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errNoData()
+	}
+
+	return nil
+}
+
+func (r *Repository) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	query := `
+		UPDATE %s
+		SET revokedAt=$1
+		WHERE familyId=$2 AND revokedAt IS NULL`
+	query = fmt.Sprintf(query, RefreshTokenModel{}.TableName())
+
+	_, err := r.exec(ctx, r.Db, query, time.Now(), familyID)
+	return err
+}
+
+func (r *Repository) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	query := `
+		UPDATE %s
+		SET revokedAt=$1
+		WHERE userId=$2 AND revokedAt IS NULL`
+	query = fmt.Sprintf(query, RefreshTokenModel{}.TableName())
+
+	_, err := r.exec(ctx, r.Db, query, time.Now(), userID)
+	return err
+}
+
+func (r *Repository) RevokeToken(ctx context.Context, input RevokedTokenInput) error {
+	query := fmt.Sprintf(`
+			INSERT INTO %s (jti, userId, expiresAt)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (jti) DO NOTHING
+		`, RevokedTokenModel{}.TableName())
+
+	_, err := r.exec(ctx, r.Db, query, input.TokenID, input.UserID, input.ExpiresAt)
+	return err
+}
+
+func (r *Repository) IsTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE jti = $1)`, RevokedTokenModel{}.TableName())
+
+	var revoked bool
+	if err := r.queryRow(ctx, r.Db, query, tokenID).Scan(&revoked); err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+// PurgeRevokedTokens removes entries whose token would be rejected as expired anyway.
+func (r *Repository) PurgeRevokedTokens(ctx context.Context, before time.Time) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE expiresAt < $1`, RevokedTokenModel{}.TableName())
+
+	_, err := r.exec(ctx, r.Db, query, before)
+	return err
+}
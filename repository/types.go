@@ -21,6 +21,23 @@ type UserInput struct {
 	FullName    string `json:"fullName"`
 	Password    string `json:"password"`
 	SaltKey     string `json:"saltKey"`
+	// PepperVersion identifies the pepper Password was hashed with
+	PepperVersion int `json:"pepperVersion"`
+	// Email is optional, lower case
+	Email *string `json:"email"`
+}
+
+// UpdateUserInput names the user to update by ID, only its non-nil fields are written
+type UpdateUserInput struct {
+	ID int `json:"id"`
+	// Version is the version the user must still have for the update to happen
+	Version     int     `json:"version"`
+	PhoneNumber *string `json:"phoneNumber"`
+	FullName    *string `json:"fullName"`
+	// Email replaces the email of the user, unverified
+	Email *string `json:"email"`
+	// RemoveEmail deletes the email of the user, Email must be nil
+	RemoveEmail bool `json:"-"`
 }
 
 // GetUserInput ...
@@ -28,20 +45,227 @@ type GetUserInput struct {
 	ID          *int    `json:"id"`
 	PhoneNumber *string `json:"phoneNumber"`
 	FullName    *string `json:"fullName"`
+	Email       *string `json:"email"`
+}
+
+// filter returns the filter of the users matching every non-nil field of input
+func (input GetUserInput) filter() *UserFilter {
+	filter := NewUserFilter()
+	if input.ID != nil {
+		filter.Equal(UserColumnID, *input.ID)
+	}
+	if input.PhoneNumber != nil {
+		filter.Equal(UserColumnPhoneNumber, *input.PhoneNumber)
+	}
+	if input.FullName != nil {
+		filter.Equal(UserColumnFullName, *input.FullName)
+	}
+	if input.Email != nil {
+		filter.Equal(UserColumnEmail, *input.Email)
+	}
+	return filter
 }
 
 // UserModel ...
 type UserModel struct {
-	ID          int       `json:"id"`
-	PhoneNumber string    `json:"phoneNumber"`
-	FullName    string    `json:"fullName"`
-	Password    string    `json:"password"`
-	SaltKey     string    `json:"saltKey"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID            int    `json:"id"`
+	PhoneNumber   string `json:"phoneNumber"`
+	FullName      string `json:"fullName"`
+	Password      string `json:"password"`
+	SaltKey       string `json:"saltKey"`
+	PepperVersion int    `json:"pepperVersion"`
+	Role          string `json:"role"`
+	TokenVersion  int    `json:"tokenVersion"`
+	// PhoneVerifiedAt is set once the user proved they own PhoneNumber
+	PhoneVerifiedAt *time.Time `json:"phoneVerifiedAt"`
+	Email           *string    `json:"email"`
+	// EmailVerifiedAt is set once the user proved they own Email, unverified emails identify nobody
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt"`
+	// Version is incremented by every change to the profile
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
 }
 
 // TableName ...
 func (UserModel) TableName() string {
 	return "users"
 }
+
+// RefreshTokenInput ...
+type RefreshTokenInput struct {
+	UserID    int       `json:"userId"`
+	TokenHash string    `json:"tokenHash"`
+	FamilyID  string    `json:"familyId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// RefreshTokenModel ...
+type RefreshTokenModel struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"userId"`
+	TokenHash string     `json:"tokenHash"`
+	FamilyID  string     `json:"familyId"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	RevokedAt *time.Time `json:"revokedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// TableName ...
+func (RefreshTokenModel) TableName() string {
+	return "refresh_tokens"
+}
+
+// RevokedTokenInput ...
+type RevokedTokenInput struct {
+	TokenID   string    `json:"jti"`
+	UserID    int       `json:"userId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// RevokedTokenModel ...
+type RevokedTokenModel struct {
+	TokenID   string    `json:"jti"`
+	UserID    int       `json:"userId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TableName ...
+func (RevokedTokenModel) TableName() string {
+	return "revoked_tokens"
+}
+
+// PasswordResetCodeInput ...
+type PasswordResetCodeInput struct {
+	UserID    int       `json:"userId"`
+	CodeHash  string    `json:"codeHash"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// PasswordResetCodeModel ...
+type PasswordResetCodeModel struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"userId"`
+	CodeHash  string     `json:"codeHash"`
+	Attempts  int        `json:"attempts"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// TableName ...
+func (PasswordResetCodeModel) TableName() string {
+	return "password_reset_codes"
+}
+
+// PasswordHistoryModel ...
+type PasswordHistoryModel struct {
+	ID            int       `json:"id"`
+	UserID        int       `json:"userId"`
+	Password      string    `json:"password"`
+	SaltKey       string    `json:"saltKey"`
+	PepperVersion int       `json:"pepperVersion"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// TableName ...
+func (PasswordHistoryModel) TableName() string {
+	return "password_history"
+}
+
+// MfaInput ...
+type MfaInput struct {
+	UserID int `json:"userId"`
+	// Secret is the encrypted TOTP secret
+	Secret string `json:"secret"`
+}
+
+// MfaModel ...
+type MfaModel struct {
+	UserID int    `json:"userId"`
+	Secret string `json:"secret"`
+	// ConfirmedAt is set once the first code was confirmed, MFA is enabled from then on
+	ConfirmedAt *time.Time `json:"confirmedAt"`
+	// LastUsedStep is the TOTP time step of the last accepted code
+	LastUsedStep int64     `json:"lastUsedStep"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// TableName ...
+func (MfaModel) TableName() string {
+	return "user_mfa"
+}
+
+// Enabled ...
+func (m *MfaModel) Enabled() bool {
+	return m.ConfirmedAt != nil
+}
+
+// RecoveryCodeModel ...
+type RecoveryCodeModel struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"userId"`
+	CodeHash  string     `json:"codeHash"`
+	UsedAt    *time.Time `json:"usedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// TableName ...
+func (RecoveryCodeModel) TableName() string {
+	return "mfa_recovery_codes"
+}
+
+// MfaChallengeInput ...
+type MfaChallengeInput struct {
+	UserID    int    `json:"userId"`
+	TokenHash string `json:"tokenHash"`
+	// AccountKey is the login throttle key of the login that started the challenge
+	AccountKey string    `json:"accountKey"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// MfaChallengeModel ...
+type MfaChallengeModel struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"userId"`
+	TokenHash  string     `json:"tokenHash"`
+	AccountKey string     `json:"accountKey"`
+	Attempts   int        `json:"attempts"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	UsedAt     *time.Time `json:"usedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// TableName ...
+func (MfaChallengeModel) TableName() string {
+	return "mfa_challenges"
+}
+
+// OtpCodeInput ...
+type OtpCodeInput struct {
+	UserID  int    `json:"userId"`
+	Purpose string `json:"purpose"`
+	// PhoneNumber is where the code was sent, it is not always the current phone number of the user
+	PhoneNumber string    `json:"phoneNumber"`
+	CodeHash    string    `json:"codeHash"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+// OtpCodeModel ...
+type OtpCodeModel struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"userId"`
+	Purpose     string     `json:"purpose"`
+	PhoneNumber string     `json:"phoneNumber"`
+	CodeHash    string     `json:"codeHash"`
+	Attempts    int        `json:"attempts"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	UsedAt      *time.Time `json:"usedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// TableName ...
+func (OtpCodeModel) TableName() string {
+	return "otp_codes"
+}
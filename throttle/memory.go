@@ -0,0 +1,82 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryAttemptStore keeps records in process, counters are not shared between instances
+type MemoryAttemptStore struct {
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+// NewMemoryAttemptStore ...
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{records: map[string]*Record{}}
+}
+
+// Get ...
+func (s *MemoryAttemptStore) Get(_ context.Context, key string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, found := s.records[key]
+	if !found {
+		return nil, nil
+	}
+	copied := *record
+	return &copied, nil
+}
+
+// RecordFailure ...
+func (s *MemoryAttemptStore) RecordFailure(_ context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, found := s.records[key]
+	if !found || (window > 0 && record.UpdatedAt.Before(now.Add(-window))) {
+		record = &Record{Key: key}
+		s.records[key] = record
+	}
+	record.Failures++
+	record.UpdatedAt = now
+	return record.Failures, nil
+}
+
+// Block ...
+func (s *MemoryAttemptStore) Block(_ context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, found := s.records[key]
+	if !found {
+		record = &Record{Key: key, UpdatedAt: time.Now()}
+		s.records[key] = record
+	}
+	record.BlockedUntil = until
+	return nil
+}
+
+// Reset ...
+func (s *MemoryAttemptStore) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+// Purge ...
+func (s *MemoryAttemptStore) Purge(_ context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, record := range s.records {
+		if record.UpdatedAt.Before(before) && record.BlockedUntil.Before(before) {
+			delete(s.records, key)
+		}
+	}
+	return nil
+}
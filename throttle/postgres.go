@@ -0,0 +1,90 @@
+package throttle
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// loginAttemptsTable is created by migration 0006_create_login_attempts
+const loginAttemptsTable = "login_attempts"
+
+// PostgresAttemptStore keeps records in the login_attempts table so that every instance sees them
+type PostgresAttemptStore struct {
+	Db *sql.DB
+}
+
+// NewPostgresAttemptStore ...
+func NewPostgresAttemptStore(db *sql.DB) *PostgresAttemptStore {
+	return &PostgresAttemptStore{Db: db}
+}
+
+// Get ...
+func (s *PostgresAttemptStore) Get(ctx context.Context, key string) (*Record, error) {
+	query := fmt.Sprintf(`SELECT key, failures, blockedUntil, updatedAt FROM %s WHERE key = $1`, loginAttemptsTable)
+
+	record := &Record{}
+	var blockedUntil sql.NullTime
+	err := s.Db.QueryRowContext(ctx, query, key).Scan(&record.Key, &record.Failures, &blockedUntil, &record.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	record.BlockedUntil = blockedUntil.Time
+	return record, nil
+}
+
+// RecordFailure ...
+func (s *PostgresAttemptStore) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	query := fmt.Sprintf(`
+		INSERT INTO %[1]s (key, failures, updatedAt)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE
+		SET failures = CASE WHEN %[1]s.updatedAt < $3 THEN 1 ELSE %[1]s.failures + 1 END,
+		    updatedAt = $2
+		RETURNING failures`, loginAttemptsTable)
+
+	forgetBefore := time.Time{}
+	if window > 0 {
+		forgetBefore = now.Add(-window)
+	}
+
+	var failures int
+	if err := s.Db.QueryRowContext(ctx, query, key, now, forgetBefore).Scan(&failures); err != nil {
+		return 0, err
+	}
+	return failures, nil
+}
+
+// Block ...
+func (s *PostgresAttemptStore) Block(ctx context.Context, key string, until time.Time) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %[1]s (key, failures, blockedUntil, updatedAt)
+		VALUES ($1, 0, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET blockedUntil = $2`, loginAttemptsTable)
+
+	_, err := s.Db.ExecContext(ctx, query, key, until, time.Now())
+	return err
+}
+
+// Reset ...
+func (s *PostgresAttemptStore) Reset(ctx context.Context, key string) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE key = $1`, loginAttemptsTable)
+
+	_, err := s.Db.ExecContext(ctx, query, key)
+	return err
+}
+
+// Purge ...
+func (s *PostgresAttemptStore) Purge(ctx context.Context, before time.Time) error {
+	query := fmt.Sprintf(`
+		DELETE FROM %s
+		WHERE updatedAt < $1 AND (blockedUntil IS NULL OR blockedUntil < $1)`, loginAttemptsTable)
+
+	_, err := s.Db.ExecContext(ctx, query, before)
+	return err
+}
@@ -0,0 +1,124 @@
+// throttle package slows down and locks out repeated failures, such as password guesses,
+// per key. Keys are free form, e.g. "phone:+62812..." or "ip:10.0.0.1".
+package throttle
+
+import (
+	"context"
+	"time"
+)
+
+// Record is what a store keeps per key
+type Record struct {
+	Key          string
+	Failures     int
+	BlockedUntil time.Time
+	UpdatedAt    time.Time
+}
+
+// AttemptStoreInterface keeps failure counters, it is shared by every instance of the service
+// when backed by Postgres
+type AttemptStoreInterface interface {
+	// Get returns nil when nothing is recorded for key
+	Get(ctx context.Context, key string) (*Record, error)
+	// RecordFailure adds a failure and returns the failures so far, failures
+	// older than window are forgotten first
+	RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (int, error)
+	Block(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+	// Purge drops records last updated before the given time
+	Purge(ctx context.Context, before time.Time) error
+}
+
+// Policy decides how long a key is blocked after a number of failures
+type Policy struct {
+	// FreeAttempts is the number of failures that are not delayed
+	FreeAttempts int
+	// BaseDelay is the delay after the first failure past FreeAttempts, it doubles with every further failure
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutThreshold is the number of failures after which the key is locked for LockoutDuration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// Window is how long failures are remembered
+	Window time.Duration
+}
+
+// Delay returns how long to block a key with the given number of failures
+func (p Policy) Delay(failures int) time.Duration {
+	if p.LockoutThreshold > 0 && failures >= p.LockoutThreshold {
+		return p.LockoutDuration
+	}
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// ThrottlerInterface ...
+type ThrottlerInterface interface {
+	// Check returns how long key is still blocked for, zero when it is not
+	Check(ctx context.Context, key string) (time.Duration, error)
+	// Fail records a failure and returns how long key is now blocked for
+	Fail(ctx context.Context, key string) (time.Duration, error)
+	Reset(ctx context.Context, key string) error
+}
+
+// Throttler applies a Policy to the records of a store
+type Throttler struct {
+	Store  AttemptStoreInterface
+	Policy Policy
+	Now    func() time.Time
+}
+
+// NewThrottler ...
+func NewThrottler(store AttemptStoreInterface, policy Policy) *Throttler {
+	return &Throttler{Store: store, Policy: policy, Now: time.Now}
+}
+
+// Check ...
+func (t *Throttler) Check(ctx context.Context, key string) (time.Duration, error) {
+	record, err := t.Store.Get(ctx, key)
+	if err != nil || record == nil {
+		return 0, err
+	}
+
+	remaining := record.BlockedUntil.Sub(t.Now())
+	if remaining < 0 {
+		return 0, nil
+	}
+	return remaining, nil
+}
+
+// Fail ...
+func (t *Throttler) Fail(ctx context.Context, key string) (time.Duration, error) {
+	now := t.Now()
+	failures, err := t.Store.RecordFailure(ctx, key, now, t.Policy.Window)
+	if err != nil {
+		return 0, err
+	}
+
+	delay := t.Policy.Delay(failures)
+	if delay <= 0 {
+		return 0, nil
+	}
+	if err := t.Store.Block(ctx, key, now.Add(delay)); err != nil {
+		return 0, err
+	}
+	return delay, nil
+}
+
+// Reset ...
+func (t *Throttler) Reset(ctx context.Context, key string) error {
+	return t.Store.Reset(ctx, key)
+}
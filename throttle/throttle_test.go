@@ -0,0 +1,109 @@
+package throttle_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/throttle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	policy := throttle.Policy{
+		FreeAttempts:     2,
+		BaseDelay:        time.Second,
+		MaxDelay:         5 * time.Second,
+		LockoutThreshold: 6,
+		LockoutDuration:  time.Hour,
+	}
+
+	expected := map[int]time.Duration{
+		1: 0,
+		2: 0,
+		3: time.Second,
+		4: 2 * time.Second,
+		5: 4 * time.Second,
+		6: time.Hour,
+		9: time.Hour,
+	}
+	for failures, delay := range expected {
+		assert.Equal(t, delay, policy.Delay(failures), "failures=%d", failures)
+	}
+
+	policy.LockoutThreshold = 0
+	assert.Equal(t, 5*time.Second, policy.Delay(20))
+}
+
+func TestThrottler(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	throttler := throttle.NewThrottler(throttle.NewMemoryAttemptStore(), throttle.Policy{
+		FreeAttempts:     1,
+		BaseDelay:        time.Second,
+		MaxDelay:         time.Minute,
+		LockoutThreshold: 4,
+		LockoutDuration:  time.Hour,
+		Window:           24 * time.Hour,
+	})
+	throttler.Now = func() time.Time { return now }
+
+	blocked, err := throttler.Fail(ctx, "phone:1")
+	require.NoError(t, err)
+	assert.Zero(t, blocked)
+
+	blocked, err = throttler.Fail(ctx, "phone:1")
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, blocked)
+
+	remaining, err := throttler.Check(ctx, "phone:1")
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, remaining)
+
+	// other keys are independent
+	remaining, err = throttler.Check(ctx, "phone:2")
+	require.NoError(t, err)
+	assert.Zero(t, remaining)
+
+	now = now.Add(2 * time.Second)
+	remaining, err = throttler.Check(ctx, "phone:1")
+	require.NoError(t, err)
+	assert.Zero(t, remaining)
+
+	_, err = throttler.Fail(ctx, "phone:1")
+	require.NoError(t, err)
+	blocked, err = throttler.Fail(ctx, "phone:1")
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, blocked)
+
+	require.NoError(t, throttler.Reset(ctx, "phone:1"))
+	remaining, err = throttler.Check(ctx, "phone:1")
+	require.NoError(t, err)
+	assert.Zero(t, remaining)
+}
+
+func TestMemoryAttemptStoreWindow(t *testing.T) {
+	ctx := context.Background()
+	store := throttle.NewMemoryAttemptStore()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	failures, err := store.RecordFailure(ctx, "ip:1", now, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, failures)
+
+	failures, err = store.RecordFailure(ctx, "ip:1", now.Add(30*time.Minute), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 2, failures)
+
+	// failures older than the window are forgotten
+	failures, err = store.RecordFailure(ctx, "ip:1", now.Add(3*time.Hour), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, failures)
+
+	require.NoError(t, store.Purge(ctx, now.Add(4*time.Hour)))
+	record, err := store.Get(ctx, "ip:1")
+	require.NoError(t, err)
+	assert.Nil(t, record)
+}